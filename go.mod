@@ -10,6 +10,7 @@ require (
 	github.com/openshift/api v3.9.1-0.20190923092516-169848dd8137+incompatible
 	github.com/openshift/client-go v0.0.0-20190923092832-6afefc9bb372
 	github.com/prometheus/client_golang v1.4.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.0-20160722081547-f62e98d28ab7
 	gopkg.in/airbrake/gobrake.v2 v2.0.9 // indirect
@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials authenticate the requests to AWS, temporary credentials carry a session token
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsConfig configures the credentials of the requests, the static access keys if set, else
+// temporary credentials of the role assumed with a web identity token, e.g. the projected service
+// account token of IAM roles for service accounts
+type CredentialsConfig struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// RoleARN is the role assumed with the token of the WebIdentityTokenFile
+	RoleARN              string
+	WebIdentityTokenFile string
+	// STSEndpoint is the endpoint of the security token service, the regional one if empty
+	STSEndpoint string
+}
+
+// credentialsProvider returns the configured credentials, temporary credentials are cached until
+// shortly before they expire
+type credentialsProvider struct {
+	config CredentialsConfig
+	region string
+	client *http.Client
+
+	lock        sync.Mutex
+	credentials Credentials
+	expires     time.Time
+}
+
+// expiryWindow is the time before the expiry of temporary credentials they are renewed at
+const expiryWindow = 5 * time.Minute
+
+func (p *credentialsProvider) retrieve() (Credentials, error) {
+	if p.config.AccessKeyID != "" {
+		return Credentials{AccessKeyID: p.config.AccessKeyID, SecretAccessKey: p.config.SecretAccessKey, SessionToken: p.config.SessionToken}, nil
+	}
+	if p.config.RoleARN == "" || p.config.WebIdentityTokenFile == "" {
+		return Credentials{}, fmt.Errorf("neither access keys nor a role with a web identity token file are configured")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if time.Now().Add(expiryWindow).Before(p.expires) {
+		return p.credentials, nil
+	}
+	credentials, expires, err := p.assumeRoleWithWebIdentity()
+	if err != nil {
+		return Credentials{}, err
+	}
+	p.credentials, p.expires = credentials, expires
+	return credentials, nil
+}
+
+// assumeRoleWithWebIdentity assumes the role with the web identity token, the request is not signed
+func (p *credentialsProvider) assumeRoleWithWebIdentity() (Credentials, time.Time, error) {
+	token, err := ioutil.ReadFile(p.config.WebIdentityTokenFile)
+	if err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("unable to read the web identity token: %v", err)
+	}
+	endpoint := p.config.STSEndpoint
+	if endpoint == "" {
+		endpoint = "https://sts." + p.region + ".amazonaws.com"
+	}
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {p.config.RoleARN},
+		"RoleSessionName":  {"reloader"},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	response, err := p.client.Get(strings.TrimSuffix(endpoint, "/") + "/?" + query.Encode())
+	if err != nil {
+		return Credentials{}, time.Time{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return Credentials{}, time.Time{}, fmt.Errorf("assuming role '%s' failed with status %s", p.config.RoleARN, response.Status)
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(response.Body).Decode(&result); err != nil {
+		return Credentials{}, time.Time{}, fmt.Errorf("invalid response assuming role '%s': %v", p.config.RoleARN, err)
+	}
+	credentials := Credentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+	}
+	return credentials, result.Credentials.Expiration, nil
+}
@@ -0,0 +1,215 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+// SQSConsumer receives the events of AWS Secrets Manager that EventBridge delivers to an SQS queue
+// and finds the changes of secret values in them
+type SQSConsumer struct {
+	QueueURL    string
+	Region      string
+	Credentials CredentialsConfig
+	// WaitTime is the time a receive waits for messages, at most 20 seconds, and the time waited
+	// after a failed receive
+	WaitTime time.Duration
+	Client   *http.Client
+
+	credentials *credentialsProvider
+}
+
+// sqsMessage is a message received from the queue
+type sqsMessage struct {
+	MessageID     string `json:"MessageId"`
+	ReceiptHandle string `json:"ReceiptHandle"`
+	Body          string `json:"Body"`
+}
+
+// Watch receives the events until the stop channel is closed and delivers the changes to the
+// channel, named by the ARN of the secret or its name if the event did not carry the ARN and
+// versioned by the ID of the event. Messages are deleted from the queue once their change is
+// delivered, so changes are delivered at least once
+func (c *SQSConsumer) Watch(changes chan<- external.Change, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	for {
+		messages, err := c.receive(ctx)
+		if err != nil && ctx.Err() == nil {
+			logrus.Errorf("Failed to receive events from %s: %v", c.QueueURL, err)
+			select {
+			case <-time.After(c.WaitTime):
+			case <-stopCh:
+				return
+			}
+		}
+		for _, message := range messages {
+			change, found, err := parseEvent(message.Body)
+			if err != nil {
+				logrus.Warnf("Dropping invalid event %s of %s: %v", message.MessageID, c.QueueURL, err)
+			}
+			if found {
+				select {
+				case changes <- change:
+				case <-stopCh:
+					return
+				}
+			}
+			if err := c.delete(ctx, message); err != nil && ctx.Err() == nil {
+				logrus.Errorf("Failed to delete event %s from %s: %v", message.MessageID, c.QueueURL, err)
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+	}
+}
+
+func (c *SQSConsumer) receive(ctx context.Context) ([]sqsMessage, error) {
+	request := map[string]interface{}{
+		"QueueUrl":            c.QueueURL,
+		"MaxNumberOfMessages": 10,
+		"WaitTimeSeconds":     int(c.WaitTime / time.Second),
+	}
+	var response struct {
+		Messages []sqsMessage `json:"Messages"`
+	}
+	err := c.call(ctx, "ReceiveMessage", request, &response)
+	return response.Messages, err
+}
+
+func (c *SQSConsumer) delete(ctx context.Context, message sqsMessage) error {
+	request := map[string]interface{}{
+		"QueueUrl":      c.QueueURL,
+		"ReceiptHandle": message.ReceiptHandle,
+	}
+	return c.call(ctx, "DeleteMessage", request, &struct{}{})
+}
+
+// call calls the action of the JSON protocol of SQS at the endpoint of the queue
+func (c *SQSConsumer) call(ctx context.Context, action string, input interface{}, output interface{}) error {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if c.credentials == nil {
+		c.credentials = &credentialsProvider{config: c.Credentials, region: c.Region, client: client}
+	}
+	credentials, err := c.credentials.retrieve()
+	if err != nil {
+		return err
+	}
+	queue, err := url.Parse(c.QueueURL)
+	if err != nil {
+		return fmt.Errorf("invalid queue URL: %v", err)
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, queue.Scheme+"://"+queue.Host+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	request.Header.Set("X-Amz-Target", "AmazonSQS."+action)
+	sign(request, body, credentials, c.Region, "sqs", time.Now())
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(response.Body).Decode(&failure)
+		return fmt.Errorf("%s failed with status %s: %s %s", action, response.Status, failure.Type, failure.Message)
+	}
+	if err := json.NewDecoder(response.Body).Decode(output); err != nil {
+		return fmt.Errorf("invalid response of %s: %v", action, err)
+	}
+	return nil
+}
+
+// changingEvents are the names of the events of AWS Secrets Manager changing the current value of a
+// secret. The stages moved by a rotation are covered by the event of its success
+var changingEvents = map[string]bool{
+	"PutSecretValue":    true,
+	"UpdateSecret":      true,
+	"RotationSucceeded": true,
+}
+
+// parseEvent finds the change of a secret value in an EventBridge event of AWS Secrets Manager
+// recorded by CloudTrail. Events of other sources or not changing the current value are no changes
+func parseEvent(body string) (external.Change, bool, error) {
+	var event struct {
+		ID     string `json:"id"`
+		Source string `json:"source"`
+		Detail struct {
+			EventName         string `json:"eventName"`
+			RequestParameters struct {
+				SecretID      string   `json:"secretId"`
+				VersionStages []string `json:"versionStages"`
+			} `json:"requestParameters"`
+			ResponseElements struct {
+				ARN       string `json:"arn"`
+				VersionID string `json:"versionId"`
+			} `json:"responseElements"`
+			AdditionalEventData struct {
+				SecretID string `json:"SecretId"`
+			} `json:"additionalEventData"`
+		} `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return external.Change{}, false, err
+	}
+	detail := event.Detail
+	if event.Source != "aws.secretsmanager" || !changingEvents[detail.EventName] {
+		return external.Change{}, false, nil
+	}
+	// values put with stages other than the current one are pending, e.g. during a rotation
+	if detail.EventName == "PutSecretValue" && len(detail.RequestParameters.VersionStages) > 0 && !contains(detail.RequestParameters.VersionStages, "AWSCURRENT") {
+		return external.Change{}, false, nil
+	}
+	// updates of the description or the key of a secret create no version
+	if detail.EventName == "UpdateSecret" && detail.ResponseElements.VersionID == "" {
+		return external.Change{}, false, nil
+	}
+
+	for _, id := range []string{detail.ResponseElements.ARN, detail.AdditionalEventData.SecretID, detail.RequestParameters.SecretID} {
+		if id != "" {
+			return external.Change{Type: constants.AWSSecretEnvVarPostfix, Name: id, Version: event.ID}, true, nil
+		}
+	}
+	return external.Change{}, false, fmt.Errorf("event %s of %s names no secret", event.ID, detail.EventName)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
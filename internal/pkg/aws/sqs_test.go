@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+const rotationEvent = `{"id":"event-1","source":"aws.secretsmanager","detail-type":"AWS Service Event via CloudTrail","detail":{"eventName":"RotationSucceeded","additionalEventData":{"SecretId":"arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/db-AbCdEf"}}}`
+
+func TestSQSConsumerDeliversChangesOfSecrets(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatalf("Failed to create token file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("web-identity-token")
+	tokenFile.Close()
+
+	var lock sync.Mutex
+	receives := 0
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.URL.Query().Get("Action") == "AssumeRoleWithWebIdentity" {
+			if r.URL.Query().Get("WebIdentityToken") != "web-identity-token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials><AccessKeyId>ASIAEXAMPLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ASIAEXAMPLE/") || r.Header.Get("X-Amz-Security-Token") != "session" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		switch r.Header.Get("X-Amz-Target") {
+		case "AmazonSQS.ReceiveMessage":
+			receives++
+			switch receives {
+			case 1:
+				messages := []map[string]string{
+					{"MessageId": "1", "ReceiptHandle": "handle-1", "Body": rotationEvent},
+					{"MessageId": "2", "ReceiptHandle": "handle-2", "Body": `{"id":"event-2","source":"aws.secretsmanager","detail":{"eventName":"GetSecretValue","requestParameters":{"secretId":"prod/db"}}}`},
+					{"MessageId": "3", "ReceiptHandle": "handle-3", "Body": "not an event"},
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"Messages": messages})
+			default:
+				w.Write([]byte(`{}`))
+			}
+		case "AmazonSQS.DeleteMessage":
+			deleted = append(deleted, request["ReceiptHandle"].(string))
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	consumer := &SQSConsumer{
+		QueueURL:    server.URL + "/123456789012/secret-events",
+		Region:      "eu-west-1",
+		Credentials: CredentialsConfig{RoleARN: "arn:aws:iam::123456789012:role/reloader", WebIdentityTokenFile: tokenFile.Name(), STSEndpoint: server.URL},
+		WaitTime:    time.Millisecond,
+	}
+	changes := make(chan external.Change)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		consumer.Watch(changes, stopCh)
+		close(done)
+	}()
+
+	select {
+	case got := <-changes:
+		want := external.Change{Type: constants.AWSSecretEnvVarPostfix, Name: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/db-AbCdEf", Version: "event-1"}
+		if got != want {
+			t.Errorf("Expected change %v, got %v", want, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change was not delivered")
+	}
+	// the messages are deleted and the next receive happens after the last one
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lock.Lock()
+		polled := receives
+		lock.Unlock()
+		if polled > 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Consumer did not stop")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if strings.Join(deleted, ",") != "handle-1,handle-2,handle-3" {
+		t.Errorf("Expected all received messages to be deleted, got %v", deleted)
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		change external.Change
+		found  bool
+	}{
+		{
+			name:   "rotation",
+			body:   rotationEvent,
+			change: external.Change{Type: constants.AWSSecretEnvVarPostfix, Name: "arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/db-AbCdEf", Version: "event-1"},
+			found:  true,
+		},
+		{
+			name:   "put value",
+			body:   `{"id":"e","source":"aws.secretsmanager","detail":{"eventName":"PutSecretValue","requestParameters":{"secretId":"prod/db"},"responseElements":{"arn":"arn:db"}}}`,
+			change: external.Change{Type: constants.AWSSecretEnvVarPostfix, Name: "arn:db", Version: "e"},
+			found:  true,
+		},
+		{
+			name:  "put pending value",
+			body:  `{"id":"e","source":"aws.secretsmanager","detail":{"eventName":"PutSecretValue","requestParameters":{"secretId":"prod/db","versionStages":["AWSPENDING"]}}}`,
+			found: false,
+		},
+		{
+			name:  "update of description",
+			body:  `{"id":"e","source":"aws.secretsmanager","detail":{"eventName":"UpdateSecret","responseElements":{"arn":"arn:db"}}}`,
+			found: false,
+		},
+		{
+			name:  "other source",
+			body:  `{"id":"e","source":"aws.ssm","detail":{"eventName":"PutSecretValue","responseElements":{"arn":"arn:db"}}}`,
+			found: false,
+		},
+	}
+	for _, tt := range tests {
+		change, found, err := parseEvent(tt.body)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if found != tt.found || change != tt.change {
+			t.Errorf("%s: expected change %v found %t, got %v found %t", tt.name, tt.change, tt.found, change, found)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package azure
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+const (
+	// subscriptionValidationEvent is the event Event Grid validates the endpoint of a subscription with
+	subscriptionValidationEvent = "Microsoft.EventGrid.SubscriptionValidationEvent"
+	// secretNewVersionCreatedEvent is the event of Key Vault for new versions of secrets
+	secretNewVersionCreatedEvent = "Microsoft.KeyVault.SecretNewVersionCreated"
+)
+
+// EventGridWebhook serves the endpoint of an Event Grid subscription of the events of Azure Key Vault
+// and finds the new versions of secrets in them. Events of the Event Grid and of the CloudEvents
+// schema are accepted
+type EventGridWebhook struct {
+	// Addr is the address the endpoint is served at, e.g. ":9091"
+	Addr string
+	// Path is the path of the endpoint
+	Path string
+	// Key is the value of the "key" query parameter requests must carry, any request is accepted if
+	// empty
+	Key string
+}
+
+// event is an event of the Event Grid or CloudEvents schema, the type of which is in eventType or type
+type event struct {
+	ID        string          `json:"id"`
+	EventType string          `json:"eventType"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// secretEventData is the data of the events of Key Vault
+type secretEventData struct {
+	// ID is the URI of the version of the secret, e.g. "https://my-vault.vault.azure.net/secrets/db/<version>"
+	ID         string `json:"Id"`
+	ObjectType string `json:"ObjectType"`
+	Version    string `json:"Version"`
+}
+
+// Watch serves the endpoint until the stop channel is closed and delivers the changes to the
+// channel, named by the URI of the secret without its version and versioned by its version. Events
+// are only acknowledged once their changes are delivered, so Event Grid retries the others
+func (w *EventGridWebhook) Watch(changes chan<- external.Change, stopCh <-chan struct{}) {
+	mux := http.NewServeMux()
+	mux.Handle(w.Path, w.handler(changes, stopCh))
+	server := &http.Server{Addr: w.Addr, Handler: mux}
+	go func() {
+		<-stopCh
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logrus.Errorf("Failed to serve the Event Grid endpoint at %s: %v", w.Addr, err)
+	}
+}
+
+func (w *EventGridWebhook) handler(changes chan<- external.Change, stopCh <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if w.Key != "" && subtle.ConstantTimeCompare([]byte(request.URL.Query().Get("key")), []byte(w.Key)) != 1 {
+			response.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the abuse protection of CloudEvents webhooks
+		if request.Method == http.MethodOptions {
+			response.Header().Set("WebHook-Allowed-Origin", request.Header.Get("WebHook-Request-Origin"))
+			response.Header().Set("WebHook-Allowed-Rate", "*")
+			return
+		}
+		if request.Method != http.MethodPost {
+			response.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		events, err := decodeEvents(request)
+		if err != nil {
+			logrus.Warnf("Rejecting invalid Event Grid events: %v", err)
+			response.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, e := range events {
+			if e.EventType == subscriptionValidationEvent {
+				var data struct {
+					ValidationCode string `json:"validationCode"`
+				}
+				json.Unmarshal(e.Data, &data)
+				json.NewEncoder(response).Encode(map[string]string{"validationResponse": data.ValidationCode})
+				return
+			}
+			change, found := parseEvent(e)
+			if !found {
+				continue
+			}
+			select {
+			case changes <- change:
+			case <-stopCh:
+				response.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+	})
+}
+
+// decodeEvents decodes the batch of Event Grid events, or the single CloudEvent, of the request
+func decodeEvents(request *http.Request) ([]event, error) {
+	var body json.RawMessage
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	var events []event
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		err := json.Unmarshal(body, &events)
+		return events, err
+	}
+	var single event
+	err := json.Unmarshal(body, &single)
+	return []event{single}, err
+}
+
+// parseEvent finds the new version of a secret in an event of Key Vault, other events are no changes
+func parseEvent(e event) (external.Change, bool) {
+	eventType := e.EventType
+	if eventType == "" {
+		eventType = e.Type
+	}
+	if eventType != secretNewVersionCreatedEvent {
+		return external.Change{}, false
+	}
+	var data secretEventData
+	if err := json.Unmarshal(e.Data, &data); err != nil || data.ID == "" {
+		logrus.Warnf("Ignoring event %s of Key Vault without the URI of the secret", e.ID)
+		return external.Change{}, false
+	}
+	uri := strings.TrimSuffix(data.ID, "/")
+	if data.Version != "" {
+		uri = strings.TrimSuffix(uri, "/"+data.Version)
+	}
+	return external.Change{Type: constants.AzureSecretEnvVarPostfix, Name: uri, Version: data.Version}, true
+}
@@ -0,0 +1,100 @@
+package azure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+const newVersionEvent = `{"id":"event-1","eventType":"Microsoft.KeyVault.SecretNewVersionCreated","subject":"db","data":{"Id":"https://my-vault.vault.azure.net/secrets/db/0a1b2c","VaultName":"my-vault","ObjectType":"Secret","ObjectName":"db","Version":"0a1b2c"}}`
+
+func TestEventGridWebhookDeliversNewVersionsOfSecrets(t *testing.T) {
+	changes := make(chan external.Change, 10)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	webhook := &EventGridWebhook{Path: "/", Key: "secret-key"}
+	server := httptest.NewServer(webhook.handler(changes, stopCh))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/?key=secret-key", "application/json", strings.NewReader(`[{"id":"validation","eventType":"Microsoft.EventGrid.SubscriptionValidationEvent","data":{"validationCode":"512d38b6"}}]`))
+	if err != nil {
+		t.Fatalf("Failed to post validation event: %v", err)
+	}
+	var validation map[string]string
+	json.NewDecoder(response.Body).Decode(&validation)
+	response.Body.Close()
+	if validation["validationResponse"] != "512d38b6" {
+		t.Errorf("Expected the validation code to be returned, got %v", validation)
+	}
+
+	response, err = http.Post(server.URL+"/?key=wrong-key", "application/json", strings.NewReader("["+newVersionEvent+"]"))
+	if err != nil {
+		t.Fatalf("Failed to post event: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected events with a wrong key to be rejected, got status %s", response.Status)
+	}
+
+	deletedEvent := `{"id":"event-2","eventType":"Microsoft.KeyVault.SecretExpired","data":{"Id":"https://my-vault.vault.azure.net/secrets/api/3d4e5f","Version":"3d4e5f"}}`
+	response, err = http.Post(server.URL+"/?key=secret-key", "application/json", strings.NewReader("["+deletedEvent+","+newVersionEvent+"]"))
+	if err != nil {
+		t.Fatalf("Failed to post events: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected the events to be acknowledged, got status %s", response.Status)
+	}
+	select {
+	case got := <-changes:
+		want := external.Change{Type: constants.AzureSecretEnvVarPostfix, Name: "https://my-vault.vault.azure.net/secrets/db", Version: "0a1b2c"}
+		if got != want {
+			t.Errorf("Expected change %v, got %v", want, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change was not delivered")
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected only the new version to be a change, got %d more", len(changes))
+	}
+}
+
+func TestEventGridWebhookAcceptsCloudEvents(t *testing.T) {
+	changes := make(chan external.Change, 10)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	server := httptest.NewServer((&EventGridWebhook{Path: "/"}).handler(changes, stopCh))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodOptions, server.URL, nil)
+	request.Header.Set("WebHook-Request-Origin", "eventgrid.azure.net")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Failed to send the abuse protection request: %v", err)
+	}
+	response.Body.Close()
+	if origin := response.Header.Get("WebHook-Allowed-Origin"); origin != "eventgrid.azure.net" {
+		t.Errorf("Expected the origin to be allowed, got %q", origin)
+	}
+
+	cloudEvent := `{"id":"event-1","specversion":"1.0","type":"Microsoft.KeyVault.SecretNewVersionCreated","source":"/subscriptions/s/resourceGroups/g/providers/Microsoft.KeyVault/vaults/my-vault","data":{"Id":"https://my-vault.vault.azure.net/secrets/db/0a1b2c","ObjectType":"Secret","Version":"0a1b2c"}}`
+	response, err = http.Post(server.URL, "application/cloudevents+json", strings.NewReader(cloudEvent))
+	if err != nil {
+		t.Fatalf("Failed to post event: %v", err)
+	}
+	response.Body.Close()
+	select {
+	case got := <-changes:
+		if got.Name != "https://my-vault.vault.azure.net/secrets/db" {
+			t.Errorf("Expected the change of the secret db, got %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change was not delivered")
+	}
+}
@@ -0,0 +1,73 @@
+package callbacks
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/stakater/Reloader/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/util/retry"
+)
+
+// GetFunc returns the resource of the name as stored
+type GetFunc func(kube.Clients, string, string) (interface{}, error)
+
+// ConflictRetryingUpgradeFuncs returns the upgrade funcs retrying updates of the workload that conflict
+// with a concurrent change. On a conflict the workload is read again and the changes of Reloader since
+// the original are applied to it before it is updated again, onConflict is called for every retry. The
+// upgrade funcs are returned unchanged if the kind cannot be read
+func ConflictRetryingUpgradeFuncs(upgradeFuncs RollingUpgradeFuncs, original runtime.Object, onConflict func()) RollingUpgradeFuncs {
+	if upgradeFuncs.GetFunc == nil || original == nil {
+		return upgradeFuncs
+	}
+	getFunc := upgradeFuncs.GetFunc
+	updateFunc := upgradeFuncs.UpdateFunc
+	upgradeFuncs.UpdateFunc = func(clients kube.Clients, namespace string, resource interface{}) error {
+		attempt := 0
+		return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			attempt++
+			if attempt == 1 {
+				return updateFunc(clients, namespace, resource)
+			}
+			onConflict()
+			current, err := reapplyChanges(clients, getFunc, namespace, original, resource)
+			if err != nil {
+				return err
+			}
+			return updateFunc(clients, namespace, current)
+		})
+	}
+	return upgradeFuncs
+}
+
+// reapplyChanges reads the workload again and applies the changes of the modified workload since the
+// original to it
+func reapplyChanges(clients kube.Clients, getFunc GetFunc, namespace string, original runtime.Object, modified interface{}) (interface{}, error) {
+	accessor, err := meta.Accessor(modified)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := CreatePatch(original, modified)
+	if err != nil {
+		return nil, err
+	}
+	current, err := getFunc(clients, namespace, accessor.GetName())
+	if err != nil {
+		return nil, err
+	}
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	patchedJSON, err := strategicpatch.StrategicMergePatch(currentJSON, patch, original)
+	if err != nil {
+		return nil, err
+	}
+	patched := reflect.New(reflect.TypeOf(current).Elem()).Interface()
+	if err := json.Unmarshal(patchedJSON, patched); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
@@ -0,0 +1,283 @@
+package callbacks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CustomWorkloadKind is a kind of custom resources embedding a pod template, e.g. of an operator, that
+// are reloaded with the dynamic client
+type CustomWorkloadKind struct {
+	Resource schema.GroupVersionResource
+	// Kind is the kind of the resources, their resource type in logs, events and metrics
+	Kind string
+	// TemplatePath is the path of the fields of the pod template in the resources
+	TemplatePath []string
+	// ReferencesPath is the path of the fields of the configmaps and secrets the resources reference
+	// outside their pod template, if any
+	ReferencesPath []string
+}
+
+// SourceReference is a configmap or secret a custom workload references outside its pod template
+type SourceReference struct {
+	// Type is the type of the source, constants.ConfigmapEnvVarPostfix or constants.SecretEnvVarPostfix,
+	// or empty if the reference does not name its kind
+	Type string
+	Name string
+}
+
+// KnativeServiceKind is the kind of the services of Knative Serving, whose revision template is a pod
+// template with the fields of revisions, e.g. containerConcurrency, in its spec
+var KnativeServiceKind = CustomWorkloadKind{
+	Resource:     kube.KnativeServiceResource,
+	Kind:         "Service",
+	TemplatePath: []string{"spec", "template"},
+}
+
+// ScaledJobKind is the kind of the scaled jobs of KEDA, whose job target is the spec of the jobs they
+// spawn
+var ScaledJobKind = CustomWorkloadKind{
+	Resource:     kube.ScaledJobResource,
+	Kind:         "ScaledJob",
+	TemplatePath: []string{"spec", "jobTargetRef", "template"},
+}
+
+// ArgoRolloutKind is the kind of the rollouts of Argo Rollouts. Only their main pod template is
+// reloaded, the pod templates of the experiments of their steps and of their analysis templates are
+// left untouched
+var ArgoRolloutKind = CustomWorkloadKind{
+	Resource:     kube.ArgoRolloutResource,
+	Kind:         "Rollout",
+	TemplatePath: []string{"spec", "template"},
+}
+
+// CustomWorkload is a custom resource with its pod template decoded, which is written back to the
+// resource on update
+type CustomWorkload struct {
+	meta_v1.ObjectMeta
+	Template     v1.PodTemplateSpec
+	Object       *unstructured.Unstructured
+	TemplatePath []string
+	References   []SourceReference
+}
+
+// ParseCustomWorkloadKind parses "<resource>.<version>.<group>=<path>[;<references path>]" into a kind
+// of custom workloads, e.g. "flinkdeployments.v1beta1.flink.apache.org=.spec.podTemplate". The paths
+// are JSONPaths of fields only, e.g. "{.spec.template}". The optional references path is the path of
+// a list of the configmaps and secrets the resources reference outside their pod template, by name
+// or as objects with a name and a kind, e.g. "{"kind": "Secret", "name": "db"}"
+func ParseCustomWorkloadKind(value string) (CustomWorkloadKind, error) {
+	parts := strings.SplitN(value, "=", 2)
+	resource := strings.SplitN(parts[0], ".", 3)
+	if len(parts) != 2 || len(resource) != 3 || resource[0] == "" || resource[1] == "" || resource[2] == "" {
+		return CustomWorkloadKind{}, fmt.Errorf("invalid custom workload '%s', expected '<resource>.<version>.<group>=<path>[;<references path>]'", value)
+	}
+	paths := strings.SplitN(parts[1], ";", 2)
+	fields, err := ParseTemplatePath(paths[0])
+	if err != nil {
+		return CustomWorkloadKind{}, fmt.Errorf("%v of custom workload '%s'", err, value)
+	}
+	var references []string
+	if len(paths) == 2 {
+		if references, err = ParseTemplatePath(paths[1]); err != nil {
+			return CustomWorkloadKind{}, fmt.Errorf("%v of custom workload '%s'", err, value)
+		}
+	}
+	return CustomWorkloadKind{
+		Resource:       schema.GroupVersionResource{Group: resource[2], Version: resource[1], Resource: resource[0]},
+		Kind:           resource[0],
+		TemplatePath:   fields,
+		ReferencesPath: references,
+	}, nil
+}
+
+// ParseTemplatePath parses a JSONPath into its fields, e.g. "{.spec.template}" of a pod template. Only
+// fields are supported
+func ParseTemplatePath(value string) ([]string, error) {
+	path := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "{"), "}")
+	if strings.ContainsAny(path, "[]*@?()") {
+		return nil, fmt.Errorf("invalid path '%s', only fields are supported", value)
+	}
+	var fields []string
+	for _, field := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if field == "" {
+			return nil, fmt.Errorf("invalid path '%s'", value)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// GetItems returns the custom workloads of the kind in given namespace, skipping resources without a
+// pod template at the path
+func (k CustomWorkloadKind) GetItems(clients kube.Clients, namespace string) []interface{} {
+	if clients.DynamicClient == nil {
+		return nil
+	}
+	list, err := clients.DynamicClient.Resource(k.Resource).Namespace(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to list %s %v", k.Resource.Resource, err)
+		return nil
+	}
+	var items []interface{}
+	for i := range list.Items {
+		workload, err := k.decode(&list.Items[i])
+		if err != nil {
+			logrus.Warnf("Ignoring '%s' of type '%s' in namespace '%s': %v", list.Items[i].GetName(), k.Kind, list.Items[i].GetNamespace(), err)
+			continue
+		}
+		items = append(items, workload)
+	}
+	return items
+}
+
+func (k CustomWorkloadKind) decode(object *unstructured.Unstructured) (*CustomWorkload, error) {
+	workload := &CustomWorkload{Object: object, TemplatePath: k.TemplatePath}
+	if metadata, found := object.Object["metadata"].(map[string]interface{}); found {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(metadata, &workload.ObjectMeta); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %v", err)
+		}
+	}
+	template, found, err := unstructured.NestedMap(object.Object, k.TemplatePath...)
+	if err != nil || !found {
+		return nil, fmt.Errorf("no pod template at .%s", strings.Join(k.TemplatePath, "."))
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(template, &workload.Template); err != nil {
+		return nil, fmt.Errorf("invalid pod template at .%s: %v", strings.Join(k.TemplatePath, "."), err)
+	}
+	if len(k.ReferencesPath) > 0 {
+		if references, found, _ := unstructured.NestedFieldNoCopy(object.Object, k.ReferencesPath...); found {
+			workload.References = decodeReferences(references)
+		}
+	}
+	return workload, nil
+}
+
+// decodeReferences decodes the references of a custom workload, a name or a list of names or of
+// objects with a name and a kind. References of other kinds than configmaps and secrets are skipped
+func decodeReferences(value interface{}) []SourceReference {
+	var references []SourceReference
+	values, isList := value.([]interface{})
+	if !isList {
+		values = []interface{}{value}
+	}
+	for _, value := range values {
+		switch reference := value.(type) {
+		case string:
+			references = append(references, SourceReference{Name: reference})
+		case map[string]interface{}:
+			name, _ := reference["name"].(string)
+			kind, _ := reference["kind"].(string)
+			switch strings.ToLower(kind) {
+			case "":
+				references = append(references, SourceReference{Name: name})
+			case "configmap":
+				references = append(references, SourceReference{Type: constants.ConfigmapEnvVarPostfix, Name: name})
+			case "secret":
+				references = append(references, SourceReference{Type: constants.SecretEnvVarPostfix, Name: name})
+			}
+		}
+	}
+	return references
+}
+
+// GetCustomWorkloadAnnotations returns the annotations of given custom workload
+func GetCustomWorkloadAnnotations(item interface{}) map[string]string {
+	return item.(*CustomWorkload).ObjectMeta.Annotations
+}
+
+// GetCustomWorkloadPodAnnotations returns the pod's annotations of given custom workload
+func GetCustomWorkloadPodAnnotations(item interface{}) map[string]string {
+	return item.(*CustomWorkload).Template.ObjectMeta.Annotations
+}
+
+// GetCustomWorkloadPodTemplate returns the pod template of given custom workload
+func GetCustomWorkloadPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return &item.(*CustomWorkload).Template
+}
+
+// GetCustomWorkloadReferences returns the configmaps and secrets given custom workload references
+// outside its pod template
+func GetCustomWorkloadReferences(item interface{}) []SourceReference {
+	return item.(*CustomWorkload).References
+}
+
+// GetCustomWorkloadContainers returns the containers of given custom workload
+func GetCustomWorkloadContainers(item interface{}) []v1.Container {
+	return item.(*CustomWorkload).Template.Spec.Containers
+}
+
+// GetCustomWorkloadInitContainers returns the init containers of given custom workload
+func GetCustomWorkloadInitContainers(item interface{}) []v1.Container {
+	return item.(*CustomWorkload).Template.Spec.InitContainers
+}
+
+// GetCustomWorkloadEphemeralContainers returns the ephemeral containers of given custom workload
+func GetCustomWorkloadEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*CustomWorkload).Template.Spec)
+}
+
+// GetCustomWorkloadVolumes returns the Volumes of given custom workload
+func GetCustomWorkloadVolumes(item interface{}) []v1.Volume {
+	return item.(*CustomWorkload).Template.Spec.Volumes
+}
+
+// Update writes the pod template back to the custom workload and updates it. Fields of the resource
+// at the path unknown to pod templates are kept, lists are replaced
+func (k CustomWorkloadKind) Update(clients kube.Clients, namespace string, resource interface{}) error {
+	workload := resource.(*CustomWorkload)
+	template, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&workload.Template)
+	if err != nil {
+		return err
+	}
+	object := workload.Object.DeepCopy()
+	current, _, err := unstructured.NestedMap(object.Object, workload.TemplatePath...)
+	if err != nil {
+		return err
+	}
+	merged := mergeFields(current, template)
+	// removed annotations and labels are not merged
+	metadata, _ := merged["metadata"].(map[string]interface{})
+	for _, field := range []string{"annotations", "labels"} {
+		if updated, found, _ := unstructured.NestedFieldNoCopy(template, "metadata", field); found && updated != nil {
+			metadata[field] = updated
+		} else if metadata != nil {
+			delete(metadata, field)
+		}
+	}
+	if err := unstructured.SetNestedMap(object.Object, merged, workload.TemplatePath...); err != nil {
+		return err
+	}
+	_, err = clients.DynamicClient.Resource(k.Resource).Namespace(namespace).Update(object, meta_v1.UpdateOptions{})
+	return err
+}
+
+// mergeFields merges the fields of update into current, recursing into maps and skipping the nil
+// fields of empty values, e.g. the creation timestamp of pod templates
+func mergeFields(current map[string]interface{}, update map[string]interface{}) map[string]interface{} {
+	if current == nil {
+		current = map[string]interface{}{}
+	}
+	for field, value := range update {
+		if value == nil {
+			continue
+		}
+		updateMap, isMap := value.(map[string]interface{})
+		currentMap, wasMap := current[field].(map[string]interface{})
+		if isMap && wasMap {
+			current[field] = mergeFields(currentMap, updateMap)
+			continue
+		}
+		current[field] = value
+	}
+	return current
+}
@@ -0,0 +1,99 @@
+package callbacks
+
+import (
+	"encoding/json"
+
+	"github.com/stakater/Reloader/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// PatchFunc applies a strategic merge patch to the resource of the name
+type PatchFunc func(kube.Clients, string, string, []byte) error
+
+// PatchingUpgradeFuncs returns the upgrade funcs updating the workload by a strategic merge patch of
+// its changes since the original instead of replacing it, so only the fields changed by Reloader are
+// written and concurrent changes of other fields by other controllers are kept. The upgrade funcs are
+// returned unchanged if the kind cannot be patched
+func PatchingUpgradeFuncs(upgradeFuncs RollingUpgradeFuncs, original runtime.Object) RollingUpgradeFuncs {
+	if upgradeFuncs.PatchFunc == nil || original == nil {
+		return upgradeFuncs
+	}
+	patchFunc := upgradeFuncs.PatchFunc
+	upgradeFuncs.UpdateFunc = func(clients kube.Clients, namespace string, resource interface{}) error {
+		patch, err := CreatePatch(original, resource)
+		if err != nil {
+			return err
+		}
+		accessor, err := meta.Accessor(resource)
+		if err != nil {
+			return err
+		}
+		return patchFunc(clients, namespace, accessor.GetName(), patch)
+	}
+	return upgradeFuncs
+}
+
+// CreatePatch returns the strategic merge patch changing the original workload into the modified one
+func CreatePatch(original runtime.Object, modified interface{}) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	modifiedJSON, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+	return strategicpatch.CreateTwoWayMergePatch(originalJSON, modifiedJSON, original)
+}
+
+// PatchDeployment patches the deployment
+func PatchDeployment(clients kube.Clients, namespace string, name string, patch []byte) error {
+	_, err := clients.KubernetesClient.AppsV1().Deployments(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// PatchDaemonSet patches the daemonSet
+func PatchDaemonSet(clients kube.Clients, namespace string, name string, patch []byte) error {
+	_, err := clients.KubernetesClient.AppsV1().DaemonSets(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// PatchStatefulSet patches the statefulSet
+func PatchStatefulSet(clients kube.Clients, namespace string, name string, patch []byte) error {
+	_, err := clients.KubernetesClient.AppsV1().StatefulSets(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// PatchDeploymentConfig patches the deploymentConfig
+func PatchDeploymentConfig(clients kube.Clients, namespace string, name string, patch []byte) error {
+	_, err := clients.OpenshiftAppsClient.AppsV1().DeploymentConfigs(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// PatchCronJob patches the cronJob, the jobs of its next runs pick up the change
+func PatchCronJob(clients kube.Clients, namespace string, name string, patch []byte) error {
+	_, err := clients.KubernetesClient.BatchV1beta1().CronJobs(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// PatchReplicaSet patches the replicaSet and evicts its pods to be recreated from the patched template
+func PatchReplicaSet(clients kube.Clients, namespace string, name string, patch []byte) error {
+	replicaSet, err := clients.KubernetesClient.AppsV1().ReplicaSets(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return err
+	}
+	return EvictReplicaSetPods(clients, namespace, replicaSet)
+}
+
+// PatchReplicationController patches the replicationController and evicts its pods to be recreated
+// from the patched template
+func PatchReplicationController(clients kube.Clients, namespace string, name string, patch []byte) error {
+	replicationController, err := clients.KubernetesClient.CoreV1().ReplicationControllers(namespace).Patch(name, types.StrategicMergePatchType, patch)
+	if err != nil {
+		return err
+	}
+	return EvictReplicationControllerPods(clients, namespace, replicationController)
+}
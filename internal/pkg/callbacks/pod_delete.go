@@ -0,0 +1,136 @@
+package callbacks
+
+import (
+	"fmt"
+
+	"github.com/stakater/Reloader/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/flowcontrol"
+
+	openshiftv1 "github.com/openshift/api/apps/v1"
+)
+
+// PodDeleteStrategy is the name of the built-in strategy evicting the pods of the workload, leaving
+// the workload untouched, so its controller recreates them with the changed mounted config
+const PodDeleteStrategy = "pod-delete"
+
+// podDeleteStrategy evicts the pods controlled by the workload one by one, which keeps to their
+// PodDisruptionBudgets, at most at the rate of its limiter. Standalone pods are never evicted, no
+// controller would recreate them
+type podDeleteStrategy struct {
+	limiter flowcontrol.RateLimiter
+}
+
+// NewPodDeleteStrategy returns the pod-delete strategy evicting at most deletionsPerSecond pods, or
+// any number of pods if not positive
+func NewPodDeleteStrategy(deletionsPerSecond float64) Strategy {
+	return podDeleteStrategy{limiter: evictionLimiter(deletionsPerSecond)}
+}
+
+// evictions limits the evictions of the pods of replicaSets and replicationControllers after their
+// update, unlimited if nil
+var evictions flowcontrol.RateLimiter
+
+// SetEvictionRate limits the evictions of the pods of updated replicaSets and replicationControllers
+// to deletionsPerSecond, no limit if not positive
+func SetEvictionRate(deletionsPerSecond float64) {
+	evictions = evictionLimiter(deletionsPerSecond)
+}
+
+// evictionLimiter returns the limiter of evictions to deletionsPerSecond, nil if not positive
+func evictionLimiter(deletionsPerSecond float64) flowcontrol.RateLimiter {
+	if deletionsPerSecond <= 0 {
+		return nil
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(float32(deletionsPerSecond), 1)
+}
+
+func (s podDeleteStrategy) Apply(clients kube.Clients, upgradeFuncs RollingUpgradeFuncs, namespace string, workload interface{}, hash string) error {
+	template := upgradeFuncs.PodTemplateFunc(workload)
+	if template == nil || len(template.Labels) == 0 {
+		return fmt.Errorf("pod template without labels to find the pods of the %s by", upgradeFuncs.ResourceType)
+	}
+	owners, err := podControllers(clients, namespace, workload)
+	if err != nil {
+		return err
+	}
+	pods, err := clients.KubernetesClient.CoreV1().Pods(namespace).List(meta_v1.ListOptions{LabelSelector: labels.SelectorFromSet(template.Labels).String()})
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !controlledByAny(pod, owners) || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := evictPod(clients, namespace, pod, s.limiter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// podControllers returns the controllers of the pods of the workload: the replicaSets of deployments,
+// the replicationControllers of deploymentConfigs and the workload itself for all other kinds
+func podControllers(clients kube.Clients, namespace string, workload interface{}) ([]meta_v1.Object, error) {
+	switch owner := workload.(type) {
+	case *appsv1.Deployment:
+		replicaSets, err := clients.KubernetesClient.AppsV1().ReplicaSets(namespace).List(meta_v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var controllers []meta_v1.Object
+		for i := range replicaSets.Items {
+			if meta_v1.IsControlledBy(&replicaSets.Items[i], owner) {
+				controllers = append(controllers, &replicaSets.Items[i])
+			}
+		}
+		return controllers, nil
+	case *openshiftv1.DeploymentConfig:
+		replicationControllers, err := clients.KubernetesClient.CoreV1().ReplicationControllers(namespace).List(meta_v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var controllers []meta_v1.Object
+		for i := range replicationControllers.Items {
+			if meta_v1.IsControlledBy(&replicationControllers.Items[i], owner) {
+				controllers = append(controllers, &replicationControllers.Items[i])
+			}
+		}
+		return controllers, nil
+	case meta_v1.Object:
+		return []meta_v1.Object{owner}, nil
+	}
+	return nil, fmt.Errorf("unable to find the controller of the pods of %T", workload)
+}
+
+func controlledByAny(pod *v1.Pod, owners []meta_v1.Object) bool {
+	for _, owner := range owners {
+		if meta_v1.IsControlledBy(pod, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// evictPod evicts the pod, once the limiter allows it if not nil. Evictions blocked by the
+// PodDisruptionBudget of the pod fail with the TooManyRequests error of the API, wrapped
+func evictPod(clients kube.Clients, namespace string, pod *v1.Pod, limiter flowcontrol.RateLimiter) error {
+	if limiter != nil {
+		limiter.Accept()
+	}
+	eviction := &policyv1beta1.Eviction{ObjectMeta: meta_v1.ObjectMeta{Name: pod.Name, Namespace: namespace}}
+	err := clients.KubernetesClient.CoreV1().Pods(namespace).Evict(eviction)
+	if errors.IsTooManyRequests(err) {
+		return fmt.Errorf("eviction of pod '%s' is blocked by its disruption budget: %w", pod.Name, err)
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to evict pod '%s': %w", pod.Name, err)
+	}
+	return nil
+}
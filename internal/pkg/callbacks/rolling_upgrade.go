@@ -1,16 +1,33 @@
 package callbacks
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
 	"github.com/stakater/Reloader/internal/pkg/util"
 	"github.com/stakater/Reloader/pkg/kube"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	openshiftv1 "github.com/openshift/api/apps/v1"
 )
 
+const (
+	// RevisionAnnotation is the annotation the deployment controller records revisions in
+	RevisionAnnotation = "deployment.kubernetes.io/revision"
+	// RevisionHistoryAnnotation lists the previous revisions of a replicaSet that was rolled back to
+	RevisionHistoryAnnotation = "deployment.kubernetes.io/revision-history"
+	// ProgressDeadlineExceededReason is the reason of the Progressing condition of deployments and
+	// deploymentConfigs whose rollout did not progress within their progress deadline
+	ProgressDeadlineExceededReason = "ProgressDeadlineExceeded"
+)
+
 //ItemsFunc is a generic function to return a specific resource array in given namespace
 type ItemsFunc func(kube.Clients, string) []interface{}
 
@@ -20,28 +37,68 @@ type ContainersFunc func(interface{}) []v1.Container
 //InitContainersFunc is a generic func to return containers
 type InitContainersFunc func(interface{}) []v1.Container
 
+//EphemeralContainersFunc is a generic func to return ephemeral containers as containers
+type EphemeralContainersFunc func(interface{}) []v1.Container
+
 //VolumesFunc is a generic func to return volumes
 type VolumesFunc func(interface{}) []v1.Volume
 
 //UpdateFunc performs the resource update
 type UpdateFunc func(kube.Clients, string, interface{}) error
 
+//EvictPodsFunc evicts the pods of the resource not created from its current pod template
+type EvictPodsFunc func(kube.Clients, string, interface{}) error
+
 //AnnotationsFunc is a generic func to return annotations
 type AnnotationsFunc func(interface{}) map[string]string
 
 //PodAnnotationsFunc is a generic func to return annotations
 type PodAnnotationsFunc func(interface{}) map[string]string
 
+//PodTemplateFunc is a generic func to return the pod template
+type PodTemplateFunc func(interface{}) *v1.PodTemplateSpec
+
+//AvailableReplicasFunc is a generic func to return the number of available replicas
+type AvailableReplicasFunc func(interface{}) int32
+
+//RolloutInProgressFunc is a generic func to check whether a rollout of the resource is in progress
+type RolloutInProgressFunc func(interface{}) bool
+
+//RolloutFailedFunc is a generic func to return why the rollout of the resource failed, empty if it did not
+type RolloutFailedFunc func(interface{}) string
+
+//ReferencesFunc is a generic func to return the configmaps and secrets referenced outside the pod template
+type ReferencesFunc func(interface{}) []SourceReference
+
 //RollingUpgradeFuncs contains generic functions to perform rolling upgrade
 type RollingUpgradeFuncs struct {
-	ItemsFunc          ItemsFunc
-	AnnotationsFunc    AnnotationsFunc
-	PodAnnotationsFunc PodAnnotationsFunc
-	ContainersFunc     ContainersFunc
-	InitContainersFunc InitContainersFunc
-	UpdateFunc         UpdateFunc
-	VolumesFunc        VolumesFunc
-	ResourceType       string
+	ItemsFunc               ItemsFunc
+	AnnotationsFunc         AnnotationsFunc
+	PodAnnotationsFunc      PodAnnotationsFunc
+	PodTemplateFunc         PodTemplateFunc
+	ContainersFunc          ContainersFunc
+	InitContainersFunc      InitContainersFunc
+	EphemeralContainersFunc EphemeralContainersFunc
+	UpdateFunc              UpdateFunc
+	VolumesFunc             VolumesFunc
+	AvailableReplicasFunc   AvailableReplicasFunc
+	RolloutInProgressFunc   RolloutInProgressFunc
+	RolloutFailedFunc       RolloutFailedFunc
+	ResourceType            string
+	// ReloadStrategy is the strategy reloading the workloads without a reload-strategy annotation
+	// instead of the default strategy, if not empty
+	ReloadStrategy string
+	// PatchFunc patches the workloads instead of updating them, nil for kinds that cannot be patched
+	PatchFunc PatchFunc
+	// GetFunc reads a workload again when its update conflicts with a concurrent change, nil for kinds
+	// whose updates are not retried
+	GetFunc GetFunc
+	// EvictPodsFunc evicts the pods left on an outdated pod template of kinds that do not roll their
+	// pods out, nil for all other kinds
+	EvictPodsFunc EvictPodsFunc
+	// ReferencesFunc returns the configmaps and secrets the workloads reference outside their pod
+	// template, which auto reload discovers like the references of the pod template, nil if none
+	ReferencesFunc ReferencesFunc
 }
 
 // GetDeploymentItems returns the deployments in given namespace
@@ -50,7 +107,7 @@ func GetDeploymentItems(clients kube.Clients, namespace string) []interface{} {
 	if err != nil {
 		logrus.Errorf("Failed to list deployments %v", err)
 	}
-	return util.InterfaceSlice(deployments.Items)
+	return util.InterfacePointerSlice(deployments.Items)
 }
 
 // GetDaemonSetItems returns the daemonSets in given namespace
@@ -59,7 +116,7 @@ func GetDaemonSetItems(clients kube.Clients, namespace string) []interface{} {
 	if err != nil {
 		logrus.Errorf("Failed to list daemonSets %v", err)
 	}
-	return util.InterfaceSlice(daemonSets.Items)
+	return util.InterfacePointerSlice(daemonSets.Items)
 }
 
 // GetStatefulSetItems returns the statefulSets in given namespace
@@ -68,7 +125,7 @@ func GetStatefulSetItems(clients kube.Clients, namespace string) []interface{} {
 	if err != nil {
 		logrus.Errorf("Failed to list statefulSets %v", err)
 	}
-	return util.InterfaceSlice(statefulSets.Items)
+	return util.InterfacePointerSlice(statefulSets.Items)
 }
 
 // GetDeploymentConfigItems returns the deploymentConfigs in given namespace
@@ -77,133 +134,593 @@ func GetDeploymentConfigItems(clients kube.Clients, namespace string) []interfac
 	if err != nil {
 		logrus.Errorf("Failed to list deploymentConfigs %v", err)
 	}
-	return util.InterfaceSlice(deploymentConfigs.Items)
+	return util.InterfacePointerSlice(deploymentConfigs.Items)
+}
+
+// GetCronJobItems returns the cronJobs in given namespace
+func GetCronJobItems(clients kube.Clients, namespace string) []interface{} {
+	cronJobs, err := clients.KubernetesClient.BatchV1beta1().CronJobs(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to list cronJobs %v", err)
+	}
+	return util.InterfacePointerSlice(cronJobs.Items)
+}
+
+// GetReplicaSetItems returns the standalone replicaSets in given namespace, those controlled by
+// deployments are reloaded through their deployment
+func GetReplicaSetItems(clients kube.Clients, namespace string) []interface{} {
+	replicaSets, err := clients.KubernetesClient.AppsV1().ReplicaSets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to list replicaSets %v", err)
+	}
+	var items []interface{}
+	for i := range replicaSets.Items {
+		if meta_v1.GetControllerOf(&replicaSets.Items[i]) == nil {
+			items = append(items, &replicaSets.Items[i])
+		}
+	}
+	return items
+}
+
+// GetReplicationControllerItems returns the standalone replicationControllers in given namespace,
+// those controlled by deploymentConfigs are reloaded through their deploymentConfig
+func GetReplicationControllerItems(clients kube.Clients, namespace string) []interface{} {
+	replicationControllers, err := clients.KubernetesClient.CoreV1().ReplicationControllers(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		logrus.Errorf("Failed to list replicationControllers %v", err)
+	}
+	var items []interface{}
+	for i := range replicationControllers.Items {
+		if meta_v1.GetControllerOf(&replicationControllers.Items[i]) == nil && replicationControllers.Items[i].Spec.Template != nil {
+			items = append(items, &replicationControllers.Items[i])
+		}
+	}
+	return items
 }
 
 // GetDeploymentAnnotations returns the annotations of given deployment
 func GetDeploymentAnnotations(item interface{}) map[string]string {
-	return item.(appsv1.Deployment).ObjectMeta.Annotations
+	return item.(*appsv1.Deployment).ObjectMeta.Annotations
 }
 
 // GetDaemonSetAnnotations returns the annotations of given daemonSet
 func GetDaemonSetAnnotations(item interface{}) map[string]string {
-	return item.(appsv1.DaemonSet).ObjectMeta.Annotations
+	return item.(*appsv1.DaemonSet).ObjectMeta.Annotations
 }
 
 // GetStatefulSetAnnotations returns the annotations of given statefulSet
 func GetStatefulSetAnnotations(item interface{}) map[string]string {
-	return item.(appsv1.StatefulSet).ObjectMeta.Annotations
+	return item.(*appsv1.StatefulSet).ObjectMeta.Annotations
 }
 
 // GetDeploymentConfigAnnotations returns the annotations of given deploymentConfig
 func GetDeploymentConfigAnnotations(item interface{}) map[string]string {
-	return item.(openshiftv1.DeploymentConfig).ObjectMeta.Annotations
+	return item.(*openshiftv1.DeploymentConfig).ObjectMeta.Annotations
+}
+
+// GetCronJobAnnotations returns the annotations of given cronJob
+func GetCronJobAnnotations(item interface{}) map[string]string {
+	return item.(*batchv1beta1.CronJob).ObjectMeta.Annotations
+}
+
+// GetReplicaSetAnnotations returns the annotations of given replicaSet
+func GetReplicaSetAnnotations(item interface{}) map[string]string {
+	return item.(*appsv1.ReplicaSet).ObjectMeta.Annotations
+}
+
+// GetReplicationControllerAnnotations returns the annotations of given replicationController
+func GetReplicationControllerAnnotations(item interface{}) map[string]string {
+	return item.(*v1.ReplicationController).ObjectMeta.Annotations
 }
 
 // GetDeploymentPodAnnotations returns the pod's annotations of given deployment
 func GetDeploymentPodAnnotations(item interface{}) map[string]string {
-	return item.(appsv1.Deployment).Spec.Template.ObjectMeta.Annotations
+	return item.(*appsv1.Deployment).Spec.Template.ObjectMeta.Annotations
 }
 
 // GetDaemonSetPodAnnotations returns the pod's annotations of given daemonSet
 func GetDaemonSetPodAnnotations(item interface{}) map[string]string {
-	return item.(appsv1.DaemonSet).Spec.Template.ObjectMeta.Annotations
+	return item.(*appsv1.DaemonSet).Spec.Template.ObjectMeta.Annotations
 }
 
 // GetStatefulSetPodAnnotations returns the pod's annotations of given statefulSet
 func GetStatefulSetPodAnnotations(item interface{}) map[string]string {
-	return item.(appsv1.StatefulSet).Spec.Template.ObjectMeta.Annotations
+	return item.(*appsv1.StatefulSet).Spec.Template.ObjectMeta.Annotations
 }
 
 // GetDeploymentConfigPodAnnotations returns the pod's annotations of given deploymentConfig
 func GetDeploymentConfigPodAnnotations(item interface{}) map[string]string {
-	return item.(openshiftv1.DeploymentConfig).Spec.Template.ObjectMeta.Annotations
+	return item.(*openshiftv1.DeploymentConfig).Spec.Template.ObjectMeta.Annotations
+}
+
+// GetCronJobPodAnnotations returns the pod's annotations of the job template of given cronJob
+func GetCronJobPodAnnotations(item interface{}) map[string]string {
+	return item.(*batchv1beta1.CronJob).Spec.JobTemplate.Spec.Template.ObjectMeta.Annotations
+}
+
+// GetReplicaSetPodAnnotations returns the pod's annotations of given replicaSet
+func GetReplicaSetPodAnnotations(item interface{}) map[string]string {
+	return item.(*appsv1.ReplicaSet).Spec.Template.ObjectMeta.Annotations
+}
+
+// GetReplicationControllerPodAnnotations returns the pod's annotations of given replicationController
+func GetReplicationControllerPodAnnotations(item interface{}) map[string]string {
+	return item.(*v1.ReplicationController).Spec.Template.ObjectMeta.Annotations
+}
+
+// GetDeploymentPodTemplate returns the pod template of given deployment
+func GetDeploymentPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return &item.(*appsv1.Deployment).Spec.Template
+}
+
+// GetDaemonSetPodTemplate returns the pod template of given daemonSet
+func GetDaemonSetPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return &item.(*appsv1.DaemonSet).Spec.Template
+}
+
+// GetStatefulSetPodTemplate returns the pod template of given statefulSet
+func GetStatefulSetPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return &item.(*appsv1.StatefulSet).Spec.Template
+}
+
+// GetDeploymentConfigPodTemplate returns the pod template of given deploymentConfig
+func GetDeploymentConfigPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return item.(*openshiftv1.DeploymentConfig).Spec.Template
+}
+
+// GetCronJobPodTemplate returns the pod template of the job template of given cronJob
+func GetCronJobPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return &item.(*batchv1beta1.CronJob).Spec.JobTemplate.Spec.Template
+}
+
+// GetReplicaSetPodTemplate returns the pod template of given replicaSet
+func GetReplicaSetPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return &item.(*appsv1.ReplicaSet).Spec.Template
+}
+
+// GetReplicationControllerPodTemplate returns the pod template of given replicationController
+func GetReplicationControllerPodTemplate(item interface{}) *v1.PodTemplateSpec {
+	return item.(*v1.ReplicationController).Spec.Template
 }
 
 // GetDeploymentContainers returns the containers of given deployment
 func GetDeploymentContainers(item interface{}) []v1.Container {
-	return item.(appsv1.Deployment).Spec.Template.Spec.Containers
+	return item.(*appsv1.Deployment).Spec.Template.Spec.Containers
 }
 
 // GetDaemonSetContainers returns the containers of given daemonSet
 func GetDaemonSetContainers(item interface{}) []v1.Container {
-	return item.(appsv1.DaemonSet).Spec.Template.Spec.Containers
+	return item.(*appsv1.DaemonSet).Spec.Template.Spec.Containers
 }
 
 // GetStatefulSetContainers returns the containers of given statefulSet
 func GetStatefulSetContainers(item interface{}) []v1.Container {
-	return item.(appsv1.StatefulSet).Spec.Template.Spec.Containers
+	return item.(*appsv1.StatefulSet).Spec.Template.Spec.Containers
 }
 
 // GetDeploymentConfigContainers returns the containers of given deploymentConfig
 func GetDeploymentConfigContainers(item interface{}) []v1.Container {
-	return item.(openshiftv1.DeploymentConfig).Spec.Template.Spec.Containers
+	return item.(*openshiftv1.DeploymentConfig).Spec.Template.Spec.Containers
+}
+
+// GetCronJobContainers returns the containers of the job template of given cronJob
+func GetCronJobContainers(item interface{}) []v1.Container {
+	return item.(*batchv1beta1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Containers
+}
+
+// GetReplicaSetContainers returns the containers of given replicaSet
+func GetReplicaSetContainers(item interface{}) []v1.Container {
+	return item.(*appsv1.ReplicaSet).Spec.Template.Spec.Containers
+}
+
+// GetReplicationControllerContainers returns the containers of given replicationController
+func GetReplicationControllerContainers(item interface{}) []v1.Container {
+	return item.(*v1.ReplicationController).Spec.Template.Spec.Containers
 }
 
 // GetDeploymentInitContainers returns the containers of given deployment
 func GetDeploymentInitContainers(item interface{}) []v1.Container {
-	return item.(appsv1.Deployment).Spec.Template.Spec.InitContainers
+	return item.(*appsv1.Deployment).Spec.Template.Spec.InitContainers
 }
 
 // GetDaemonSetInitContainers returns the containers of given daemonSet
 func GetDaemonSetInitContainers(item interface{}) []v1.Container {
-	return item.(appsv1.DaemonSet).Spec.Template.Spec.InitContainers
+	return item.(*appsv1.DaemonSet).Spec.Template.Spec.InitContainers
 }
 
 // GetStatefulSetInitContainers returns the containers of given statefulSet
 func GetStatefulSetInitContainers(item interface{}) []v1.Container {
-	return item.(appsv1.StatefulSet).Spec.Template.Spec.InitContainers
+	return item.(*appsv1.StatefulSet).Spec.Template.Spec.InitContainers
 }
 
 // GetDeploymentConfigInitContainers returns the containers of given deploymentConfig
 func GetDeploymentConfigInitContainers(item interface{}) []v1.Container {
-	return item.(openshiftv1.DeploymentConfig).Spec.Template.Spec.InitContainers
+	return item.(*openshiftv1.DeploymentConfig).Spec.Template.Spec.InitContainers
+}
+
+// GetCronJobInitContainers returns the containers of the job template of given cronJob
+func GetCronJobInitContainers(item interface{}) []v1.Container {
+	return item.(*batchv1beta1.CronJob).Spec.JobTemplate.Spec.Template.Spec.InitContainers
+}
+
+// GetReplicaSetInitContainers returns the containers of given replicaSet
+func GetReplicaSetInitContainers(item interface{}) []v1.Container {
+	return item.(*appsv1.ReplicaSet).Spec.Template.Spec.InitContainers
+}
+
+// GetReplicationControllerInitContainers returns the containers of given replicationController
+func GetReplicationControllerInitContainers(item interface{}) []v1.Container {
+	return item.(*v1.ReplicationController).Spec.Template.Spec.InitContainers
+}
+
+// GetDeploymentEphemeralContainers returns the ephemeral containers of given deployment
+func GetDeploymentEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*appsv1.Deployment).Spec.Template.Spec)
+}
+
+// GetDaemonSetEphemeralContainers returns the ephemeral containers of given daemonSet
+func GetDaemonSetEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*appsv1.DaemonSet).Spec.Template.Spec)
+}
+
+// GetStatefulSetEphemeralContainers returns the ephemeral containers of given statefulSet
+func GetStatefulSetEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*appsv1.StatefulSet).Spec.Template.Spec)
+}
+
+// GetDeploymentConfigEphemeralContainers returns the ephemeral containers of given deploymentConfig
+func GetDeploymentConfigEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*openshiftv1.DeploymentConfig).Spec.Template.Spec)
+}
+
+// GetCronJobEphemeralContainers returns the ephemeral containers of the job template of given cronJob
+func GetCronJobEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*batchv1beta1.CronJob).Spec.JobTemplate.Spec.Template.Spec)
+}
+
+// GetReplicaSetEphemeralContainers returns the ephemeral containers of given replicaSet
+func GetReplicaSetEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*appsv1.ReplicaSet).Spec.Template.Spec)
+}
+
+// GetReplicationControllerEphemeralContainers returns the ephemeral containers of given replicationController
+func GetReplicationControllerEphemeralContainers(item interface{}) []v1.Container {
+	return ephemeralContainers(item.(*v1.ReplicationController).Spec.Template.Spec)
+}
+
+// ephemeralContainers converts the ephemeral containers of the pod spec to containers, which share all
+// their fields
+func ephemeralContainers(spec v1.PodSpec) []v1.Container {
+	containers := make([]v1.Container, 0, len(spec.EphemeralContainers))
+	for _, container := range spec.EphemeralContainers {
+		containers = append(containers, v1.Container(container.EphemeralContainerCommon))
+	}
+	return containers
+}
+
+// GetDeployment returns the deployment of the name
+func GetDeployment(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.KubernetesClient.AppsV1().Deployments(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+// GetDaemonSet returns the daemonSet of the name
+func GetDaemonSet(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.KubernetesClient.AppsV1().DaemonSets(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+// GetStatefulSet returns the statefulSet of the name
+func GetStatefulSet(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.KubernetesClient.AppsV1().StatefulSets(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+// GetDeploymentConfig returns the deploymentConfig of the name
+func GetDeploymentConfig(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.OpenshiftAppsClient.AppsV1().DeploymentConfigs(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+// GetCronJob returns the cronJob of the name
+func GetCronJob(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.KubernetesClient.BatchV1beta1().CronJobs(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+// GetReplicaSet returns the replicaSet of the name
+func GetReplicaSet(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.KubernetesClient.AppsV1().ReplicaSets(namespace).Get(name, meta_v1.GetOptions{})
+}
+
+// GetReplicationController returns the replicationController of the name
+func GetReplicationController(clients kube.Clients, namespace string, name string) (interface{}, error) {
+	return clients.KubernetesClient.CoreV1().ReplicationControllers(namespace).Get(name, meta_v1.GetOptions{})
 }
 
 // UpdateDeployment performs rolling upgrade on deployment
 func UpdateDeployment(clients kube.Clients, namespace string, resource interface{}) error {
-	deployment := resource.(appsv1.Deployment)
-	_, err := clients.KubernetesClient.AppsV1().Deployments(namespace).Update(&deployment)
+	deployment := resource.(*appsv1.Deployment)
+	_, err := clients.KubernetesClient.AppsV1().Deployments(namespace).Update(deployment)
 	return err
 }
 
 // UpdateDaemonSet performs rolling upgrade on daemonSet
 func UpdateDaemonSet(clients kube.Clients, namespace string, resource interface{}) error {
-	daemonSet := resource.(appsv1.DaemonSet)
-	_, err := clients.KubernetesClient.AppsV1().DaemonSets(namespace).Update(&daemonSet)
+	daemonSet := resource.(*appsv1.DaemonSet)
+	_, err := clients.KubernetesClient.AppsV1().DaemonSets(namespace).Update(daemonSet)
 	return err
 }
 
 // UpdateStatefulSet performs rolling upgrade on statefulSet
 func UpdateStatefulSet(clients kube.Clients, namespace string, resource interface{}) error {
-	statefulSet := resource.(appsv1.StatefulSet)
-	_, err := clients.KubernetesClient.AppsV1().StatefulSets(namespace).Update(&statefulSet)
+	statefulSet := resource.(*appsv1.StatefulSet)
+	_, err := clients.KubernetesClient.AppsV1().StatefulSets(namespace).Update(statefulSet)
 	return err
 }
 
 // UpdateDeploymentConfig performs rolling upgrade on deploymentConfig
 func UpdateDeploymentConfig(clients kube.Clients, namespace string, resource interface{}) error {
-	deploymentConfig := resource.(openshiftv1.DeploymentConfig)
-	_, err := clients.OpenshiftAppsClient.AppsV1().DeploymentConfigs(namespace).Update(&deploymentConfig)
+	deploymentConfig := resource.(*openshiftv1.DeploymentConfig)
+	_, err := clients.OpenshiftAppsClient.AppsV1().DeploymentConfigs(namespace).Update(deploymentConfig)
 	return err
 }
 
+// UpdateCronJob updates the job template of cronJob, the jobs of its next runs pick up the change
+func UpdateCronJob(clients kube.Clients, namespace string, resource interface{}) error {
+	cronJob := resource.(*batchv1beta1.CronJob)
+	_, err := clients.KubernetesClient.BatchV1beta1().CronJobs(namespace).Update(cronJob)
+	return err
+}
+
+// UpdateReplicaSet updates the pod template of replicaSet, which does not roll its pods out, so
+// they are evicted to be recreated from the updated template
+func UpdateReplicaSet(clients kube.Clients, namespace string, resource interface{}) error {
+	replicaSet := resource.(*appsv1.ReplicaSet)
+	if _, err := clients.KubernetesClient.AppsV1().ReplicaSets(namespace).Update(replicaSet); err != nil {
+		return err
+	}
+	return EvictReplicaSetPods(clients, namespace, replicaSet)
+}
+
+// UpdateReplicationController updates the pod template of replicationController, which does not roll
+// its pods out, so they are evicted to be recreated from the updated template
+func UpdateReplicationController(clients kube.Clients, namespace string, resource interface{}) error {
+	replicationController := resource.(*v1.ReplicationController)
+	if _, err := clients.KubernetesClient.CoreV1().ReplicationControllers(namespace).Update(replicationController); err != nil {
+		return err
+	}
+	return EvictReplicationControllerPods(clients, namespace, replicationController)
+}
+
+// EvictReplicaSetPods evicts the pods of replicaSet not created from its current pod template
+func EvictReplicaSetPods(clients kube.Clients, namespace string, resource interface{}) error {
+	replicaSet := resource.(*appsv1.ReplicaSet)
+	return evictControlledPods(clients, namespace, replicaSet, &replicaSet.Spec.Template)
+}
+
+// EvictReplicationControllerPods evicts the pods of replicationController not created from its current
+// pod template
+func EvictReplicationControllerPods(clients kube.Clients, namespace string, resource interface{}) error {
+	replicationController := resource.(*v1.ReplicationController)
+	if replicationController.Spec.Template == nil {
+		return nil
+	}
+	return evictControlledPods(clients, namespace, replicationController, replicationController.Spec.Template)
+}
+
+// evictControlledPods evicts the pods controlled by the owner not created from the template one by one,
+// at most at the eviction rate, which keeps to their PodDisruptionBudgets
+func evictControlledPods(clients kube.Clients, namespace string, owner meta_v1.Object, template *v1.PodTemplateSpec) error {
+	pods, err := clients.KubernetesClient.CoreV1().Pods(namespace).List(meta_v1.ListOptions{LabelSelector: labels.SelectorFromSet(template.Labels).String()})
+	if err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !meta_v1.IsControlledBy(pod, owner) || pod.DeletionTimestamp != nil || !podOutdated(pod, template) {
+			continue
+		}
+		if err := evictPod(clients, namespace, pod, evictions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// podOutdated checks whether the pod was created from an earlier version of the template, by the
+// annotations of the template and the env vars Reloader records hashes in
+func podOutdated(pod *v1.Pod, template *v1.PodTemplateSpec) bool {
+	for key, value := range template.Annotations {
+		if pod.Annotations[key] != value {
+			return true
+		}
+	}
+	podContainers := map[string]v1.Container{}
+	for _, container := range pod.Spec.Containers {
+		podContainers[container.Name] = container
+	}
+	for _, container := range template.Spec.Containers {
+		podContainer, found := podContainers[container.Name]
+		if !found || !equality.Semantic.DeepEqual(reloaderEnv(container.Env), reloaderEnv(podContainer.Env)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reloaderEnv returns the values of the env vars Reloader records hashes in by name
+func reloaderEnv(env []v1.EnvVar) map[string]string {
+	values := map[string]string{}
+	for _, envVar := range env {
+		if strings.HasPrefix(envVar.Name, constants.EnvVarPrefix) {
+			values[envVar.Name] = envVar.Value
+		}
+	}
+	return values
+}
+
+// GetDeploymentRevisionTemplate returns the pod template of the given revision of a deployment, as
+// recorded in the replicaSets it owns
+func GetDeploymentRevisionTemplate(clients kube.Clients, namespace string, item interface{}, revision string) (*v1.PodTemplateSpec, error) {
+	deployment := item.(*appsv1.Deployment)
+	replicaSets, err := clients.KubernetesClient.AppsV1().ReplicaSets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range replicaSets.Items {
+		replicaSet := replicaSets.Items[i]
+		if !meta_v1.IsControlledBy(&replicaSet, deployment) || !hasRevision(replicaSet.Annotations, revision) {
+			continue
+		}
+		template := replicaSet.Spec.Template.DeepCopy()
+		delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		return template, nil
+	}
+	return nil, fmt.Errorf("revision %s of deployment '%s' not found", revision, deployment.Name)
+}
+
+func hasRevision(annotations map[string]string, revision string) bool {
+	if annotations[RevisionAnnotation] == revision {
+		return true
+	}
+	for _, previous := range strings.Split(annotations[RevisionHistoryAnnotation], ",") {
+		if previous == revision {
+			return true
+		}
+	}
+	return false
+}
+
 // GetDeploymentVolumes returns the Volumes of given deployment
 func GetDeploymentVolumes(item interface{}) []v1.Volume {
-	return item.(appsv1.Deployment).Spec.Template.Spec.Volumes
+	return item.(*appsv1.Deployment).Spec.Template.Spec.Volumes
 }
 
 // GetDaemonSetVolumes returns the Volumes of given daemonSet
 func GetDaemonSetVolumes(item interface{}) []v1.Volume {
-	return item.(appsv1.DaemonSet).Spec.Template.Spec.Volumes
+	return item.(*appsv1.DaemonSet).Spec.Template.Spec.Volumes
 }
 
 // GetStatefulSetVolumes returns the Volumes of given statefulSet
 func GetStatefulSetVolumes(item interface{}) []v1.Volume {
-	return item.(appsv1.StatefulSet).Spec.Template.Spec.Volumes
+	return item.(*appsv1.StatefulSet).Spec.Template.Spec.Volumes
 }
 
 // GetDeploymentConfigVolumes returns the Volumes of given deploymentConfig
 func GetDeploymentConfigVolumes(item interface{}) []v1.Volume {
-	return item.(openshiftv1.DeploymentConfig).Spec.Template.Spec.Volumes
+	return item.(*openshiftv1.DeploymentConfig).Spec.Template.Spec.Volumes
+}
+
+// GetCronJobVolumes returns the Volumes of the job template of given cronJob
+func GetCronJobVolumes(item interface{}) []v1.Volume {
+	return item.(*batchv1beta1.CronJob).Spec.JobTemplate.Spec.Template.Spec.Volumes
+}
+
+// GetReplicaSetVolumes returns the Volumes of given replicaSet
+func GetReplicaSetVolumes(item interface{}) []v1.Volume {
+	return item.(*appsv1.ReplicaSet).Spec.Template.Spec.Volumes
+}
+
+// GetReplicationControllerVolumes returns the Volumes of given replicationController
+func GetReplicationControllerVolumes(item interface{}) []v1.Volume {
+	return item.(*v1.ReplicationController).Spec.Template.Spec.Volumes
+}
+
+// GetDeploymentAvailableReplicas returns the available replicas of given deployment
+func GetDeploymentAvailableReplicas(item interface{}) int32 {
+	return item.(*appsv1.Deployment).Status.AvailableReplicas
+}
+
+// GetDaemonSetAvailableReplicas returns the available daemon pods of given daemonSet
+func GetDaemonSetAvailableReplicas(item interface{}) int32 {
+	return item.(*appsv1.DaemonSet).Status.NumberAvailable
+}
+
+// GetStatefulSetAvailableReplicas returns the ready replicas of given statefulSet
+func GetStatefulSetAvailableReplicas(item interface{}) int32 {
+	return item.(*appsv1.StatefulSet).Status.ReadyReplicas
+}
+
+// GetDeploymentConfigAvailableReplicas returns the available replicas of given deploymentConfig
+func GetDeploymentConfigAvailableReplicas(item interface{}) int32 {
+	return item.(*openshiftv1.DeploymentConfig).Status.AvailableReplicas
+}
+
+// GetReplicaSetAvailableReplicas returns the available replicas of given replicaSet
+func GetReplicaSetAvailableReplicas(item interface{}) int32 {
+	return item.(*appsv1.ReplicaSet).Status.AvailableReplicas
+}
+
+// GetReplicationControllerAvailableReplicas returns the available replicas of given replicationController
+func GetReplicationControllerAvailableReplicas(item interface{}) int32 {
+	return item.(*v1.ReplicationController).Status.AvailableReplicas
+}
+
+// IsDeploymentRolloutInProgress checks whether given deployment still rolls out, like `kubectl rollout status`
+func IsDeploymentRolloutInProgress(item interface{}) bool {
+	deployment := item.(*appsv1.Deployment)
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.ObservedGeneration < deployment.Generation ||
+		status.UpdatedReplicas < replicas ||
+		status.Replicas > status.UpdatedReplicas ||
+		status.AvailableReplicas < status.UpdatedReplicas
+}
+
+// IsDaemonSetRolloutInProgress checks whether given daemonSet still rolls out, like `kubectl rollout status`
+func IsDaemonSetRolloutInProgress(item interface{}) bool {
+	daemonSet := item.(*appsv1.DaemonSet)
+	status := daemonSet.Status
+	return status.ObservedGeneration < daemonSet.Generation ||
+		status.UpdatedNumberScheduled < status.DesiredNumberScheduled ||
+		status.NumberAvailable < status.DesiredNumberScheduled
+}
+
+// IsStatefulSetRolloutInProgress checks whether given statefulSet still rolls out, like `kubectl rollout status`
+func IsStatefulSetRolloutInProgress(item interface{}) bool {
+	statefulSet := item.(*appsv1.StatefulSet)
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	status := statefulSet.Status
+	return status.ObservedGeneration < statefulSet.Generation ||
+		status.ReadyReplicas < replicas ||
+		(status.UpdateRevision != "" && status.UpdateRevision != status.CurrentRevision)
+}
+
+// IsDeploymentConfigRolloutInProgress checks whether given deploymentConfig still rolls out
+func IsDeploymentConfigRolloutInProgress(item interface{}) bool {
+	deploymentConfig := item.(*openshiftv1.DeploymentConfig)
+	status := deploymentConfig.Status
+	return status.ObservedGeneration < deploymentConfig.Generation ||
+		status.UpdatedReplicas < deploymentConfig.Spec.Replicas ||
+		status.Replicas > status.UpdatedReplicas ||
+		status.AvailableReplicas < status.UpdatedReplicas
+}
+
+// GetDeploymentRolloutFailure returns why the rollout of given deployment failed, empty if it did not
+func GetDeploymentRolloutFailure(item interface{}) string {
+	for _, condition := range item.(*appsv1.Deployment).Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == ProgressDeadlineExceededReason {
+			return progressDeadlineExceeded(condition.Message)
+		}
+	}
+	return ""
+}
+
+// GetDeploymentConfigRolloutFailure returns why the rollout of given deploymentConfig failed, empty if it did not
+func GetDeploymentConfigRolloutFailure(item interface{}) string {
+	for _, condition := range item.(*openshiftv1.DeploymentConfig).Status.Conditions {
+		if condition.Type == openshiftv1.DeploymentProgressing && condition.Reason == ProgressDeadlineExceededReason {
+			return progressDeadlineExceeded(condition.Message)
+		}
+	}
+	return ""
+}
+
+func progressDeadlineExceeded(message string) string {
+	if message == "" {
+		return ProgressDeadlineExceededReason
+	}
+	return message
 }
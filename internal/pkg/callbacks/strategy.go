@@ -0,0 +1,90 @@
+package callbacks
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/stakater/Reloader/pkg/kube"
+
+	openshiftv1 "github.com/openshift/api/apps/v1"
+)
+
+const (
+	// RollingStrategy is the name of the built-in strategy updating the pod template of the workload
+	// with the hash of the change in an env var
+	RollingStrategy = "rolling"
+	// AnnotationsStrategy is the name of the built-in strategy updating the pod template of the workload
+	// with the hash of the change in an annotation, leaving the env of its containers untouched
+	AnnotationsStrategy = "annotations"
+	// InstantiateStrategy is the name of the built-in strategy rolling out DeploymentConfigs through
+	// the instantiate API of OpenShift, leaving their pod template untouched so their triggers and
+	// deployment hooks run as for any other rollout. Other workloads are updated as by RollingStrategy
+	InstantiateStrategy = "instantiate"
+)
+
+// Strategy reloads a workload for a change of one of its configmaps or secrets
+type Strategy interface {
+	// Apply reloads the workload in the namespace. The workload already carries the hash of the
+	// change in its pod template, in an annotation for the AnnotationsStrategy and in an env var
+	// for all others, which is only persisted by strategies updating the workload
+	Apply(clients kube.Clients, upgradeFuncs RollingUpgradeFuncs, namespace string, workload interface{}, hash string) error
+}
+
+var (
+	strategiesLock sync.RWMutex
+	strategies     = map[string]Strategy{}
+)
+
+func init() {
+	RegisterStrategy(RollingStrategy, rollingStrategy{})
+	RegisterStrategy(AnnotationsStrategy, rollingStrategy{})
+	RegisterStrategy(InstantiateStrategy, instantiateStrategy{})
+	RegisterStrategy(PodDeleteStrategy, NewPodDeleteStrategy(0))
+}
+
+// RegisterStrategy makes the strategy available by name, replacing a strategy of the same name
+func RegisterStrategy(name string, strategy Strategy) {
+	strategiesLock.Lock()
+	defer strategiesLock.Unlock()
+	strategies[name] = strategy
+}
+
+// GetStrategy returns the strategy registered by the name, if any
+func GetStrategy(name string) (Strategy, bool) {
+	strategiesLock.RLock()
+	defer strategiesLock.RUnlock()
+	strategy, found := strategies[name]
+	return strategy, found
+}
+
+// StrategyNames returns the names of the registered strategies in order
+func StrategyNames() []string {
+	strategiesLock.RLock()
+	defer strategiesLock.RUnlock()
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rollingStrategy updates the workload, rolling its pods out with the hash in their template
+type rollingStrategy struct{}
+
+func (rollingStrategy) Apply(clients kube.Clients, upgradeFuncs RollingUpgradeFuncs, namespace string, workload interface{}, hash string) error {
+	return upgradeFuncs.UpdateFunc(clients, namespace, workload)
+}
+
+// instantiateStrategy requests the latest deployment of DeploymentConfigs, the hash is not persisted
+type instantiateStrategy struct{}
+
+func (instantiateStrategy) Apply(clients kube.Clients, upgradeFuncs RollingUpgradeFuncs, namespace string, workload interface{}, hash string) error {
+	deploymentConfig, isDeploymentConfig := workload.(*openshiftv1.DeploymentConfig)
+	if !isDeploymentConfig {
+		return upgradeFuncs.UpdateFunc(clients, namespace, workload)
+	}
+	request := &openshiftv1.DeploymentRequest{Name: deploymentConfig.Name, Latest: true, Force: true}
+	_, err := clients.OpenshiftAppsClient.AppsV1().DeploymentConfigs(namespace).Instantiate(deploymentConfig.Name, request)
+	return err
+}
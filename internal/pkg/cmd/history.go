@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/stakater/Reloader/internal/pkg/history"
+	"github.com/stakater/Reloader/internal/pkg/options"
+)
+
+// NewHistoryCommand queries the reload decisions recorded in the history file
+func NewHistoryCommand() *cobra.Command {
+	var filter history.Filter
+	var deployment, daemonSet, statefulSet string
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the reload decisions recorded in the history file",
+		Run: func(cmd *cobra.Command, args []string) {
+			for kind, name := range map[string]string{"Deployment": deployment, "DaemonSet": daemonSet, "StatefulSet": statefulSet} {
+				if name != "" {
+					filter.Kind, filter.Name = kind, name
+				}
+			}
+			if err := showHistory(filter); err != nil {
+				logrus.Fatal(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&filter.Namespace, "namespace", "", "only show decisions for workloads in this namespace")
+	cmd.Flags().StringVar(&deployment, "deployment", "", "only show decisions for this deployment")
+	cmd.Flags().StringVar(&daemonSet, "daemonset", "", "only show decisions for this daemonset")
+	cmd.Flags().StringVar(&statefulSet, "statefulset", "", "only show decisions for this statefulset")
+	return cmd
+}
+
+func showHistory(filter history.Filter) error {
+	if options.HistoryFile == "" {
+		return fmt.Errorf("no history file given with --history-file")
+	}
+	records, err := history.NewStore(options.HistoryFile, options.HistoryMaxSize).Query(filter)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tNAMESPACE\tKIND\tNAME\tSOURCE\tDECISION\tREASON")
+	for _, record := range records {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s/%s\t%s\t%s\n", record.Time.Format(time.RFC3339), record.Namespace, record.Kind, record.Name, record.SourceType, record.SourceName, record.Decision, record.Reason)
+	}
+	return writer.Flush()
+}
@@ -4,17 +4,47 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/stakater/Reloader/internal/pkg/aws"
+	"github.com/stakater/Reloader/internal/pkg/azure"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/consul"
 	"github.com/stakater/Reloader/internal/pkg/controller"
+	"github.com/stakater/Reloader/internal/pkg/crypto"
+	"github.com/stakater/Reloader/internal/pkg/diagnostics"
+	"github.com/stakater/Reloader/internal/pkg/gcp"
+	"github.com/stakater/Reloader/internal/pkg/handler"
+	"github.com/stakater/Reloader/internal/pkg/health"
+	"github.com/stakater/Reloader/internal/pkg/history"
+	"github.com/stakater/Reloader/internal/pkg/leadership"
+	"github.com/stakater/Reloader/internal/pkg/logging"
 	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/notifier"
 	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/schedule"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
+	"github.com/stakater/Reloader/internal/pkg/trigger"
 	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/internal/pkg/vault"
 	"github.com/stakater/Reloader/pkg/kube"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// apiServerPingInterval is the interval at which Reloader contacts the API server to report whether
+// it is reachable
+const apiServerPingInterval = 10 * time.Second
+
+// tracesExportTimeout is the time the spans ended before Reloader stops may take to be exported
+const tracesExportTimeout = 5 * time.Second
+
 // NewReloaderCommand starts the reloader controller
 func NewReloaderCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -29,36 +59,157 @@ func NewReloaderCommand() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&options.ReloaderAutoAnnotation, "auto-annotation", "reloader.stakater.com/auto", "annotation to detect changes in secrets")
 	cmd.PersistentFlags().StringVar(&options.AutoSearchAnnotation, "auto-search-annotation", "reloader.stakater.com/search", "annotation to detect changes in configmaps or secrets tagged with special match annotation")
 	cmd.PersistentFlags().StringVar(&options.SearchMatchAnnotation, "search-match-annotation", "reloader.stakater.com/match", "annotation to mark secrets or configmapts to match the search")
-	cmd.PersistentFlags().StringVar(&options.LogFormat, "log-format", "", "Log format to use (empty string for text, or JSON")
+	cmd.PersistentFlags().StringVar(&options.InstanceAnnotation, "instance-annotation", "reloader.stakater.com/instance", "annotation to address a workload to a specific Reloader instance")
+	cmd.PersistentFlags().StringVar(&options.InstanceName, "instance-name", "", "name of this Reloader instance, only workloads addressed to it via the instance annotation are handled")
+	cmd.PersistentFlags().BoolVar(&options.DefaultInstance, "default-instance", false, "handle workloads that are not addressed to any instance (implied when instance-name is empty)")
+	cmd.PersistentFlags().BoolVar(&options.ObserveOnly, "observe-only", false, "only log and report the rolling upgrades that would be performed, never update workloads")
+	cmd.PersistentFlags().BoolVar(&options.DryRun, "dry-run", false, "like observe-only, but record the rolling upgrades that would be performed as WouldReload events on the workloads")
+	cmd.PersistentFlags().BoolVar(&options.RecordChangeCause, "record-change-cause", false, "record the configmap or secret change as kubernetes.io/change-cause of the rolling upgrades of all workloads, not only of the workloads with the change-cause annotation")
+	cmd.PersistentFlags().StringVar(&options.ChangeCauseAnnotation, "change-cause-annotation", "reloader.stakater.com/change-cause", "annotation to customize the change-cause recorded for rolling upgrades of a workload")
+	cmd.PersistentFlags().StringVar(&options.ReloadStrategyAnnotation, "reload-strategy-annotation", "reloader.stakater.com/reload-strategy", "annotation to name the strategy reloading a workload")
+	cmd.PersistentFlags().StringVar(&options.ReloadStrategy, "reload-strategy", callbacks.RollingStrategy, "strategy reloading workloads without a reload-strategy annotation")
+	cmd.PersistentFlags().StringVar(&options.HashAlgorithm, "hash-algorithm", crypto.SHA256, "algorithm the SHAs of configmaps and secrets are generated with, one of 'sha256', 'sha512' or the legacy 'sha1'")
+	cmd.PersistentFlags().BoolVar(&options.FIPSMode, "fips", false, "only allow hash algorithms approved by FIPS")
+	cmd.PersistentFlags().BoolVar(&options.DeferReloadUntilCertificateValid, "defer-reload-until-certificate-valid", false, "defer the reloads for a changed TLS secret until the notBefore of its certificate")
+	cmd.PersistentFlags().Float64Var(&options.PodDeleteRate, "pod-delete-rate", 1, "maximum number of pods per second the pod-delete strategy, and the reloads of standalone replicaSets and replicationControllers, evict, unlimited if 0")
+	cmd.PersistentFlags().BoolVar(&options.ReloadOnSourceCreate, "reload-on-source-create", true, "reload the workloads referencing a configmap or secret when it is created after them")
+	cmd.PersistentFlags().BoolVar(&options.ReloadOnSourceCreate, "reload-on-create", true, "alias of reload-on-source-create")
+	cmd.PersistentFlags().BoolVar(&options.WatchSecretProviderClasses, "watch-secret-provider-classes", false, "watch the secret provider classes of the Secrets Store CSI driver and reload the workloads mounting a changed one")
+	cmd.PersistentFlags().StringVar(&options.SecretProviderClassUpdateOnChangeAnnotation, "secret-provider-class-annotation", "secretproviderclass.reloader.stakater.com/reload", "annotation to detect changes in secret provider classes, specified by name")
+	cmd.PersistentFlags().BoolVar(&options.WatchExternalSecrets, "watch-external-secrets", false, "watch the external secrets of the External Secrets Operator and reload the workloads listing a refreshed one whose synced secret changed")
+	cmd.PersistentFlags().BoolVar(&options.WatchCertificates, "watch-certificates", false, "watch the certificates of cert-manager and reload the workloads listing a re-issued one whose certificate changed")
+	cmd.PersistentFlags().StringVar(&options.CertificateUpdateOnChangeAnnotation, "certificate-annotation", "certificate.reloader.stakater.com/reload", "annotation to detect changes in the secrets issued for certificates, specified by name")
+	cmd.PersistentFlags().StringVar(&options.ExternalSecretUpdateOnChangeAnnotation, "external-secret-annotation", "externalsecret.reloader.stakater.com/reload", "annotation to detect changes in the secrets synced by external secrets, specified by name")
+	cmd.PersistentFlags().BoolVar(&options.ReloadOnSourceDelete, "reload-on-delete", false, "reload the workloads with the reload-on-delete annotation referencing a configmap or secret when it is deleted")
+	cmd.PersistentFlags().IntVar(&options.ReconcileWorkers, "reconcile-workers", 5, "number of workers processing the configmaps and secrets found when syncing")
+	cmd.PersistentFlags().BoolVar(&options.SyncOnStart, "sync-on-start", true, "reload at startup the workloads holding an outdated hash of a configmap or secret, which missed its changes while Reloader was down")
+	cmd.PersistentFlags().DurationVar(&options.ResyncPeriod, "resync-period", 0, "interval of the syncs of the workloads with all configmaps and secrets like at startup, e.g. 1h, never if 0")
+	cmd.PersistentFlags().IntVar(&options.MaxConcurrentReloads, "max-concurrent-reloads", 1, "number of workers processing the changes of each informer and number of workloads reloaded at the same time, each workload is reloaded for one change at a time")
+	cmd.PersistentFlags().BoolVar(&options.DeferReloadDuringRollout, "defer-reload-during-rollout", false, "defer the reload of a workload that still rolls out a previous change until the rollout completes, then apply the latest change once")
+	cmd.PersistentFlags().BoolVar(&options.DeferReloadOnDisruptionBudget, "defer-reload-on-disruption-budget", false, "defer the reload of a workload while a pod disruption budget of its pods allows no disruptions")
+	cmd.PersistentFlags().StringVar(&options.AllowedReloadWindow, "allowed-reload-window", "", "weekly window reloads are allowed in, e.g. 'Mon-Fri 09:00-17:00' in UTC, reloads outside of it are deferred until it opens")
+	cmd.PersistentFlags().DurationVar(&options.MaxQueuedAge, "max-queued-age", 0, "time after which deferred reloads are dropped and counted as expired, 0 never drops them")
+	cmd.PersistentFlags().DurationVar(&options.ReloadDebounce, "reload-debounce", 0, "window starting with an update of a configmap or secret, during which further updates of it are merged into one reload, e.g. 30s, updates are handled immediately if 0")
+	cmd.PersistentFlags().DurationVar(&options.ShutdownTimeout, "shutdown-timeout", 25*time.Second, "time to handle the changes already detected before exiting on termination, shorter than the termination grace period of the pod")
+	cmd.PersistentFlags().DurationVar(&options.APIServerUnreachableTimeout, "api-server-unreachable-timeout", 2*time.Minute, "time after which Reloader reports to be unhealthy at /healthz when it cannot contact the API server, never if 0")
+	cmd.PersistentFlags().IntVar(&options.MaxRetries, "max-retries", 5, "number of times a change is handled again after workload updates failed with a transient error, e.g. a conflict, before it is dropped")
+	cmd.PersistentFlags().DurationVar(&options.RetryBaseDelay, "retry-base-delay", time.Second, "delay of the first retry of a change, doubled for every further retry")
+	cmd.PersistentFlags().DurationVar(&options.RetryMaxDelay, "retry-max-delay", 5*time.Minute, "maximum delay of the retries of a change")
+	cmd.PersistentFlags().DurationVar(&options.DeferredReloadRetryInterval, "deferred-reload-retry-interval", 30*time.Second, "interval at which deferred reloads of workloads are retried")
+	cmd.PersistentFlags().DurationVar(&options.DeferredReloadMaxRetryInterval, "deferred-reload-max-retry-interval", 5*time.Minute, "maximum interval at which reloads deferred during rollouts or by disruption budgets are retried, the interval doubles with each retry")
+	cmd.PersistentFlags().BoolVar(&options.MonitorRollouts, "monitor-rollouts", false, "track the rollouts of reloaded workloads and report whether they complete with events, metrics and notifications")
+	cmd.PersistentFlags().DurationVar(&options.RolloutCheckInterval, "rollout-check-interval", 10*time.Second, "interval at which monitored rollouts are checked")
+	cmd.PersistentFlags().DurationVar(&options.RolloutTimeout, "rollout-timeout", 10*time.Minute, "time after which monitored rollouts that did not complete are failed")
+	cmd.PersistentFlags().StringSliceVar(&options.RolloutTimeouts, "rollout-timeouts", []string{}, "list of '<kind>=<duration>' overriding the rollout-timeout for a kind of workload, e.g. 'StatefulSet=30m'")
+	cmd.PersistentFlags().BoolVar(&options.ReloadKnativeServices, "reload-knative-services", false, "reload Knative Services referencing a changed configmap or secret in their revision template by rolling a new revision, Knative Serving has to be installed")
+	cmd.PersistentFlags().BoolVar(&options.ReloadScaledJobs, "reload-scaled-jobs", false, "reload KEDA ScaledJobs referencing a changed configmap or secret in the pod template of their job target, for the jobs they spawn next, KEDA has to be installed")
+	cmd.PersistentFlags().BoolVar(&options.ReloadArgoRollouts, "reload-argo-rollouts", false, "reload Argo Rollouts referencing a changed configmap or secret in their pod template, Argo Rollouts has to be installed")
+	cmd.PersistentFlags().StringVar(&options.ArgoRolloutTemplatePath, "argo-rollout-template-path", ".spec.template", "JSONPath of the pod template of Argo Rollouts that is reloaded, the templates of their analyses and experiments are left untouched")
+	cmd.PersistentFlags().StringArrayVar(&options.CustomWorkloads, "custom-workload", []string{}, "'<resource>.<version>.<group>=<path>[;<references path>]' of custom resources embedding a pod template at the JSONPath, and optionally referencing configmaps and secrets at the references JSONPath, e.g. 'flinkdeployments.v1beta1.flink.apache.org=.spec.podTemplate', reloaded like the built-in workloads, may be repeated")
+	cmd.PersistentFlags().StringVar(&options.WorkloadRegistryConfigMap, "workload-registry-configmap", "", "'<namespace>/<name>', or the name in the namespace of Reloader, of a configmap listing more custom workloads like --custom-workload, one per line of its values, applied on each change")
+	cmd.PersistentFlags().BoolVar(&options.CacheWorkloads, "cache-workloads", true, "serve the built-in workloads from shared informers indexed by the configmaps and secrets they reference instead of listing them for every change, they are listed until the informers synced")
+	cmd.PersistentFlags().BoolVar(&options.PatchWorkloads, "patch-workloads", true, "write the changes of Reloader to workloads as strategic merge patches of the changed fields instead of replacing the workloads, so concurrent changes by other controllers are kept")
+	cmd.PersistentFlags().StringVar(&options.PrometheusURL, "prometheus-url", "", "URL of the Prometheus evaluating the guard queries of workloads")
+	cmd.PersistentFlags().StringVar(&options.MetricsAddr, "metrics-addr", ":9090", "address (host:port) the metrics endpoint listens on, metrics are not served if empty")
+	cmd.PersistentFlags().StringVar(&options.MetricsPath, "metrics-path", "/metrics", "path the metrics endpoint serves metrics at")
+	cmd.PersistentFlags().StringVar(&options.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint, e.g. http://otel-collector:4318, the traces of the handling of changes are exported to, from the informer event to the matching, hashing and patching of the workloads (defaults to OTEL_EXPORTER_OTLP_ENDPOINT, tracing is disabled if empty)")
+	cmd.PersistentFlags().BoolVar(&options.EnablePprof, "enable-pprof", false, "serve the pprof handlers at /debug/pprof/ and runtime variables like queue lengths, cache sizes and goroutine counts at /debug/vars along with the metrics")
+	cmd.PersistentFlags().StringVar(&options.TeamLabel, "team-label", "", "workload label whose value reloads are counted by in the reloader_reloads_by_team_total metric")
+	cmd.PersistentFlags().BoolVar(&options.DetailedMetrics, "detailed-metrics", false, "count reloads per workload and resourceVersion of the changed configmap or secret, this creates a metric series per change")
+	cmd.PersistentFlags().BoolVar(&options.RequireDiscovery, "require-discovery", false, "exit if the environment (Kubernetes or Openshift) cannot be detected at startup, instead of assuming Kubernetes and retrying in the background")
+	cmd.PersistentFlags().StringVar(&options.TriggerURL, "trigger-url", "", "endpoint polled for a JSON array of trigger messages naming workloads to reload, e.g. a bridge draining a queue")
+	cmd.PersistentFlags().DurationVar(&options.TriggerPollInterval, "trigger-poll-interval", 30*time.Second, "interval at which the trigger-url is polled")
+	cmd.PersistentFlags().StringVar(&options.VaultAddress, "vault-address", "", "address of the Vault server polled for new versions of the secrets at the vault-paths")
+	cmd.PersistentFlags().StringSliceVar(&options.VaultPaths, "vault-paths", []string{}, "list of Vault KV version 2 paths, e.g. 'secret/app/db', reloading the workloads listing them in their vault-path annotation on new versions")
+	cmd.PersistentFlags().DurationVar(&options.VaultPollInterval, "vault-poll-interval", time.Minute, "interval at which the vault-paths are polled")
+	cmd.PersistentFlags().StringVar(&options.VaultRole, "vault-role", "", "role of the Kubernetes auth method of Vault to log in with")
+	cmd.PersistentFlags().StringVar(&options.VaultAuthPath, "vault-auth-path", "kubernetes", "mount path of the Kubernetes auth method of Vault")
+	cmd.PersistentFlags().StringVar(&options.VaultTokenFile, "vault-token-file", "/var/run/secrets/kubernetes.io/serviceaccount/token", "file holding the service account token to log in to Vault with")
+	cmd.PersistentFlags().StringVar(&options.AWSQueueURL, "aws-queue-url", "", "SQS queue receiving the events of AWS Secrets Manager from EventBridge, reloading the workloads listing a changed secret in their aws-secret annotation")
+	cmd.PersistentFlags().StringVar(&options.AWSRegion, "aws-region", "", "region of the aws-queue-url, AWS_REGION if empty")
+	cmd.PersistentFlags().StringVar(&options.AWSRoleARN, "aws-role-arn", "", "role assumed with the aws-web-identity-token-file to receive the events, AWS_ROLE_ARN if empty. Access keys are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY instead if set")
+	cmd.PersistentFlags().StringVar(&options.AWSWebIdentityTokenFile, "aws-web-identity-token-file", "", "file holding the token the aws-role-arn is assumed with, AWS_WEB_IDENTITY_TOKEN_FILE if empty")
+	cmd.PersistentFlags().StringVar(&options.AWSSecretAnnotation, "aws-secret-annotation", "reloader.stakater.com/aws-secret", "annotation listing the secrets of AWS Secrets Manager reloading a workload")
+	cmd.PersistentFlags().StringVar(&options.GCPSubscription, "gcp-subscription", "", "Pub/Sub subscription receiving the notifications of GCP Secret Manager, reloading the workloads listing a secret with a new version in their gcp-secret annotation")
+	cmd.PersistentFlags().StringVar(&options.GCPCredentialsFile, "gcp-credentials-file", "", "key file of the service account pulling the notifications, GOOGLE_APPLICATION_CREDENTIALS if empty. The service account of the metadata server is used without one")
+	cmd.PersistentFlags().StringVar(&options.GCPSecretAnnotation, "gcp-secret-annotation", "reloader.stakater.com/gcp-secret", "annotation listing the secrets of GCP Secret Manager reloading a workload")
+	cmd.PersistentFlags().StringVar(&options.AzureEventGridAddr, "azure-event-grid-addr", "", "address to serve the endpoint of an Event Grid subscription of Azure Key Vault events at, e.g. ':9091', reloading the workloads listing a secret with a new version in their azure-secret annotation")
+	cmd.PersistentFlags().StringVar(&options.AzureEventGridPath, "azure-event-grid-path", "/azure/eventgrid", "path of the endpoint of the Event Grid subscription")
+	cmd.PersistentFlags().StringVar(&options.AzureEventGridKey, "azure-event-grid-key", "", "value of the 'key' query parameter the events must carry, AZURE_EVENT_GRID_KEY if empty")
+	cmd.PersistentFlags().StringVar(&options.AzureSecretAnnotation, "azure-secret-annotation", "reloader.stakater.com/azure-secret", "annotation listing the secrets of Azure Key Vault reloading a workload")
+	cmd.PersistentFlags().StringVar(&options.ConsulAddress, "consul-address", "", "address of the Consul agent watched for changes of the keys under the consul-prefixes")
+	cmd.PersistentFlags().StringSliceVar(&options.ConsulPrefixes, "consul-prefixes", []string{}, "list of Consul KV prefixes, e.g. 'config/app/', reloading the workloads listing their changed keys in their consul-key annotation")
+	cmd.PersistentFlags().StringVar(&options.ConsulToken, "consul-token", "", "ACL token of the queries of the Consul agent, CONSUL_HTTP_TOKEN if empty")
+	cmd.PersistentFlags().StringVar(&options.ConsulKeyAnnotation, "consul-key-annotation", "reloader.stakater.com/consul-key", "annotation listing the Consul keys reloading a workload")
+	cmd.PersistentFlags().StringVar(&options.VaultPathAnnotation, "vault-path-annotation", "reloader.stakater.com/vault-path", "annotation listing the Vault KV paths reloading a workload")
+	cmd.PersistentFlags().StringVar(&options.HistoryFile, "history-file", "", "file to record reload decisions in for the history command, none are recorded if empty")
+	cmd.PersistentFlags().Int64Var(&options.HistoryMaxSize, "history-max-size", 10<<20, "size in bytes at which the history file is rotated, keeping one rotated file")
+	cmd.PersistentFlags().StringVar(&options.LogFormat, "log-format", "", "Log format to use, json or text (the default)")
+	cmd.PersistentFlags().StringVar(&options.LogLevel, "log-level", "info", "level of the logs, e.g. debug, info or warning, changed at runtime with a PUT to /log-level of the metrics address or toggled to debug with SIGUSR1")
+	cmd.PersistentFlags().StringSliceVar(&options.AlwaysReloadSources, "always-reload-sources", []string{}, "list of '<namespace>/<name>' configmaps or secrets whose changes reload all workloads referencing them, regardless of their annotations")
+	cmd.PersistentFlags().BoolVar(&options.EnableCrossNamespaceReferences, "enable-cross-namespace-references", false, "reload workloads referencing configmaps or secrets of other namespaces as '<namespace>/<name>' in their reload annotations")
+	cmd.PersistentFlags().StringSliceVar(&options.CrossNamespaceSourceNamespaces, "cross-namespace-source-namespaces", []string{}, "list of namespaces whose configmaps and secrets are watched only for the workloads of the watched namespaces referencing them, when not watching all namespaces")
+	cmd.PersistentFlags().DurationVar(&options.CrossNamespaceSourceDiscoveryInterval, "cross-namespace-source-discovery-interval", time.Minute, "interval at which the namespaces of configmaps and secrets referenced by the workloads of the watched namespaces are watched if they are not yet, 0 to only log them once")
+	cmd.PersistentFlags().StringSliceVar(&options.IgnoredSourceManagers, "ignore-source-managers", []string{}, "list of field managers whose changes to configmaps or secrets are ignored, based on managedFields")
+	cmd.PersistentFlags().BoolVar(&options.EmitEvents, "emit-events", true, "record every reload as Kubernetes Event on the reloaded workload")
+	cmd.PersistentFlags().BoolVar(&options.EmitSourceEvents, "emit-source-events", false, "also record every reload as Kubernetes Event on the changed configmap or secret")
+	cmd.PersistentFlags().StringSliceVar(&options.WebhookURLs, "webhook-url", []string{}, "list of URLs performed and failed reloads are posted to as JSON")
+	cmd.PersistentFlags().IntVar(&options.WebhookRetries, "webhook-retries", 3, "number of times a failed post to a webhook is retried")
+	cmd.PersistentFlags().DurationVar(&options.WebhookRetryInterval, "webhook-retry-interval", 5*time.Second, "time waited before a failed post to a webhook is retried, doubled for each further retry")
+	cmd.PersistentFlags().StringVar(&options.WebhookCAFile, "webhook-ca-file", "", "PEM file of the CA certificates verifying the webhooks, the system ones are used if empty")
+	cmd.PersistentFlags().BoolVar(&options.WebhookInsecureSkipVerify, "webhook-insecure-skip-verify", false, "do not verify the certificates of the webhooks")
+	cmd.PersistentFlags().StringVar(&options.SlackWebhookURL, "slack-webhook-url", "", "Slack incoming webhook reloads are posted to as messages")
+	cmd.PersistentFlags().StringVar(&options.SlackToken, "slack-token", "", "Slack bot token posting reloads as messages to the slack-channel, defaults to SLACK_TOKEN")
+	cmd.PersistentFlags().StringVar(&options.SlackChannel, "slack-channel", "", "Slack channel the bot token posts messages to")
+	cmd.PersistentFlags().StringVar(&options.SlackMessageTemplate, "slack-message-template", "", "Go template of the Slack messages, e.g. '{{.Kind}} {{.Name}} restarted due to {{.SourceKind}} {{.SourceName}} change'")
+	cmd.PersistentFlags().DurationVar(&options.SlackMinInterval, "slack-min-interval", 10*time.Second, "minimum time between two Slack messages, the reloads in between are counted in the next message")
+	cmd.PersistentFlags().IntVar(&options.SlackRetries, "slack-retries", 3, "number of times a failed post to Slack is retried")
+	cmd.PersistentFlags().DurationVar(&options.SlackRetryInterval, "slack-retry-interval", 5*time.Second, "time waited before a failed post to Slack is retried, doubled for each further retry")
+	cmd.PersistentFlags().DurationVar(&options.NotificationRetryMaxDelay, "notification-retry-max-delay", time.Minute, "maximum time waited before a failed post to a webhook or Slack is retried")
+	cmd.PersistentFlags().IntVar(&options.NotificationBufferSize, "notification-buffer-size", 100, "number of notifications waiting to be posted to each webhook or Slack, further ones are dropped")
+	cmd.PersistentFlags().IntVar(&options.NotificationBreakerThreshold, "notification-breaker-threshold", 5, "number of notifications in a row a webhook or Slack failed to receive after their retries that drops its further notifications for the notification-breaker-cooldown, 0 to never drop them")
+	cmd.PersistentFlags().DurationVar(&options.NotificationBreakerCooldown, "notification-breaker-cooldown", time.Minute, "time the notifications to a failing webhook or Slack are dropped for")
+	cmd.PersistentFlags().DurationVar(&options.NotificationDigestWindow, "notification-digest-window", 0, "time the notifications of the reloads for a change of a configmap or secret are collected in, to notify them as a single digest, each reload is notified if 0")
+	cmd.PersistentFlags().BoolVar(&options.EnableHA, "enable-ha", false, "run as one of several replicas, only the replica holding the leader election lease performs rolling upgrades")
+	cmd.PersistentFlags().StringVar(&options.LeaderElectionLeaseName, "leader-election-lease-name", "reloader", "name of the Lease the replicas compete for")
+	cmd.PersistentFlags().StringVar(&options.LeaderElectionNamespace, "leader-election-namespace", "", "namespace of the Lease, defaults to POD_NAMESPACE or KUBERNETES_NAMESPACE")
+	cmd.PersistentFlags().DurationVar(&options.LeaderElectionLeaseDuration, "leader-election-lease-duration", 15*time.Second, "time replicas wait before taking over a lease the leader did not renew")
+	cmd.PersistentFlags().DurationVar(&options.LeaderElectionRenewDeadline, "leader-election-renew-deadline", 10*time.Second, "time the leader retries renewing the lease before it stops leading")
+	cmd.PersistentFlags().DurationVar(&options.LeaderElectionRetryPeriod, "leader-election-retry-period", 2*time.Second, "interval at which replicas try to acquire or renew the lease")
+	cmd.PersistentFlags().StringVar(&options.ResourceLabelSelector, "resource-label-selector", "", "label selector of the configmaps and secrets to watch, e.g. 'reloader=enabled', all are watched if empty")
 	cmd.PersistentFlags().StringSlice("resources-to-ignore", []string{}, "list of resources to ignore (valid options 'configMaps' or 'secrets')")
+	cmd.PersistentFlags().StringSlice("resources-to-watch", []string{}, "list of resources to watch, all if empty (valid options 'configMaps' or 'secrets'), the leader election lease is suffixed with them so instances watching different resources are elected each for their own")
 	cmd.PersistentFlags().StringSlice("namespaces-to-ignore", []string{}, "list of namespaces to ignore")
-	return cmd
-}
+	cmd.PersistentFlags().StringSlice("namespaces-to-watch", []string{}, "list of namespaces to watch, each with its own informer instead of a cluster-wide watch (overrides KUBERNETES_NAMESPACE)")
 
-func configureLogging(logFormat string) error {
-	switch logFormat {
-	case "json":
-		logrus.SetFormatter(&logrus.JSONFormatter{})
-	default:
-		// just let the library use default on empty string.
-		if logFormat != "" {
-			return fmt.Errorf("unsupported logging formatter: %q", logFormat)
-		}
-	}
-	return nil
+	cmd.AddCommand(NewHistoryCommand())
+	return cmd
 }
 
 func startReloader(cmd *cobra.Command, args []string) {
-	err := configureLogging(options.LogFormat)
+	err := logging.Configure(options.LogFormat, options.LogLevel)
 	if err != nil {
 		logrus.Warn(err)
 	}
+	go logging.ToggleDebugOnSignal(make(chan struct{}))
 
 	logrus.Info("Starting Reloader")
+	if options.DryRun {
+		options.ObserveOnly = true
+		logrus.Info("Running in dry-run mode, workloads will not be updated")
+	} else if options.ObserveOnly {
+		logrus.Info("Running in observe-only mode, workloads will not be updated")
+	}
+	watchedNamespacesList, err := getWatchedNamespacesList(cmd)
+	if err != nil {
+		logrus.Fatal(err)
+	}
 	currentNamespace := os.Getenv("KUBERNETES_NAMESPACE")
 	if len(currentNamespace) == 0 {
 		currentNamespace = v1.NamespaceAll
-		logrus.Warnf("KUBERNETES_NAMESPACE is unset, will detect changes in all namespaces.")
+		if len(watchedNamespacesList) == 0 {
+			logrus.Warnf("KUBERNETES_NAMESPACE is unset, will detect changes in all namespaces.")
+		}
 	}
 
 	// create the clientset
@@ -66,44 +217,505 @@ func startReloader(cmd *cobra.Command, args []string) {
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	kube.DetectEnvironment(options.RequireDiscovery)
+
+	callbacks.RegisterStrategy(callbacks.PodDeleteStrategy, callbacks.NewPodDeleteStrategy(options.PodDeleteRate))
+	callbacks.SetEvictionRate(options.PodDeleteRate)
+	if _, found := callbacks.GetStrategy(options.ReloadStrategy); !found {
+		logrus.Fatalf("unknown reload strategy '%s', expected one of %s", options.ReloadStrategy, strings.Join(callbacks.StrategyNames(), ", "))
+	}
+
+	if err := crypto.SetAlgorithm(options.HashAlgorithm, options.FIPSMode); err != nil {
+		logrus.Fatal(err)
+	}
+
+	for _, timeout := range options.RolloutTimeouts {
+		parts := strings.SplitN(timeout, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			logrus.Fatalf("'rollout-timeouts' only accepts '<kind>=<duration>', not '%s'", timeout)
+		}
+		if _, err := time.ParseDuration(parts[1]); err != nil {
+			logrus.Fatalf("invalid rollout timeout of kind '%s': %v", parts[0], err)
+		}
+	}
+
+	for _, source := range options.AlwaysReloadSources {
+		if parts := strings.Split(source, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			logrus.Fatalf("'always-reload-sources' only accepts '<namespace>/<name>', not '%s'", source)
+		}
+	}
+
+	if options.APIServerUnreachableTimeout < 0 {
+		logrus.Fatal("'api-server-unreachable-timeout' must not be negative")
+	}
+
+	if options.ResyncPeriod < 0 {
+		logrus.Fatal("'resync-period' must not be negative")
+	}
+
+	if options.MaxConcurrentReloads < 1 {
+		logrus.Fatal("'max-concurrent-reloads' must be at least 1")
+	}
+
+	if options.MaxRetries < 0 || options.RetryBaseDelay <= 0 || options.RetryMaxDelay < options.RetryBaseDelay {
+		logrus.Fatal("'max-retries' must not be negative and 'retry-max-delay' must not be less than the positive 'retry-base-delay'")
+	}
+
+	if _, err := labels.Parse(options.ResourceLabelSelector); err != nil {
+		logrus.Fatalf("invalid 'resource-label-selector' '%s': %v", options.ResourceLabelSelector, err)
+	}
+
+	if options.VaultAddress != "" && (len(options.VaultPaths) == 0 || options.VaultRole == "") {
+		logrus.Fatal("'vault-address' requires 'vault-paths' and 'vault-role'")
+	}
+
+	if options.ConsulAddress != "" {
+		if len(options.ConsulPrefixes) == 0 {
+			logrus.Fatal("'consul-address' requires 'consul-prefixes'")
+		}
+		if options.ConsulToken == "" {
+			options.ConsulToken = os.Getenv("CONSUL_HTTP_TOKEN")
+		}
+	}
+
+	awsCredentials := aws.CredentialsConfig{
+		AccessKeyID:          os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey:      os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:         os.Getenv("AWS_SESSION_TOKEN"),
+		RoleARN:              options.AWSRoleARN,
+		WebIdentityTokenFile: options.AWSWebIdentityTokenFile,
+	}
+	if options.AWSQueueURL != "" {
+		if options.AWSRegion == "" {
+			options.AWSRegion = os.Getenv("AWS_REGION")
+		}
+		if awsCredentials.RoleARN == "" {
+			awsCredentials.RoleARN = os.Getenv("AWS_ROLE_ARN")
+		}
+		if awsCredentials.WebIdentityTokenFile == "" {
+			awsCredentials.WebIdentityTokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		}
+		if options.AWSRegion == "" {
+			logrus.Fatal("'aws-queue-url' requires 'aws-region'")
+		}
+		if awsCredentials.AccessKeyID == "" && (awsCredentials.RoleARN == "" || awsCredentials.WebIdentityTokenFile == "") {
+			logrus.Fatal("'aws-queue-url' requires access keys or 'aws-role-arn' and 'aws-web-identity-token-file'")
+		}
+	}
+
+	if options.GCPSubscription != "" && options.GCPCredentialsFile == "" {
+		options.GCPCredentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+
+	if options.AzureEventGridAddr != "" && options.AzureEventGridKey == "" {
+		options.AzureEventGridKey = os.Getenv("AZURE_EVENT_GRID_KEY")
+	}
+
+	if options.AllowedReloadWindow != "" {
+		if _, err := schedule.Parse(options.AllowedReloadWindow); err != nil {
+			logrus.Fatal(err)
+		}
+	}
 
 	ignoredResourcesList, err := getIgnoredResourcesList(cmd)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	watchedResourcesList, err := getWatchedResourcesList(cmd)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if len(watchedResourcesList) > 0 && len(ignoredResourcesList) > 0 {
+		logrus.Fatal("'resources-to-watch' and 'resources-to-ignore' cannot be combined")
+	}
+	resources := controller.WatchedResources(watchedResourcesList, ignoredResourcesList)
 
 	ignoredNamespacesList, err := getIgnoredNamespacesList(cmd)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	namespaces := controller.WatchedNamespaces(watchedNamespacesList, currentNamespace, ignoredNamespacesList)
+	if len(namespaces) == 0 {
+		logrus.Fatal("'namespaces-to-watch' only lists ignored namespaces")
+	}
 
-	collectors := metrics.SetupPrometheusEndpoint()
+	handler.WatchedNamespaces = namespaces
+	for _, value := range options.CustomWorkloads {
+		kind, err := callbacks.ParseCustomWorkloadKind(value)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		// the kind is logged and recorded in events and metrics as resource type
+		if discovered, err := kube.ResourceKind(clientset, kind.Resource); err != nil {
+			logrus.Warnf("Unable to discover the kind of custom workload '%s', using its resource as kind: %v", value, err)
+		} else {
+			kind.Kind = discovered
+		}
+		handler.CustomWorkloadKinds = append(handler.CustomWorkloadKinds, kind)
+	}
+	if _, err := callbacks.ParseTemplatePath(options.ArgoRolloutTemplatePath); options.ReloadArgoRollouts && err != nil {
+		logrus.Fatalf("Invalid 'argo-rollout-template-path': %v", err)
+	}
+	registryNamespace, registryName := currentNamespace, options.WorkloadRegistryConfigMap
+	if parts := strings.SplitN(options.WorkloadRegistryConfigMap, "/", 2); len(parts) == 2 {
+		registryNamespace, registryName = parts[0], parts[1]
+	}
+	if registryName != "" && registryNamespace == v1.NamespaceAll {
+		logrus.Fatal("KUBERNETES_NAMESPACE is unset, name the namespace of 'workload-registry-configmap' as '<namespace>/<name>'")
+	}
+	// the sources of cross-namespace references are watched in namespaces of their own
+	sourceNamespaces := namespaces
+	if options.EnableCrossNamespaceReferences && namespaces[0] != v1.NamespaceAll {
+		sourceNamespaces = controller.WatchedNamespaces(append(append([]string{}, namespaces...), options.CrossNamespaceSourceNamespaces...), currentNamespace, ignoredNamespacesList)
+	}
+
+	if options.HistoryFile != "" {
+		handler.History = history.NewStore(options.HistoryFile, options.HistoryMaxSize)
+	}
 
-	for k := range kube.ResourceMap {
-		if ignoredResourcesList.Contains(k) {
-			continue
+	collectors := metrics.SetupPrometheusEndpoint(options.MetricsAddr, options.MetricsPath)
+
+	// each webhook and Slack is a sink of its own, retried, buffered and circuit broken on its own
+	sinkOptions := func(retries int, retryInterval time.Duration) notifier.SinkOptions {
+		return notifier.SinkOptions{
+			Retries:          retries,
+			RetryBaseDelay:   retryInterval,
+			RetryMaxDelay:    options.NotificationRetryMaxDelay,
+			BufferSize:       options.NotificationBufferSize,
+			BreakerThreshold: options.NotificationBreakerThreshold,
+			BreakerCooldown:  options.NotificationBreakerCooldown,
+		}
+	}
+	notifiers := notifier.Notifiers{}
+	for i, url := range options.WebhookURLs {
+		webhook, err := notifier.NewWebhookNotifier([]string{url}, options.WebhookRetries, options.WebhookRetryInterval, options.WebhookCAFile, options.WebhookInsecureSkipVerify)
+		if err != nil {
+			logrus.Fatalf("invalid webhook options: %v", err)
+		}
+		// webhook URLs may hold tokens, they are named by position in logs and metrics
+		notifiers = append(notifiers, notifier.NewSinkNotifier(fmt.Sprintf("webhook-%d", i+1), webhook, sinkOptions(options.WebhookRetries, options.WebhookRetryInterval), collectors.NotificationsDropped))
+	}
+	if options.SlackToken == "" {
+		options.SlackToken = os.Getenv("SLACK_TOKEN")
+	}
+	if options.SlackWebhookURL != "" || options.SlackChannel != "" {
+		slack, err := notifier.NewSlackNotifier(options.SlackWebhookURL, options.SlackToken, options.SlackChannel, options.SlackMessageTemplate, options.SlackMinInterval)
+		if err != nil {
+			logrus.Fatalf("invalid Slack options: %v", err)
+		}
+		notifiers = append(notifiers, notifier.NewSinkNotifier("slack", slack, sinkOptions(options.SlackRetries, options.SlackRetryInterval), collectors.NotificationsDropped))
+	}
+	if len(notifiers) > 0 && options.NotificationDigestWindow > 0 {
+		handler.Notifier = notifier.NewDigestNotifier(notifiers, options.NotificationDigestWindow)
+	} else if len(notifiers) > 0 {
+		handler.Notifier = notifiers
+	}
+
+	diagnostics.Enabled = options.EnablePprof
+	if options.OTLPEndpoint == "" {
+		options.OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if options.OTLPEndpoint != "" {
+		service := os.Getenv("OTEL_SERVICE_NAME")
+		if service == "" {
+			service = "reloader"
+		}
+		if err := tracing.Configure(options.OTLPEndpoint, service); err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("Exporting the traces of reloads to %s", options.OTLPEndpoint)
+	}
+	health.Default.SetUnreachableTimeout(options.APIServerUnreachableTimeout)
+
+	// controllers are running until they drained their queues once the stop channel is closed
+	var controllers sync.WaitGroup
+	startController := func(c *controller.Controller, stopCh <-chan struct{}) {
+		controllers.Add(1)
+		go func() {
+			defer controllers.Done()
+			c.Run(options.MaxConcurrentReloads, stopCh)
+		}()
+	}
+
+	// run starts the controllers and the trigger consumer, which run until the stop channel is closed
+	run := func(stopCh <-chan struct{}) {
+		if options.CacheWorkloads {
+			handler.StartWorkloadCache(clientset, namespaces, stopCh)
+		}
+		if registryName != "" {
+			logrus.Infof("Watching the workload registry '%s' in namespace '%s'", registryName, registryNamespace)
+			go controller.WatchWorkloadRegistry(clientset, registryNamespace, registryName, stopCh)
+		}
+
+		for _, k := range resources {
+			for _, namespace := range sourceNamespaces {
+				c, err := controller.NewController(clientset, k, namespace, ignoredNamespacesList, collectors)
+				if err != nil {
+					logrus.Fatalf("%s", err)
+				}
+
+				// Now let's start the controller
+				if namespace != v1.NamespaceAll {
+					logrus.Infof("Starting Controller to watch resource type: %s in namespace: %s", k, namespace)
+				} else {
+					logrus.Infof("Starting Controller to watch resource type: %s", k)
+				}
+				startController(c, stopCh)
+			}
+		}
+
+		// the namespaces of sources referenced across namespaces are watched once referenced
+		if options.EnableCrossNamespaceReferences && namespaces[0] != v1.NamespaceAll {
+			clients := kube.GetClients()
+			foreignSources := &controller.ForeignSources{
+				Watched: sourceNamespaces,
+				Ignored: ignoredNamespacesList,
+				Referenced: func() []string {
+					return handler.ReferencedSourceNamespaces(clients)
+				},
+			}
+			if options.CrossNamespaceSourceDiscoveryInterval > 0 {
+				foreignSources.Watch = func(namespace string) {
+					for _, k := range resources {
+						c, err := controller.NewController(clientset, k, namespace, ignoredNamespacesList, collectors)
+						if err != nil {
+							logrus.Errorf("Unable to watch resource type: %s in namespace: %s: %v", k, namespace, err)
+							continue
+						}
+						logrus.Infof("Starting Controller to watch resource type: %s in namespace: %s", k, namespace)
+						startController(c, stopCh)
+					}
+				}
+			}
+			go foreignSources.Run(options.CrossNamespaceSourceDiscoveryInterval, stopCh)
+		}
+
+		if options.WatchSecretProviderClasses {
+			dynamicClient, err := kube.GetDynamicClient()
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			for _, namespace := range sourceNamespaces {
+				c, err := controller.NewSecretProviderClassController(dynamicClient, namespace, ignoredNamespacesList, collectors)
+				if err != nil {
+					logrus.Fatalf("%s", err)
+				}
+				if namespace != v1.NamespaceAll {
+					logrus.Infof("Starting Controller to watch resource type: %s in namespace: %s", kube.SecretProviderClassResource.Resource, namespace)
+				} else {
+					logrus.Infof("Starting Controller to watch resource type: %s", kube.SecretProviderClassResource.Resource)
+				}
+				startController(c, stopCh)
+			}
+		}
+
+		if options.WatchExternalSecrets {
+			dynamicClient, err := kube.GetDynamicClient()
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			for _, namespace := range sourceNamespaces {
+				c, err := controller.NewExternalSecretController(dynamicClient, namespace, ignoredNamespacesList, collectors)
+				if err != nil {
+					logrus.Fatalf("%s", err)
+				}
+				if namespace != v1.NamespaceAll {
+					logrus.Infof("Starting Controller to watch resource type: %s in namespace: %s", kube.ExternalSecretResource.Resource, namespace)
+				} else {
+					logrus.Infof("Starting Controller to watch resource type: %s", kube.ExternalSecretResource.Resource)
+				}
+				startController(c, stopCh)
+			}
+		}
+
+		if options.WatchCertificates {
+			dynamicClient, err := kube.GetDynamicClient()
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			for _, namespace := range sourceNamespaces {
+				c, err := controller.NewCertificateController(dynamicClient, namespace, ignoredNamespacesList, collectors)
+				if err != nil {
+					logrus.Fatalf("%s", err)
+				}
+				if namespace != v1.NamespaceAll {
+					logrus.Infof("Starting Controller to watch resource type: %s in namespace: %s", kube.CertificateResource.Resource, namespace)
+				} else {
+					logrus.Infof("Starting Controller to watch resource type: %s", kube.CertificateResource.Resource)
+				}
+				startController(c, stopCh)
+			}
+		}
+
+		if options.TriggerURL != "" {
+			logrus.Infof("Polling trigger messages from %s", options.TriggerURL)
+			consumer := trigger.HTTPConsumer{URL: options.TriggerURL, Interval: options.TriggerPollInterval}
+			go handler.ConsumeTriggers(consumer, kube.GetClients(), collectors, stopCh)
+		}
+
+		if options.VaultAddress != "" {
+			logrus.Infof("Polling Vault paths %s at %s", strings.Join(options.VaultPaths, ", "), options.VaultAddress)
+			poller := &vault.Poller{
+				Address:   options.VaultAddress,
+				Paths:     options.VaultPaths,
+				Interval:  options.VaultPollInterval,
+				Role:      options.VaultRole,
+				AuthPath:  options.VaultAuthPath,
+				TokenFile: options.VaultTokenFile,
+			}
+			go handler.WatchExternal(poller, kube.GetClients(), collectors, stopCh)
 		}
 
-		c, err := controller.NewController(clientset, k, currentNamespace, ignoredNamespacesList, collectors)
+		if options.ConsulAddress != "" {
+			logrus.Infof("Watching Consul prefixes %s at %s", strings.Join(options.ConsulPrefixes, ", "), options.ConsulAddress)
+			watcher := &consul.Watcher{
+				Address:       options.ConsulAddress,
+				Prefixes:      options.ConsulPrefixes,
+				Token:         options.ConsulToken,
+				WaitTime:      5 * time.Minute,
+				RetryInterval: 10 * time.Second,
+			}
+			go handler.WatchExternal(watcher, kube.GetClients(), collectors, stopCh)
+		}
+
+		if options.AWSQueueURL != "" {
+			logrus.Infof("Receiving AWS Secrets Manager events from %s", options.AWSQueueURL)
+			consumer := &aws.SQSConsumer{
+				QueueURL:    options.AWSQueueURL,
+				Region:      options.AWSRegion,
+				Credentials: awsCredentials,
+				WaitTime:    20 * time.Second,
+			}
+			go handler.WatchExternal(consumer, kube.GetClients(), collectors, stopCh)
+		}
+
+		if options.GCPSubscription != "" {
+			logrus.Infof("Pulling GCP Secret Manager notifications from %s", options.GCPSubscription)
+			subscriber := &gcp.Subscriber{
+				Subscription:    options.GCPSubscription,
+				CredentialsFile: options.GCPCredentialsFile,
+				MetadataURL:     gcpMetadataURL(),
+				WaitTime:        20 * time.Second,
+			}
+			go handler.WatchExternal(subscriber, kube.GetClients(), collectors, stopCh)
+		}
+
+		if options.AzureEventGridAddr != "" {
+			logrus.Infof("Serving the Event Grid endpoint of Azure Key Vault events at %s%s", options.AzureEventGridAddr, options.AzureEventGridPath)
+			webhook := &azure.EventGridWebhook{
+				Addr: options.AzureEventGridAddr,
+				Path: options.AzureEventGridPath,
+				Key:  options.AzureEventGridKey,
+			}
+			go handler.WatchExternal(webhook, kube.GetClients(), collectors, stopCh)
+		}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go health.Default.WatchAPIServer(func() error {
+		_, err := clientset.Discovery().ServerVersion()
+		return err
+	}, apiServerPingInterval, stop)
+	if options.EnableHA {
+		config := getLeaderElectionConfig(currentNamespace, watchedResourcesList)
+		// only the leader is ready, the other replicas wait for the lease
+		health.Default.RequireLeadership()
+		logrus.Infof("Waiting to be elected as '%s' for lease '%s' in namespace '%s'", config.Identity, config.LeaseName, config.LeaseNamespace)
+		go func() {
+			defer close(stopped)
+			leadership.Run(clientset, config, func(leaderStop <-chan struct{}) {
+				health.Default.SetLeading(true)
+				run(leaderStop)
+				<-leaderStop
+				select {
+				case <-stop:
+					// keep leading until the detected changes are handled
+					controllers.Wait()
+				default:
+					// the controllers cannot be restarted, a new replica competes for the lease instead
+					logrus.Fatalf("Lost lease '%s' in namespace '%s'", config.LeaseName, config.LeaseNamespace)
+				}
+			}, stop)
+		}()
+	} else {
+		run(stop)
+		go func() {
+			defer close(stopped)
+			<-stop
+			controllers.Wait()
+		}()
+	}
+
+	// Wait for a termination signal
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	logrus.Infof("Received signal %s, stopping Reloader", <-signals)
+	health.Default.ShutDown()
+	go func() {
+		logrus.Fatalf("Received signal %s, exiting without handling the detected changes", <-signals)
+	}()
+	close(stop)
+	// wait for the detected changes to be handled, up to the shutdown timeout, and for the lease to be
+	// released, so another replica takes over without delay
+	<-stopped
+	tracing.Shutdown(tracesExportTimeout)
+	logrus.Infof("Summary: %s", collectors.Summarize())
+}
+
+// gcpMetadataURL returns the address of the metadata server of GCE_METADATA_HOST, the default one if
+// unset
+func gcpMetadataURL() string {
+	if host := os.Getenv("GCE_METADATA_HOST"); host != "" {
+		return "http://" + host
+	}
+	return ""
+}
+
+func getLeaderElectionConfig(currentNamespace string, watchedResources []string) leadership.Config {
+	namespace := options.LeaderElectionNamespace
+	for _, fallback := range []string{os.Getenv("POD_NAMESPACE"), currentNamespace} {
+		if namespace == "" {
+			namespace = fallback
+		}
+	}
+	if namespace == "" {
+		logrus.Fatal("'leader-election-namespace' is required when neither POD_NAMESPACE nor KUBERNETES_NAMESPACE is set")
+	}
+
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		hostname, err := os.Hostname()
 		if err != nil {
-			logrus.Fatalf("%s", err)
+			logrus.Fatal(err)
 		}
+		identity = hostname
+	}
 
-		// Now let's start the controller
-		stop := make(chan struct{})
-		defer close(stop)
-		logrus.Infof("Starting Controller to watch resource type: %s", k)
-		go c.Run(1, stop)
+	// instances scoped to resource types are elected per resource types, unscoped ones keep the lease
+	leaseName := options.LeaderElectionLeaseName
+	if len(watchedResources) > 0 {
+		leaseName = leadership.ScopedLeaseName(leaseName, controller.WatchedResources(watchedResources, nil))
 	}
 
-	// Wait forever
-	select {}
+	return leadership.Config{
+		LeaseName:      leaseName,
+		LeaseNamespace: namespace,
+		Identity:       identity,
+		LeaseDuration:  options.LeaderElectionLeaseDuration,
+		RenewDeadline:  options.LeaderElectionRenewDeadline,
+		RetryPeriod:    options.LeaderElectionRetryPeriod,
+	}
 }
 
 func getIgnoredNamespacesList(cmd *cobra.Command) (util.List, error) {
 	return getStringSliceFromFlags(cmd, "namespaces-to-ignore")
 }
 
+func getWatchedNamespacesList(cmd *cobra.Command) ([]string, error) {
+	return getStringSliceFromFlags(cmd, "namespaces-to-watch")
+}
+
 func getStringSliceFromFlags(cmd *cobra.Command, flag string) ([]string, error) {
 	slice, err := cmd.Flags().GetStringSlice(flag)
 	if err != nil {
@@ -132,3 +744,18 @@ func getIgnoredResourcesList(cmd *cobra.Command) (util.List, error) {
 
 	return ignoredResourcesList, nil
 }
+
+func getWatchedResourcesList(cmd *cobra.Command) ([]string, error) {
+	watchedResourcesList, err := getStringSliceFromFlags(cmd, "resources-to-watch")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range watchedResourcesList {
+		if v != "configMaps" && v != "secrets" {
+			return nil, fmt.Errorf("'resources-to-watch' only accepts 'configMaps' or 'secrets', not '%s'", v)
+		}
+	}
+
+	return watchedResourcesList, nil
+}
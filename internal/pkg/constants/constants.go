@@ -5,6 +5,33 @@ const (
 	ConfigmapEnvVarPostfix = "CONFIGMAP"
 	// SecretEnvVarPostfix is a postfix for secret envVar
 	SecretEnvVarPostfix = "SECRET"
+	// SecretProviderClassEnvVarPostfix is a postfix for the envVar of secret provider classes of the
+	// Secrets Store CSI driver
+	SecretProviderClassEnvVarPostfix = "SECRETPROVIDERCLASS"
+	// SecretsStoreCSIDriver is the name of the Secrets Store CSI driver
+	SecretsStoreCSIDriver = "secrets-store.csi.k8s.io"
+	// SecretProviderClassVolumeAttribute is the attribute of CSI volumes of the Secrets Store CSI
+	// driver naming their secret provider class
+	SecretProviderClassVolumeAttribute = "secretProviderClass"
+	// ExternalSecretEnvVarPostfix is a postfix for the envVar of external secrets of the External
+	// Secrets Operator
+	ExternalSecretEnvVarPostfix = "EXTERNALSECRET"
+	// CertificateEnvVarPostfix is a postfix for the envVar of certificates of cert-manager
+	CertificateEnvVarPostfix = "CERTIFICATE"
+	// VaultEnvVarPostfix is a postfix for the envVar of Vault KV paths
+	VaultEnvVarPostfix = "VAULT"
+	// AWSSecretEnvVarPostfix is a postfix for the envVar of secrets of AWS Secrets Manager
+	AWSSecretEnvVarPostfix = "AWSSECRET"
+	// GCPSecretEnvVarPostfix is a postfix for the envVar of secrets of GCP Secret Manager
+	GCPSecretEnvVarPostfix = "GCPSECRET"
+	// AzureSecretEnvVarPostfix is a postfix for the envVar of secrets of Azure Key Vault
+	AzureSecretEnvVarPostfix = "AZURESECRET"
+	// ConsulKeyEnvVarPostfix is a postfix for the envVar of keys of the Consul KV store
+	ConsulKeyEnvVarPostfix = "CONSULKEY"
+	// TriggerEnvVarPostfix is a postfix for the envVar of trigger messages
+	TriggerEnvVarPostfix = "TRIGGER"
 	// EnvVarPrefix is a Prefix for environment variable
 	EnvVarPrefix = "STAKATER_"
+	// KubernetesChangeCauseAnnotation is the annotation rollout history reads the change-cause from
+	KubernetesChangeCauseAnnotation = "kubernetes.io/change-cause"
 )
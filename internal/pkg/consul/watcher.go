@@ -0,0 +1,157 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+// Watcher watches the keys under KV prefixes of Consul, e.g. "config/app/", with blocking queries
+type Watcher struct {
+	Address  string
+	Prefixes []string
+	// Token is the ACL token of the queries
+	Token string
+	// WaitTime is the time a blocking query waits for changes
+	WaitTime time.Duration
+	// RetryInterval is the time waited after a failed query
+	RetryInterval time.Duration
+	Client        *http.Client
+}
+
+// entry is a key of the KV store in the response of a query
+type entry struct {
+	Key         string
+	ModifyIndex uint64
+}
+
+// Watch watches the prefixes until the stop channel is closed and delivers the created, modified and
+// deleted keys to the channel. The keys found by the first successful query of a prefix are not changes
+func (w *Watcher) Watch(changes chan<- external.Change, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	var wait sync.WaitGroup
+	for _, prefix := range w.Prefixes {
+		wait.Add(1)
+		go func(prefix string) {
+			defer wait.Done()
+			w.watchPrefix(ctx, prefix, changes, stopCh)
+		}(prefix)
+	}
+	wait.Wait()
+}
+
+func (w *Watcher) watchPrefix(ctx context.Context, prefix string, changes chan<- external.Change, stopCh <-chan struct{}) {
+	var index uint64
+	var indexes map[string]uint64
+	for {
+		entries, next, err := w.query(ctx, prefix, index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logrus.Errorf("Failed to watch Consul prefix '%s': %v", prefix, err)
+			select {
+			case <-time.After(w.RetryInterval):
+				continue
+			case <-stopCh:
+				return
+			}
+		}
+		// the index is reset if it goes backwards, e.g. after a restore of a snapshot, and is at least
+		// 1 so the next query blocks
+		if next < index || next == 0 {
+			next = 1
+		}
+		index = next
+
+		current := map[string]uint64{}
+		for _, entry := range entries {
+			current[entry.Key] = entry.ModifyIndex
+		}
+		if indexes != nil {
+			for _, change := range changedKeys(indexes, current, index) {
+				select {
+				case changes <- change:
+				case <-stopCh:
+					return
+				}
+			}
+		}
+		indexes = current
+	}
+}
+
+// changedKeys returns the changes of the keys created, modified or deleted since the previous query,
+// versioned by their modify index or, for deleted keys, the index of the query
+func changedKeys(previous map[string]uint64, current map[string]uint64, index uint64) []external.Change {
+	var changes []external.Change
+	for key, modifyIndex := range current {
+		if previousIndex, found := previous[key]; !found || previousIndex != modifyIndex {
+			changes = append(changes, external.Change{Type: constants.ConsulKeyEnvVarPostfix, Name: key, Version: strconv.FormatUint(modifyIndex, 10)})
+		}
+	}
+	for key := range previous {
+		if _, found := current[key]; !found {
+			changes = append(changes, external.Change{Type: constants.ConsulKeyEnvVarPostfix, Name: key, Version: "deleted-" + strconv.FormatUint(index, 10)})
+		}
+	}
+	return changes
+}
+
+// query returns the keys under the prefix and the index of the store, blocking until the index
+// exceeds the given one or the wait time passed
+func (w *Watcher) query(ctx context.Context, prefix string, index uint64) ([]entry, uint64, error) {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	query := url.Values{}
+	query.Set("recurse", "true")
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", strconv.Itoa(int(w.WaitTime.Seconds()))+"s")
+	}
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(w.Address, "/")+"/v1/kv/"+strings.TrimPrefix(prefix, "/")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request = request.WithContext(ctx)
+	if w.Token != "" {
+		request.Header.Set("X-Consul-Token", w.Token)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	next, err := strconv.ParseUint(response.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid index '%s'", response.Header.Get("X-Consul-Index"))
+	}
+	// no keys are under the prefix
+	if response.StatusCode == http.StatusNotFound {
+		return nil, next, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	var entries []entry
+	if err := json.NewDecoder(response.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("invalid response: %v", err)
+	}
+	return entries, next, nil
+}
@@ -0,0 +1,89 @@
+package consul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+func TestWatcherDeliversChangedKeys(t *testing.T) {
+	var lock sync.Mutex
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/config/app/" || r.URL.Query().Get("recurse") != "true" || r.Header.Get("X-Consul-Token") != "token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lock.Lock()
+		queries++
+		query := queries
+		lock.Unlock()
+		switch query {
+		case 1:
+			w.Header().Set("X-Consul-Index", "10")
+			w.Write([]byte(`[{"Key":"config/app/db","ModifyIndex":5},{"Key":"config/app/cache","ModifyIndex":7}]`))
+		case 2:
+			if r.URL.Query().Get("index") != "10" || r.URL.Query().Get("wait") != "60s" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-Consul-Index", "12")
+			w.Write([]byte(`[{"Key":"config/app/db","ModifyIndex":12}]`))
+		default:
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	changes := make(chan external.Change)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	watcher := &Watcher{Address: server.URL, Prefixes: []string{"config/app/"}, Token: "token", WaitTime: time.Minute, RetryInterval: time.Millisecond}
+	go func() {
+		watcher.Watch(changes, stopCh)
+		close(done)
+	}()
+
+	var got []external.Change
+	for len(got) < 2 {
+		select {
+		case change := <-changes:
+			got = append(got, change)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Changes were not delivered, got %v", got)
+		}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	want := []external.Change{
+		{Type: constants.ConsulKeyEnvVarPostfix, Name: "config/app/cache", Version: "deleted-12"},
+		{Type: constants.ConsulKeyEnvVarPostfix, Name: "config/app/db", Version: "12"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected changes %v, got %v", want, got)
+	}
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watcher did not stop")
+	}
+}
+
+func TestChangedKeys(t *testing.T) {
+	previous := map[string]uint64{"a": 1, "b": 2}
+	if changes := changedKeys(previous, map[string]uint64{"a": 1, "b": 2}, 3); len(changes) != 0 {
+		t.Errorf("Expected no changes of unchanged keys, got %v", changes)
+	}
+	changes := changedKeys(previous, map[string]uint64{"a": 1, "b": 2, "c": 4}, 4)
+	if want := []external.Change{{Type: constants.ConsulKeyEnvVarPostfix, Name: "c", Version: "4"}}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected the change of the created key %v, got %v", want, changes)
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestAddQueuesCreatedSourceOnlyIfEnabled(t *testing.T) {
+	reloadOnSourceCreate := options.ReloadOnSourceCreate
+	defer func() { options.ReloadOnSourceCreate = reloadOnSourceCreate }()
+
+	configmap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "created-later", Namespace: "default"}}
+	for _, enabled := range []bool{true, false} {
+		options.ReloadOnSourceCreate = enabled
+		c := &Controller{resource: "configMaps", queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()), collectors: metrics.NewCollectors(), synced: 1}
+		c.Add(configmap)
+		if events := promtestutil.ToFloat64(c.collectors.Events.With(prometheus.Labels{"resource": "configMaps", "event": "add"})); events != 1 {
+			t.Errorf("Expected the add event to be counted once, got %v", events)
+		}
+
+		expected := 0
+		if enabled {
+			expected = 1
+		}
+		if c.queue.Len() != expected {
+			t.Errorf("With reload-on-source-create %v, expected %d queued creations, got %d", enabled, expected, c.queue.Len())
+		}
+		c.queue.ShutDown()
+	}
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestCertificateControllerQueuesOnlyUpdates(t *testing.T) {
+	reloadOnSourceDelete, reloadDebounce := options.ReloadOnSourceDelete, options.ReloadDebounce
+	defer func() { options.ReloadOnSourceDelete, options.ReloadDebounce = reloadOnSourceDelete, reloadDebounce }()
+	options.ReloadOnSourceDelete = true
+	options.ReloadDebounce = 0
+
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata":   map[string]interface{}{"name": "tls", "namespace": "default"},
+		"status":     map[string]interface{}{"revision": int64(1)},
+	}}
+	c, err := NewCertificateController(fake.NewSimpleDynamicClient(runtime.NewScheme()), "default", []string{}, metrics.NewCollectors())
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	defer c.queue.ShutDown()
+	c.synced = 1
+
+	// the secret issued for the certificate is watched for creations and deletions
+	c.Add(certificate)
+	c.Delete(certificate)
+	if c.queue.Len() != 0 {
+		t.Errorf("Expected no queued creations or deletions of certificates, got %d", c.queue.Len())
+	}
+
+	reissued := certificate.DeepCopy()
+	reissued.Object["status"] = map[string]interface{}{"revision": int64(2)}
+	c.Update(certificate, reissued)
+	if c.queue.Len() != 1 {
+		t.Errorf("Expected the update of the certificate to be queued, got %d queued changes", c.queue.Len())
+	}
+}
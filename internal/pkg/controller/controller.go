@@ -2,16 +2,29 @@ package controller
 
 import (
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/diagnostics"
 	"github.com/stakater/Reloader/internal/pkg/handler"
+	"github.com/stakater/Reloader/internal/pkg/health"
 	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
 	"github.com/stakater/Reloader/internal/pkg/util"
 	"github.com/stakater/Reloader/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
@@ -22,12 +35,19 @@ import (
 // Controller for checking events
 type Controller struct {
 	client            kubernetes.Interface
+	resource          string
 	indexer           cache.Indexer
 	queue             workqueue.RateLimitingInterface
 	informer          cache.Controller
 	namespace         string
 	ignoredNamespaces util.List
 	collectors        metrics.Collectors
+	synced            int32
+	debounceLock      sync.Mutex
+	debounced         map[debounceKey]handler.ResourceUpdatedHandler
+	// updatesOnly is whether only the updates of the resources are handled, neither their creations
+	// and deletions nor syncs
+	updatesOnly bool
 }
 
 // NewController for initializing a Controller
@@ -36,14 +56,84 @@ func NewController(
 
 	c := Controller{
 		client:            client,
+		resource:          resource,
 		namespace:         namespace,
 		ignoredNamespaces: ignoredNamespaces,
 	}
 
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
-	listWatcher := cache.NewListWatchFromClient(client.CoreV1().RESTClient(), resource, namespace, fields.Everything())
+	listWatcher := cache.NewFilteredListWatchFromClient(client.CoreV1().RESTClient(), resource, namespace, tweakListOptions)
+	c.start(listWatcher, kube.ResourceMap[resource], collectors)
+	return &c, nil
+}
+
+// NewSecretProviderClassController initializes a Controller for the secret provider classes of the
+// Secrets Store CSI driver, which are watched with the dynamic client
+func NewSecretProviderClassController(
+	client dynamic.Interface, namespace string, ignoredNamespaces []string, collectors metrics.Collectors) (*Controller, error) {
+
+	c := Controller{
+		resource:          kube.SecretProviderClassResource.Resource,
+		namespace:         namespace,
+		ignoredNamespaces: ignoredNamespaces,
+	}
+
+	c.start(dynamicListWatcher(client.Resource(kube.SecretProviderClassResource).Namespace(namespace)), &unstructured.Unstructured{}, collectors)
+	return &c, nil
+}
+
+// NewExternalSecretController initializes a Controller for the external secrets of the External
+// Secrets Operator, which are watched with the dynamic client. Only their updates are handled, the
+// secrets they sync are watched for creations and deletions and synced at startup
+func NewExternalSecretController(
+	client dynamic.Interface, namespace string, ignoredNamespaces []string, collectors metrics.Collectors) (*Controller, error) {
+
+	c := Controller{
+		resource:          kube.ExternalSecretResource.Resource,
+		namespace:         namespace,
+		ignoredNamespaces: ignoredNamespaces,
+		updatesOnly:       true,
+	}
+
+	c.start(dynamicListWatcher(client.Resource(kube.ExternalSecretResource).Namespace(namespace)), &unstructured.Unstructured{}, collectors)
+	return &c, nil
+}
+
+// NewCertificateController initializes a Controller for the certificates of cert-manager, which are
+// watched with the dynamic client. Only their updates are handled, the secrets issued for them are
+// watched for creations and deletions and synced at startup
+func NewCertificateController(
+	client dynamic.Interface, namespace string, ignoredNamespaces []string, collectors metrics.Collectors) (*Controller, error) {
+
+	c := Controller{
+		resource:          kube.CertificateResource.Resource,
+		namespace:         namespace,
+		ignoredNamespaces: ignoredNamespaces,
+		updatesOnly:       true,
+	}
+
+	c.start(dynamicListWatcher(client.Resource(kube.CertificateResource).Namespace(namespace)), &unstructured.Unstructured{}, collectors)
+	return &c, nil
+}
 
-	indexer, informer := cache.NewIndexerInformer(listWatcher, kube.ResourceMap[resource], 0, cache.ResourceEventHandlerFuncs{
+// dynamicListWatcher returns the list watcher of the resource of the dynamic client
+func dynamicListWatcher(resource dynamic.ResourceInterface) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(listOptions metav1.ListOptions) (apiruntime.Object, error) {
+			tweakListOptions(&listOptions)
+			return resource.List(listOptions)
+		},
+		WatchFunc: func(listOptions metav1.ListOptions) (watch.Interface, error) {
+			tweakListOptions(&listOptions)
+			return resource.Watch(listOptions)
+		},
+	}
+}
+
+// start sets up the informer of the controller listing and watching the resources of the type with
+// the list watcher
+func (c *Controller) start(listWatcher cache.ListerWatcher, objType apiruntime.Object, collectors metrics.Collectors) {
+	queue := workqueue.NewRateLimitingQueue(retryRateLimiter())
+	indexer, informer := cache.NewIndexerInformer(listWatcher, objType, 0, cache.ResourceEventHandlerFuncs{
 		AddFunc:    c.Add,
 		UpdateFunc: c.Update,
 		DeleteFunc: c.Delete,
@@ -52,15 +142,33 @@ func NewController(
 	c.informer = informer
 	c.queue = queue
 	c.collectors = collectors
-	return &c, nil
+}
+
+// retryRateLimiter delays the retries of a failed change exponentially from the retry base delay up to
+// the retry max delay
+func retryRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(options.RetryBaseDelay, options.RetryMaxDelay)
+}
+
+// tweakListOptions restricts the listed and watched resources to the resource label selector, so
+// the API server only sends the matching ones
+func tweakListOptions(listOptions *metav1.ListOptions) {
+	listOptions.FieldSelector = fields.Everything().String()
+	listOptions.LabelSelector = options.ResourceLabelSelector
 }
 
 // Add function to add a new object to the queue in case of creating a resource
 func (c *Controller) Add(obj interface{}) {
+	c.collectors.CountEvent(c.resource, "add")
+	// objects listed before the caches are synced are handled by the sync at startup, if enabled
+	if atomic.LoadInt32(&c.synced) == 0 || !options.ReloadOnSourceCreate || c.updatesOnly {
+		return
+	}
 	if !c.resourceInIgnoredNamespace(obj) {
-		c.queue.Add(handler.ResourceCreatedHandler{
+		c.enqueue(handler.ResourceCreatedHandler{
 			Resource:   obj,
 			Collectors: c.collectors,
+			Span:       c.eventSpan("add", obj),
 		})
 	}
 }
@@ -71,47 +179,184 @@ func (c *Controller) resourceInIgnoredNamespace(raw interface{}) bool {
 		return c.ignoredNamespaces.Contains(object.ObjectMeta.Namespace)
 	case *v1.Secret:
 		return c.ignoredNamespaces.Contains(object.ObjectMeta.Namespace)
+	case *unstructured.Unstructured:
+		return c.ignoredNamespaces.Contains(object.GetNamespace())
 	}
 	return false
 }
 
 // Update function to add an old object and a new object to the queue in case of updating a resource
 func (c *Controller) Update(old interface{}, new interface{}) {
-	if !c.resourceInIgnoredNamespace(new) {
-		c.queue.Add(handler.ResourceUpdatedHandler{
-			Resource:    new,
-			OldResource: old,
-			Collectors:  c.collectors,
-		})
+	c.collectors.CountEvent(c.resource, "update")
+	if c.resourceInIgnoredNamespace(new) {
+		return
 	}
+	update := handler.ResourceUpdatedHandler{
+		Resource:    new,
+		OldResource: old,
+		Collectors:  c.collectors,
+		Span:        c.eventSpan("update", new),
+	}
+	if options.ReloadDebounce > 0 {
+		c.debounce(update, options.ReloadDebounce)
+		return
+	}
+	c.enqueue(update)
 }
 
 // Delete function to add an object to the queue in case of deleting a resource
 func (c *Controller) Delete(old interface{}) {
-	// Todo: Any future delete event can be handled here
+	c.collectors.CountEvent(c.resource, "delete")
+	if !options.ReloadOnSourceDelete || c.updatesOnly {
+		return
+	}
+	// deletions missed while disconnected only carry the last known state of the resource
+	if tombstone, ok := old.(cache.DeletedFinalStateUnknown); ok {
+		old = tombstone.Obj
+	}
+	if !c.resourceInIgnoredNamespace(old) {
+		c.enqueue(handler.ResourceDeletedHandler{
+			Resource:   old,
+			Collectors: c.collectors,
+			Span:       c.eventSpan("delete", old),
+		})
+	}
+}
+
+// eventSpan starts the span of the handling of the informer event of the resource, the root span of
+// its trace, which ends once the handling succeeded or is not retried anymore
+func (c *Controller) eventSpan(event string, obj interface{}) *tracing.Span {
+	span := tracing.Start(c.resource+" "+event, "resource", c.resource)
+	if accessor, err := meta.Accessor(obj); err == nil {
+		span.SetAttribute("namespace", accessor.GetNamespace())
+		span.SetAttribute("name", accessor.GetName())
+		span.SetAttribute("resourceVersion", accessor.GetResourceVersion())
+	}
+	return span
+}
+
+// eventSpanOf returns the span of the informer event handled by the handler, nil if it is not traced
+func eventSpanOf(item interface{}) *tracing.Span {
+	switch resourceHandler := item.(type) {
+	case handler.ResourceCreatedHandler:
+		return resourceHandler.Span
+	case handler.ResourceUpdatedHandler:
+		return resourceHandler.Span
+	case handler.ResourceDeletedHandler:
+		return resourceHandler.Span
+	}
+	return nil
+}
+
+// enqueue adds the handler of a change to the queue
+func (c *Controller) enqueue(item interface{}) {
+	c.queue.Add(item)
+	c.collectors.SetQueueDepth(c.resource, c.namespace, c.queue.Len())
 }
 
 //Run function for controller which handles the queue
-func (c *Controller) Run(threadiness int, stopCh chan struct{}) {
+func (c *Controller) Run(threadiness int, stopCh <-chan struct{}) {
 	defer runtime.HandleCrash()
 
 	// Let the workers stop when we are done
 	defer c.queue.ShutDown()
 
-	go c.informer.Run(stopCh)
+	// Reloader is ready once the caches of all informers are synced, and unhealthy if one stops
+	name := c.resource
+	if c.namespace != v1.NamespaceAll {
+		name += " in namespace " + c.namespace
+	}
+	health.Default.AddInformer(name, c.informer.HasSynced)
+	diagnostics.Publish("queue "+name, func() interface{} {
+		return c.queue.Len()
+	})
+	diagnostics.Publish("cache "+name, func() interface{} {
+		return len(c.indexer.ListKeys())
+	})
+	go func() {
+		c.informer.Run(stopCh)
+		health.Default.InformerStopped(name)
+	}()
 
 	// Wait for all involved caches to be synced, before processing items from the queue is started
 	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
 		runtime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
 		return
 	}
+	atomic.StoreInt32(&c.synced, 1)
+	if options.SyncOnStart && !c.updatesOnly {
+		go c.reconcile(stopCh)
+	}
+	if options.ResyncPeriod > 0 && !c.updatesOnly {
+		go c.resync(options.ResyncPeriod, stopCh)
+	}
 
+	var workers sync.WaitGroup
 	for i := 0; i < threadiness; i++ {
-		go wait.Until(c.runWorker, time.Second, stopCh)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			wait.Until(c.runWorker, time.Second, stopCh)
+		}()
 	}
 
 	<-stopCh
 	logrus.Infof("Stopping Controller")
+	c.drain(&workers, options.ShutdownTimeout)
+}
+
+// drain lets the workers handle the changes already queued, and the pending debounced updates, before
+// the controller stops. It returns once the queue is empty or after the timeout
+func (c *Controller) drain(workers *sync.WaitGroup, timeout time.Duration) {
+	c.flushDebounced()
+	logrus.Infof("Draining %d changes of resource type %s", c.queue.Len(), c.resource)
+	c.queue.ShutDown()
+
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		logrus.Warnf("Stopped draining the changes of resource type %s at the shutdown timeout, dropping %d queued changes", c.resource, c.queue.Len())
+	}
+}
+
+// resync reconciles the resources at every period until stopCh is closed
+func (c *Controller) resync(period time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.reconcile(stopCh)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// reconcile reloads the workloads holding an outdated hash of any of the resources of the informer
+func (c *Controller) reconcile(stopCh <-chan struct{}) {
+	objects := c.indexer.List()
+	logrus.Infof("Syncing the workloads with %d resources of type %s with %d workers", len(objects), c.resource, options.ReconcileWorkers)
+	reconcile(objects, options.ReconcileWorkers, func(obj interface{}) {
+		if c.resourceInIgnoredNamespace(obj) {
+			return
+		}
+		span := c.eventSpan("sync", obj)
+		err := handler.ResourceSyncedHandler{
+			Resource:   obj,
+			Collectors: c.collectors,
+			Span:       span,
+		}.Handle()
+		span.End(err)
+		if err != nil {
+			logrus.Errorf("Error reconciling resource: %v", err)
+		}
+	}, stopCh)
 }
 
 func (c *Controller) runWorker() {
@@ -125,15 +370,27 @@ func (c *Controller) processNextItem() bool {
 	if quit {
 		return false
 	}
+	c.collectors.SetQueueDepth(c.resource, c.namespace, c.queue.Len())
 	// Tell the queue that we are done with processing this key. This unblocks the key for other workers
 	// This allows safe parallel processing because two events with the same key are never processed in
 	// parallel.
 	defer c.queue.Done(resourceHandler)
 
+	item := resourceHandler
+	if key, debounced := resourceHandler.(debounceKey); debounced {
+		update, found := c.takeDebounced(key)
+		if !found {
+			return true
+		}
+		// retries are queued with the merged update, they must not wait for a debounce window again
+		c.queue.Forget(key)
+		item = update
+	}
+
 	// Invoke the method containing the business logic
-	err := resourceHandler.(handler.ResourceHandler).Handle()
+	err := item.(handler.ResourceHandler).Handle()
 	// Handle the error if something went wrong during the execution of the business logic
-	c.handleErr(err, resourceHandler)
+	c.handleErr(err, item)
 	return true
 }
 
@@ -144,21 +401,75 @@ func (c *Controller) handleErr(err error, key interface{}) {
 		// This ensures that future processing of updates for this key is not delayed because of
 		// an outdated error history.
 		c.queue.Forget(key)
+		eventSpanOf(key).End(nil)
 		return
 	}
 
-	// This controller retries 5 times if something goes wrong. After that, it stops trying.
-	if c.queue.NumRequeues(key) < 5 {
+	// A newer change of the resource is handled instead, retrying would reload an outdated state
+	if c.superseded(key) {
+		c.queue.Forget(key)
+		c.collectors.CountRetry(c.resource, metrics.RetrySuperseded)
+		eventSpanOf(key).SetAttribute("superseded", "true")
+		eventSpanOf(key).End(err)
+		logrus.Infof("Not retrying the key %q, its resource changed again: %v", key, err)
+		return
+	}
+
+	// This controller retries options.MaxRetries times if something goes wrong. After that, it stops trying.
+	// Retries are not queued anymore once it is shutting down.
+	if c.queue.NumRequeues(key) < options.MaxRetries && !c.queue.ShuttingDown() {
 		logrus.Errorf("Error syncing events %v: %v", key, err)
 
 		// Re-enqueue the key rate limited. Based on the rate limiter on the
 		// queue and the re-enqueue history, the key will be processed later again.
 		c.queue.AddRateLimited(key)
+		c.collectors.CountRetry(c.resource, metrics.RetryRetried)
+		eventSpanOf(key).SetAttribute("retries", strconv.Itoa(c.queue.NumRequeues(key)))
 		return
 	}
 
 	c.queue.Forget(key)
+	c.collectors.CountRetry(c.resource, metrics.RetryDropped)
+	eventSpanOf(key).End(err)
 	// Report to an external entity that, even after several retries, we could not successfully process this key
 	runtime.HandleError(err)
 	logrus.Infof("Dropping the key %q out of the queue: %v", key, err)
 }
+
+// superseded checks whether the resource of the handler changed since, according to the informer, so
+// the handler of the newer change is queued
+func (c *Controller) superseded(item interface{}) bool {
+	if c.indexer == nil {
+		return false
+	}
+	var resource interface{}
+	deleted := false
+	switch resourceHandler := item.(type) {
+	case handler.ResourceCreatedHandler:
+		resource = resourceHandler.Resource
+	case handler.ResourceUpdatedHandler:
+		resource = resourceHandler.Resource
+	case handler.ResourceDeletedHandler:
+		resource = resourceHandler.Resource
+		deleted = true
+	default:
+		return false
+	}
+	accessor, err := meta.Accessor(resource)
+	if err != nil {
+		return false
+	}
+	current, exists, err := c.indexer.Get(resource)
+	if err != nil {
+		return false
+	}
+	if deleted {
+		// the resource was created again
+		return exists
+	}
+	if !exists {
+		return true
+	}
+	currentAccessor, err := meta.Accessor(current)
+	return err == nil && currentAccessor.GetResourceVersion() != accessor.GetResourceVersion()
+}
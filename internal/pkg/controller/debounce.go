@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/handler"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"k8s.io/client-go/tools/cache"
+)
+
+// debounceKey is queued for the updates of a resource within the debounce window, the update
+// handler is looked up when the key is processed
+type debounceKey string
+
+// debounce queues the update once per debounce window of the resource. Updates within the window
+// are merged into a single update from the resource before the first to the one after the last
+func (c *Controller) debounce(update handler.ResourceUpdatedHandler, window time.Duration) {
+	key, err := cache.MetaNamespaceKeyFunc(update.Resource)
+	if err != nil {
+		logrus.Errorf("Unable to debounce update, handling it immediately: %v", err)
+		c.enqueue(update)
+		return
+	}
+
+	c.debounceLock.Lock()
+	defer c.debounceLock.Unlock()
+	if c.debounced == nil {
+		c.debounced = map[debounceKey]handler.ResourceUpdatedHandler{}
+	}
+	pending, found := c.debounced[debounceKey(key)]
+	if found {
+		update.OldResource = pending.OldResource
+		// the merged update is traced from the first of the updates
+		update.Span.SetAttribute("debounced", "merged into trace "+pending.Span.TraceID())
+		update.Span.End(nil)
+		update.Span = pending.Span
+		c.collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonDebounced}).Inc()
+	}
+	c.debounced[debounceKey(key)] = update
+	if !found {
+		c.queue.AddAfter(debounceKey(key), window)
+	}
+}
+
+// takeDebounced removes and returns the merged update of the key
+func (c *Controller) takeDebounced(key debounceKey) (handler.ResourceUpdatedHandler, bool) {
+	c.debounceLock.Lock()
+	defer c.debounceLock.Unlock()
+	update, found := c.debounced[key]
+	delete(c.debounced, key)
+	return update, found
+}
+
+// flushDebounced queues the pending debounced updates to be handled without waiting for the end of their
+// debounce window
+func (c *Controller) flushDebounced() {
+	c.debounceLock.Lock()
+	defer c.debounceLock.Unlock()
+	for key := range c.debounced {
+		c.queue.Add(key)
+	}
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestUpdateDebouncesConsecutiveUpdates(t *testing.T) {
+	reloadDebounce := options.ReloadDebounce
+	options.ReloadDebounce = 100 * time.Millisecond
+	defer func() { options.ReloadDebounce = reloadDebounce }()
+
+	c := &Controller{resource: "configMaps", queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()), collectors: metrics.NewCollectors()}
+	defer c.queue.ShutDown()
+	versions := make([]*v1.ConfigMap, 4)
+	for i := range versions {
+		versions[i] = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pipeline-config", Namespace: "default", ResourceVersion: strconv.Itoa(i)}}
+	}
+	for i := 1; i < len(versions); i++ {
+		c.Update(versions[i-1], versions[i])
+	}
+	if c.queue.Len() != 0 {
+		t.Errorf("Expected updates to wait for the debounce window, got %d queued", c.queue.Len())
+	}
+
+	item, _ := c.queue.Get()
+	key, debounced := item.(debounceKey)
+	if !debounced || key != "default/pipeline-config" {
+		t.Fatalf("Expected the debounce key of the configmap to be queued, got %v", item)
+	}
+	update, found := c.takeDebounced(key)
+	if !found || update.OldResource != versions[0] || update.Resource != versions[3] {
+		t.Errorf("Expected a single update from the first to the last version, got %v to %v", update.OldResource, update.Resource)
+	}
+	if _, found := c.takeDebounced(key); found {
+		t.Errorf("Merged update was taken twice")
+	}
+	if merged := promtestutil.ToFloat64(c.collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonDebounced})); merged != 2 {
+		t.Errorf("Expected 2 merged updates to be counted, got %v", merged)
+	}
+}
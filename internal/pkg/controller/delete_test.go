@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/handler"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestDeleteQueuesDeletedSourceOnlyIfEnabled(t *testing.T) {
+	reloadOnSourceDelete := options.ReloadOnSourceDelete
+	defer func() { options.ReloadOnSourceDelete = reloadOnSourceDelete }()
+
+	configmap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "deleted", Namespace: "default"}}
+	for _, enabled := range []bool{true, false} {
+		options.ReloadOnSourceDelete = enabled
+		c := &Controller{resource: "configMaps", queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()), collectors: metrics.NewCollectors(), synced: 1}
+		c.Delete(configmap)
+		if events := promtestutil.ToFloat64(c.collectors.Events.With(prometheus.Labels{"resource": "configMaps", "event": "delete"})); events != 1 {
+			t.Errorf("Expected the delete event to be counted once, got %v", events)
+		}
+
+		expected := 0
+		if enabled {
+			expected = 1
+		}
+		if c.queue.Len() != expected {
+			t.Errorf("With reload-on-delete %v, expected %d queued deletions, got %d", enabled, expected, c.queue.Len())
+		}
+		c.queue.ShutDown()
+	}
+}
+
+func TestDeleteUnwrapsTombstone(t *testing.T) {
+	reloadOnSourceDelete := options.ReloadOnSourceDelete
+	defer func() { options.ReloadOnSourceDelete = reloadOnSourceDelete }()
+	options.ReloadOnSourceDelete = true
+
+	configmap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "deleted", Namespace: "default"}}
+	c := &Controller{resource: "configMaps", queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()), collectors: metrics.NewCollectors(), synced: 1}
+	defer c.queue.ShutDown()
+	c.Delete(cache.DeletedFinalStateUnknown{Key: "default/deleted", Obj: configmap})
+
+	item, _ := c.queue.Get()
+	deleted, ok := item.(handler.ResourceDeletedHandler)
+	if !ok || deleted.Resource != configmap {
+		t.Errorf("Expected the deletion of the last known state of the configmap to be queued, got %v", item)
+	}
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestExternalSecretControllerQueuesOnlyUpdates(t *testing.T) {
+	reloadOnSourceDelete, reloadDebounce := options.ReloadOnSourceDelete, options.ReloadDebounce
+	defer func() { options.ReloadOnSourceDelete, options.ReloadDebounce = reloadOnSourceDelete, reloadDebounce }()
+	options.ReloadOnSourceDelete = true
+	options.ReloadDebounce = 0
+
+	externalSecret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "ExternalSecret",
+		"metadata":   map[string]interface{}{"name": "database", "namespace": "default"},
+		"status":     map[string]interface{}{"refreshTime": "2026-10-15T10:00:00Z"},
+	}}
+	c, err := NewExternalSecretController(fake.NewSimpleDynamicClient(runtime.NewScheme()), "default", []string{}, metrics.NewCollectors())
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	defer c.queue.ShutDown()
+	c.synced = 1
+
+	// the secret synced by the external secret is watched for creations and deletions
+	c.Add(externalSecret)
+	c.Delete(externalSecret)
+	if c.queue.Len() != 0 {
+		t.Errorf("Expected no queued creations or deletions of external secrets, got %d", c.queue.Len())
+	}
+
+	refreshed := externalSecret.DeepCopy()
+	refreshed.Object["status"] = map[string]interface{}{"refreshTime": "2026-10-15T11:00:00Z"}
+	c.Update(externalSecret, refreshed)
+	if c.queue.Len() != 1 {
+		t.Errorf("Expected the update of the external secret to be queued, got %d queued changes", c.queue.Len())
+	}
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ForeignSources watches the namespaces of the configmaps and secrets that workloads reference in
+// other namespaces than the watched ones, once they are first referenced
+type ForeignSources struct {
+	// Watched are the namespaces whose configmaps and secrets are already watched
+	Watched util.List
+	// Ignored are the namespaces whose configmaps and secrets are never watched
+	Ignored util.List
+	// Referenced returns the namespaces of the configmaps and secrets the workloads reference
+	Referenced func() []string
+	// Watch starts watching the configmaps and secrets of the namespace, if nil the namespaces
+	// that are not watched are only logged
+	Watch func(namespace string)
+	seen  map[string]bool
+}
+
+// Run looks for newly referenced namespaces every interval until the stop channel is closed, or
+// only once if the interval is not positive
+func (f *ForeignSources) Run(interval time.Duration, stopCh <-chan struct{}) {
+	if interval <= 0 {
+		f.sync()
+		return
+	}
+	wait.Until(f.sync, interval, stopCh)
+}
+
+// sync watches, or logs, each referenced namespace that is not watched yet once
+func (f *ForeignSources) sync() {
+	if f.seen == nil {
+		f.seen = map[string]bool{}
+	}
+	for _, namespace := range f.Referenced() {
+		if f.seen[namespace] || f.Watched.Contains(namespace) {
+			continue
+		}
+		f.seen[namespace] = true
+		if f.Ignored.Contains(namespace) {
+			logrus.Warnf("Workloads reference configmaps or secrets of ignored namespace '%s', their changes cannot be watched", namespace)
+		} else if f.Watch == nil {
+			logrus.Warnf("Workloads reference configmaps or secrets of namespace '%s', which is not watched, add it to --cross-namespace-source-namespaces to reload them on changes", namespace)
+		} else {
+			logrus.Infof("Workloads reference configmaps or secrets of namespace '%s', watching it", namespace)
+			f.Watch(namespace)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForeignSourcesWatchesEachReferencedNamespaceOnce(t *testing.T) {
+	referenced := []string{"shared", "payments", "kube-system"}
+	var watched []string
+	f := &ForeignSources{
+		Watched:    []string{"payments"},
+		Ignored:    []string{"kube-system"},
+		Referenced: func() []string { return referenced },
+		Watch:      func(namespace string) { watched = append(watched, namespace) },
+	}
+	f.sync()
+	referenced = append(referenced, "vault")
+	f.sync()
+
+	if expected := []string{"shared", "vault"}; !reflect.DeepEqual(watched, expected) {
+		t.Errorf("Expected the namespaces %v to be watched, got %v", expected, watched)
+	}
+}
+
+func TestForeignSourcesOnlyLogsWithoutWatch(t *testing.T) {
+	f := &ForeignSources{
+		Referenced: func() []string { return []string{"shared"} },
+	}
+	// without watch, the referenced namespaces are only logged
+	f.Run(0, nil)
+	if !f.seen["shared"] {
+		t.Errorf("Expected the referenced namespace to be logged")
+	}
+}
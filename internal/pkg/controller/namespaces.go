@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"github.com/stakater/Reloader/internal/pkg/util"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WatchedNamespaces returns the namespaces a controller is created for per resource, each with
+// its own informer. These are the namespaces to watch without the ignored ones, or the current
+// namespace if none are given, which is all namespaces if empty
+func WatchedNamespaces(namespacesToWatch []string, currentNamespace string, ignoredNamespaces util.List) []string {
+	if len(namespacesToWatch) == 0 {
+		return []string{currentNamespace}
+	}
+
+	namespaces := []string{}
+	seen := map[string]bool{}
+	for _, namespace := range namespacesToWatch {
+		if namespace == v1.NamespaceAll || seen[namespace] || ignoredNamespaces.Contains(namespace) {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
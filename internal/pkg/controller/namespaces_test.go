@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/util"
+)
+
+func TestWatchedNamespaces(t *testing.T) {
+	tests := []struct {
+		name              string
+		namespacesToWatch []string
+		currentNamespace  string
+		ignored           util.List
+		want              []string
+	}{
+		{name: "all namespaces", want: []string{""}},
+		{name: "current namespace", currentNamespace: "reloader", want: []string{"reloader"}},
+		{name: "watched namespaces replace the current one", namespacesToWatch: []string{"payments", "orders"}, currentNamespace: "reloader", want: []string{"payments", "orders"}},
+		{name: "ignored and duplicate namespaces are dropped", namespacesToWatch: []string{"payments", "orders", "payments", ""}, ignored: util.List{"orders"}, want: []string{"payments"}},
+	}
+	for _, test := range tests {
+		if got := WatchedNamespaces(test.namespacesToWatch, test.currentNamespace, test.ignored); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected namespaces %v, got %v", test.name, test.want, got)
+		}
+	}
+}
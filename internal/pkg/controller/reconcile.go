@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"sync"
+)
+
+// reconcile calls handle for every object using the given number of workers. It returns once all
+// objects have been handled, or early once stopCh is closed and the in-flight objects are done
+func reconcile(objects []interface{}, workers int, handle func(interface{}), stopCh <-chan struct{}) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	work := make(chan interface{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range work {
+				handle(obj)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(work)
+
+	for _, obj := range objects {
+		select {
+		case work <- obj:
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconcileUsesConfiguredConcurrency(t *testing.T) {
+	const workers = 8
+	objects := make([]interface{}, 2000)
+	for i := range objects {
+		objects[i] = i
+	}
+
+	var handled, inFlight, maxInFlight int32
+	handle := func(obj interface{}) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		atomic.AddInt32(&handled, 1)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		reconcile(objects, workers, handle, make(chan struct{}))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Reconciliation of %d objects did not complete in time", len(objects))
+	}
+
+	if int(handled) != len(objects) {
+		t.Errorf("Expected %d handled objects, got %d", len(objects), handled)
+	}
+	if maxInFlight > workers {
+		t.Errorf("Expected at most %d concurrent handlers, got %d", workers, maxInFlight)
+	}
+	if maxInFlight < 2 {
+		t.Errorf("Expected objects to be handled concurrently, got %d concurrent handlers", maxInFlight)
+	}
+}
+
+func TestReconcileStopsWhenStopped(t *testing.T) {
+	objects := make([]interface{}, 1000)
+	for i := range objects {
+		objects[i] = i
+	}
+
+	stopCh := make(chan struct{})
+	var handled int32
+	handle := func(obj interface{}) {
+		if atomic.AddInt32(&handled, 1) == 10 {
+			close(stopCh)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		reconcile(objects, 2, handle, stopCh)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Reconciliation did not stop in time")
+	}
+
+	if int(atomic.LoadInt32(&handled)) == len(objects) {
+		t.Errorf("Expected reconciliation to stop before handling all objects")
+	}
+}
+
+func BenchmarkReconcile(b *testing.B) {
+	objects := make([]interface{}, 10000)
+	for i := range objects {
+		objects[i] = i
+	}
+	for i := 0; i < b.N; i++ {
+		reconcile(objects, 5, func(interface{}) {}, make(chan struct{}))
+	}
+}
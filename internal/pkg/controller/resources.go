@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"sort"
+
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+)
+
+// WatchedResources returns the resource types a controller is created for per namespace, sorted.
+// These are the resources to watch without the ignored ones, or all resource types if none are given
+func WatchedResources(resourcesToWatch []string, ignoredResources util.List) []string {
+	watched := util.List(resourcesToWatch)
+	resources := []string{}
+	for resource := range kube.ResourceMap {
+		if len(resourcesToWatch) > 0 && !watched.Contains(resource) {
+			continue
+		}
+		if ignoredResources.Contains(resource) {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	return resources
+}
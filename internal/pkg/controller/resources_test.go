@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/util"
+)
+
+func TestWatchedResources(t *testing.T) {
+	tests := []struct {
+		name             string
+		resourcesToWatch []string
+		ignored          util.List
+		want             []string
+	}{
+		{name: "all resources", want: []string{"configMaps", "secrets"}},
+		{name: "scoped to configmaps ignores secrets", resourcesToWatch: []string{"configMaps"}, want: []string{"configMaps"}},
+		{name: "scoped to secrets ignores configmaps", resourcesToWatch: []string{"secrets"}, want: []string{"secrets"}},
+		{name: "ignored resources are dropped", ignored: util.List{"secrets"}, want: []string{"configMaps"}},
+	}
+	for _, test := range tests {
+		if got := WatchedResources(test.resourcesToWatch, test.ignored); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: expected resources %v, got %v", test.name, test.want, got)
+		}
+	}
+}
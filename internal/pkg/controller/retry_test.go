@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/handler"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestHandleErrRetriesWithBackoff(t *testing.T) {
+	maxRetries := options.MaxRetries
+	options.MaxRetries = 2
+	defer func() { options.MaxRetries = maxRetries }()
+
+	c := &Controller{resource: "configMaps", indexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}), queue: workqueue.NewRateLimitingQueue(retryRateLimiter()), collectors: metrics.NewCollectors()}
+	defer c.queue.ShutDown()
+	configmap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pipeline-config", Namespace: "default", ResourceVersion: "1"}}
+	if err := c.indexer.Add(configmap); err != nil {
+		t.Fatalf("Failed to add configmap to the indexer: %v", err)
+	}
+	update := handler.ResourceUpdatedHandler{Resource: configmap, OldResource: configmap, Collectors: c.collectors}
+
+	for i := 0; i < 3; i++ {
+		c.handleErr(errors.New("conflict"), update)
+	}
+	if requeues := c.queue.NumRequeues(update); requeues != 0 {
+		t.Errorf("Expected the dropped update to be forgotten, got %d requeues", requeues)
+	}
+	if retried := promtestutil.ToFloat64(c.collectors.Retries.With(prometheus.Labels{"resource": "configMaps", "result": metrics.RetryRetried})); retried != 2 {
+		t.Errorf("Expected 2 retries to be counted, got %v", retried)
+	}
+	if dropped := promtestutil.ToFloat64(c.collectors.Retries.With(prometheus.Labels{"resource": "configMaps", "result": metrics.RetryDropped})); dropped != 1 {
+		t.Errorf("Expected 1 dropped update to be counted, got %v", dropped)
+	}
+
+	changed := configmap.DeepCopy()
+	changed.ResourceVersion = "2"
+	if err := c.indexer.Update(changed); err != nil {
+		t.Fatalf("Failed to update configmap in the indexer: %v", err)
+	}
+	c.handleErr(errors.New("conflict"), update)
+	if superseded := promtestutil.ToFloat64(c.collectors.Retries.With(prometheus.Labels{"resource": "configMaps", "result": metrics.RetrySuperseded})); superseded != 1 {
+		t.Errorf("Expected the update of the changed configmap not to be retried, got %v superseded", superseded)
+	}
+	if requeues := c.queue.NumRequeues(update); requeues != 0 {
+		t.Errorf("Expected the superseded update not to be requeued, got %d requeues", requeues)
+	}
+}
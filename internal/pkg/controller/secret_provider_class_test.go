@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSecretProviderClassControllerListsClasses(t *testing.T) {
+	class := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "secrets-store.csi.x-k8s.io/v1",
+		"kind":       "SecretProviderClass",
+		"metadata":   map[string]interface{}{"name": "vault", "namespace": "default"},
+		"spec":       map[string]interface{}{"provider": "vault"},
+	}}
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(), class)
+	c, err := NewSecretProviderClassController(client, "default", []string{}, metrics.NewCollectors())
+	if err != nil {
+		t.Fatalf("Failed to create controller: %v", err)
+	}
+	defer c.queue.ShutDown()
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go c.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		t.Fatalf("Timed out waiting for the cache to sync")
+	}
+	objects := c.indexer.List()
+	if len(objects) != 1 {
+		t.Fatalf("Expected the secret provider class to be listed, got %v", objects)
+	}
+	if listed, ok := objects[0].(*unstructured.Unstructured); !ok || listed.GetName() != "vault" {
+		t.Errorf("Expected the secret provider class 'vault', got %v", objects[0])
+	}
+}
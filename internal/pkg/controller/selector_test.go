@@ -0,0 +1,22 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/options"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTweakListOptionsPushesResourceLabelSelector(t *testing.T) {
+	resourceLabelSelector := options.ResourceLabelSelector
+	defer func() { options.ResourceLabelSelector = resourceLabelSelector }()
+
+	for _, selector := range []string{"", "reloader=enabled,team in (payments)"} {
+		options.ResourceLabelSelector = selector
+		listOptions := metav1.ListOptions{}
+		tweakListOptions(&listOptions)
+		if listOptions.LabelSelector != selector {
+			t.Errorf("Expected label selector %q in the list options, got %q", selector, listOptions.LabelSelector)
+		}
+	}
+}
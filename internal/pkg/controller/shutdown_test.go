@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestDrainHandlesQueuedAndDebouncedChanges(t *testing.T) {
+	reloadDebounce := options.ReloadDebounce
+	options.ReloadDebounce = time.Hour
+	defer func() { options.ReloadDebounce = reloadDebounce }()
+
+	c := &Controller{resource: "configMaps", queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()), collectors: metrics.NewCollectors()}
+	c.enqueue("queued")
+	old := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pipeline-config", Namespace: "default", ResourceVersion: "1"}}
+	c.Update(old, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pipeline-config", Namespace: "default", ResourceVersion: "2"}})
+
+	var handled []interface{}
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		for {
+			item, quit := c.queue.Get()
+			if quit {
+				return
+			}
+			handled = append(handled, item)
+			c.queue.Done(item)
+		}
+	}()
+	c.drain(&workers, 5*time.Second)
+
+	if len(handled) != 2 || handled[0] != "queued" || handled[1] != debounceKey("default/pipeline-config") {
+		t.Errorf("Expected the queued change and the debounced update to be handled, got %v", handled)
+	}
+}
+
+func TestDrainStopsAtShutdownTimeout(t *testing.T) {
+	c := &Controller{resource: "configMaps", queue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()), collectors: metrics.NewCollectors()}
+	c.enqueue("stuck")
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		item, _ := c.queue.Get()
+		<-blocked
+		c.queue.Done(item)
+	}()
+
+	start := time.Now()
+	c.drain(&workers, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected draining to stop at the shutdown timeout, took %v", elapsed)
+	}
+}
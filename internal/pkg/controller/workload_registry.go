@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"github.com/stakater/Reloader/internal/pkg/handler"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WatchWorkloadRegistry watches the workload registry configmap and reconfigures the registered kinds
+// of custom workloads on each change until the stop channel is closed
+func WatchWorkloadRegistry(client kubernetes.Interface, namespace string, name string, stopCh <-chan struct{}) {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	listWatcher := &cache.ListWatch{
+		ListFunc: func(listOptions metav1.ListOptions) (apiruntime.Object, error) {
+			listOptions.FieldSelector = selector
+			return client.CoreV1().ConfigMaps(namespace).List(listOptions)
+		},
+		WatchFunc: func(listOptions metav1.ListOptions) (watch.Interface, error) {
+			listOptions.FieldSelector = selector
+			return client.CoreV1().ConfigMaps(namespace).Watch(listOptions)
+		},
+	}
+	apply := func(obj interface{}) {
+		if configmap, ok := obj.(*v1.ConfigMap); ok && configmap.Name == name {
+			handler.ApplyWorkloadRegistry(client, configmap)
+		}
+	}
+	_, informer := cache.NewInformer(listWatcher, &v1.ConfigMap{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc:    apply,
+		UpdateFunc: func(old interface{}, new interface{}) { apply(new) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if configmap, ok := obj.(*v1.ConfigMap); ok && configmap.Name == name {
+				handler.ApplyWorkloadRegistry(client, nil)
+			}
+		},
+	})
+	informer.Run(stopCh)
+}
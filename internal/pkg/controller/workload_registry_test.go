@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/handler"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchWorkloadRegistryReconfiguresCustomWorkloads(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	stopCh := make(chan struct{})
+	defer handler.ApplyWorkloadRegistry(nil, nil)
+	defer close(stopCh)
+	go WatchWorkloadRegistry(client, "reloader", "reloader-workloads", stopCh)
+
+	registry := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "reloader-workloads", Namespace: "reloader"},
+		Data:       map[string]string{"flink": "flinkdeployments.v1beta1.flink.apache.org=.spec.podTemplate"},
+	}
+	if _, err := client.CoreV1().ConfigMaps("reloader").Create(registry); err != nil {
+		t.Fatalf("Failed to create the workload registry: %v", err)
+	}
+	registered := func(count int) func() (bool, error) {
+		return func() (bool, error) { return len(handler.RegisteredWorkloadKinds()) == count, nil }
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, registered(1)); err != nil {
+		t.Fatalf("Expected the kind of the workload registry to be registered, got %v", handler.RegisteredWorkloadKinds())
+	}
+
+	registry.Data["spark"] = "sparkapplications.v1beta2.sparkoperator.k8s.io=.spec.driver.template"
+	if _, err := client.CoreV1().ConfigMaps("reloader").Update(registry); err != nil {
+		t.Fatalf("Failed to update the workload registry: %v", err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, registered(2)); err != nil {
+		t.Fatalf("Expected the kind added to the workload registry to be registered, got %v", handler.RegisteredWorkloadKinds())
+	}
+
+	if err := client.CoreV1().ConfigMaps("reloader").Delete(registry.Name, &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete the workload registry: %v", err)
+	}
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, registered(0)); err != nil {
+		t.Errorf("Expected the kinds to be removed with the workload registry, got %v", handler.RegisteredWorkloadKinds())
+	}
+}
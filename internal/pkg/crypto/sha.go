@@ -2,15 +2,79 @@ package crypto
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
 	"io"
+	"sort"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// SHA1 is the algorithm of the SHAs of earlier releases
+	SHA1 = "sha1"
+	// SHA256 is the default algorithm
+	SHA256 = "sha256"
+	// SHA512 is the SHA-512 algorithm
+	SHA512 = "sha512"
+	// LegacyAlgorithm is the algorithm the SHAs held by workloads reloaded by earlier releases were
+	// generated with
+	LegacyAlgorithm = SHA1
+)
+
+// hashers are the hash functions of the algorithms SHAs can be generated with
+var hashers = map[string]func() hash.Hash{
+	SHA1:   sha1.New,
+	SHA256: sha256.New,
+	SHA512: sha512.New,
+}
+
+// fipsApproved are the algorithms allowed in FIPS mode
+var fipsApproved = map[string]bool{
+	SHA256: true,
+	SHA512: true,
+}
+
+var algorithm = SHA256
+
+// SetAlgorithm sets the algorithm SHAs are generated with, which must be FIPS approved in FIPS mode
+func SetAlgorithm(name string, fips bool) error {
+	if _, found := hashers[name]; !found {
+		return fmt.Errorf("unknown hash algorithm '%s', expected one of %s", name, strings.Join(Algorithms(), ", "))
+	}
+	if fips && !fipsApproved[name] {
+		return fmt.Errorf("hash algorithm '%s' is not approved in FIPS mode", name)
+	}
+	algorithm = name
+	return nil
+}
+
+// Algorithm returns the algorithm SHAs are generated with
+func Algorithm() string {
+	return algorithm
+}
+
+// Algorithms returns the names of the known algorithms
+func Algorithms() []string {
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GenerateSHA generates SHA from string
 func GenerateSHA(data string) string {
-	hasher := sha1.New()
+	return GenerateSHAWith(algorithm, data)
+}
+
+// GenerateSHAWith generates SHA from string with the given algorithm
+func GenerateSHAWith(algorithm string, data string) string {
+	hasher := hashers[algorithm]()
 	_, err := io.WriteString(hasher, data)
 	if err != nil {
 		logrus.Errorf("Unable to write data in hash writer %v", err)
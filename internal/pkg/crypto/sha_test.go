@@ -7,9 +7,44 @@ import (
 // TestGenerateSHA generates the sha from given data and verifies whether it is correct or not
 func TestGenerateSHA(t *testing.T) {
 	data := "www.stakater.com"
-	sha := "abd4ed82fb04548388a6cf3c339fd9dc84d275df"
+	sha := "bfe96a7c834576eef1b9a307159b607f0edceef48fc92d3db563ec0a07a90082"
 	result := GenerateSHA(data)
 	if result != sha {
 		t.Errorf("Failed to generate SHA")
 	}
 }
+
+// TestGenerateSHAWithLegacyAlgorithm verifies the SHAs of earlier releases are still generated
+func TestGenerateSHAWithLegacyAlgorithm(t *testing.T) {
+	data := "www.stakater.com"
+	sha := "abd4ed82fb04548388a6cf3c339fd9dc84d275df"
+	result := GenerateSHAWith(LegacyAlgorithm, data)
+	if result != sha {
+		t.Errorf("Failed to generate legacy SHA")
+	}
+}
+
+func TestSetAlgorithm(t *testing.T) {
+	defer func() {
+		algorithm = SHA256
+	}()
+
+	if err := SetAlgorithm(SHA512, true); err != nil {
+		t.Errorf("Setting a FIPS approved algorithm in FIPS mode failed: %v", err)
+	}
+	if len(GenerateSHA("www.stakater.com")) != 128 {
+		t.Errorf("SHA was not generated with the set algorithm")
+	}
+	if err := SetAlgorithm(SHA1, true); err == nil {
+		t.Errorf("Setting an algorithm that is not FIPS approved in FIPS mode did not fail")
+	}
+	if err := SetAlgorithm(SHA1, false); err != nil {
+		t.Errorf("Setting the legacy algorithm failed: %v", err)
+	}
+	if err := SetAlgorithm("md5", false); err == nil {
+		t.Errorf("Setting an unknown algorithm did not fail")
+	}
+	if Algorithm() != SHA1 {
+		t.Errorf("Failed setting an algorithm changed the algorithm")
+	}
+}
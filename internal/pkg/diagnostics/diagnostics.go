@@ -0,0 +1,64 @@
+package diagnostics
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+)
+
+// Enabled serves the pprof handlers and the runtime variables along with the metrics
+var Enabled = false
+
+// values are the variables of Reloader, e.g. the lengths of its queues and the sizes of its caches,
+// served as "reloader" at /debug/vars
+var values = struct {
+	lock   sync.Mutex
+	values map[string]func() interface{}
+}{values: map[string]func() interface{}{}}
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("reloader", expvar.Func(snapshot))
+}
+
+// Publish serves the value returned by value as the variable of the name, replacing the variable
+// published before with the name
+func Publish(name string, value func() interface{}) {
+	values.lock.Lock()
+	defer values.lock.Unlock()
+	values.values[name] = value
+}
+
+// snapshot returns the current values of the published variables
+func snapshot() interface{} {
+	values.lock.Lock()
+	published := make(map[string]func() interface{}, len(values.values))
+	for name, value := range values.values {
+		published[name] = value
+	}
+	values.lock.Unlock()
+
+	current := make(map[string]interface{}, len(published))
+	for name, value := range published {
+		current[name] = value()
+	}
+	return current
+}
+
+// Register serves the pprof handlers at /debug/pprof/ and the runtime variables at /debug/vars on the
+// mux, if enabled
+func Register(mux *http.ServeMux) {
+	if !Enabled {
+		return
+	}
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
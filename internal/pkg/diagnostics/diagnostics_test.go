@@ -0,0 +1,49 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterServesNothingUnlessEnabled(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected no runtime variables unless enabled, got status %d", recorder.Code)
+	}
+}
+
+func TestRegisterServesPublishedVariables(t *testing.T) {
+	Enabled = true
+	defer func() { Enabled = false }()
+	mux := http.NewServeMux()
+	Register(mux)
+
+	Publish("queue configMaps", func() interface{} { return 1 })
+	Publish("queue configMaps", func() interface{} { return 3 })
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars struct {
+		Goroutines int            `json:"goroutines"`
+		Reloader   map[string]int `json:"reloader"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Failed to decode the runtime variables: %v", err)
+	}
+	if vars.Goroutines == 0 {
+		t.Errorf("Expected the goroutine count to be served")
+	}
+	if vars.Reloader["queue configMaps"] != 3 {
+		t.Errorf("Expected the latest published queue length, got %v", vars.Reloader)
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected the pprof index to be served, got status %d", recorder.Code)
+	}
+}
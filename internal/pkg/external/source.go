@@ -0,0 +1,19 @@
+package external
+
+// Change is a change of a secret of an external store, e.g. Vault or AWS Secrets Manager
+type Change struct {
+	// Type is the type of the store, the postfix of the env var of the reloaded workloads, e.g.
+	// constants.VaultEnvVarPostfix
+	Type string
+	// Name names the secret in the store
+	Name string
+	// Version identifies the change, a change delivered again has the same version and reloads no
+	// workload again
+	Version string
+}
+
+// Source delivers the changes of the secrets of an external store
+type Source interface {
+	// Watch delivers the changes to the channel until the stop channel is closed
+	Watch(changes chan<- Change, stopCh <-chan struct{})
+}
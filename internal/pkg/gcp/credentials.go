@@ -0,0 +1,173 @@
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pubsubScope is the OAuth scope of the access tokens
+	pubsubScope = "https://www.googleapis.com/auth/pubsub"
+	// defaultMetadataURL is the metadata server of GCE and GKE
+	defaultMetadataURL = "http://metadata.google.internal"
+	// expiryWindow is the time before the expiry of access tokens they are renewed at
+	expiryWindow = 5 * time.Minute
+)
+
+// serviceAccountKey is the key file of a service account
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// tokenSource returns the access tokens of the service account of the key file if set, else of the
+// service account of the metadata server. Tokens are cached until shortly before they expire
+type tokenSource struct {
+	credentialsFile string
+	metadataURL     string
+	client          *http.Client
+
+	lock    sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// accessToken is the response of the token endpoints
+type accessToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (s *tokenSource) retrieve() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if time.Now().Add(expiryWindow).Before(s.expires) {
+		return s.token, nil
+	}
+
+	var token accessToken
+	var err error
+	if s.credentialsFile != "" {
+		token, err = s.serviceAccountToken()
+	} else {
+		token, err = s.metadataToken()
+	}
+	if err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("no access token was returned")
+	}
+	s.token = token.AccessToken
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// metadataToken requests a token of the service account of the metadata server
+func (s *tokenSource) metadataToken() (accessToken, error) {
+	metadataURL := s.metadataURL
+	if metadataURL == "" {
+		metadataURL = defaultMetadataURL
+	}
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(metadataURL, "/")+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return accessToken{}, err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	return s.requestToken(request)
+}
+
+// serviceAccountToken exchanges a JWT signed with the key of the service account for a token
+func (s *tokenSource) serviceAccountToken() (accessToken, error) {
+	data, err := ioutil.ReadFile(s.credentialsFile)
+	if err != nil {
+		return accessToken{}, fmt.Errorf("unable to read the credentials file: %v", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return accessToken{}, fmt.Errorf("invalid credentials file: %v", err)
+	}
+	assertion, err := signJWT(key, time.Now())
+	if err != nil {
+		return accessToken{}, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	request, err := http.NewRequest(http.MethodPost, key.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return accessToken{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.requestToken(request)
+}
+
+func (s *tokenSource) requestToken(request *http.Request) (accessToken, error) {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return accessToken{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return accessToken{}, fmt.Errorf("token request failed with status %s", response.Status)
+	}
+	var token accessToken
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return accessToken{}, fmt.Errorf("invalid token response: %v", err)
+	}
+	return token, nil
+}
+
+// signJWT signs the claims of a token request of the service account with its private key
+func signJWT(key serviceAccountKey, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", errors.New("the credentials file holds no PEM encoded private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %v", err)
+	}
+	privateKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("the private key is no RSA key")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"scope": pubsubScope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
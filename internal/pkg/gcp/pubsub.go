@@ -0,0 +1,165 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+// defaultEndpoint is the endpoint of the Pub/Sub API
+const defaultEndpoint = "https://pubsub.googleapis.com"
+
+// changingEvents are the event types of the notifications of GCP Secret Manager making a new
+// version of a secret available
+var changingEvents = map[string]bool{
+	"SECRET_VERSION_ADD":    true,
+	"SECRET_VERSION_ENABLE": true,
+}
+
+// Subscriber pulls the notifications of GCP Secret Manager from a Pub/Sub subscription of the topic
+// of the secrets and finds the new versions of secrets in them
+type Subscriber struct {
+	// Subscription is the resource name of the subscription, e.g.
+	// "projects/my-project/subscriptions/secret-events"
+	Subscription string
+	// CredentialsFile is the key file of the service account, the service account of the metadata
+	// server is used if empty
+	CredentialsFile string
+	// Endpoint is the endpoint of the Pub/Sub API, the public one if empty
+	Endpoint string
+	// MetadataURL is the address of the metadata server, the one of GCE and GKE if empty
+	MetadataURL string
+	// WaitTime is the time waited after a failed pull
+	WaitTime time.Duration
+	Client   *http.Client
+
+	tokens *tokenSource
+}
+
+// pubsubMessage is a message pulled from the subscription
+type pubsubMessage struct {
+	AckID   string `json:"ackId"`
+	Message struct {
+		MessageID  string            `json:"messageId"`
+		Attributes map[string]string `json:"attributes"`
+	} `json:"message"`
+}
+
+// Watch pulls the notifications until the stop channel is closed and delivers the changes to the
+// channel, named by the resource name of the secret and versioned by the ID of the message. The
+// messages are acknowledged once their changes are delivered, so changes are delivered at least once
+func (s *Subscriber) Watch(changes chan<- external.Change, stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	for {
+		messages, err := s.pull(ctx)
+		if err != nil && ctx.Err() == nil {
+			logrus.Errorf("Failed to pull notifications from %s: %v", s.Subscription, err)
+			select {
+			case <-time.After(s.WaitTime):
+			case <-stopCh:
+				return
+			}
+		}
+		var ackIDs []string
+		for _, message := range messages {
+			if change, found := parseNotification(message); found {
+				select {
+				case changes <- change:
+				case <-stopCh:
+					return
+				}
+			}
+			ackIDs = append(ackIDs, message.AckID)
+		}
+		if len(ackIDs) > 0 {
+			if err := s.acknowledge(ctx, ackIDs); err != nil && ctx.Err() == nil {
+				logrus.Errorf("Failed to acknowledge notifications of %s: %v", s.Subscription, err)
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+	}
+}
+
+func (s *Subscriber) pull(ctx context.Context) ([]pubsubMessage, error) {
+	var response struct {
+		ReceivedMessages []pubsubMessage `json:"receivedMessages"`
+	}
+	err := s.call(ctx, "pull", map[string]interface{}{"maxMessages": 10}, &response)
+	return response.ReceivedMessages, err
+}
+
+func (s *Subscriber) acknowledge(ctx context.Context, ackIDs []string) error {
+	return s.call(ctx, "acknowledge", map[string]interface{}{"ackIds": ackIDs}, &struct{}{})
+}
+
+// call calls the method of the subscription of the Pub/Sub API
+func (s *Subscriber) call(ctx context.Context, method string, input interface{}, output interface{}) error {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if s.tokens == nil {
+		s.tokens = &tokenSource{credentialsFile: s.CredentialsFile, metadataURL: s.MetadataURL, client: client}
+	}
+	token, err := s.tokens.retrieve()
+	if err != nil {
+		return fmt.Errorf("unable to get an access token: %v", err)
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	request, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/v1/"+s.Subscription+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed with status %s", method, response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(output); err != nil {
+		return fmt.Errorf("invalid response of %s: %v", method, err)
+	}
+	return nil
+}
+
+// parseNotification finds the new version of a secret in a notification of GCP Secret Manager, the
+// notifications of other event types are no changes
+func parseNotification(message pubsubMessage) (external.Change, bool) {
+	attributes := message.Message.Attributes
+	if !changingEvents[attributes["eventType"]] || attributes["secretId"] == "" {
+		return external.Change{}, false
+	}
+	return external.Change{Type: constants.GCPSecretEnvVarPostfix, Name: attributes["secretId"], Version: message.Message.MessageID}, true
+}
@@ -0,0 +1,174 @@
+package gcp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+const subscription = "projects/my-project/subscriptions/secret-events"
+
+// newPubSubServer serves the pull and acknowledge methods of the subscription, the first pull
+// returns the messages. It accepts the requests with the token
+func newPubSubServer(token string, messages []map[string]interface{}, acknowledged *[]string, lock *sync.Mutex) *httptest.Server {
+	pulls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/" + subscription + ":pull":
+			pulls++
+			if pulls == 1 {
+				json.NewEncoder(w).Encode(map[string]interface{}{"receivedMessages": messages})
+			} else {
+				w.Write([]byte(`{}`))
+			}
+		case "/v1/" + subscription + ":acknowledge":
+			var request struct {
+				AckIDs []string `json:"ackIds"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			*acknowledged = append(*acknowledged, request.AckIDs...)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func notification(ackID string, messageID string, eventType string, secret string) map[string]interface{} {
+	return map[string]interface{}{
+		"ackId":   ackID,
+		"message": map[string]interface{}{"messageId": messageID, "attributes": map[string]string{"eventType": eventType, "secretId": secret}},
+	}
+}
+
+// watchOnce watches the subscriber until it delivered a change and acknowledged the messages
+func watchOnce(t *testing.T, subscriber *Subscriber, acknowledged *[]string, lock *sync.Mutex, count int) external.Change {
+	changes := make(chan external.Change)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		subscriber.Watch(changes, stopCh)
+		close(done)
+	}()
+	defer func() {
+		close(stopCh)
+		<-done
+	}()
+
+	var change external.Change
+	select {
+	case change = <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change was not delivered")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lock.Lock()
+		acks := len(*acknowledged)
+		lock.Unlock()
+		if acks >= count || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return change
+}
+
+func TestSubscriberDeliversNewVersionsOfSecrets(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	var lock sync.Mutex
+	var acknowledged []string
+	messages := []map[string]interface{}{
+		notification("ack-1", "1", "SECRET_ROTATE", "projects/123/secrets/db"),
+		notification("ack-2", "2", "SECRET_VERSION_ADD", "projects/123/secrets/db"),
+	}
+	server := newPubSubServer("service-account-token", messages, &acknowledged, &lock)
+	defer server.Close()
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[len(parts)-1])
+		digest := sha256.Sum256([]byte(strings.Join(parts[:len(parts)-1], ".")))
+		if len(parts) != 3 || rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"service-account-token","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	key, _ := json.Marshal(serviceAccountKey{
+		ClientEmail: "reloader@my-project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    tokenServer.URL,
+	})
+	credentialsFile, err := ioutil.TempFile("", "credentials")
+	if err != nil {
+		t.Fatalf("Failed to create credentials file: %v", err)
+	}
+	defer os.Remove(credentialsFile.Name())
+	credentialsFile.Write(key)
+	credentialsFile.Close()
+
+	subscriber := &Subscriber{Subscription: subscription, CredentialsFile: credentialsFile.Name(), Endpoint: server.URL, WaitTime: time.Millisecond}
+	change := watchOnce(t, subscriber, &acknowledged, &lock, 2)
+	if want := (external.Change{Type: constants.GCPSecretEnvVarPostfix, Name: "projects/123/secrets/db", Version: "2"}); change != want {
+		t.Errorf("Expected change %v, got %v", want, change)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if strings.Join(acknowledged, ",") != "ack-1,ack-2" {
+		t.Errorf("Expected all pulled messages to be acknowledged, got %v", acknowledged)
+	}
+}
+
+func TestSubscriberAuthenticatesWithMetadataServer(t *testing.T) {
+	var lock sync.Mutex
+	var acknowledged []string
+	messages := []map[string]interface{}{notification("ack-1", "1", "SECRET_VERSION_ENABLE", "projects/123/secrets/db")}
+	server := newPubSubServer("metadata-token", messages, &acknowledged, &lock)
+	defer server.Close()
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"metadata-token","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	defer metadataServer.Close()
+
+	subscriber := &Subscriber{Subscription: subscription, Endpoint: server.URL, MetadataURL: metadataServer.URL, WaitTime: time.Millisecond}
+	if change := watchOnce(t, subscriber, &acknowledged, &lock, 1); change.Name != "projects/123/secrets/db" {
+		t.Errorf("Expected the change of projects/123/secrets/db, got %v", change)
+	}
+}
@@ -0,0 +1,91 @@
+package guard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+type queryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type vectorSample struct {
+	Value []interface{} `json:"value"`
+}
+
+// Query evaluates the PromQL query against the Prometheus at prometheusURL and returns the highest
+// value of the result, or 0 if the result is empty
+func Query(prometheusURL string, query string) (float64, error) {
+	endpoint := strings.TrimSuffix(prometheusURL, "/") + "/api/v1/query?" + url.Values{"query": {query}}.Encode()
+	response, err := client.Get(endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, err
+	}
+	var result queryResponse
+	if err = json.Unmarshal(body, &result); err != nil {
+		return 0, fmt.Errorf("unable to parse response of Prometheus with status %d: %v", response.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return 0, fmt.Errorf("query '%s' failed: %s", query, result.Error)
+	}
+
+	switch result.Data.ResultType {
+	case "scalar":
+		var sample []interface{}
+		if err = json.Unmarshal(result.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+		return sampleValue(sample)
+	case "vector":
+		var samples []vectorSample
+		if err = json.Unmarshal(result.Data.Result, &samples); err != nil {
+			return 0, err
+		}
+		if len(samples) == 0 {
+			return 0, nil
+		}
+		max := math.Inf(-1)
+		for _, sample := range samples {
+			value, err := sampleValue(sample.Value)
+			if err != nil {
+				return 0, err
+			}
+			max = math.Max(max, value)
+		}
+		return max, nil
+	default:
+		return 0, fmt.Errorf("query '%s' returned unsupported result type '%s'", query, result.Data.ResultType)
+	}
+}
+
+// sampleValue returns the value of a [timestamp, "value"] sample
+func sampleValue(sample []interface{}) (float64, error) {
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("invalid sample %v", sample)
+	}
+	value, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid sample value %v", sample[1])
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,62 @@
+package guard
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func prometheusServer(t *testing.T, query string, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("query") != query {
+			t.Errorf("Unexpected query %s", r.URL.Query().Get("query"))
+		}
+		fmt.Fprint(w, response)
+	}))
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected float64
+	}{
+		{
+			name:     "vector",
+			response: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"pod":"a"},"value":[1600000000,"0.01"]},{"metric":{"pod":"b"},"value":[1600000000,"0.2"]}]}}`,
+			expected: 0.2,
+		},
+		{
+			name:     "empty vector",
+			response: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expected: 0,
+		},
+		{
+			name:     "scalar",
+			response: `{"status":"success","data":{"resultType":"scalar","result":[1600000000,"3"]}}`,
+			expected: 3,
+		},
+	}
+	for _, test := range tests {
+		server := prometheusServer(t, "rate(errors[5m])", test.response)
+		value, err := Query(server.URL+"/", "rate(errors[5m])")
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		} else if value != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, value)
+		}
+	}
+}
+
+func TestQueryFailure(t *testing.T) {
+	server := prometheusServer(t, "rate(errors[5m", `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+	defer server.Close()
+	if _, err := Query(server.URL, "rate(errors[5m"); err == nil {
+		t.Errorf("Expected error for failed query")
+	}
+}
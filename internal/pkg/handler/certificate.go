@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// certificateKind is the kind of the certificates of cert-manager
+const certificateKind = "Certificate"
+
+var issuedHashes = &syncedHashes{hashes: make(map[types.UID]string)}
+
+func isCertificate(resource interface{}) bool {
+	object, ok := resource.(*unstructured.Unstructured)
+	return ok && object.GetKind() == certificateKind && object.GroupVersionKind().Group == kube.CertificateResource.Group
+}
+
+// certificateIssued checks whether the status of the certificate reports an issuance since its old
+// state, cert-manager increments its status.revision for each issued certificate. Its other updates,
+// e.g. of its conditions or renewal time, leave the issued secret untouched
+func certificateIssued(old *unstructured.Unstructured, new *unstructured.Unstructured) bool {
+	oldRevision, _, _ := unstructured.NestedInt64(old.Object, "status", "revision")
+	newRevision, _, _ := unstructured.NestedInt64(new.Object, "status", "revision")
+	return oldRevision != newRevision
+}
+
+// handleCertificateUpdate reloads the workloads listing the certificate once it was issued and the
+// data of the secret issued for it changed. Issuances leaving the certificate payload unchanged are
+// ignored
+func handleCertificateUpdate(clients kube.Clients, old *unstructured.Unstructured, new *unstructured.Unstructured, collectors metrics.Collectors, span *tracing.Span) error {
+	if !certificateIssued(old, new) {
+		return nil
+	}
+	hashSpan := span.Start("compute hash")
+	target := util.CertificateSecretName(new)
+	secret, err := clients.KubernetesClient.CoreV1().Secrets(new.GetNamespace()).Get(target, metav1.GetOptions{})
+	hashSpan.End(err)
+	if errors.IsNotFound(err) {
+		logrus.Infof("Ignoring issuance of certificate '%s' in namespace '%s', its secret '%s' does not exist", new.GetName(), new.GetNamespace(), target)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	config := util.GetCertificateConfig(new, secret)
+	config.Span = span
+	if !issuedHashes.changed(new.GetUID(), config.SHAValue) || ignoredSource(config, collectors) {
+		return nil
+	}
+	if err := upgradeWorkloads(clients, config, collectors); err != nil {
+		return err
+	}
+	issuedHashes.handled(new.GetUID(), config.SHAValue)
+	return nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestHandleCertificateUpdateReloadsOnlyOnChangedCertificate(t *testing.T) {
+	certificateNamespace := "test-handler-certificate-" + testutil.RandSeq(5)
+	name := "testdeployment-certificate-" + testutil.RandSeq(5)
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(certificateNamespace, name)
+	deploymentObj.Annotations = map[string]string{options.CertificateUpdateOnChangeAnnotation: "tls"}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(certificateNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	// the certificate is issued into the secret named by its secretName
+	if _, err := clients.KubernetesClient.CoreV1().Secrets(certificateNamespace).Create(testutil.GetSecret(certificateNamespace, "tls-certificate", "certificate")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	certificate := func(revision int64, ready string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata":   map[string]interface{}{"name": "tls", "namespace": certificateNamespace, "uid": "uid-" + name},
+			"spec":       map[string]interface{}{"secretName": "tls-certificate"},
+			"status": map[string]interface{}{
+				"revision":   revision,
+				"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+			},
+		}}
+	}
+	collectors := getCollectors()
+	changes := []struct {
+		description string
+		old         *unstructured.Unstructured
+		new         *unstructured.Unstructured
+		data        string
+		reloads     float64
+	}{
+		{"an update of its conditions", certificate(1, "False"), certificate(1, "True"), "", 0},
+		{"its first issuance", certificate(1, "True"), certificate(2, "True"), "", 1},
+		{"an issuance of the same certificate", certificate(2, "True"), certificate(3, "True"), "", 1},
+		{"an issuance of a changed certificate", certificate(3, "True"), certificate(4, "True"), "renewed-certificate", 2},
+	}
+	for _, change := range changes {
+		if change.data != "" {
+			if _, err := clients.KubernetesClient.CoreV1().Secrets(certificateNamespace).Update(testutil.GetSecret(certificateNamespace, "tls-certificate", change.data)); err != nil {
+				t.Fatalf("Failed to update secret: %v", err)
+			}
+		}
+		if err := handleCertificateUpdate(clients, change.old, change.new, collectors, nil); err != nil {
+			t.Errorf("Rolling upgrade failed for %s of the certificate: %v", change.description, err)
+		}
+		if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != change.reloads {
+			t.Errorf("After %s of the certificate, expected %v reloads, got %v", change.description, change.reloads, reloads)
+		}
+	}
+
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(certificateNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName("tls") + "_" + constants.CertificateEnvVarPostfix
+	found := false
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		found = found || env.Name == envName
+	}
+	if !found {
+		t.Errorf("Expected the deployment to hold the hash of the certificate in %s", envName)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"sync"
+
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+)
+
+// reloadLimiter limits the workloads reloaded at the same time, by all changes, to
+// options.MaxConcurrentReloads
+type reloadLimiter struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	running int
+}
+
+var reloads = newReloadLimiter()
+
+func newReloadLimiter() *reloadLimiter {
+	l := &reloadLimiter{}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+func (l *reloadLimiter) acquire() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for l.running >= maxConcurrentReloads() {
+		l.cond.Wait()
+	}
+	l.running++
+}
+
+func (l *reloadLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.running--
+	l.cond.Broadcast()
+}
+
+func maxConcurrentReloads() int {
+	if options.MaxConcurrentReloads < 1 {
+		return 1
+	}
+	return options.MaxConcurrentReloads
+}
+
+// workloadLocks serialize the upgrades of each workload, so changes of different sources handled at the
+// same time never update a workload concurrently
+var workloadLocks = struct {
+	lock  sync.Mutex
+	locks map[string]*workloadLock
+}{locks: map[string]*workloadLock{}}
+
+// workloadLock is removed once it has no more users
+type workloadLock struct {
+	sync.Mutex
+	users int
+}
+
+// lockWorkload locks the workload of the key until the returned func is called
+func lockWorkload(key string) func() {
+	workloadLocks.lock.Lock()
+	lock, found := workloadLocks.locks[key]
+	if !found {
+		lock = &workloadLock{}
+		workloadLocks.locks[key] = lock
+	}
+	lock.users++
+	workloadLocks.lock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		workloadLocks.lock.Lock()
+		defer workloadLocks.lock.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(workloadLocks.locks, key)
+		}
+	}
+}
+
+// upgradeItems calls upgrade for each of the workloads of the kind, upgrading up to
+// options.MaxConcurrentReloads workloads at the same time and each workload once at a time. It returns
+// the last error of upgrade
+func upgradeItems(upgradeFuncs callbacks.RollingUpgradeFuncs, items []interface{}, upgrade func(item interface{}) error) error {
+	var lock sync.Mutex
+	var err error
+	var wg sync.WaitGroup
+	for _, item := range items {
+		reloads.acquire()
+		wg.Add(1)
+		go func(item interface{}) {
+			defer wg.Done()
+			defer reloads.release()
+			meta := util.ToObjectMeta(item)
+			defer lockWorkload(workloadKey(upgradeFuncs, meta.Namespace, meta.Name))()
+			if itemErr := upgrade(item); itemErr != nil {
+				lock.Lock()
+				err = itemErr
+				lock.Unlock()
+			}
+		}(item)
+	}
+	wg.Wait()
+	return err
+}
@@ -3,26 +3,38 @@ package handler
 import (
 	"github.com/sirupsen/logrus"
 	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
 	"github.com/stakater/Reloader/internal/pkg/util"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // ResourceCreatedHandler contains new objects
 type ResourceCreatedHandler struct {
 	Resource   interface{}
 	Collectors metrics.Collectors
+	// Span is the span of the informer event of the change, nil if it is not traced
+	Span *tracing.Span
 }
 
 // Handle processes the newly created resource
 func (r ResourceCreatedHandler) Handle() error {
+	var err error
 	if r.Resource == nil {
 		logrus.Errorf("Resource creation handler received nil resource")
 	} else {
+		span := r.Span.Start("compute hash")
 		config, _ := r.GetConfig()
+		span.End(nil)
+		config.Span = r.Span
 		// process resource based on its type
-		doRollingUpgrade(config, r.Collectors)
+		err = doRollingUpgrade(config, r.Collectors)
+		if accessor, accessorErr := meta.Accessor(r.Resource); accessorErr == nil {
+			doRollover(config, accessor.GetCreationTimestamp(), r.Collectors)
+		}
 	}
-	return nil
+	return err
 }
 
 // GetConfig gets configurations containing SHA, annotations, namespace and resource name
@@ -33,8 +45,10 @@ func (r ResourceCreatedHandler) GetConfig() (util.Config, string) {
 		config = util.GetConfigmapConfig(r.Resource.(*v1.ConfigMap))
 	} else if _, ok := r.Resource.(*v1.Secret); ok {
 		config = util.GetSecretConfig(r.Resource.(*v1.Secret))
+	} else if _, ok := r.Resource.(*unstructured.Unstructured); ok {
+		config = util.GetSecretProviderClassConfig(r.Resource.(*unstructured.Unstructured))
 	} else {
-		logrus.Warnf("Invalid resource: Resource should be 'Secret' or 'Configmap' but found, %v", r.Resource)
+		logrus.Warnf("Invalid resource: Resource should be 'Secret', 'Configmap' or 'SecretProviderClass' but found, %v", r.Resource)
 	}
 	return config, oldSHAData
 }
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResourceCreatedHandlerReloadsWorkloadDeployedBeforeConfigmap(t *testing.T) {
+	createNamespace := "test-handler-create-" + testutil.RandSeq(5)
+	name := "testconfigmap-create-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, createNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	configmap := testutil.GetConfigmap(createNamespace, name, "www.stakater.com")
+	if _, err := clients.KubernetesClient.CoreV1().ConfigMaps(createNamespace).Create(configmap); err != nil {
+		t.Fatalf("Failed to create configmap: %v", err)
+	}
+	config, _ := ResourceCreatedHandler{Resource: configmap}.GetConfig()
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment deployed before its configmap was not reloaded when the configmap was created")
+	}
+}
+
+func TestRolloverToCreatedGenerationOfConfigmap(t *testing.T) {
+	rolloverNamespace := "test-handler-rollover-" + testutil.RandSeq(5)
+	prefix := "myconfig-" + testutil.RandSeq(5) + "-"
+	now := time.Now()
+	createGeneration := func(suffix string, created time.Time) util.Config {
+		configmap := testutil.GetConfigmap(rolloverNamespace, prefix+suffix, "www.stakater.com")
+		configmap.CreationTimestamp = v1.NewTime(created)
+		if _, err := clients.KubernetesClient.CoreV1().ConfigMaps(rolloverNamespace).Create(configmap); err != nil {
+			t.Fatalf("Failed to create configmap: %v", err)
+		}
+		return util.GetConfigmapConfig(configmap)
+	}
+	referencedGeneration := func() string {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(rolloverNamespace).Get("rollover", v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment: %v", err)
+		}
+		return deployment.Spec.Template.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name
+	}
+
+	createGeneration("aaaa", now.Add(-time.Hour))
+	annotations := map[string]string{options.ConfigmapRolloverAnnotation: prefix}
+	if err := createDeploymentReferencingConfigmap(clients, rolloverNamespace, "rollover", prefix+"aaaa", annotations); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	config := createGeneration("bbbb", now)
+	if err := rollover(clients, config, v1.NewTime(now), GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rollover failed for Deployment: %v", err)
+	}
+	if generation := referencedGeneration(); generation != prefix+"bbbb" {
+		t.Errorf("Expected deployment to be rolled over to %sbbbb, it references %s", prefix, generation)
+	}
+
+	// an older generation found later, e.g. by the startup reconciliation, is never rolled over to
+	config = createGeneration("0000", now.Add(-2*time.Hour))
+	if err := rollover(clients, config, v1.NewTime(now.Add(-2*time.Hour)), GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rollover failed for Deployment: %v", err)
+	}
+	if generation := referencedGeneration(); generation != prefix+"bbbb" {
+		t.Errorf("Expected deployment to stay on %sbbbb, it references %s", prefix, generation)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+)
+
+// WatchedNamespaces are the namespaces workloads are reloaded in, all namespaces if empty or if it
+// holds the empty namespace
+var WatchedNamespaces []string
+
+// watchesNamespace checks whether workloads are reloaded in the namespace
+func watchesNamespace(namespace string) bool {
+	if len(WatchedNamespaces) == 0 {
+		return true
+	}
+	for _, watched := range WatchedNamespaces {
+		if watched == v1.NamespaceAll || watched == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// crossNamespaceUpgrade reloads the workloads in the other watched namespaces that reference the
+// changed configmap or secret as "<namespace>/<name>" in their reload annotations
+func crossNamespaceUpgrade(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors) error {
+	namespaces := WatchedNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
+	}
+	span := config.Span.Start("match " + upgradeFuncs.ResourceType + " in other namespaces")
+	var items []interface{}
+	if cached, found := crossNamespaceItems(config, upgradeFuncs); found {
+		items = cached
+	} else {
+		for _, namespace := range namespaces {
+			items = append(items, upgradeFuncs.ItemsFunc(clients, namespace)...)
+		}
+	}
+	span.SetAttribute("candidates", strconv.Itoa(len(items)))
+	err := upgradeItems(upgradeFuncs, items, func(item interface{}) error {
+		itemNamespace := util.ToObjectMeta(item).Namespace
+		if itemNamespace == config.Namespace {
+			return nil
+		}
+		itemConfig := config
+		itemConfig.Namespace = itemNamespace
+		itemConfig.SourceNamespace = config.Namespace
+		itemConfig.Span = span
+		return upgradeItem(clients, itemConfig, upgradeFuncs, collectors, item)
+	})
+	span.End(err)
+	return err
+}
+
+// referencesSource checks whether the value of a reload annotation of a workload names, or matches, the
+// changed configmap or secret as "<namespace>/<name>" or, in the namespace of the workload, by its name
+func referencesSource(config util.Config, value string) bool {
+	if config.SourceNamespace != "" {
+		return matchesValue(value, config.SourceNamespace+"/"+config.ResourceName)
+	}
+	return matchesValue(value, config.ResourceName) || matchesValue(value, config.Namespace+"/"+config.ResourceName)
+}
+
+// sourceReference returns the name of the changed configmap or secret, prefixed by its namespace if it
+// is not the namespace of the workload
+func sourceReference(config util.Config) string {
+	if config.SourceNamespace != "" {
+		return config.SourceNamespace + "/" + config.ResourceName
+	}
+	return config.ResourceName
+}
+
+// ReferencedSourceNamespaces returns the namespaces of the configmaps and secrets that the workloads
+// of the watched namespaces reference as "<namespace>/<name>" in their reload annotations, other than
+// their own namespace. Patterns are skipped, they name no namespace to watch
+func ReferencedSourceNamespaces(clients kube.Clients) []string {
+	namespaces := WatchedNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
+	}
+	referenced := map[string]bool{}
+	for _, upgradeFuncs := range workloadKinds() {
+		for _, namespace := range namespaces {
+			for _, item := range upgradeFuncs.ItemsFunc(clients, namespace) {
+				itemNamespace := util.ToObjectMeta(item).Namespace
+				for _, annotations := range []map[string]string{upgradeFuncs.AnnotationsFunc(item), upgradeFuncs.PodAnnotationsFunc(item)} {
+					for _, annotation := range []string{options.ConfigmapUpdateOnChangeAnnotation, options.SecretUpdateOnChangeAnnotation} {
+						for _, value := range strings.Split(annotations[annotation], ",") {
+							parts := strings.SplitN(value, "/", 2)
+							if len(parts) == 2 && parts[0] != itemNamespace && !isPattern(value) {
+								referenced[parts[0]] = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	result := make([]string, 0, len(referenced))
+	for namespace := range referenced {
+		result = append(result, namespace)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CustomWorkloadKinds are the kinds of custom resources embedding a pod template that are reloaded
+// like the built-in workloads
+var CustomWorkloadKinds []callbacks.CustomWorkloadKind
+
+var (
+	registryLock sync.RWMutex
+	// registeredWorkloadKinds are the kinds of custom workloads of the workload registry configmap
+	registeredWorkloadKinds []callbacks.CustomWorkloadKind
+)
+
+// RegisteredWorkloadKinds returns the kinds of custom workloads registered in the workload registry
+// configmap
+func RegisteredWorkloadKinds() []callbacks.CustomWorkloadKind {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	return registeredWorkloadKinds
+}
+
+// ApplyWorkloadRegistry replaces the registered kinds of custom workloads by the kinds of the workload
+// registry configmap, one "<resource>.<version>.<group>=<path>" per line of its values. Invalid kinds
+// are logged and skipped, a nil configmap, e.g. once it is deleted, removes all of them
+func ApplyWorkloadRegistry(client kubernetes.Interface, configmap *v1.ConfigMap) {
+	var kinds []callbacks.CustomWorkloadKind
+	if configmap != nil {
+		keys := make([]string, 0, len(configmap.Data))
+		for key := range configmap.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range strings.Split(configmap.Data[key], "\n") {
+				if value = strings.TrimSpace(value); value == "" {
+					continue
+				}
+				kind, err := callbacks.ParseCustomWorkloadKind(value)
+				if err != nil {
+					logrus.Errorf("Ignoring key '%s' of workload registry '%s': %v", key, configmap.Name, err)
+					continue
+				}
+				if client != nil {
+					if discovered, err := kube.ResourceKind(client, kind.Resource); err != nil {
+						logrus.Warnf("Unable to discover the kind of custom workload '%s', using its resource as kind: %v", value, err)
+					} else {
+						kind.Kind = discovered
+					}
+				}
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	registeredWorkloadKinds = kinds
+	logrus.Infof("Workload registry lists %d kinds of custom workloads", len(kinds))
+}
+
+// GetCustomWorkloadRollingUpgradeFuncs returns all callback funcs for a kind of custom workloads. Their
+// replicas and rollouts are unknown, so the strategies waiting for them do not apply
+func GetCustomWorkloadRollingUpgradeFuncs(kind callbacks.CustomWorkloadKind) callbacks.RollingUpgradeFuncs {
+	return callbacks.RollingUpgradeFuncs{
+		ItemsFunc:               kind.GetItems,
+		AnnotationsFunc:         callbacks.GetCustomWorkloadAnnotations,
+		PodAnnotationsFunc:      callbacks.GetCustomWorkloadPodAnnotations,
+		PodTemplateFunc:         callbacks.GetCustomWorkloadPodTemplate,
+		ContainersFunc:          callbacks.GetCustomWorkloadContainers,
+		InitContainersFunc:      callbacks.GetCustomWorkloadInitContainers,
+		EphemeralContainersFunc: callbacks.GetCustomWorkloadEphemeralContainers,
+		UpdateFunc:              kind.Update,
+		VolumesFunc:             callbacks.GetCustomWorkloadVolumes,
+		ResourceType:            kind.Kind,
+		ReferencesFunc:          callbacks.GetCustomWorkloadReferences,
+	}
+}
+
+// GetKnativeServiceRollingUpgradeFuncs returns all callback funcs for Knative Services. Changes of their
+// revision template roll a new revision, which the annotations strategy creates without adding env
+// vars to their containers
+func GetKnativeServiceRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
+	funcs := GetCustomWorkloadRollingUpgradeFuncs(callbacks.KnativeServiceKind)
+	funcs.ReloadStrategy = callbacks.AnnotationsStrategy
+	return funcs
+}
+
+// GetArgoRolloutRollingUpgradeFuncs returns all callback funcs for Argo Rollouts, reloading the pod
+// template at the argo rollout template path, and only it, so the templates of their analyses and
+// experiments are left untouched
+func GetArgoRolloutRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
+	kind := callbacks.ArgoRolloutKind
+	if path, err := callbacks.ParseTemplatePath(options.ArgoRolloutTemplatePath); err != nil {
+		logrus.Errorf("Ignoring the template path of Argo Rollouts: %v", err)
+	} else {
+		kind.TemplatePath = path
+	}
+	return GetCustomWorkloadRollingUpgradeFuncs(kind)
+}
+
+// GetScaledJobRollingUpgradeFuncs returns all callback funcs for KEDA ScaledJobs. Like cronJobs, they
+// have no rollouts, the pod template of their job target is updated for the jobs they spawn next
+func GetScaledJobRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
+	return GetCustomWorkloadRollingUpgradeFuncs(callbacks.ScaledJobKind)
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/diagnostics"
+	"github.com/stakater/Reloader/internal/pkg/history"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// deferredReload is the rolling upgrade of a workload for a configmap or secret change, put off
+// until the workload allows it
+type deferredReload struct {
+	clients      kube.Clients
+	config       util.Config
+	upgradeFuncs callbacks.RollingUpgradeFuncs
+	collectors   metrics.Collectors
+	name         string
+	// queuedAt is when the first change still pending for the workload was deferred
+	queuedAt time.Time
+	// retries is the number of times the reload was deferred again since
+	retries int
+}
+
+// deferredReloads holds the deferred reloads by workload and source, only the latest change of a
+// source is kept for a workload. It also tracks when workloads with a post-reload cooldown, and
+// workloads for sources with a sample interval, were reloaded
+type deferredReloads struct {
+	lock       sync.Mutex
+	pending    map[string]*deferredReload
+	reloadedAt map[string]time.Time
+	queue      workqueue.DelayingInterface
+	start      sync.Once
+	clock      clock.Clock
+}
+
+var deferred = newDeferredReloads()
+
+func init() {
+	diagnostics.Publish("deferred reloads", func() interface{} {
+		return deferred.size()
+	})
+}
+
+func newDeferredReloads() *deferredReloads {
+	return &deferredReloads{
+		pending:    make(map[string]*deferredReload),
+		reloadedAt: make(map[string]time.Time),
+		queue:      workqueue.NewDelayingQueue(),
+		clock:      clock.RealClock{},
+	}
+}
+
+// size returns the number of pending deferred reloads
+func (d *deferredReloads) size() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.pending)
+}
+
+func deferredReloadKey(config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, name string) string {
+	return upgradeFuncs.ResourceType + "/" + config.Namespace + "/" + name + "/" + config.Type + "/" + sourceReference(config)
+}
+
+func workloadKey(upgradeFuncs callbacks.RollingUpgradeFuncs, namespace string, name string) string {
+	return upgradeFuncs.ResourceType + "/" + namespace + "/" + name
+}
+
+// markReloaded records that the workload or the workload and source of the key was reloaded now
+func (d *deferredReloads) markReloaded(key string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.reloadedAt[key] = d.clock.Now()
+}
+
+// remaining returns how much of the interval is left since the key was last marked as reloaded, 0
+// if it elapsed or the key was never marked
+func (d *deferredReloads) remaining(key string, interval time.Duration) time.Duration {
+	d.lock.Lock()
+	reloadedAt, found := d.reloadedAt[key]
+	d.lock.Unlock()
+	if !found {
+		return 0
+	}
+	if remaining := reloadedAt.Add(interval).Sub(d.clock.Now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// forget drops the deferred reload of the key, once a newer change of its source was applied
+func (d *deferredReloads) forget(key string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.pending, key)
+}
+
+// add defers the rolling upgrade of the workload by delay, replacing an earlier deferred change of
+// the same source. The reload is retried earlier if it would exceed the max queued age by then
+func (d *deferredReloads) add(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors, item interface{}, delay time.Duration) {
+	name := util.ToObjectMeta(item).Name
+	key := deferredReloadKey(config, upgradeFuncs, name)
+	now := d.clock.Now()
+
+	d.lock.Lock()
+	queuedAt := now
+	retries := 0
+	if previous, found := d.pending[key]; found {
+		queuedAt = previous.queuedAt
+		retries = previous.retries + 1
+	}
+	d.pending[key] = &deferredReload{
+		clients:      clients,
+		config:       config,
+		upgradeFuncs: upgradeFuncs,
+		collectors:   collectors,
+		name:         name,
+		queuedAt:     queuedAt,
+		retries:      retries,
+	}
+	d.lock.Unlock()
+
+	if options.MaxQueuedAge > 0 {
+		if untilExpiry := queuedAt.Add(options.MaxQueuedAge).Sub(now); untilExpiry < delay {
+			delay = untilExpiry
+		}
+	}
+	d.start.Do(func() {
+		go d.run()
+	})
+	d.queue.AddAfter(key, delay)
+}
+
+// backoff returns the delay of the next retry of the reload of the workload for the source, the retry
+// interval doubled for each time it was deferred again, up to the max retry interval
+func (d *deferredReloads) backoff(config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}) time.Duration {
+	d.lock.Lock()
+	previous, found := d.pending[deferredReloadKey(config, upgradeFuncs, util.ToObjectMeta(item).Name)]
+	d.lock.Unlock()
+	delay := options.DeferredReloadRetryInterval
+	if !found || options.DeferredReloadMaxRetryInterval <= delay {
+		return delay
+	}
+	for i := 0; i <= previous.retries && delay < options.DeferredReloadMaxRetryInterval; i++ {
+		delay *= 2
+	}
+	if delay > options.DeferredReloadMaxRetryInterval {
+		return options.DeferredReloadMaxRetryInterval
+	}
+	return delay
+}
+
+// get returns the deferred reload of the key, if any
+func (d *deferredReloads) get(key string) (deferredReload, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	reload, found := d.pending[key]
+	if !found {
+		return deferredReload{}, false
+	}
+	return *reload, true
+}
+
+func (d *deferredReloads) run() {
+	for {
+		key, quit := d.queue.Get()
+		if quit {
+			return
+		}
+		d.process(key.(string))
+		d.queue.Done(key)
+	}
+}
+
+// process retries the deferred reload of the key against the current state of the workload, or drops
+// it once it exceeds the max queued age. The reload is limited and ordered with the reloads of live
+// changes, by the max concurrent reloads and the lock of the workload
+func (d *deferredReloads) process(key string) {
+	d.lock.Lock()
+	reload, found := d.pending[key]
+	d.lock.Unlock()
+	if !found {
+		return
+	}
+	log := workloadLog(reload.config, reload.upgradeFuncs.ResourceType, reload.name)
+	// a reload deferred again, or retried, keeps its queued time, others are done once processed
+	retried := false
+	defer func() {
+		d.lock.Lock()
+		if d.pending[key] == reload && !retried {
+			delete(d.pending, key)
+		}
+		d.lock.Unlock()
+	}()
+
+	if age := d.clock.Since(reload.queuedAt); options.MaxQueuedAge > 0 && age >= options.MaxQueuedAge {
+		log.Errorf("Dropping deferred rolling upgrade of '%s' of type '%s' in namespace '%s' for changes in '%s' of type '%s', it was queued for %s", reload.name, reload.upgradeFuncs.ResourceType, reload.config.Namespace, reload.config.ResourceName, reload.config.Type, age)
+		reload.collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonExpired}).Inc()
+		recordDecision(reload.config, reload.upgradeFuncs, reload.name, history.Skipped, metrics.SkipReasonExpired)
+		return
+	}
+
+	item, err := reload.workload()
+	if err != nil {
+		log.Errorf("Unable to get '%s' of type '%s' in namespace '%s' for its deferred rolling upgrade, retrying in %s: %v", reload.name, reload.upgradeFuncs.ResourceType, reload.config.Namespace, options.DeferredReloadRetryInterval, err)
+		retried = true
+		d.queue.AddAfter(key, options.DeferredReloadRetryInterval)
+		return
+	}
+	if item == nil {
+		log.Infof("Dropping deferred rolling upgrade of '%s' of type '%s' in namespace '%s', it no longer exists", reload.name, reload.upgradeFuncs.ResourceType, reload.config.Namespace)
+		return
+	}
+	err = upgradeItems(reload.upgradeFuncs, []interface{}{item}, func(item interface{}) error {
+		return upgradeItem(reload.clients, reload.config, reload.upgradeFuncs, reload.collectors, item)
+	})
+	if err != nil {
+		log.Errorf("Deferred rolling upgrade of '%s' of type '%s' in namespace '%s' failed with error %v", reload.name, reload.upgradeFuncs.ResourceType, reload.config.Namespace, err)
+	}
+}
+
+// workload reads the current state of the workload of the deferred reload, nil if it no longer exists
+func (r *deferredReload) workload() (interface{}, error) {
+	if r.upgradeFuncs.GetFunc != nil {
+		item, err := r.upgradeFuncs.GetFunc(r.clients, r.config.Namespace, r.name)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return item, nil
+	}
+	// kinds that cannot be read by name, e.g. custom workloads, are listed
+	for _, item := range r.upgradeFuncs.ItemsFunc(r.clients, r.config.Namespace) {
+		if util.ToObjectMeta(item).Name == r.name {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/crypto"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceDeletedHandler contains deleted objects
+type ResourceDeletedHandler struct {
+	Resource   interface{}
+	Collectors metrics.Collectors
+	// Span is the span of the informer event of the change, nil if it is not traced
+	Span *tracing.Span
+}
+
+// Handle processes the deleted resource
+func (r ResourceDeletedHandler) Handle() error {
+	var err error
+	if r.Resource == nil {
+		logrus.Errorf("Resource deletion handler received nil resource")
+	} else {
+		span := r.Span.Start("compute hash")
+		config, _ := r.GetConfig()
+		span.End(nil)
+		config.Span = r.Span
+		// only workloads with the reload-on-delete annotation are reloaded
+		err = doRollingUpgrade(config, r.Collectors)
+	}
+	return err
+}
+
+// GetConfig gets configurations containing SHA, annotations, namespace and resource name. The SHA
+// marks the deletion of the resource instead of its data
+func (r ResourceDeletedHandler) GetConfig() (util.Config, string) {
+	var oldSHAData string
+	var config util.Config
+	if _, ok := r.Resource.(*v1.ConfigMap); ok {
+		oldSHAData = util.GetConfigmapConfig(r.Resource.(*v1.ConfigMap)).SHAValue
+		config = util.GetConfigmapConfig(r.Resource.(*v1.ConfigMap))
+	} else if _, ok := r.Resource.(*v1.Secret); ok {
+		oldSHAData = util.GetSecretConfig(r.Resource.(*v1.Secret)).SHAValue
+		config = util.GetSecretConfig(r.Resource.(*v1.Secret))
+	} else if _, ok := r.Resource.(*unstructured.Unstructured); ok {
+		oldSHAData = util.GetSecretProviderClassConfig(r.Resource.(*unstructured.Unstructured)).SHAValue
+		config = util.GetSecretProviderClassConfig(r.Resource.(*unstructured.Unstructured))
+	} else {
+		logrus.Warnf("Invalid resource: Resource should be 'Secret', 'Configmap' or 'SecretProviderClass' but found, %v", r.Resource)
+		return config, oldSHAData
+	}
+	config.Deleted = true
+	config.SHAValue = crypto.GenerateSHA("deleted;" + string(config.ResourceUID))
+	config.LegacySHAValue = ""
+	config.ResourceData = map[string]string{}
+	config.KeyHashes = map[string]string{}
+	config.NotBefore = time.Time{}
+	return config, oldSHAData
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+)
+
+func TestRollingUpgradeOnDeletionOnlyForAnnotatedWorkloads(t *testing.T) {
+	deleteNamespace := "test-handler-delete-" + testutil.RandSeq(5)
+	name := "testconfigmap-delete-" + testutil.RandSeq(5)
+	deployments := map[string]map[string]string{
+		name + "-annotated": {options.ReloadOnDeleteAnnotation: "true"},
+		name + "-plain":     {},
+	}
+	for deploymentName, annotations := range deployments {
+		if err := createDeploymentReferencingConfigmap(clients, deleteNamespace, deploymentName, name, annotations); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	config, oldSHAData := ResourceDeletedHandler{Resource: testutil.GetConfigmap(deleteNamespace, name, "www.stakater.com")}.GetConfig()
+	if !config.Deleted || config.SHAValue == oldSHAData {
+		t.Fatalf("Expected the config of the deleted configmap to mark its deletion")
+	}
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if !isDeploymentUpdated(t, clients, config, name+"-annotated") {
+		t.Errorf("Deployment with the reload-on-delete annotation was not reloaded when its configmap was deleted")
+	}
+	if isDeploymentUpdated(t, clients, config, name+"-plain") {
+		t.Errorf("Deployment without the reload-on-delete annotation was reloaded when its configmap was deleted")
+	}
+}
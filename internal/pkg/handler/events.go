@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// EventReasonReloaded is the reason of the events recorded for reloads
+	EventReasonReloaded = "Reloaded"
+	// EventReasonReloadFailed is the reason of the events recorded for failed reloads
+	EventReasonReloadFailed = "ReloadFailed"
+	// EventReasonWouldReload is the reason of the events recorded for reloads skipped in dry-run mode
+	EventReasonWouldReload = "WouldReload"
+	// EventReasonReloadDeferred is the reason of the events recorded for deferred reloads
+	EventReasonReloadDeferred = "ReloadDeferred"
+	// EventReasonRolloutSucceeded is the reason of the events recorded for completed rollouts of reloaded workloads
+	EventReasonRolloutSucceeded = "RolloutSucceeded"
+	// EventReasonRolloutFailed is the reason of the events recorded for failed rollouts of reloaded workloads
+	EventReasonRolloutFailed = "RolloutFailed"
+	// EventReasonRolledBack is the reason of the events recorded for reloads rolled back after their rollout failed
+	EventReasonRolledBack = "ReloadRolledBack"
+	// EventReasonRollbackFailed is the reason of the events recorded for failed rollbacks of reloads
+	EventReasonRollbackFailed = "ReloadRollbackFailed"
+)
+
+// sourceKinds are the kinds of the sources by the type of their config
+var sourceKinds = map[string]string{
+	constants.ConfigmapEnvVarPostfix:           "ConfigMap",
+	constants.SecretEnvVarPostfix:              "Secret",
+	constants.SecretProviderClassEnvVarPostfix: "SecretProviderClass",
+	constants.ExternalSecretEnvVarPostfix:      externalSecretKind,
+	constants.CertificateEnvVarPostfix:         certificateKind,
+}
+
+// sourceAPIVersions are the API versions of the sources by the type of their config, if not core
+var sourceAPIVersions = map[string]string{
+	constants.SecretProviderClassEnvVarPostfix: kube.SecretProviderClassResource.GroupVersion().String(),
+	constants.ExternalSecretEnvVarPostfix:      kube.ExternalSecretResource.GroupVersion().String(),
+	constants.CertificateEnvVarPostfix:         kube.CertificateResource.GroupVersion().String(),
+}
+
+// recordReloadEvent records the reload of the workload, or its failure, as Kubernetes Event on the
+// workload and, if enabled, on the changed configmap or secret
+func recordReloadEvent(clients kube.Clients, config util.Config, resourceType string, item interface{}, err error) {
+	if clients.EventRecorder == nil || !options.EmitEvents {
+		return
+	}
+	meta := util.ToObjectMeta(item)
+	workload := workloadReference(resourceType, item)
+	kind, isSource := sourceKinds[config.Type]
+	source := sourceDescription(config)
+	if err != nil {
+		clients.EventRecorder.Eventf(workload, v1.EventTypeWarning, EventReasonReloadFailed, "Reload after change of %s failed: %v", source, err)
+		return
+	}
+	clients.EventRecorder.Eventf(workload, v1.EventTypeNormal, EventReasonReloaded, "Reloaded after change of %s", source)
+
+	if isSource && options.EmitSourceEvents {
+		namespace := config.Namespace
+		if config.SourceNamespace != "" {
+			namespace = config.SourceNamespace
+		}
+		reference := &v1.ObjectReference{APIVersion: sourceAPIVersions[config.Type], Kind: kind, Namespace: namespace, Name: config.ResourceName, UID: config.ResourceUID}
+		clients.EventRecorder.Eventf(reference, v1.EventTypeNormal, EventReasonReloaded, "Reloaded %s '%s' after the change", resourceType, meta.Name)
+		// the owner, e.g. a SealedSecret, is the resource users actually changed
+		if owner := config.Owner; owner != nil {
+			reference := &v1.ObjectReference{APIVersion: owner.APIVersion, Kind: owner.Kind, Namespace: namespace, Name: owner.Name, UID: owner.UID}
+			clients.EventRecorder.Eventf(reference, v1.EventTypeNormal, EventReasonReloaded, "Reloaded %s '%s' after the change of its %s '%s'", resourceType, meta.Name, kind, config.ResourceName)
+		}
+	}
+}
+
+// recordObservedReloadEvent records the reload of the workload skipped in dry-run mode as Kubernetes
+// Event on the workload. Observe-only mode writes no events, it runs with read-only permissions
+func recordObservedReloadEvent(clients kube.Clients, config util.Config, resourceType string, item interface{}) {
+	if clients.EventRecorder == nil || !options.EmitEvents || !options.DryRun {
+		return
+	}
+	clients.EventRecorder.Eventf(workloadReference(resourceType, item), v1.EventTypeNormal, EventReasonWouldReload, "Would reload after change of %s, skipped in dry-run mode", sourceDescription(config))
+}
+
+// recordDeferredReloadEvent records the deferral of the reload of the workload as Kubernetes Event on
+// the workload, explained by until
+func recordDeferredReloadEvent(clients kube.Clients, config util.Config, resourceType string, item interface{}, until string) {
+	if clients.EventRecorder == nil || !options.EmitEvents {
+		return
+	}
+	clients.EventRecorder.Eventf(workloadReference(resourceType, item), v1.EventTypeNormal, EventReasonReloadDeferred, "Reload after change of %s deferred %s", sourceDescription(config), until)
+}
+
+// recordRolloutEvent records the completed, or failed, rollout of the reloaded workload as Kubernetes
+// Event on the workload
+func recordRolloutEvent(clients kube.Clients, config util.Config, resourceType string, item interface{}, err error) {
+	if clients.EventRecorder == nil || !options.EmitEvents {
+		return
+	}
+	workload := workloadReference(resourceType, item)
+	if err != nil {
+		clients.EventRecorder.Eventf(workload, v1.EventTypeWarning, EventReasonRolloutFailed, "Rollout after change of %s failed: %v", sourceDescription(config), err)
+		return
+	}
+	clients.EventRecorder.Eventf(workload, v1.EventTypeNormal, EventReasonRolloutSucceeded, "Rolled out after change of %s", sourceDescription(config))
+}
+
+// recordRollbackEvent records the rollback of the reload of the workload after its failed rollout, or
+// the failure of the rollback, as Kubernetes Event on the workload
+func recordRollbackEvent(clients kube.Clients, config util.Config, resourceType string, item interface{}, err error) {
+	if clients.EventRecorder == nil || !options.EmitEvents {
+		return
+	}
+	workload := workloadReference(resourceType, item)
+	if err != nil {
+		clients.EventRecorder.Eventf(workload, v1.EventTypeWarning, EventReasonRollbackFailed, "Rollback of the reload after change of %s failed: %v", sourceDescription(config), err)
+		return
+	}
+	clients.EventRecorder.Eventf(workload, v1.EventTypeNormal, EventReasonRolledBack, "Rolled back the reload after change of %s, its rollout failed", sourceDescription(config))
+}
+
+func workloadReference(resourceType string, item interface{}) *v1.ObjectReference {
+	meta := util.ToObjectMeta(item)
+	reference := &v1.ObjectReference{Kind: resourceType, Namespace: meta.Namespace, Name: meta.Name, UID: meta.UID}
+	// the kinds of custom workloads, e.g. Knative Services, are only unique in their API version
+	if workload, isCustom := item.(*callbacks.CustomWorkload); isCustom {
+		reference.APIVersion = workload.Object.GetAPIVersion()
+	}
+	return reference
+}
+
+// sourceDescription describes the changed configmap or secret along with its owner, or the trigger
+// message, of the config
+func sourceDescription(config util.Config) string {
+	if kind, found := sourceKinds[config.Type]; found && config.Owner != nil {
+		return fmt.Sprintf("%s '%s' (of %s '%s')", kind, sourceReference(config), config.Owner.Kind, config.Owner.Name)
+	} else if found {
+		return fmt.Sprintf("%s '%s'", kind, sourceReference(config))
+	}
+	if kind, found := externalSourceKinds[config.Type]; found {
+		return fmt.Sprintf("%s '%s'", kind, config.ResourceName)
+	}
+	return "trigger " + config.ResourceName
+}
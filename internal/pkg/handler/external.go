@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/crypto"
+	"github.com/stakater/Reloader/internal/pkg/external"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+)
+
+// externalSourceKinds are the kinds of the secrets of external stores by the type of their change
+var externalSourceKinds = map[string]string{
+	constants.VaultEnvVarPostfix:       "Vault path",
+	constants.AWSSecretEnvVarPostfix:   "AWS secret",
+	constants.GCPSecretEnvVarPostfix:   "GCP secret",
+	constants.AzureSecretEnvVarPostfix: "Azure secret",
+	constants.ConsulKeyEnvVarPostfix:   "Consul key",
+}
+
+// externalAnnotation returns the annotation workloads list the secrets of the external store of the
+// type in
+func externalAnnotation(changeType string) string {
+	switch changeType {
+	case constants.VaultEnvVarPostfix:
+		return options.VaultPathAnnotation
+	case constants.AWSSecretEnvVarPostfix:
+		return options.AWSSecretAnnotation
+	case constants.GCPSecretEnvVarPostfix:
+		return options.GCPSecretAnnotation
+	case constants.AzureSecretEnvVarPostfix:
+		return options.AzureSecretAnnotation
+	case constants.ConsulKeyEnvVarPostfix:
+		return options.ConsulKeyAnnotation
+	}
+	return ""
+}
+
+// WatchExternal reloads the workloads listing the secrets of the changes of the external source
+// until the stop channel is closed
+func WatchExternal(source external.Source, clients kube.Clients, collectors metrics.Collectors, stopCh <-chan struct{}) {
+	changes := make(chan external.Change)
+	go source.Watch(changes, stopCh)
+	for {
+		select {
+		case change := <-changes:
+			if err := HandleExternalChange(clients, change, collectors); err != nil {
+				logrus.Errorf("Rolling upgrade for %s '%s' failed with error = %v", externalSourceKinds[change.Type], change.Name, err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// HandleExternalChange reloads the workloads in the watched namespaces listing the changed secret
+// of an external store, by name or pattern, in the annotation of the store. Such secrets belong to
+// no namespace
+func HandleExternalChange(clients kube.Clients, change external.Change, collectors metrics.Collectors) error {
+	annotation := externalAnnotation(change.Type)
+	if annotation == "" {
+		logrus.Warnf("Ignoring change of '%s' of unknown external store '%s'", change.Name, change.Type)
+		return nil
+	}
+	config := util.Config{
+		ResourceName: change.Name,
+		Annotation:   annotation,
+		SHAValue:     crypto.GenerateSHA(change.Name + ";" + change.Version),
+		Type:         change.Type,
+	}
+	namespaces := WatchedNamespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{v1.NamespaceAll}
+	}
+	var err error
+	for _, upgradeFuncs := range workloadKinds() {
+		for _, namespace := range namespaces {
+			for _, item := range upgradeFuncs.ItemsFunc(clients, namespace) {
+				itemConfig := config
+				itemConfig.Namespace = util.ToObjectMeta(item).Namespace
+				if itemErr := upgradeItem(clients, itemConfig, upgradeFuncs, collectors, item); itemErr != nil {
+					err = itemErr
+				}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// externalSecretKind is the kind of the external secrets of the External Secrets Operator
+const externalSecretKind = "ExternalSecret"
+
+// syncedHashes are the hashes of the data of the secrets synced by resources such as external secrets,
+// as of the last handled sync of each resource
+type syncedHashes struct {
+	lock   sync.Mutex
+	hashes map[types.UID]string
+}
+
+var refreshedHashes = &syncedHashes{hashes: make(map[types.UID]string)}
+
+// changed checks whether the hash differs from the one of the last handled sync of the resource, or
+// none was handled yet
+func (h *syncedHashes) changed(uid types.UID, sha string) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	previous, found := h.hashes[uid]
+	return !found || previous != sha
+}
+
+// handled records the hash of the handled sync of the resource
+func (h *syncedHashes) handled(uid types.UID, sha string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.hashes[uid] = sha
+}
+
+func isExternalSecret(resource interface{}) bool {
+	object, ok := resource.(*unstructured.Unstructured)
+	return ok && object.GetKind() == externalSecretKind
+}
+
+// externalSecretRefreshed checks whether the status of the external secret reports a sync since its old
+// state, either a refresh from the external store or a sync after a change of its spec. Its other
+// updates, e.g. of its conditions, leave the synced secret untouched
+func externalSecretRefreshed(old *unstructured.Unstructured, new *unstructured.Unstructured) bool {
+	for _, field := range []string{"refreshTime", "syncedResourceVersion"} {
+		oldValue, _, _ := unstructured.NestedString(old.Object, "status", field)
+		newValue, _, _ := unstructured.NestedString(new.Object, "status", field)
+		if oldValue != newValue {
+			return true
+		}
+	}
+	return false
+}
+
+// handleExternalSecretUpdate reloads the workloads listing the external secret once it was refreshed
+// and the data of the secret it syncs changed. Refreshes fetching the same data from the external
+// store are ignored
+func handleExternalSecretUpdate(clients kube.Clients, old *unstructured.Unstructured, new *unstructured.Unstructured, collectors metrics.Collectors, span *tracing.Span) error {
+	if !externalSecretRefreshed(old, new) {
+		return nil
+	}
+	hashSpan := span.Start("compute hash")
+	target := util.ExternalSecretTargetName(new)
+	secret, err := clients.KubernetesClient.CoreV1().Secrets(new.GetNamespace()).Get(target, metav1.GetOptions{})
+	hashSpan.End(err)
+	if errors.IsNotFound(err) {
+		logrus.Infof("Ignoring refresh of external secret '%s' in namespace '%s', its secret '%s' does not exist", new.GetName(), new.GetNamespace(), target)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	config := util.GetExternalSecretConfig(new, secret)
+	config.Span = span
+	if !refreshedHashes.changed(new.GetUID(), config.SHAValue) || ignoredSource(config, collectors) {
+		return nil
+	}
+	if err := upgradeWorkloads(clients, config, collectors); err != nil {
+		return err
+	}
+	refreshedHashes.handled(new.GetUID(), config.SHAValue)
+	return nil
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestHandleExternalSecretUpdateReloadsOnlyOnChangedData(t *testing.T) {
+	externalSecretNamespace := "test-handler-externalsecret-" + testutil.RandSeq(5)
+	name := "testdeployment-externalsecret-" + testutil.RandSeq(5)
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(externalSecretNamespace, name)
+	deploymentObj.Annotations = map[string]string{options.ExternalSecretUpdateOnChangeAnnotation: "database"}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(externalSecretNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	// the external secret syncs the secret named by its target
+	if _, err := clients.KubernetesClient.CoreV1().Secrets(externalSecretNamespace).Create(testutil.GetSecret(externalSecretNamespace, "database-credentials", "password")); err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+
+	externalSecret := func(refreshTime string, ready string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "external-secrets.io/v1beta1",
+			"kind":       "ExternalSecret",
+			"metadata":   map[string]interface{}{"name": "database", "namespace": externalSecretNamespace, "uid": "uid-" + name},
+			"spec":       map[string]interface{}{"target": map[string]interface{}{"name": "database-credentials"}},
+			"status": map[string]interface{}{
+				"refreshTime": refreshTime,
+				"conditions":  []interface{}{map[string]interface{}{"type": "Ready", "status": ready}},
+			},
+		}}
+	}
+	collectors := getCollectors()
+	changes := []struct {
+		description string
+		old         *unstructured.Unstructured
+		new         *unstructured.Unstructured
+		data        string
+		reloads     float64
+	}{
+		{"an update of its conditions", externalSecret("2026-10-15T10:00:00Z", "False"), externalSecret("2026-10-15T10:00:00Z", "True"), "", 0},
+		{"its first refresh", externalSecret("2026-10-15T10:00:00Z", "True"), externalSecret("2026-10-15T11:00:00Z", "True"), "", 1},
+		{"a refresh of the same data", externalSecret("2026-10-15T11:00:00Z", "True"), externalSecret("2026-10-15T12:00:00Z", "True"), "", 1},
+		{"a refresh of changed data", externalSecret("2026-10-15T12:00:00Z", "True"), externalSecret("2026-10-15T13:00:00Z", "True"), "new-password", 2},
+	}
+	for _, change := range changes {
+		if change.data != "" {
+			if _, err := clients.KubernetesClient.CoreV1().Secrets(externalSecretNamespace).Update(testutil.GetSecret(externalSecretNamespace, "database-credentials", change.data)); err != nil {
+				t.Fatalf("Failed to update secret: %v", err)
+			}
+		}
+		if err := handleExternalSecretUpdate(clients, change.old, change.new, collectors, nil); err != nil {
+			t.Errorf("Rolling upgrade failed for %s of the external secret: %v", change.description, err)
+		}
+		if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != change.reloads {
+			t.Errorf("After %s of the external secret, expected %v reloads, got %v", change.description, change.reloads, reloads)
+		}
+	}
+	// refreshes of the same data are no changes
+	if changesCount := promtestutil.ToFloat64(collectors.Changes); changesCount != 2 {
+		t.Errorf("Expected 2 changes of the external secret, got %v", changesCount)
+	}
+
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(externalSecretNamespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName("database") + "_" + constants.ExternalSecretEnvVarPostfix
+	found := false
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		found = found || env.Name == envName
+	}
+	if !found {
+		t.Errorf("Expected the deployment to hold the hash of the external secret in %s", envName)
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"testing"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHandleExternalChangeOfVaultPath(t *testing.T) {
+	vaultNamespace := "test-handler-vault-" + testutil.RandSeq(5)
+	name := "testdeployment-vault-" + testutil.RandSeq(5)
+	deploymentPaths := map[string]string{name: "secret/app/config,secret/app/db", name + "-other": "secret/other/db"}
+	for deploymentName, paths := range deploymentPaths {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(vaultNamespace, "unrelated-configmap")
+		deploymentObj.Name = deploymentName
+		deploymentObj.Annotations = map[string]string{options.VaultPathAnnotation: paths}
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(vaultNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	collectors := getCollectors()
+	// the same version is polled again and reloads only once
+	for i := 0; i < 2; i++ {
+		if err := HandleExternalChange(clients, external.Change{Type: constants.VaultEnvVarPostfix, Name: "secret/app/db", Version: "3"}, collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for Vault change: %v", err)
+		}
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 1 {
+		t.Errorf("Expected a single reload, got %v reloads", reloads)
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName("secret/app/db") + "_" + constants.VaultEnvVarPostfix
+	for deploymentName, expected := range map[string]bool{name: true, name + "-other": false} {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(vaultNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		found := false
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			found = found || env.Name == envName
+		}
+		if found != expected {
+			t.Errorf("Expected deployment %s to be reloaded %t, got %t", deploymentName, expected, found)
+		}
+	}
+}
+
+func TestHandleExternalChangeOfAWSSecret(t *testing.T) {
+	awsNamespace := "test-handler-aws-" + testutil.RandSeq(5)
+	name := "testdeployment-aws-" + testutil.RandSeq(5)
+	arn := "arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/db-AbCdEf"
+	deploymentSecrets := map[string]string{name: arn, name + "-pattern": "regex:arn:aws:secretsmanager:.*:secret:prod/db-.*", name + "-other": "arn:aws:secretsmanager:eu-west-1:123456789012:secret:prod/api-XyZ123"}
+	for deploymentName, secrets := range deploymentSecrets {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(awsNamespace, "unrelated-configmap")
+		deploymentObj.Name = deploymentName
+		deploymentObj.Annotations = map[string]string{options.AWSSecretAnnotation: secrets}
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(awsNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	collectors := getCollectors()
+	// the event is redelivered, as queues deliver at least once
+	for i := 0; i < 2; i++ {
+		if err := HandleExternalChange(clients, external.Change{Type: constants.AWSSecretEnvVarPostfix, Name: arn, Version: "event-1"}, collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for AWS secret change: %v", err)
+		}
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 2 {
+		t.Errorf("Expected each listing deployment to be reloaded once, got %v reloads", reloads)
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(arn) + "_" + constants.AWSSecretEnvVarPostfix
+	for deploymentName, expected := range map[string]bool{name: true, name + "-pattern": true, name + "-other": false} {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(awsNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		found := false
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			found = found || env.Name == envName
+		}
+		if found != expected {
+			t.Errorf("Expected deployment %s to be reloaded %t, got %t", deploymentName, expected, found)
+		}
+	}
+}
+
+func TestHandleExternalChangeOfGCPSecret(t *testing.T) {
+	gcpNamespace := "test-handler-gcp-" + testutil.RandSeq(5)
+	name := "testdeployment-gcp-" + testutil.RandSeq(5)
+	secret := "projects/123456789012/secrets/db"
+	deploymentSecrets := map[string]string{name: "projects/*/secrets/db", name + "-other": "projects/*/secrets/api"}
+	for deploymentName, secrets := range deploymentSecrets {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(gcpNamespace, "unrelated-configmap")
+		deploymentObj.Name = deploymentName
+		deploymentObj.Annotations = map[string]string{options.GCPSecretAnnotation: secrets}
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(gcpNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	if err := HandleExternalChange(clients, external.Change{Type: constants.GCPSecretEnvVarPostfix, Name: secret, Version: "4711"}, getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for GCP secret change: %v", err)
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(secret) + "_" + constants.GCPSecretEnvVarPostfix
+	for deploymentName, expected := range map[string]bool{name: true, name + "-other": false} {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(gcpNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		found := false
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			found = found || env.Name == envName
+		}
+		if found != expected {
+			t.Errorf("Expected deployment %s to be reloaded %t, got %t", deploymentName, expected, found)
+		}
+	}
+}
+
+func TestHandleExternalChangeOfAzureSecret(t *testing.T) {
+	azureNamespace := "test-handler-azure-" + testutil.RandSeq(5)
+	name := "testdeployment-azure-" + testutil.RandSeq(5)
+	secret := "https://my-vault.vault.azure.net/secrets/db"
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(azureNamespace, "unrelated-configmap")
+	deploymentObj.Name = name
+	deploymentObj.Annotations = map[string]string{options.AzureSecretAnnotation: secret}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(azureNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	collectors := getCollectors()
+	if err := HandleExternalChange(clients, external.Change{Type: constants.AzureSecretEnvVarPostfix, Name: secret, Version: "0a1b2c"}, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Azure secret change: %v", err)
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 1 {
+		t.Errorf("Expected the listing deployment to be reloaded, got %v reloads", reloads)
+	}
+}
+
+func TestHandleExternalChangeOfConsulKey(t *testing.T) {
+	consulNamespace := "test-handler-consul-" + testutil.RandSeq(5)
+	name := "testdeployment-consul-" + testutil.RandSeq(5)
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(consulNamespace, "unrelated-configmap")
+	deploymentObj.Name = name
+	deploymentObj.Annotations = map[string]string{options.ConsulKeyAnnotation: "config/app/*"}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(consulNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	collectors := getCollectors()
+	if err := HandleExternalChange(clients, external.Change{Type: constants.ConsulKeyEnvVarPostfix, Name: "config/other/db", Version: "12"}, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Consul key change: %v", err)
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 0 {
+		t.Errorf("Expected the deployment not to be reloaded by an unlisted key, got %v reloads", reloads)
+	}
+	if err := HandleExternalChange(clients, external.Change{Type: constants.ConsulKeyEnvVarPostfix, Name: "config/app/db", Version: "12"}, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Consul key change: %v", err)
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 1 {
+		t.Errorf("Expected the listing deployment to be reloaded, got %v reloads", reloads)
+	}
+}
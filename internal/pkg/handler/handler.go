@@ -4,7 +4,7 @@ import (
 	"github.com/stakater/Reloader/internal/pkg/util"
 )
 
-// ResourceHandler handles the creation and update of resources
+// ResourceHandler handles the creation, update and deletion of resources
 type ResourceHandler interface {
 	Handle() error
 	GetConfig() (util.Config, string)
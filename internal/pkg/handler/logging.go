@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/util"
+)
+
+// sourceLog returns the logger of the handling of the changes of the configmap or secret, with the
+// namespace and the trigger, "<type>/<name>" of the configmap or secret, on every line, and the ID of
+// the trace of the handling if it is traced
+func sourceLog(config util.Config) *logrus.Entry {
+	fields := logrus.Fields{
+		"namespace": config.Namespace,
+		"trigger":   strings.ToLower(config.Type) + "/" + sourceReference(config),
+	}
+	if traceID := config.Span.TraceID(); traceID != "" {
+		fields["trace_id"] = traceID
+	}
+	return logrus.WithFields(fields)
+}
+
+// workloadLog returns the logger of the reload of the named workload of the kind for the changes of
+// the configmap or secret, adding the kind and the workload to the fields of the source logger
+func workloadLog(config util.Config, kind string, name string) *logrus.Entry {
+	return sourceLog(config).WithFields(logrus.Fields{
+		"kind":     kind,
+		"workload": name,
+	})
+}
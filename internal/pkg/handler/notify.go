@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"github.com/stakater/Reloader/internal/pkg/notifier"
+	"github.com/stakater/Reloader/internal/pkg/util"
+)
+
+// Notifier is notified of performed and failed reloads, none are notified if nil
+var Notifier notifier.Notifier
+
+// notifyReload notifies the Notifier of the reload of the workload, or its failure, without waiting
+// for slow sinks
+func notifyReload(config util.Config, resourceType string, item interface{}, err error) {
+	notify(config, resourceType, item, notifier.Reloaded, notifier.Failed, err)
+}
+
+// notifyRollout notifies the Notifier of the completed, or failed, rollout of the reloaded workload
+func notifyRollout(config util.Config, resourceType string, item interface{}, err error) {
+	notify(config, resourceType, item, notifier.RolloutSucceeded, notifier.RolloutFailed, err)
+}
+
+func notify(config util.Config, resourceType string, item interface{}, result string, failedResult string, err error) {
+	if Notifier == nil {
+		return
+	}
+	notification := notifier.Notification{
+		Time:       deferred.clock.Now().UTC(),
+		Namespace:  config.Namespace,
+		Kind:       resourceType,
+		Name:       util.ToObjectMeta(item).Name,
+		SourceType: config.Type,
+		SourceName: config.ResourceName,
+		Result:     result,
+	}
+	if config.Owner != nil {
+		notification.SourceOwnerKind = config.Owner.Kind
+		notification.SourceOwnerName = config.Owner.Name
+	}
+	if err != nil {
+		notification.Result = failedResult
+		notification.Error = err.Error()
+	}
+	go Notifier.Notify(notification)
+}
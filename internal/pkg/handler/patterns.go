@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// regexPrefix marks the values of reload annotations that are regular expressions
+const regexPrefix = "regex:"
+
+// compiledPatterns caches the patterns compiled from the values of reload annotations, so each is
+// compiled once for all workloads and events. Invalid patterns are cached as nil
+var compiledPatterns = struct {
+	lock     sync.Mutex
+	patterns map[string]*regexp.Regexp
+}{patterns: map[string]*regexp.Regexp{}}
+
+// isPattern checks whether the value of a reload annotation is a wildcard or regex pattern rather
+// than a name
+func isPattern(value string) bool {
+	return strings.HasPrefix(value, regexPrefix) || strings.ContainsAny(value, "*?")
+}
+
+// matchesValue checks whether the reference of a configmap or secret, its name or "<namespace>/<name>",
+// matches the value of a reload annotation. Values prefixed with "regex:" are regular expressions
+// matching the whole reference, values with "*" or "?" are wildcards not matching "/", all others
+// are names
+func matchesValue(value string, reference string) bool {
+	if !isPattern(value) {
+		return value == reference
+	}
+	pattern := compilePattern(value)
+	return pattern != nil && pattern.MatchString(reference)
+}
+
+func compilePattern(value string) *regexp.Regexp {
+	compiledPatterns.lock.Lock()
+	defer compiledPatterns.lock.Unlock()
+	if pattern, found := compiledPatterns.patterns[value]; found {
+		return pattern
+	}
+
+	var expression string
+	if strings.HasPrefix(value, regexPrefix) {
+		expression = "^(?:" + strings.TrimPrefix(value, regexPrefix) + ")$"
+	} else {
+		expression = regexp.QuoteMeta(value)
+		expression = strings.Replace(expression, `\*`, "[^/]*", -1)
+		expression = strings.Replace(expression, `\?`, "[^/]", -1)
+		expression = "^" + expression + "$"
+	}
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		logrus.Warnf("Ignoring invalid pattern '%s' of reload annotations: %v", value, err)
+		pattern = nil
+	}
+	compiledPatterns.patterns[value] = pattern
+	return pattern
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+)
+
+func TestMatchesValue(t *testing.T) {
+	tests := []struct {
+		value     string
+		reference string
+		matches   bool
+	}{
+		{"app-config", "app-config", true},
+		{"app-config", "app-config-2", false},
+		{"app-*-credentials", "app-db-credentials", true},
+		{"app-*-credentials", "app-credentials", false},
+		{"app-?-config", "app-1-config", true},
+		{"*-config", "shared/app-config", false},
+		{"shared/*-config", "shared/app-config", true},
+		{"regex:app-(blue|green)-config", "app-green-config", true},
+		{"regex:app-(blue|green)-config", "app-red-config", false},
+		{"regex:app", "my-app-config", false},
+		{"regex:app-(", "app-(", false},
+	}
+	for _, test := range tests {
+		if matches := matchesValue(test.value, test.reference); matches != test.matches {
+			t.Errorf("Expected '%s' matching '%s' to be %t, got %t", test.value, test.reference, test.matches, matches)
+		}
+	}
+	if compilePattern("app-*-credentials") != compilePattern("app-*-credentials") {
+		t.Errorf("Pattern was compiled again")
+	}
+}
+
+func TestRollingUpgradeWithPatternAnnotation(t *testing.T) {
+	patternNamespace := "test-handler-pattern-" + testutil.RandSeq(5)
+	name := "app-" + testutil.RandSeq(5) + "-credentials"
+	deployments := map[string]string{
+		"pattern-wildcard": "app-*-credentials",
+		"pattern-regex":    "regex:app-[a-z]{5}-credentials",
+		"pattern-other":    "other-*",
+	}
+	for deploymentName, value := range deployments {
+		annotations := map[string]string{options.SecretUpdateOnChangeAnnotation: value}
+		if err := createDeploymentReferencingConfigmap(clients, patternNamespace, deploymentName, deploymentName, annotations); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.SecretResourceType, patternNamespace, name, "dGVzdFVwZGF0ZWRTZWNyZXRFbmNvZGluZ0ZvclJlbG9hZGVy")
+	config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, name, shaData, options.SecretUpdateOnChangeAnnotation)
+	config.Namespace = patternNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	for deploymentName := range deployments {
+		if updated, expected := isDeploymentUpdated(t, clients, config, deploymentName), deploymentName != "pattern-other"; updated != expected {
+			t.Errorf("Expected deployment %s to be updated %t, got %t", deploymentName, expected, updated)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stakater/Reloader/pkg/kube"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SourceResolver resolves the name of the configmap a workload references through an indirection
+type SourceResolver interface {
+	// Resolve returns the configmap name held by the reference, in the namespace of the workload
+	Resolve(clients kube.Clients, namespace string, annotations map[string]string, reference string) (string, error)
+}
+
+// SourceResolvers are the resolvers by the kind of indirection leading a resolve-source-from
+// reference, e.g. "secret" in "secret/app-refs/configmap"
+var SourceResolvers = map[string]SourceResolver{
+	"configmap":  configmapKeyResolver{},
+	"secret":     secretKeyResolver{},
+	"annotation": annotationResolver{},
+}
+
+// configmapKeyResolver resolves "<configmap>/<key>" to the value of the key in the configmap
+type configmapKeyResolver struct{}
+
+func (configmapKeyResolver) Resolve(clients kube.Clients, namespace string, annotations map[string]string, reference string) (string, error) {
+	name, key, err := splitKeyReference(reference)
+	if err != nil {
+		return "", err
+	}
+	configmap, err := clients.KubernetesClient.CoreV1().ConfigMaps(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	value, found := configmap.Data[key]
+	if !found {
+		return "", fmt.Errorf("configmap '%s' has no key '%s'", name, key)
+	}
+	return value, nil
+}
+
+// secretKeyResolver resolves "<secret>/<key>" to the value of the key in the secret
+type secretKeyResolver struct{}
+
+func (secretKeyResolver) Resolve(clients kube.Clients, namespace string, annotations map[string]string, reference string) (string, error) {
+	name, key, err := splitKeyReference(reference)
+	if err != nil {
+		return "", err
+	}
+	secret, err := clients.KubernetesClient.CoreV1().Secrets(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	value, found := secret.Data[key]
+	if !found {
+		return "", fmt.Errorf("secret '%s' has no key '%s'", name, key)
+	}
+	return string(value), nil
+}
+
+// annotationResolver resolves "<annotation>" to the value of the annotation on the workload
+type annotationResolver struct{}
+
+func (annotationResolver) Resolve(clients kube.Clients, namespace string, annotations map[string]string, reference string) (string, error) {
+	value, found := annotations[reference]
+	if !found {
+		return "", fmt.Errorf("workload has no annotation '%s'", reference)
+	}
+	return value, nil
+}
+
+func splitKeyReference(reference string) (string, string, error) {
+	parts := strings.SplitN(reference, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid reference '%s', expected '<name>/<key>'", reference)
+	}
+	return parts[0], parts[1], nil
+}
+
+// resolveSource follows the resolve-source-from reference of a workload one hop to the name of the
+// configmap it references. A reference resolving to another reference or to the configmap holding
+// it is rejected, as only one hop is followed
+func resolveSource(clients kube.Clients, namespace string, annotations map[string]string, reference string) (string, error) {
+	parts := strings.SplitN(reference, "/", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid reference '%s', expected '<kind>/<reference>'", reference)
+	}
+	resolver, found := SourceResolvers[parts[0]]
+	if !found {
+		return "", fmt.Errorf("no resolver for reference '%s'", reference)
+	}
+
+	name, err := resolver.Resolve(clients, namespace, annotations, parts[1])
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("reference '%s' resolves to an empty name", reference)
+	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("reference '%s' resolves to another reference '%s', only one hop is followed", reference, name)
+	}
+	if parts[0] == "configmap" && strings.HasPrefix(parts[1], name+"/") {
+		return "", fmt.Errorf("reference '%s' resolves to the configmap holding it", reference)
+	}
+	return name, nil
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	core_v1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveSource(t *testing.T) {
+	resolverNamespace := "test-handler-resolver-" + testutil.RandSeq(5)
+	_, err := clients.KubernetesClient.CoreV1().Secrets(resolverNamespace).Create(&core_v1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "app-refs", Namespace: resolverNamespace},
+		Data:       map[string][]byte{"configmap": []byte("app-config"), "nested": []byte("secret/other-refs/configmap")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	_, err = clients.KubernetesClient.CoreV1().ConfigMaps(resolverNamespace).Create(&core_v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "cm-refs", Namespace: resolverNamespace},
+		Data:       map[string]string{"configmap": "app-config", "self": "cm-refs"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create configmap: %v", err)
+	}
+	annotations := map[string]string{"example.com/config-name": "app-config"}
+
+	resolved := []string{"secret/app-refs/configmap", "configmap/cm-refs/configmap", "annotation/example.com/config-name"}
+	for _, reference := range resolved {
+		name, err := resolveSource(clients, resolverNamespace, annotations, reference)
+		if err != nil {
+			t.Errorf("Unable to resolve %s: %v", reference, err)
+		} else if name != "app-config" {
+			t.Errorf("Expected %s to resolve to app-config, got %s", reference, name)
+		}
+	}
+
+	rejected := map[string]string{
+		"secret/app-refs/nested":  "one hop",
+		"configmap/cm-refs/self":  "holding it",
+		"secret/app-refs/missing": "no key",
+		"unknown/app-refs/key":    "no resolver",
+	}
+	for reference, reason := range rejected {
+		_, err := resolveSource(clients, resolverNamespace, annotations, reference)
+		if err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("Expected %s to be rejected with '%s', got %v", reference, reason, err)
+		}
+	}
+}
+
+func TestRollingUpgradeForDeploymentResolvingSourceFromSecret(t *testing.T) {
+	resolverNamespace := "test-handler-resolver-upgrade-" + testutil.RandSeq(5)
+	name := "testconfigmap-resolver-" + testutil.RandSeq(5)
+	_, err := clients.KubernetesClient.CoreV1().Secrets(resolverNamespace).Create(&core_v1.Secret{
+		ObjectMeta: v1.ObjectMeta{Name: "app-refs", Namespace: resolverNamespace},
+		Data:       map[string][]byte{"configmap": []byte(name)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create secret: %v", err)
+	}
+	deploymentObj := testutil.GetDeployment(resolverNamespace, name+"-deployment")
+	deploymentObj.Annotations = map[string]string{options.ResolveSourceFromAnnotation: "secret/app-refs/configmap"}
+	if _, err = clients.KubernetesClient.AppsV1().Deployments(resolverNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, resolverNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = resolverNamespace
+	if err = PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if !isDeploymentUpdated(t, clients, config, name+"-deployment") {
+		t.Errorf("Deployment referencing the configmap through a secret was not updated")
+	}
+
+	other := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name+"-other", shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	other.Namespace = resolverNamespace
+	if err = PerformRollingUpgrade(clients, other, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if isDeploymentUpdated(t, clients, other, name+"-deployment") {
+		t.Errorf("Deployment was updated for a configmap it does not reference")
+	}
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// monitoredRollout is the rollout of a workload reloaded for a configmap or secret change
+type monitoredRollout struct {
+	clients      kube.Clients
+	config       util.Config
+	upgradeFuncs callbacks.RollingUpgradeFuncs
+	collectors   metrics.Collectors
+	name         string
+	startedAt    time.Time
+	// previousTemplate is the pod template before the reload, the reload is rolled back to it once the
+	// rollout fails, if set
+	previousTemplate *v1.PodTemplateSpec
+}
+
+// rolloutMonitor checks the rollouts of reloaded workloads until they complete, fail or time out.
+// Only the rollout of the latest reload of a workload is monitored
+type rolloutMonitor struct {
+	lock    sync.Mutex
+	pending map[string]*monitoredRollout
+	queue   workqueue.DelayingInterface
+	start   sync.Once
+}
+
+var rollouts = newRolloutMonitor()
+
+func newRolloutMonitor() *rolloutMonitor {
+	return &rolloutMonitor{
+		pending: make(map[string]*monitoredRollout),
+		queue:   workqueue.NewDelayingQueue(),
+	}
+}
+
+// add monitors the rollout of the reloaded workload, if it has any and rollouts are monitored or the
+// reload is rolled back to the previous template once its rollout fails
+func (m *rolloutMonitor) add(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors, item interface{}, previousTemplate *v1.PodTemplateSpec) {
+	if (!options.MonitorRollouts && previousTemplate == nil) || upgradeFuncs.RolloutInProgressFunc == nil {
+		return
+	}
+	name := util.ToObjectMeta(item).Name
+	key := workloadKey(upgradeFuncs, config.Namespace, name)
+	m.lock.Lock()
+	m.pending[key] = &monitoredRollout{
+		clients:          clients,
+		config:           config,
+		upgradeFuncs:     upgradeFuncs,
+		collectors:       collectors,
+		name:             name,
+		startedAt:        deferred.clock.Now(),
+		previousTemplate: previousTemplate,
+	}
+	m.lock.Unlock()
+
+	m.start.Do(func() {
+		go m.run()
+	})
+	m.queue.AddAfter(key, options.RolloutCheckInterval)
+}
+
+func (m *rolloutMonitor) run() {
+	for {
+		key, quit := m.queue.Get()
+		if quit {
+			return
+		}
+		m.check(key.(string))
+		m.queue.Done(key)
+	}
+}
+
+// check reports the rollout of the key once it completed, failed or timed out, and checks it again
+// after the check interval otherwise
+func (m *rolloutMonitor) check(key string) {
+	m.lock.Lock()
+	rollout, found := m.pending[key]
+	m.lock.Unlock()
+	if !found {
+		return
+	}
+	log := workloadLog(rollout.config, rollout.upgradeFuncs.ResourceType, rollout.name)
+
+	var item interface{}
+	for _, i := range rollout.upgradeFuncs.ItemsFunc(rollout.clients, rollout.config.Namespace) {
+		if util.ToObjectMeta(i).Name == rollout.name {
+			item = i
+			break
+		}
+	}
+	if item == nil {
+		log.Infof("Dropping the monitoring of the rollout of '%s' of type '%s' in namespace '%s', it no longer exists", rollout.name, rollout.upgradeFuncs.ResourceType, rollout.config.Namespace)
+		m.done(key, rollout)
+		return
+	}
+
+	var err error
+	if rollout.upgradeFuncs.RolloutFailedFunc != nil {
+		if reason := rollout.upgradeFuncs.RolloutFailedFunc(item); reason != "" {
+			err = fmt.Errorf("%s", reason)
+		}
+	}
+	if err == nil && rollout.upgradeFuncs.RolloutInProgressFunc(item) {
+		timeout := rolloutTimeout(rollout.upgradeFuncs.ResourceType)
+		if elapsed := deferred.clock.Since(rollout.startedAt); elapsed < timeout {
+			m.queue.AddAfter(key, options.RolloutCheckInterval)
+			return
+		}
+		err = fmt.Errorf("not completed within %s", timeout)
+	}
+
+	kind := rollout.upgradeFuncs.ResourceType
+	if err != nil {
+		log.Errorf("Rollout of '%s' of type '%s' in namespace '%s' after changes in '%s' of type '%s' failed: %v", rollout.name, kind, rollout.config.Namespace, rollout.config.ResourceName, rollout.config.Type, err)
+		rollout.collectors.CountRollout(rollout.config.Namespace, kind, metrics.RolloutFailed)
+	} else {
+		log.Infof("Rolled out '%s' of type '%s' in namespace '%s' after changes in '%s' of type '%s'", rollout.name, kind, rollout.config.Namespace, rollout.config.ResourceName, rollout.config.Type)
+		rollout.collectors.CountRollout(rollout.config.Namespace, kind, metrics.RolloutSucceeded)
+	}
+	recordRolloutEvent(rollout.clients, rollout.config, kind, item, err)
+	notifyRollout(rollout.config, kind, item, err)
+	if err != nil && rollout.previousTemplate != nil {
+		rollBack(rollout, item)
+	}
+	m.done(key, rollout)
+}
+
+// templateToRollBackTo returns a copy of the pod template of the workload before its reload, if the
+// workload has the auto-rollback annotation
+func templateToRollBackTo(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, annotations map[string]string) *v1.PodTemplateSpec {
+	if autoRollback, _ := strconv.ParseBool(annotations[options.AutoRollbackAnnotation]); !autoRollback || upgradeFuncs.PodTemplateFunc == nil {
+		return nil
+	}
+	template := upgradeFuncs.PodTemplateFunc(item)
+	if template == nil {
+		return nil
+	}
+	return template.DeepCopy()
+}
+
+// rollBack reverts the env vars and the annotation Reloader injected into the pod template of the
+// workload to their values before the reload, leaving all other changes of the template untouched
+func rollBack(rollout *monitoredRollout, item interface{}) {
+	template := rollout.upgradeFuncs.PodTemplateFunc(item)
+	if template == nil {
+		return
+	}
+	original := originalWorkload(item)
+	reloaded := template.DeepCopy()
+	restoreReloaderEnvVars(template.Spec.Containers, rollout.previousTemplate.Spec.Containers)
+	restoreReloaderEnvVars(template.Spec.InitContainers, rollout.previousTemplate.Spec.InitContainers)
+	if value, found := rollout.previousTemplate.Annotations[options.LastReloadedFromAnnotation]; found {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[options.LastReloadedFromAnnotation] = value
+	} else {
+		delete(template.Annotations, options.LastReloadedFromAnnotation)
+	}
+	if equality.Semantic.DeepEqual(reloaded, template) {
+		return
+	}
+
+	kind := rollout.upgradeFuncs.ResourceType
+	log := workloadLog(rollout.config, kind, rollout.name)
+	err := persistingFuncs(rollout.upgradeFuncs, original, rollout.collectors).UpdateFunc(rollout.clients, rollout.config.Namespace, item)
+	if err != nil {
+		log.Errorf("Rollback of '%s' of type '%s' in namespace '%s' after its failed rollout failed with error %v", rollout.name, kind, rollout.config.Namespace, err)
+	} else {
+		log.Infof("Rolled back '%s' of type '%s' in namespace '%s' to its config before changes in '%s' of type '%s'", rollout.name, kind, rollout.config.Namespace, rollout.config.ResourceName, rollout.config.Type)
+	}
+	recordRollbackEvent(rollout.clients, rollout.config, kind, item, err)
+}
+
+// restoreReloaderEnvVars sets the env vars of Reloader in the containers to their values in the
+// previous containers of the same name, removing those the previous containers do not have
+func restoreReloaderEnvVars(containers []v1.Container, previousContainers []v1.Container) {
+	previousValues := map[string]map[string]string{}
+	for _, container := range previousContainers {
+		previousValues[container.Name] = map[string]string{}
+		for _, env := range container.Env {
+			if strings.HasPrefix(env.Name, constants.EnvVarPrefix) {
+				previousValues[container.Name][env.Name] = env.Value
+			}
+		}
+	}
+	for i := range containers {
+		env := containers[i].Env[:0]
+		for _, envVar := range containers[i].Env {
+			if strings.HasPrefix(envVar.Name, constants.EnvVarPrefix) {
+				value, found := previousValues[containers[i].Name][envVar.Name]
+				if !found {
+					continue
+				}
+				envVar.Value = value
+			}
+			env = append(env, envVar)
+		}
+		containers[i].Env = env
+	}
+}
+
+// done stops the monitoring of the rollout, unless a newer reload of the workload replaced it
+func (m *rolloutMonitor) done(key string, rollout *monitoredRollout) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.pending[key] == rollout {
+		delete(m.pending, key)
+	}
+}
+
+// rolloutTimeout returns the rollout timeout of the kind of workload
+func rolloutTimeout(kind string) time.Duration {
+	for _, timeout := range options.RolloutTimeouts {
+		parts := strings.SplitN(timeout, "=", 2)
+		if len(parts) != 2 || parts[0] != kind {
+			continue
+		}
+		if duration, err := time.ParseDuration(parts[1]); err == nil {
+			return duration
+		}
+	}
+	return options.RolloutTimeout
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// doRollover moves the workloads referencing older generations of the created configmap or secret,
+// named by the rollover annotation of the workload, to the created one
+func doRollover(config util.Config, created meta_v1.Time, collectors metrics.Collectors) {
+	if !watchesNamespace(config.Namespace) || ignoredSource(config, collectors) {
+		return
+	}
+	clients := kube.GetClients()
+	for _, upgradeFuncs := range workloadKinds() {
+		if err := rollover(clients, config, created, upgradeFuncs, collectors); err != nil {
+			logrus.Errorf("Rollover to '%s' failed with error = %v", config.ResourceName, err)
+		}
+	}
+}
+
+// rollover replaces the references of the workloads to older generations of the created configmap or
+// secret, the configmaps or secrets matching the same rollover annotation, by references to it
+func rollover(clients kube.Clients, config util.Config, created meta_v1.Time, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors) error {
+	var err error
+	for _, item := range upgradeFuncs.ItemsFunc(clients, config.Namespace) {
+		if !ownedByInstance(upgradeFuncs, item) || upgradeFuncs.PodTemplateFunc == nil {
+			continue
+		}
+		generation, found := upgradeFuncs.AnnotationsFunc(item)[rolloverAnnotation(config)]
+		if !found || !matchesGeneration(generation, config.ResourceName) {
+			continue
+		}
+		template := upgradeFuncs.PodTemplateFunc(item)
+		if template == nil {
+			continue
+		}
+		previous := map[string]bool{}
+		for _, name := range referencedNames(template.Spec, config.Type) {
+			if name != config.ResourceName && matchesGeneration(generation, name) && olderGeneration(clients, config, name, created) {
+				previous[name] = true
+			}
+		}
+		if len(previous) == 0 {
+			continue
+		}
+
+		name := util.ToObjectMeta(item).Name
+		log := workloadLog(config, upgradeFuncs.ResourceType, name)
+		if options.ObserveOnly {
+			log.Infof("Observe-only: would roll '%s' of type '%s' in namespace '%s' over to '%s'", name, upgradeFuncs.ResourceType, config.Namespace, config.ResourceName)
+			recordObservedReloadEvent(clients, config, upgradeFuncs.ResourceType, item)
+			collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonObserveOnly}).Inc()
+			continue
+		}
+		original := originalWorkload(item)
+		replaceReferences(&template.Spec, config.Type, previous, config.ResourceName)
+		if updateErr := persistingFuncs(upgradeFuncs, original, collectors).UpdateFunc(clients, config.Namespace, item); updateErr != nil {
+			log.Errorf("Rollover of '%s' of type '%s' in namespace '%s' to '%s' failed with error %v", name, upgradeFuncs.ResourceType, config.Namespace, config.ResourceName, updateErr)
+			collectors.CountReload(config.Namespace, upgradeFuncs.ResourceType, config.Type, false)
+			recordReloadEvent(clients, config, upgradeFuncs.ResourceType, item, updateErr)
+			notifyReload(config, upgradeFuncs.ResourceType, item, updateErr)
+			err = updateErr
+			continue
+		}
+		log.Infof("Rolled '%s' of type '%s' in namespace '%s' over to '%s'", name, upgradeFuncs.ResourceType, config.Namespace, config.ResourceName)
+		collectors.CountReload(config.Namespace, upgradeFuncs.ResourceType, config.Type, true)
+		recordReloadEvent(clients, config, upgradeFuncs.ResourceType, item, nil)
+		notifyReload(config, upgradeFuncs.ResourceType, item, nil)
+		countTeamReload(item, collectors)
+		countWorkloadReload(item, upgradeFuncs.ResourceType, config, collectors)
+	}
+	return err
+}
+
+// rolloverAnnotation returns the rollover annotation of the type of the created configmap or secret
+func rolloverAnnotation(config util.Config) string {
+	if config.Type == constants.SecretEnvVarPostfix {
+		return options.SecretRolloverAnnotation
+	}
+	return options.ConfigmapRolloverAnnotation
+}
+
+// matchesGeneration checks whether the name is a generation of the rollover annotation value, a
+// prefix or a wildcard or regex pattern
+func matchesGeneration(value string, name string) bool {
+	if isPattern(value) {
+		return matchesValue(value, name)
+	}
+	return value != "" && strings.HasPrefix(name, value)
+}
+
+// olderGeneration checks whether the configmap or secret of the name was created before the created
+// one or no longer exists, so references are never moved back to an older generation
+func olderGeneration(clients kube.Clients, config util.Config, name string, created meta_v1.Time) bool {
+	var object meta_v1.Object
+	var err error
+	if config.Type == constants.SecretEnvVarPostfix {
+		object, err = clients.KubernetesClient.CoreV1().Secrets(config.Namespace).Get(name, meta_v1.GetOptions{})
+	} else {
+		object, err = clients.KubernetesClient.CoreV1().ConfigMaps(config.Namespace).Get(name, meta_v1.GetOptions{})
+	}
+	if errors.IsNotFound(err) {
+		return true
+	}
+	if err != nil {
+		sourceLog(config).Warnf("Unable to get '%s' of type '%s' in namespace '%s' to roll over from: %v", name, config.Type, config.Namespace, err)
+		return false
+	}
+	creationTimestamp := object.GetCreationTimestamp()
+	return creationTimestamp.Before(&created)
+}
+
+// referencedNames returns the names of the configmaps or secrets of the type the pod spec references
+// in the env of its containers and init containers and in its volumes
+func referencedNames(spec v1.PodSpec, resourceType string) []string {
+	var names []string
+	visitReferences(&spec, resourceType, func(name *string) {
+		names = append(names, *name)
+	})
+	return names
+}
+
+// replaceReferences replaces the references of the pod spec to the previous configmaps or secrets of
+// the type by references to the named one
+func replaceReferences(spec *v1.PodSpec, resourceType string, previous map[string]bool, name string) {
+	visitReferences(spec, resourceType, func(reference *string) {
+		if previous[*reference] {
+			*reference = name
+		}
+	})
+}
+
+// visitReferences calls visit with the name of every reference of the pod spec to a configmap or
+// secret of the type
+func visitReferences(spec *v1.PodSpec, resourceType string, visit func(name *string)) {
+	containers := [][]v1.Container{spec.Containers, spec.InitContainers}
+	for _, list := range containers {
+		for i := range list {
+			for j := range list[i].Env {
+				source := list[i].Env[j].ValueFrom
+				if source == nil {
+					continue
+				}
+				if resourceType == constants.SecretEnvVarPostfix && source.SecretKeyRef != nil {
+					visit(&source.SecretKeyRef.Name)
+				} else if resourceType == constants.ConfigmapEnvVarPostfix && source.ConfigMapKeyRef != nil {
+					visit(&source.ConfigMapKeyRef.Name)
+				}
+			}
+			for j := range list[i].EnvFrom {
+				envFrom := &list[i].EnvFrom[j]
+				if resourceType == constants.SecretEnvVarPostfix && envFrom.SecretRef != nil {
+					visit(&envFrom.SecretRef.Name)
+				} else if resourceType == constants.ConfigmapEnvVarPostfix && envFrom.ConfigMapRef != nil {
+					visit(&envFrom.ConfigMapRef.Name)
+				}
+			}
+		}
+	}
+	for i := range spec.Volumes {
+		volume := &spec.Volumes[i]
+		if resourceType == constants.SecretEnvVarPostfix && volume.Secret != nil {
+			visit(&volume.Secret.SecretName)
+		} else if resourceType == constants.ConfigmapEnvVarPostfix && volume.ConfigMap != nil {
+			visit(&volume.ConfigMap.Name)
+		}
+		if volume.Projected == nil {
+			continue
+		}
+		for j := range volume.Projected.Sources {
+			source := &volume.Projected.Sources[j]
+			if resourceType == constants.SecretEnvVarPostfix && source.Secret != nil {
+				visit(&source.Secret.Name)
+			} else if resourceType == constants.ConfigmapEnvVarPostfix && source.ConfigMap != nil {
+				visit(&source.ConfigMap.Name)
+			}
+		}
+	}
+}
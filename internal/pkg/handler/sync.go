@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
+)
+
+// ResourceSyncedHandler contains the objects found when syncing the workloads with the configmaps and
+// secrets, at startup or on periodic resyncs
+type ResourceSyncedHandler struct {
+	Resource   interface{}
+	Collectors metrics.Collectors
+	// Span is the span of the sync of the resource, nil if it is not traced
+	Span *tracing.Span
+}
+
+// Handle reloads the workloads holding an other hash of the resource than its current one, which
+// missed its changes while Reloader was down
+func (r ResourceSyncedHandler) Handle() error {
+	if r.Resource == nil {
+		logrus.Errorf("Resource sync handler received nil resource")
+		return nil
+	}
+	span := r.Span.Start("compute hash")
+	config, _ := ResourceCreatedHandler{Resource: r.Resource, Collectors: r.Collectors}.GetConfig()
+	span.End(nil)
+	config.Span = r.Span
+	config.DriftedOnly = true
+	return doRollingUpgrade(config, r.Collectors)
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/crypto"
+	"github.com/stakater/Reloader/internal/pkg/history"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/trigger"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConsumeTriggers reloads the workloads named by the messages of the consumer until the stop channel is closed
+func ConsumeTriggers(consumer trigger.Consumer, clients kube.Clients, collectors metrics.Collectors, stopCh <-chan struct{}) {
+	messages := make(chan trigger.Message)
+	go consumer.Consume(messages, stopCh)
+	for {
+		select {
+		case message := <-messages:
+			if err := HandleTrigger(clients, message, collectors); err != nil {
+				logrus.Errorf("Trigger message '%s' failed with error %v", message.ID, err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// HandleTrigger reloads the workloads named by the message with their reload strategy
+func HandleTrigger(clients kube.Clients, message trigger.Message, collectors metrics.Collectors) error {
+	if message.Name == "" && message.Selector == "" {
+		return fmt.Errorf("trigger message names neither a workload nor a selector")
+	}
+	selector, err := labels.Parse(message.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector '%s': %v", message.Selector, err)
+	}
+	value := message.ID
+	if value == "" {
+		value = crypto.GenerateSHA(time.Now().String())
+	}
+	// the trigger is reported like the change of a source named after the message
+	config := util.Config{
+		Namespace:    message.Namespace,
+		ResourceName: "message " + message.ID,
+		SHAValue:     value,
+		Type:         constants.TriggerEnvVarPostfix,
+	}
+
+	for _, upgradeFuncs := range workloadKinds() {
+		if message.Kind != "" && message.Kind != upgradeFuncs.ResourceType {
+			continue
+		}
+		for _, item := range upgradeFuncs.ItemsFunc(clients, message.Namespace) {
+			meta := util.ToObjectMeta(item)
+			if (message.Name != "" && meta.Name != message.Name) || !selector.Matches(labels.Set(meta.Labels)) {
+				continue
+			}
+			if itemErr := triggerItem(clients, config, upgradeFuncs, collectors, item); itemErr != nil {
+				err = itemErr
+			}
+		}
+	}
+	return err
+}
+
+// triggerItem reloads a single workload for a trigger message, unless it already was for the message
+func triggerItem(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors, item interface{}) error {
+	name := util.ToObjectMeta(item).Name
+	log := workloadLog(config, upgradeFuncs.ResourceType, name)
+	if !ownedByInstance(upgradeFuncs, item) {
+		return nil
+	}
+	containers := upgradeFuncs.ContainersFunc(item)
+	if len(containers) == 0 {
+		return nil
+	}
+	annotations := upgradeFuncs.AnnotationsFunc(item)
+	strategyName := reloadStrategyName(clients, upgradeFuncs, util.ToObjectMeta(item).Namespace, annotations)
+	previousTemplate := templateToRollBackTo(upgradeFuncs, item, annotations)
+	var result constants.Result
+	if strategyName == callbacks.AnnotationsStrategy {
+		result = updatePodAnnotation(log, upgradeFuncs, item, config)
+	} else {
+		envar := constants.EnvVarPrefix + constants.TriggerEnvVarPostfix
+		result = updateEnvVar(containers, envar, config.SHAValue)
+		if result == constants.NoEnvVarFound {
+			containers[0].Env = append(containers[0].Env, v1.EnvVar{Name: envar, Value: config.SHAValue})
+		}
+	}
+	if result == constants.NotUpdated {
+		log.Infof("Ignoring trigger %s of '%s' of type '%s' in namespace '%s', it was already applied", config.ResourceName, name, upgradeFuncs.ResourceType, config.Namespace)
+		return nil
+	}
+
+	if options.ObserveOnly {
+		log.Infof("Observe-only: would update '%s' of type '%s' in namespace '%s' for trigger %s", name, upgradeFuncs.ResourceType, config.Namespace, config.ResourceName)
+		recordDecision(config, upgradeFuncs, name, history.Skipped, metrics.SkipReasonObserveOnly)
+		recordObservedReloadEvent(clients, config, upgradeFuncs.ResourceType, item)
+		collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonObserveOnly}).Inc()
+		return nil
+	}
+
+	strategy, err := reloadStrategy(strategyName)
+	if err == nil {
+		err = strategy.Apply(clients, upgradeFuncs, config.Namespace, item, config.SHAValue)
+	}
+	if err != nil {
+		log.Errorf("Update for '%s' of type '%s' in namespace '%s' failed with error %v", name, upgradeFuncs.ResourceType, config.Namespace, err)
+		collectors.CountReload(config.Namespace, upgradeFuncs.ResourceType, config.Type, false)
+		recordDecision(config, upgradeFuncs, name, history.Failed, err.Error())
+		recordReloadEvent(clients, config, upgradeFuncs.ResourceType, item, err)
+		notifyReload(config, upgradeFuncs.ResourceType, item, err)
+		return err
+	}
+	log.Infof("Updated '%s' of type '%s' in namespace '%s' for trigger %s", name, upgradeFuncs.ResourceType, config.Namespace, config.ResourceName)
+	collectors.CountReload(config.Namespace, upgradeFuncs.ResourceType, config.Type, true)
+	recordDecision(config, upgradeFuncs, name, history.Reloaded, "")
+	recordReloadEvent(clients, config, upgradeFuncs.ResourceType, item, nil)
+	notifyReload(config, upgradeFuncs.ResourceType, item, nil)
+	rollouts.add(clients, config, upgradeFuncs, collectors, item, previousTemplate)
+	countTeamReload(item, collectors)
+	return nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	"github.com/stakater/Reloader/internal/pkg/trigger"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeConsumer delivers its messages, then waits to be stopped
+type fakeConsumer struct {
+	messages  []trigger.Message
+	delivered chan struct{}
+}
+
+func (c fakeConsumer) Consume(messages chan<- trigger.Message, stopCh <-chan struct{}) {
+	for _, message := range c.messages {
+		messages <- message
+	}
+	close(c.delivered)
+	<-stopCh
+}
+
+func TestConsumeTriggersReloadsSelectedWorkloads(t *testing.T) {
+	triggerNamespace := "test-handler-trigger-" + testutil.RandSeq(5)
+	name := "testdeployment-trigger-" + testutil.RandSeq(5)
+	deploymentLabels := map[string]map[string]string{name: {"team": "payments"}, name + "-other": {"team": "orders"}}
+	for deploymentName, labels := range deploymentLabels {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(triggerNamespace, "unrelated-configmap")
+		deploymentObj.Name = deploymentName
+		deploymentObj.Labels = labels
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(triggerNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	message := trigger.Message{ID: "4711", Namespace: triggerNamespace, Kind: "Deployment", Selector: "team=payments"}
+	// the message is redelivered, as queues deliver at least once
+	consumer := fakeConsumer{messages: []trigger.Message{message, message}, delivered: make(chan struct{})}
+	collectors := getCollectors()
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		ConsumeTriggers(consumer, clients, collectors, stopCh)
+		close(done)
+	}()
+	select {
+	case <-consumer.delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Trigger messages were not consumed")
+	}
+	close(stopCh)
+	<-done
+
+	envName := constants.EnvVarPrefix + constants.TriggerEnvVarPostfix
+	for deploymentName, expected := range map[string]string{name: "4711", name + "-other": ""} {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(triggerNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		if got := testutil.GetResourceSHA(deployment.Spec.Template.Spec.Containers, envName); got != expected {
+			t.Errorf("Deployment %s has trigger %q, expected %q", deploymentName, got, expected)
+		}
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Expected a single reload for the redelivered trigger message")
+	}
+}
+
+func TestHandleTriggerRejectsUnselectiveMessage(t *testing.T) {
+	if err := HandleTrigger(clients, trigger.Message{ID: "1", Namespace: namespace}, getCollectors()); err == nil {
+		t.Errorf("Trigger message naming neither a workload nor a selector was accepted")
+	}
+}
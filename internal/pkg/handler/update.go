@@ -1,10 +1,17 @@
 package handler
 
 import (
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
 	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // ResourceUpdatedHandler contains updated objects
@@ -12,20 +19,33 @@ type ResourceUpdatedHandler struct {
 	Resource    interface{}
 	OldResource interface{}
 	Collectors  metrics.Collectors
+	// Span is the span of the informer event of the change, nil if it is not traced
+	Span *tracing.Span
 }
 
 // Handle processes the updated resource
 func (r ResourceUpdatedHandler) Handle() error {
+	var err error
 	if r.Resource == nil || r.OldResource == nil {
 		logrus.Errorf("Resource update handler received nil resource")
+	} else if isExternalSecret(r.Resource) {
+		err = handleExternalSecretUpdate(kube.GetClients(), r.OldResource.(*unstructured.Unstructured), r.Resource.(*unstructured.Unstructured), r.Collectors, r.Span)
+	} else if isCertificate(r.Resource) {
+		err = handleCertificateUpdate(kube.GetClients(), r.OldResource.(*unstructured.Unstructured), r.Resource.(*unstructured.Unstructured), r.Collectors, r.Span)
 	} else {
+		span := r.Span.Start("compute hash")
 		config, oldSHAData := r.GetConfig()
-		if config.SHAValue != oldSHAData {
+		span.End(nil)
+		config.Span = r.Span
+		if config.SHAValue != oldSHAData && changedByIgnoredManager(r.Resource) {
+			sourceLog(config).Infof("Ignoring changes in '%s' of type '%s' in namespace '%s' made by an ignored manager", config.ResourceName, config.Type, config.Namespace)
+			r.Collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonIgnoredManager}).Inc()
+		} else if config.SHAValue != oldSHAData {
 			// process resource based on its type
-			doRollingUpgrade(config, r.Collectors)
+			err = doRollingUpgrade(config, r.Collectors)
 		}
 	}
-	return nil
+	return err
 }
 
 // GetConfig gets configurations containing SHA, annotations, namespace and resource name
@@ -33,13 +53,46 @@ func (r ResourceUpdatedHandler) GetConfig() (util.Config, string) {
 	var oldSHAData string
 	var config util.Config
 	if _, ok := r.Resource.(*v1.ConfigMap); ok {
-		oldSHAData = util.GetSHAfromConfigmap(r.OldResource.(*v1.ConfigMap).Data)
+		oldConfig := util.GetConfigmapConfig(r.OldResource.(*v1.ConfigMap))
+		oldSHAData = oldConfig.SHAValue
 		config = util.GetConfigmapConfig(r.Resource.(*v1.ConfigMap))
+		config.PreviousResourceData = oldConfig.ResourceData
+		if config.PreviousResourceData == nil {
+			config.PreviousResourceData = map[string]string{}
+		}
 	} else if _, ok := r.Resource.(*v1.Secret); ok {
-		oldSHAData = util.GetSHAfromSecret(r.OldResource.(*v1.Secret).Data)
+		oldSHAData = util.GetSecretConfig(r.OldResource.(*v1.Secret)).SHAValue
 		config = util.GetSecretConfig(r.Resource.(*v1.Secret))
+	} else if _, ok := r.Resource.(*unstructured.Unstructured); ok {
+		oldSHAData = util.GetSecretProviderClassConfig(r.OldResource.(*unstructured.Unstructured)).SHAValue
+		config = util.GetSecretProviderClassConfig(r.Resource.(*unstructured.Unstructured))
 	} else {
-		logrus.Warnf("Invalid resource: Resource should be 'Secret' or 'Configmap' but found, %v", r.Resource)
+		logrus.Warnf("Invalid resource: Resource should be 'Secret', 'Configmap' or 'SecretProviderClass' but found, %v", r.Resource)
 	}
 	return config, oldSHAData
 }
+
+// changedByIgnoredManager checks whether the most recent change to the resource, according to its
+// managedFields, was made by one of the managers listed in options.IgnoredSourceManagers
+func changedByIgnoredManager(resource interface{}) bool {
+	ignoredManagers := util.List(options.IgnoredSourceManagers)
+	if len(ignoredManagers) == 0 {
+		return false
+	}
+	accessor, err := meta.Accessor(resource)
+	if err != nil {
+		return false
+	}
+
+	var latest *metav1.ManagedFieldsEntry
+	managedFields := accessor.GetManagedFields()
+	for i := range managedFields {
+		if managedFields[i].Time == nil {
+			continue
+		}
+		if latest == nil || latest.Time.Before(managedFields[i].Time) {
+			latest = &managedFields[i]
+		}
+	}
+	return latest != nil && ignoredManagers.Contains(latest.Manager)
+}
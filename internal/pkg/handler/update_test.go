@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/testutil"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestChangedByIgnoredManager(t *testing.T) {
+	options.IgnoredSourceManagers = []string{"payments-app"}
+	defer func() {
+		options.IgnoredSourceManagers = []string{}
+	}()
+
+	older := v1.NewTime(time.Now().Add(-time.Hour))
+	newer := v1.NewTime(time.Now())
+	tests := []struct {
+		name          string
+		managedFields []v1.ManagedFieldsEntry
+		ignored       bool
+	}{
+		{
+			name: "change by ignored manager",
+			managedFields: []v1.ManagedFieldsEntry{
+				{Manager: "kubectl", Operation: v1.ManagedFieldsOperationUpdate, Time: &older},
+				{Manager: "payments-app", Operation: v1.ManagedFieldsOperationUpdate, Time: &newer},
+			},
+			ignored: true,
+		},
+		{
+			name: "change by another manager",
+			managedFields: []v1.ManagedFieldsEntry{
+				{Manager: "payments-app", Operation: v1.ManagedFieldsOperationUpdate, Time: &older},
+				{Manager: "kubectl", Operation: v1.ManagedFieldsOperationUpdate, Time: &newer},
+			},
+			ignored: false,
+		},
+		{
+			name:    "no managed fields",
+			ignored: false,
+		},
+	}
+
+	for _, tt := range tests {
+		configmap := testutil.GetConfigmap(namespace, configmapName, "www.stakater.com")
+		configmap.ManagedFields = tt.managedFields
+		if changedByIgnoredManager(configmap) != tt.ignored {
+			t.Errorf("%s: expected ignored = %t", tt.name, tt.ignored)
+		}
+	}
+}
+
+func TestResourceUpdatedHandlerSkipsChangeByIgnoredManager(t *testing.T) {
+	options.IgnoredSourceManagers = []string{"payments-app"}
+	defer func() {
+		options.IgnoredSourceManagers = []string{}
+	}()
+
+	now := v1.NewTime(time.Now())
+	oldConfigmap := testutil.GetConfigmap(namespace, configmapName, "www.google.com")
+	newConfigmap := testutil.GetConfigmap(namespace, configmapName, "www.stakater.com")
+	newConfigmap.ManagedFields = []v1.ManagedFieldsEntry{
+		{Manager: "payments-app", Operation: v1.ManagedFieldsOperationUpdate, Time: &now},
+	}
+
+	collectors := getCollectors()
+	err := ResourceUpdatedHandler{Resource: newConfigmap, OldResource: oldConfigmap, Collectors: collectors}.Handle()
+	if err != nil {
+		t.Errorf("Handling the update failed: %v", err)
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 0 {
+		t.Errorf("Changes made by an ignored manager triggered a reload")
+	}
+	if promtestutil.ToFloat64(collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonIgnoredManager})) != 1 {
+		t.Errorf("Changes made by an ignored manager were not counted as skipped")
+	}
+}
+
+func TestResourceUpdatedHandlerSkipsChangeOfIgnoredSource(t *testing.T) {
+	oldConfigmap := testutil.GetConfigmap(namespace, configmapName, "www.google.com")
+	newConfigmap := testutil.GetConfigmap(namespace, configmapName, "www.stakater.com")
+	newConfigmap.Annotations = map[string]string{options.IgnoreAnnotation: "true"}
+
+	collectors := getCollectors()
+	err := ResourceUpdatedHandler{Resource: newConfigmap, OldResource: oldConfigmap, Collectors: collectors}.Handle()
+	if err != nil {
+		t.Errorf("Handling the update failed: %v", err)
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 0 {
+		t.Errorf("Changes of a configmap with the ignore annotation triggered a reload")
+	}
+	if promtestutil.ToFloat64(collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonIgnoredSource})) != 1 {
+		t.Errorf("Changes of a configmap with the ignore annotation were not counted as skipped")
+	}
+}
+
+func TestResourceUpdatedHandlerDetectsHashSaltChange(t *testing.T) {
+	oldSecret := testutil.GetSecret(namespace, secretName, "dGVzdFNlY3JldEVuY29kaW5nRm9yUmVsb2FkZXI=")
+	oldSecret.Annotations = map[string]string{options.HashSaltAnnotation: "1"}
+	newSecret := testutil.GetSecret(namespace, secretName, "dGVzdFNlY3JldEVuY29kaW5nRm9yUmVsb2FkZXI=")
+	newSecret.Annotations = map[string]string{options.HashSaltAnnotation: "2"}
+
+	config, oldSHAData := ResourceUpdatedHandler{Resource: newSecret, OldResource: oldSecret}.GetConfig()
+	if config.SHAValue == oldSHAData {
+		t.Errorf("Changing the hash salt of identical data was not detected as a change")
+	}
+
+	config, oldSHAData = ResourceUpdatedHandler{Resource: newSecret, OldResource: newSecret.DeepCopy()}.GetConfig()
+	if config.SHAValue != oldSHAData {
+		t.Errorf("Unchanged hash salt was detected as a change")
+	}
+}
+
+func TestResourceUpdatedHandlerIgnoresReappliedConfigmap(t *testing.T) {
+	oldConfigmap := testutil.GetConfigmap(namespace, configmapName, "www.stakater.com")
+	oldConfigmap.ResourceVersion = "1"
+	newConfigmap := oldConfigmap.DeepCopy()
+	now := v1.NewTime(time.Now())
+	newConfigmap.ResourceVersion = "2"
+	newConfigmap.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}"}
+	newConfigmap.ManagedFields = []v1.ManagedFieldsEntry{
+		{Manager: "kubectl", Operation: v1.ManagedFieldsOperationApply, Time: &now},
+	}
+
+	collectors := getCollectors()
+	err := ResourceUpdatedHandler{Resource: newConfigmap, OldResource: oldConfigmap, Collectors: collectors}.Handle()
+	if err != nil {
+		t.Errorf("Handling the update failed: %v", err)
+	}
+	if promtestutil.ToFloat64(collectors.Changes) != 0 {
+		t.Errorf("Reapplying an identical configmap with changed metadata was processed as a change")
+	}
+}
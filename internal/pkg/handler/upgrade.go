@@ -1,191 +1,1020 @@
 package handler
 
 import (
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/stakater/Reloader/internal/pkg/callbacks"
 	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/guard"
+	"github.com/stakater/Reloader/internal/pkg/history"
 	"github.com/stakater/Reloader/internal/pkg/metrics"
 	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/schedule"
 	"github.com/stakater/Reloader/internal/pkg/util"
 	"github.com/stakater/Reloader/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
 )
 
+// defaultGuardThreshold is the value guard queries must return less than, unless the workload sets a threshold
+const defaultGuardThreshold = 1.0
+
 // GetDeploymentRollingUpgradeFuncs returns all callback funcs for a deployment
 func GetDeploymentRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
 	return callbacks.RollingUpgradeFuncs{
-		ItemsFunc:          callbacks.GetDeploymentItems,
-		AnnotationsFunc:    callbacks.GetDeploymentAnnotations,
-		PodAnnotationsFunc: callbacks.GetDeploymentPodAnnotations,
-		ContainersFunc:     callbacks.GetDeploymentContainers,
-		InitContainersFunc: callbacks.GetDeploymentInitContainers,
-		UpdateFunc:         callbacks.UpdateDeployment,
-		VolumesFunc:        callbacks.GetDeploymentVolumes,
-		ResourceType:       "Deployment",
+		ItemsFunc:               callbacks.GetDeploymentItems,
+		AnnotationsFunc:         callbacks.GetDeploymentAnnotations,
+		PodAnnotationsFunc:      callbacks.GetDeploymentPodAnnotations,
+		PodTemplateFunc:         callbacks.GetDeploymentPodTemplate,
+		ContainersFunc:          callbacks.GetDeploymentContainers,
+		InitContainersFunc:      callbacks.GetDeploymentInitContainers,
+		EphemeralContainersFunc: callbacks.GetDeploymentEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateDeployment,
+		PatchFunc:               callbacks.PatchDeployment,
+		GetFunc:                 callbacks.GetDeployment,
+		VolumesFunc:             callbacks.GetDeploymentVolumes,
+		AvailableReplicasFunc:   callbacks.GetDeploymentAvailableReplicas,
+		RolloutInProgressFunc:   callbacks.IsDeploymentRolloutInProgress,
+		RolloutFailedFunc:       callbacks.GetDeploymentRolloutFailure,
+		ResourceType:            "Deployment",
 	}
 }
 
 // GetDaemonSetRollingUpgradeFuncs returns all callback funcs for a daemonset
 func GetDaemonSetRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
 	return callbacks.RollingUpgradeFuncs{
-		ItemsFunc:          callbacks.GetDaemonSetItems,
-		AnnotationsFunc:    callbacks.GetDaemonSetAnnotations,
-		PodAnnotationsFunc: callbacks.GetDaemonSetPodAnnotations,
-		ContainersFunc:     callbacks.GetDaemonSetContainers,
-		InitContainersFunc: callbacks.GetDaemonSetInitContainers,
-		UpdateFunc:         callbacks.UpdateDaemonSet,
-		VolumesFunc:        callbacks.GetDaemonSetVolumes,
-		ResourceType:       "DaemonSet",
+		ItemsFunc:               callbacks.GetDaemonSetItems,
+		AnnotationsFunc:         callbacks.GetDaemonSetAnnotations,
+		PodAnnotationsFunc:      callbacks.GetDaemonSetPodAnnotations,
+		PodTemplateFunc:         callbacks.GetDaemonSetPodTemplate,
+		ContainersFunc:          callbacks.GetDaemonSetContainers,
+		InitContainersFunc:      callbacks.GetDaemonSetInitContainers,
+		EphemeralContainersFunc: callbacks.GetDaemonSetEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateDaemonSet,
+		PatchFunc:               callbacks.PatchDaemonSet,
+		GetFunc:                 callbacks.GetDaemonSet,
+		VolumesFunc:             callbacks.GetDaemonSetVolumes,
+		AvailableReplicasFunc:   callbacks.GetDaemonSetAvailableReplicas,
+		RolloutInProgressFunc:   callbacks.IsDaemonSetRolloutInProgress,
+		ResourceType:            "DaemonSet",
 	}
 }
 
 // GetStatefulSetRollingUpgradeFuncs returns all callback funcs for a statefulSet
 func GetStatefulSetRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
 	return callbacks.RollingUpgradeFuncs{
-		ItemsFunc:          callbacks.GetStatefulSetItems,
-		AnnotationsFunc:    callbacks.GetStatefulSetAnnotations,
-		PodAnnotationsFunc: callbacks.GetStatefulSetPodAnnotations,
-		ContainersFunc:     callbacks.GetStatefulSetContainers,
-		InitContainersFunc: callbacks.GetStatefulSetInitContainers,
-		UpdateFunc:         callbacks.UpdateStatefulSet,
-		VolumesFunc:        callbacks.GetStatefulSetVolumes,
-		ResourceType:       "StatefulSet",
+		ItemsFunc:               callbacks.GetStatefulSetItems,
+		AnnotationsFunc:         callbacks.GetStatefulSetAnnotations,
+		PodAnnotationsFunc:      callbacks.GetStatefulSetPodAnnotations,
+		PodTemplateFunc:         callbacks.GetStatefulSetPodTemplate,
+		ContainersFunc:          callbacks.GetStatefulSetContainers,
+		InitContainersFunc:      callbacks.GetStatefulSetInitContainers,
+		EphemeralContainersFunc: callbacks.GetStatefulSetEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateStatefulSet,
+		PatchFunc:               callbacks.PatchStatefulSet,
+		GetFunc:                 callbacks.GetStatefulSet,
+		VolumesFunc:             callbacks.GetStatefulSetVolumes,
+		AvailableReplicasFunc:   callbacks.GetStatefulSetAvailableReplicas,
+		RolloutInProgressFunc:   callbacks.IsStatefulSetRolloutInProgress,
+		ResourceType:            "StatefulSet",
 	}
 }
 
 // GetDeploymentConfigRollingUpgradeFuncs returns all callback funcs for a deploymentConfig
 func GetDeploymentConfigRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
 	return callbacks.RollingUpgradeFuncs{
-		ItemsFunc:          callbacks.GetDeploymentConfigItems,
-		AnnotationsFunc:    callbacks.GetDeploymentConfigAnnotations,
-		PodAnnotationsFunc: callbacks.GetDeploymentConfigPodAnnotations,
-		ContainersFunc:     callbacks.GetDeploymentConfigContainers,
-		InitContainersFunc: callbacks.GetDeploymentConfigInitContainers,
-		UpdateFunc:         callbacks.UpdateDeploymentConfig,
-		VolumesFunc:        callbacks.GetDeploymentConfigVolumes,
-		ResourceType:       "DeploymentConfig",
+		ItemsFunc:               callbacks.GetDeploymentConfigItems,
+		AnnotationsFunc:         callbacks.GetDeploymentConfigAnnotations,
+		PodAnnotationsFunc:      callbacks.GetDeploymentConfigPodAnnotations,
+		PodTemplateFunc:         callbacks.GetDeploymentConfigPodTemplate,
+		ContainersFunc:          callbacks.GetDeploymentConfigContainers,
+		InitContainersFunc:      callbacks.GetDeploymentConfigInitContainers,
+		EphemeralContainersFunc: callbacks.GetDeploymentConfigEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateDeploymentConfig,
+		PatchFunc:               callbacks.PatchDeploymentConfig,
+		GetFunc:                 callbacks.GetDeploymentConfig,
+		VolumesFunc:             callbacks.GetDeploymentConfigVolumes,
+		AvailableReplicasFunc:   callbacks.GetDeploymentConfigAvailableReplicas,
+		RolloutInProgressFunc:   callbacks.IsDeploymentConfigRolloutInProgress,
+		RolloutFailedFunc:       callbacks.GetDeploymentConfigRolloutFailure,
+		ResourceType:            "DeploymentConfig",
+	}
+}
+
+// GetReplicaSetRollingUpgradeFuncs returns all callback funcs for a standalone replicaSet. ReplicaSets
+// do not roll their pods out, their pods are evicted on update
+func GetReplicaSetRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
+	return callbacks.RollingUpgradeFuncs{
+		ItemsFunc:               callbacks.GetReplicaSetItems,
+		AnnotationsFunc:         callbacks.GetReplicaSetAnnotations,
+		PodAnnotationsFunc:      callbacks.GetReplicaSetPodAnnotations,
+		PodTemplateFunc:         callbacks.GetReplicaSetPodTemplate,
+		ContainersFunc:          callbacks.GetReplicaSetContainers,
+		InitContainersFunc:      callbacks.GetReplicaSetInitContainers,
+		EphemeralContainersFunc: callbacks.GetReplicaSetEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateReplicaSet,
+		PatchFunc:               callbacks.PatchReplicaSet,
+		GetFunc:                 callbacks.GetReplicaSet,
+		VolumesFunc:             callbacks.GetReplicaSetVolumes,
+		AvailableReplicasFunc:   callbacks.GetReplicaSetAvailableReplicas,
+		EvictPodsFunc:           callbacks.EvictReplicaSetPods,
+		ResourceType:            "ReplicaSet",
+	}
+}
+
+// GetReplicationControllerRollingUpgradeFuncs returns all callback funcs for a standalone
+// replicationController. ReplicationControllers do not roll their pods out, their pods are evicted on
+// update
+func GetReplicationControllerRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
+	return callbacks.RollingUpgradeFuncs{
+		ItemsFunc:               callbacks.GetReplicationControllerItems,
+		AnnotationsFunc:         callbacks.GetReplicationControllerAnnotations,
+		PodAnnotationsFunc:      callbacks.GetReplicationControllerPodAnnotations,
+		PodTemplateFunc:         callbacks.GetReplicationControllerPodTemplate,
+		ContainersFunc:          callbacks.GetReplicationControllerContainers,
+		InitContainersFunc:      callbacks.GetReplicationControllerInitContainers,
+		EphemeralContainersFunc: callbacks.GetReplicationControllerEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateReplicationController,
+		PatchFunc:               callbacks.PatchReplicationController,
+		GetFunc:                 callbacks.GetReplicationController,
+		VolumesFunc:             callbacks.GetReplicationControllerVolumes,
+		AvailableReplicasFunc:   callbacks.GetReplicationControllerAvailableReplicas,
+		EvictPodsFunc:           callbacks.EvictReplicationControllerPods,
+		ResourceType:            "ReplicationController",
 	}
 }
 
-func doRollingUpgrade(config util.Config, collectors metrics.Collectors) {
-	clients := kube.GetClients()
+// GetCronJobRollingUpgradeFuncs returns all callback funcs for a cronJob. CronJobs have no replicas
+// and no rollouts, their job template is updated for their next runs
+func GetCronJobRollingUpgradeFuncs() callbacks.RollingUpgradeFuncs {
+	return callbacks.RollingUpgradeFuncs{
+		ItemsFunc:               callbacks.GetCronJobItems,
+		AnnotationsFunc:         callbacks.GetCronJobAnnotations,
+		PodAnnotationsFunc:      callbacks.GetCronJobPodAnnotations,
+		PodTemplateFunc:         callbacks.GetCronJobPodTemplate,
+		ContainersFunc:          callbacks.GetCronJobContainers,
+		InitContainersFunc:      callbacks.GetCronJobInitContainers,
+		EphemeralContainersFunc: callbacks.GetCronJobEphemeralContainers,
+		UpdateFunc:              callbacks.UpdateCronJob,
+		PatchFunc:               callbacks.PatchCronJob,
+		GetFunc:                 callbacks.GetCronJob,
+		VolumesFunc:             callbacks.GetCronJobVolumes,
+		ResourceType:            "CronJob",
+	}
+}
+
+// doRollingUpgrade reloads the workloads referencing the changed configmap or secret. It returns the
+// last retryable error of the failed updates, so the change is handled again later
+func doRollingUpgrade(config util.Config, collectors metrics.Collectors) error {
+	if ignoredSource(config, collectors) {
+		return nil
+	}
+	return upgradeWorkloads(kube.GetClients(), config, collectors)
+}
+
+// upgradeWorkloads reloads the workloads referencing the changed configmap or secret with the clients
+func upgradeWorkloads(clients kube.Clients, config util.Config, collectors metrics.Collectors) error {
+	// syncs replay the configmaps and secrets as they are, they are no changes
+	if !config.DriftedOnly {
+		collectors.Changes.Inc()
+	}
+
+	var retryErr error
+	for _, upgradeFuncs := range workloadKinds() {
+		// the source may be in a namespace only watched for workloads of other namespaces
+		if watchesNamespace(config.Namespace) {
+			if err := rollingUpgrade(clients, config, upgradeFuncs, collectors); retryable(err) {
+				retryErr = err
+			}
+		}
+		if options.EnableCrossNamespaceReferences {
+			if err := crossNamespaceUpgrade(clients, config, upgradeFuncs, collectors); err != nil {
+				sourceLog(config).Errorf("Rolling upgrade of workloads in other namespaces for '%s' failed with error = %v", config.ResourceName, err)
+				if retryable(err) {
+					retryErr = err
+				}
+			}
+		}
+	}
+	return retryErr
+}
 
-	rollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors)
-	rollingUpgrade(clients, config, GetDaemonSetRollingUpgradeFuncs(), collectors)
-	rollingUpgrade(clients, config, GetStatefulSetRollingUpgradeFuncs(), collectors)
+// originalWorkload returns a copy of the workload before Reloader changes it, nil if it cannot be copied
+func originalWorkload(item interface{}) runtime.Object {
+	if object, isObject := item.(runtime.Object); isObject {
+		return object.DeepCopyObject()
+	}
+	return nil
+}
 
-	if kube.IsOpenshift {
-		rollingUpgrade(clients, config, GetDeploymentConfigRollingUpgradeFuncs(), collectors)
+// persistingFuncs returns the upgrade funcs persisting the changes of the workload since the original
+// by patching it or, if workloads are updated or the kind cannot be patched, by updates that are
+// retried on conflicts with concurrent changes
+func persistingFuncs(upgradeFuncs callbacks.RollingUpgradeFuncs, original runtime.Object, collectors metrics.Collectors) callbacks.RollingUpgradeFuncs {
+	if options.PatchWorkloads && upgradeFuncs.PatchFunc != nil {
+		return callbacks.PatchingUpgradeFuncs(upgradeFuncs, original)
 	}
+	return callbacks.ConflictRetryingUpgradeFuncs(upgradeFuncs, original, func() {
+		collectors.CountConflictRetry(upgradeFuncs.ResourceType)
+	})
 }
 
-func rollingUpgrade(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors) {
+// retryable checks whether the error of a workload update is transient, e.g. a conflict with a
+// concurrent change of the workload or an unavailable API server. The error of the API may be wrapped,
+// e.g. by a failed eviction
+func retryable(err error) bool {
+	var status errors.APIStatus
+	if goerrors.As(err, &status) {
+		if apiErr, isError := status.(error); isError {
+			err = apiErr
+		}
+	}
+	return err != nil && (errors.IsConflict(err) || errors.IsServerTimeout(err) || errors.IsTimeout(err) ||
+		errors.IsTooManyRequests(err) || errors.IsServiceUnavailable(err) || errors.IsInternalError(err) ||
+		errors.IsUnexpectedServerError(err))
+}
+
+// workloadKinds returns the rolling upgrade funcs of all kinds of workloads reloaded in the environment
+func workloadKinds() []callbacks.RollingUpgradeFuncs {
+	kinds := []callbacks.RollingUpgradeFuncs{GetDeploymentRollingUpgradeFuncs(), GetDaemonSetRollingUpgradeFuncs(), GetStatefulSetRollingUpgradeFuncs(), GetCronJobRollingUpgradeFuncs(), GetReplicaSetRollingUpgradeFuncs(), GetReplicationControllerRollingUpgradeFuncs()}
+	if kube.IsOpenshift() {
+		kinds = append(kinds, GetDeploymentConfigRollingUpgradeFuncs())
+	}
+	if options.ReloadKnativeServices {
+		kinds = append(kinds, GetKnativeServiceRollingUpgradeFuncs())
+	}
+	if options.ReloadScaledJobs {
+		kinds = append(kinds, GetScaledJobRollingUpgradeFuncs())
+	}
+	if options.ReloadArgoRollouts {
+		kinds = append(kinds, GetArgoRolloutRollingUpgradeFuncs())
+	}
+	for _, kind := range CustomWorkloadKinds {
+		kinds = append(kinds, GetCustomWorkloadRollingUpgradeFuncs(kind))
+	}
+	for _, kind := range RegisteredWorkloadKinds() {
+		kinds = append(kinds, GetCustomWorkloadRollingUpgradeFuncs(kind))
+	}
+	if workloads != nil {
+		for i := range kinds {
+			kinds[i].ItemsFunc = cachedItems(kinds[i])
+		}
+	}
+	return kinds
+}
+
+// ignoredSource checks whether the changed configmap or secret has the ignore annotation, counting its
+// change as skipped if so
+func ignoredSource(config util.Config, collectors metrics.Collectors) bool {
+	if ignored, _ := strconv.ParseBool(config.ResourceAnnotations[options.IgnoreAnnotation]); !ignored {
+		return false
+	}
+	sourceLog(config).Infof("Ignoring changes in '%s' of type '%s' in namespace '%s', it has the ignore annotation", config.ResourceName, config.Type, config.Namespace)
+	collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonIgnoredSource}).Inc()
+	return true
+}
+
+func rollingUpgrade(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors) error {
 
 	err := PerformRollingUpgrade(clients, config, upgradeFuncs, collectors)
 	if err != nil {
-		logrus.Errorf("Rolling upgrade for '%s' failed with error = %v", config.ResourceName, err)
+		sourceLog(config).Errorf("Rolling upgrade for '%s' failed with error = %v", config.ResourceName, err)
 	}
+	return err
 }
 
 // PerformRollingUpgrade upgrades the deployment if there is any change in configmap or secret data
 func PerformRollingUpgrade(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors) error {
-	items := upgradeFuncs.ItemsFunc(clients, config.Namespace)
-	var err error
-	for _, i := range items {
-		// find correct annotation and update the resource
-		annotations := upgradeFuncs.AnnotationsFunc(i)
-		annotationValue, found := annotations[config.Annotation]
-		searchAnnotationValue, foundSearchAnn := annotations[options.AutoSearchAnnotation]
-		reloaderEnabledValue, foundAuto := annotations[options.ReloaderAutoAnnotation]
-		if !found && !foundAuto && !foundSearchAnn {
-			annotations = upgradeFuncs.PodAnnotationsFunc(i)
-			annotationValue = annotations[config.Annotation]
-			searchAnnotationValue = annotations[options.AutoSearchAnnotation]
-			reloaderEnabledValue = annotations[options.ReloaderAutoAnnotation]
-		}
-		result := constants.NotUpdated
-		reloaderEnabled, err := strconv.ParseBool(reloaderEnabledValue)
-		if err == nil && reloaderEnabled {
-			result = updateContainers(upgradeFuncs, i, config, true)
-		}
+	span := config.Span.Start("match "+upgradeFuncs.ResourceType, "namespace", config.Namespace)
+	items := referencingItems(clients, config, upgradeFuncs)
+	span.SetAttribute("candidates", strconv.Itoa(len(items)))
+	config.Span = span
+	err := upgradeItems(upgradeFuncs, items, func(i interface{}) error {
+		return upgradeItem(clients, config, upgradeFuncs, collectors, i)
+	})
+	span.End(err)
+	return err
+}
 
-		if result != constants.Updated && annotationValue != "" {
-			values := strings.Split(annotationValue, ",")
-			for _, value := range values {
-				if value == config.ResourceName {
-					result = updateContainers(upgradeFuncs, i, config, false)
-					if result == constants.Updated {
-						break
-					}
+// upgradeItem upgrades a single workload if it references the changed configmap or secret
+func upgradeItem(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors, i interface{}) error {
+	if !ownedByInstance(upgradeFuncs, i) {
+		return nil
+	}
+
+	original := originalWorkload(i)
+	log := workloadLog(config, upgradeFuncs.ResourceType, util.ToObjectMeta(i).Name)
+	// find correct annotation and update the resource
+	annotations := upgradeFuncs.AnnotationsFunc(i)
+	annotationValue, found := annotations[config.Annotation]
+	searchAnnotationValue, foundSearchAnn := annotations[options.AutoSearchAnnotation]
+	reloaderEnabledValue, foundAuto := annotations[options.ReloaderAutoAnnotation]
+	resolveSourceValue, foundResolve := annotations[options.ResolveSourceFromAnnotation]
+	if !found && !foundAuto && !foundSearchAnn && !foundResolve {
+		annotations = upgradeFuncs.PodAnnotationsFunc(i)
+		annotationValue = annotations[config.Annotation]
+		searchAnnotationValue = annotations[options.AutoSearchAnnotation]
+		reloaderEnabledValue = annotations[options.ReloaderAutoAnnotation]
+		resolveSourceValue = annotations[options.ResolveSourceFromAnnotation]
+	}
+	if keys, found := annotations[keysAnnotation(config)]; found {
+		// the hash of the listed keys leaves the workload untouched on changes of other keys
+		config.SHAValue = util.GetSHAofKeys(config, strings.Split(keys, ","))
+		config.LegacySHAValue = ""
+	}
+	if reloadOnDelete, _ := strconv.ParseBool(annotations[options.ReloadOnDeleteAnnotation]); config.Deleted && !reloadOnDelete {
+		return nil
+	}
+	strategyName := reloadStrategyName(clients, upgradeFuncs, util.ToObjectMeta(i).Namespace, annotations)
+	previousTemplate := templateToRollBackTo(upgradeFuncs, i, annotations)
+	result := constants.NotUpdated
+	// workloads in other namespaces only reference the source in their reload annotations
+	sameNamespace := config.SourceNamespace == ""
+	reloaderEnabled, err := strconv.ParseBool(reloaderEnabledValue)
+	if err == nil && reloaderEnabled && sameNamespace {
+		result = updateContainers(log, upgradeFuncs, i, config, true, strategyName)
+	}
+
+	if result != constants.Updated && annotationValue != "" {
+		values := strings.Split(annotationValue, ",")
+		for _, value := range values {
+			if referencesSource(config, value) {
+				result = updateContainers(log, upgradeFuncs, i, config, false, strategyName)
+				if result == constants.Updated {
+					break
 				}
 			}
 		}
+	}
 
-		if result != constants.Updated && searchAnnotationValue == "true" {
-			matchAnnotationValue := config.ResourceAnnotations[options.SearchMatchAnnotation]
-			if matchAnnotationValue == "true" {
-				result = updateContainers(upgradeFuncs, i, config, true)
-			}
+	if result != constants.Updated && resolveSourceValue != "" && config.Type == constants.ConfigmapEnvVarPostfix && sameNamespace {
+		name, err := resolveSource(clients, config.Namespace, annotations, resolveSourceValue)
+		if err != nil {
+			log.Warnf("Unable to resolve %s of '%s' of type '%s' in namespace '%s': %v", options.ResolveSourceFromAnnotation, util.ToObjectMeta(i).Name, upgradeFuncs.ResourceType, config.Namespace, err)
+		} else if name == config.ResourceName {
+			result = updateContainers(log, upgradeFuncs, i, config, false, strategyName)
 		}
+	}
 
-		if result == constants.Updated {
-			err = upgradeFuncs.UpdateFunc(clients, config.Namespace, i)
-			resourceName := util.ToObjectMeta(i).Name
-			if err != nil {
-				logrus.Errorf("Update for '%s' of type '%s' in namespace '%s' failed with error %v", resourceName, upgradeFuncs.ResourceType, config.Namespace, err)
-				collectors.Reloaded.With(prometheus.Labels{"success": "false"}).Inc()
-			} else {
-				logrus.Infof("Changes detected in '%s' of type '%s' in namespace '%s'", config.ResourceName, config.Type, config.Namespace)
-				logrus.Infof("Updated '%s' of type '%s' in namespace '%s'", resourceName, upgradeFuncs.ResourceType, config.Namespace)
-				collectors.Reloaded.With(prometheus.Labels{"success": "true"}).Inc()
-			}
+	if result != constants.Updated && searchAnnotationValue == "true" && sameNamespace {
+		matchAnnotationValue := config.ResourceAnnotations[options.SearchMatchAnnotation]
+		if matchAnnotationValue == "true" {
+			result = updateContainers(log, upgradeFuncs, i, config, true, strategyName)
 		}
 	}
-	return err
+
+	if result != constants.Updated && alwaysReloaded(config) && sameNamespace {
+		result = updateContainers(log, upgradeFuncs, i, config, true, strategyName)
+	}
+
+	if result != constants.Updated {
+		// the pods an earlier reload of the change left on the outdated template, e.g. as their eviction
+		// was blocked by a disruption budget, are evicted when the change is handled again
+		if upgradeFuncs.EvictPodsFunc != nil && !options.ObserveOnly && holdsChange(upgradeFuncs, i, config, strategyName) {
+			return upgradeFuncs.EvictPodsFunc(clients, config.Namespace, i)
+		}
+		return nil
+	}
+	if condition, found := annotations[options.ReloadWhenKeyAnnotation]; found && config.Type == constants.ConfigmapEnvVarPostfix && !reloadConditionReached(log, config, condition) {
+		log.Infof("Ignoring changes in '%s' of type '%s' in namespace '%s' for '%s' of type '%s', they do not reach %s", config.ResourceName, config.Type, config.Namespace, util.ToObjectMeta(i).Name, upgradeFuncs.ResourceType, condition)
+		return nil
+	}
+	for _, conflict := range annotationConflicts(upgradeFuncs, i, config) {
+		log.Warnf("'%s' of type '%s' in namespace '%s' has conflicting reload annotations (%s), %s", util.ToObjectMeta(i).Name, upgradeFuncs.ResourceType, config.Namespace, conflict, annotationConflictPrecedence[conflict])
+		collectors.AnnotationConflicts.With(prometheus.Labels{"conflict": conflict}).Inc()
+	}
+
+	if options.ObserveOnly {
+		log.Infof("Changes detected in '%s' of type '%s' in namespace '%s'", config.ResourceName, config.Type, config.Namespace)
+		log.Infof("Observe-only: would update '%s' of type '%s' in namespace '%s'", util.ToObjectMeta(i).Name, upgradeFuncs.ResourceType, config.Namespace)
+		recordDecision(config, upgradeFuncs, util.ToObjectMeta(i).Name, history.Skipped, metrics.SkipReasonObserveOnly)
+		recordObservedReloadEvent(clients, config, upgradeFuncs.ResourceType, i)
+		collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonObserveOnly}).Inc()
+		return nil
+	}
+
+	now := deferred.clock.Now()
+	if opens := nextReloadWindow(log, now, annotations); opens.After(now) {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "outside-reload-window", "until the allowed reload window opens at "+opens.Format(time.RFC3339), opens.Sub(now))
+		return nil
+	}
+
+	if options.DeferReloadUntilCertificateValid && config.NotBefore.After(now) {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "certificate-not-yet-valid", "until its certificate becomes valid at "+config.NotBefore.Format(time.RFC3339), config.NotBefore.Sub(now))
+		return nil
+	}
+
+	if options.DeferReloadDuringRollout && upgradeFuncs.RolloutInProgressFunc != nil && upgradeFuncs.RolloutInProgressFunc(i) {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "rollout-in-progress", "until its current rollout completes", deferred.backoff(config, upgradeFuncs, i))
+		return nil
+	}
+
+	if budget := disruptionBudgetBlocking(log, clients, upgradeFuncs, config.Namespace, i); budget != "" {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "disruption-budget", "while its pod disruption budget '"+budget+"' allows no disruptions", deferred.backoff(config, upgradeFuncs, i))
+		return nil
+	}
+
+	if !hasMinAvailable(log, upgradeFuncs, i, annotations) {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "min-available", "until it has "+annotations[options.MinAvailableBeforeReloadAnnotation]+" available replicas", options.DeferredReloadRetryInterval)
+		return nil
+	}
+
+	if !guardPasses(log, i, annotations) {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "guard-query", "until its guard query passes", options.DeferredReloadRetryInterval)
+		return nil
+	}
+
+	if remaining := sampleIntervalRemaining(log, upgradeFuncs, config, i); remaining > 0 {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "sample-interval", "until its sample interval ends in "+remaining.String(), remaining)
+		return nil
+	}
+
+	if remaining := cooldownRemaining(log, upgradeFuncs, config, i, annotations); remaining > 0 {
+		deferReload(log, clients, config, upgradeFuncs, collectors, i, "post-reload-cooldown", "until its post-reload cooldown ends in "+remaining.String(), remaining)
+		return nil
+	}
+
+	if revision, pinned := annotations[options.PinToRevisionAnnotation]; pinned && upgradeFuncs.ResourceType == "Deployment" {
+		return pinToRevision(log, clients, config, i, revision, collectors)
+	}
+
+	setChangeCause(log, i, config)
+	if forcePull, _ := strconv.ParseBool(annotations[options.ForcePullAnnotation]); forcePull {
+		markForcedPull(upgradeFuncs, i, config)
+	}
+	strategy, err := reloadStrategy(strategyName)
+	if err == nil {
+		span := config.Span.Start("patch "+upgradeFuncs.ResourceType, "namespace", config.Namespace, "workload", util.ToObjectMeta(i).Name, "strategy", strategyName)
+		err = strategy.Apply(clients, persistingFuncs(upgradeFuncs, original, collectors), config.Namespace, i, config.SHAValue)
+		span.End(err)
+	}
+	resourceName := util.ToObjectMeta(i).Name
+	if err != nil {
+		log.Errorf("Update for '%s' of type '%s' in namespace '%s' failed with error %v", resourceName, upgradeFuncs.ResourceType, config.Namespace, err)
+		collectors.CountReload(config.Namespace, upgradeFuncs.ResourceType, config.Type, false)
+		recordDecision(config, upgradeFuncs, resourceName, history.Failed, err.Error())
+		recordReloadEvent(clients, config, upgradeFuncs.ResourceType, i, err)
+		notifyReload(config, upgradeFuncs.ResourceType, i, err)
+		return err
+	}
+	log.Infof("Changes detected in '%s' of type '%s' in namespace '%s'", config.ResourceName, config.Type, config.Namespace)
+	log.Infof("Updated '%s' of type '%s' in namespace '%s'", resourceName, upgradeFuncs.ResourceType, config.Namespace)
+	collectors.CountReload(config.Namespace, upgradeFuncs.ResourceType, config.Type, true)
+	recordDecision(config, upgradeFuncs, resourceName, history.Reloaded, "")
+	recordReloadEvent(clients, config, upgradeFuncs.ResourceType, i, nil)
+	notifyReload(config, upgradeFuncs.ResourceType, i, nil)
+	rollouts.add(clients, config, upgradeFuncs, collectors, i, previousTemplate)
+	// an older deferred change of the source must not be applied after this one
+	deferred.forget(deferredReloadKey(config, upgradeFuncs, resourceName))
+	if _, found := cooldownAnnotation(annotations); found {
+		deferred.markReloaded(workloadKey(upgradeFuncs, config.Namespace, resourceName))
+	}
+	if _, found := config.ResourceAnnotations[options.ReloadSampleIntervalAnnotation]; found {
+		deferred.markReloaded(deferredReloadKey(config, upgradeFuncs, resourceName))
+	}
+	countTeamReload(i, collectors)
+	countWorkloadReload(i, upgradeFuncs.ResourceType, config, collectors)
+	return nil
+}
+
+// History records the reload decisions taken for workloads, none are recorded if nil
+var History *history.Store
+
+func recordDecision(config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, name string, decision string, reason string) {
+	if History == nil {
+		return
+	}
+	record := history.Record{
+		Time:       deferred.clock.Now().UTC(),
+		Namespace:  config.Namespace,
+		Kind:       upgradeFuncs.ResourceType,
+		Name:       name,
+		SourceType: config.Type,
+		SourceName: config.ResourceName,
+		Decision:   decision,
+		Reason:     reason,
+	}
+	if err := History.Append(record); err != nil {
+		logrus.Errorf("Failed to record the reload decision for '%s' of type '%s' in namespace '%s': %v", record.Name, record.Kind, record.Namespace, err)
+	}
+}
+
+// deferReload puts the rolling upgrade of the workload off by delay, recording the deferral for the
+// reason and explaining it in the logs and the event by until
+func deferReload(log *logrus.Entry, clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs, collectors metrics.Collectors, item interface{}, reason string, until string, delay time.Duration) {
+	name := util.ToObjectMeta(item).Name
+	log.Infof("Changes detected in '%s' of type '%s' in namespace '%s'", config.ResourceName, config.Type, config.Namespace)
+	log.Infof("Deferring update of '%s' of type '%s' in namespace '%s' %s", name, upgradeFuncs.ResourceType, config.Namespace, until)
+	recordDecision(config, upgradeFuncs, name, history.Deferred, reason)
+	recordDeferredReloadEvent(clients, config, upgradeFuncs.ResourceType, item, until)
+	deferred.add(clients, config, upgradeFuncs, collectors, item, delay)
+}
+
+// annotationConflictPrecedence documents how each conflict of reload annotations is resolved
+var annotationConflictPrecedence = map[string]string{
+	metrics.ConflictMetadataAndPodTemplate: "the annotations of the pod template are ignored",
+	metrics.ConflictAutoAndExplicit:        "all referenced configmaps and secrets reload it, as well as the listed ones",
+	metrics.ConflictAutoAndSearch:          "all referenced configmaps and secrets reload it, regardless of their match annotation",
+}
+
+// annotationConflicts returns the conflicting combinations of reload annotations of a workload
+func annotationConflicts(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config) []string {
+	var conflicts []string
+	keys := []string{config.Annotation, options.ReloaderAutoAnnotation, options.AutoSearchAnnotation, options.ResolveSourceFromAnnotation}
+	annotations := upgradeFuncs.AnnotationsFunc(item)
+	podAnnotations := upgradeFuncs.PodAnnotationsFunc(item)
+	if !hasAnyAnnotation(annotations, keys) {
+		annotations = podAnnotations
+	} else if hasAnyAnnotation(podAnnotations, keys) {
+		conflicts = append(conflicts, metrics.ConflictMetadataAndPodTemplate)
+	}
+
+	if auto, err := strconv.ParseBool(annotations[options.ReloaderAutoAnnotation]); err == nil && auto {
+		if annotations[options.ConfigmapUpdateOnChangeAnnotation] != "" || annotations[options.SecretUpdateOnChangeAnnotation] != "" {
+			conflicts = append(conflicts, metrics.ConflictAutoAndExplicit)
+		}
+		if annotations[options.AutoSearchAnnotation] == "true" {
+			conflicts = append(conflicts, metrics.ConflictAutoAndSearch)
+		}
+	}
+	return conflicts
+}
+
+func hasAnyAnnotation(annotations map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if _, found := annotations[key]; found {
+			return true
+		}
+	}
+	return false
 }
 
-func getVolumeMountName(volumes []v1.Volume, mountType string, volumeName string) string {
+// cooldownAnnotation returns the post-reload cooldown of the workload, named by the post-reload-cooldown
+// annotation or else its cooldown alias
+func cooldownAnnotation(annotations map[string]string) (string, bool) {
+	if value, found := annotations[options.PostReloadCooldownAnnotation]; found {
+		return value, true
+	}
+	value, found := annotations[options.CooldownAnnotation]
+	return value, found
+}
+
+// cooldownRemaining returns how long the post-reload cooldown of the workload still lasts, 0 once it ended
+func cooldownRemaining(log *logrus.Entry, upgradeFuncs callbacks.RollingUpgradeFuncs, config util.Config, item interface{}, annotations map[string]string) time.Duration {
+	value, found := cooldownAnnotation(annotations)
+	if !found {
+		return 0
+	}
+	cooldown, err := time.ParseDuration(value)
+	if err != nil {
+		log.Errorf("Ignoring invalid post-reload cooldown '%s' of '%s' of type '%s' in namespace '%s'", value, util.ToObjectMeta(item).Name, upgradeFuncs.ResourceType, config.Namespace)
+		return 0
+	}
+	return deferred.remaining(workloadKey(upgradeFuncs, config.Namespace, util.ToObjectMeta(item).Name), cooldown)
+}
+
+// sampleIntervalRemaining returns how long until the workload may be reloaded again for the
+// configmap or secret, if the source sets a sample interval, 0 once the interval elapsed
+func sampleIntervalRemaining(log *logrus.Entry, upgradeFuncs callbacks.RollingUpgradeFuncs, config util.Config, item interface{}) time.Duration {
+	value, found := config.ResourceAnnotations[options.ReloadSampleIntervalAnnotation]
+	if !found {
+		return 0
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		log.Errorf("Ignoring invalid reload sample interval '%s' of '%s' of type '%s' in namespace '%s'", value, config.ResourceName, config.Type, config.Namespace)
+		return 0
+	}
+	return deferred.remaining(deferredReloadKey(config, upgradeFuncs, util.ToObjectMeta(item).Name), interval)
+}
+
+// reloadConditionReached checks whether the change of the configmap sets the key of the "<key>=<value>"
+// condition to the value, while it was not set to it before the change
+func reloadConditionReached(log *logrus.Entry, config util.Config, condition string) bool {
+	parts := strings.SplitN(condition, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		log.Errorf("Invalid %s '%s', expected '<key>=<value>'", options.ReloadWhenKeyAnnotation, condition)
+		return false
+	}
+	value, found := config.ResourceData[parts[0]]
+	if !found || value != parts[1] {
+		return false
+	}
+	if config.PreviousResourceData == nil {
+		return true
+	}
+	previous, found := config.PreviousResourceData[parts[0]]
+	return !found || previous != parts[1]
+}
+
+// keysAnnotation returns the annotation listing the keys of the type of the changed configmap or
+// secret a workload is reloaded for
+func keysAnnotation(config util.Config) string {
+	switch config.Type {
+	case constants.SecretEnvVarPostfix:
+		return options.SecretKeysAnnotation
+	case constants.ConfigmapEnvVarPostfix:
+		return options.ConfigmapKeysAnnotation
+	}
+	// secret provider classes have no keys
+	return ""
+}
+
+// alwaysReloaded checks whether changes of the configmap or secret reload all workloads referencing it
+func alwaysReloaded(config util.Config) bool {
+	sources := util.List(options.AlwaysReloadSources)
+	return sources.Contains(config.Namespace + "/" + config.ResourceName)
+}
+
+// namespaceStrategyTTL is how long the default strategy of a namespace is cached
+const namespaceStrategyTTL = 30 * time.Second
+
+type namespaceStrategy struct {
+	name      string
+	fetchedAt time.Time
+}
+
+// namespaceStrategies caches the default strategies of namespaces, so the workloads of a namespace
+// reloaded for a change share one lookup of their namespace
+var namespaceStrategies = struct {
+	sync.Mutex
+	entries map[string]namespaceStrategy
+}{entries: map[string]namespaceStrategy{}}
+
+// reloadStrategyName returns the name of the strategy named by the reload-strategy annotation of the
+// workload, or by the default-strategy annotation of its namespace, or of the strategy of its kind, or
+// of the default strategy
+func reloadStrategyName(clients kube.Clients, upgradeFuncs callbacks.RollingUpgradeFuncs, namespace string, annotations map[string]string) string {
+	if value, found := annotations[options.ReloadStrategyAnnotation]; found {
+		return value
+	}
+	if value := namespaceStrategyName(clients, namespace); value != "" {
+		return value
+	}
+	if upgradeFuncs.ReloadStrategy != "" {
+		return upgradeFuncs.ReloadStrategy
+	}
+	return options.ReloadStrategy
+}
+
+// namespaceStrategyName returns the name of the strategy named by the default-strategy annotation of
+// the namespace, empty if it has none or cannot be read
+func namespaceStrategyName(clients kube.Clients, namespace string) string {
+	if clients.KubernetesClient == nil || namespace == "" {
+		return ""
+	}
+	now := deferred.clock.Now()
+	namespaceStrategies.Lock()
+	defer namespaceStrategies.Unlock()
+	if cached, found := namespaceStrategies.entries[namespace]; found && now.Sub(cached.fetchedAt) < namespaceStrategyTTL {
+		return cached.name
+	}
+	var name string
+	if ns, err := clients.KubernetesClient.CoreV1().Namespaces().Get(namespace, meta_v1.GetOptions{}); err != nil {
+		logrus.Debugf("Unable to read the default strategy of namespace '%s': %v", namespace, err)
+	} else {
+		name = ns.Annotations[options.NamespaceDefaultStrategyAnnotation]
+	}
+	namespaceStrategies.entries[namespace] = namespaceStrategy{name: name, fetchedAt: now}
+	return name
+}
+
+// reloadStrategy returns the registered strategy of the name
+func reloadStrategy(name string) (callbacks.Strategy, error) {
+	strategy, found := callbacks.GetStrategy(name)
+	if !found {
+		return nil, fmt.Errorf("unknown reload strategy '%s', expected one of %s", name, strings.Join(callbacks.StrategyNames(), ", "))
+	}
+	return strategy, nil
+}
+
+// nextReloadWindow returns when the allowed reload window of the workload, or else the global one,
+// opens next, or the given time while it is open
+func nextReloadWindow(log *logrus.Entry, now time.Time, annotations map[string]string) time.Time {
+	windowValue := options.AllowedReloadWindow
+	if value, found := annotations[options.ReloadWindowAnnotation]; found {
+		windowValue = value
+	}
+	if windowValue == "" {
+		return now
+	}
+	window, err := schedule.Parse(windowValue)
+	if err != nil {
+		log.Errorf("Ignoring allowed reload window: %v", err)
+		return now
+	}
+	return window.Next(now)
+}
+
+// disruptionBudgetBlocking returns the name of a PodDisruptionBudget of the pods of the workload that
+// allows no disruptions, if reloads are deferred by disruption budgets
+func disruptionBudgetBlocking(log *logrus.Entry, clients kube.Clients, upgradeFuncs callbacks.RollingUpgradeFuncs, namespace string, item interface{}) string {
+	if !options.DeferReloadOnDisruptionBudget || upgradeFuncs.PodTemplateFunc == nil {
+		return ""
+	}
+	template := upgradeFuncs.PodTemplateFunc(item)
+	if template == nil || len(template.Labels) == 0 {
+		return ""
+	}
+	budgets, err := clients.KubernetesClient.PolicyV1beta1().PodDisruptionBudgets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		log.Errorf("Failed to list pod disruption budgets %v", err)
+		return ""
+	}
+	for _, budget := range budgets.Items {
+		selector, err := meta_v1.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(labels.Set(template.Labels)) {
+			continue
+		}
+		if budget.Status.PodDisruptionsAllowed < 1 {
+			return budget.Name
+		}
+	}
+	return ""
+}
+
+// hasMinAvailable checks whether the workload has the available replicas its min-available-before-reload
+// annotation requires, workloads without the annotation always have
+func hasMinAvailable(log *logrus.Entry, upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, annotations map[string]string) bool {
+	value, found := annotations[options.MinAvailableBeforeReloadAnnotation]
+	if !found || upgradeFuncs.AvailableReplicasFunc == nil {
+		return true
+	}
+	minAvailable, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s annotation '%s' on '%s': %v", options.MinAvailableBeforeReloadAnnotation, value, util.ToObjectMeta(item).Name, err)
+		return true
+	}
+	return upgradeFuncs.AvailableReplicasFunc(item) >= int32(minAvailable)
+}
+
+// guardPasses checks whether the guard query of the workload returns a value below its threshold,
+// workloads without a guard query always pass
+func guardPasses(log *logrus.Entry, item interface{}, annotations map[string]string) bool {
+	query, found := annotations[options.GuardQueryAnnotation]
+	if !found || options.PrometheusURL == "" {
+		return true
+	}
+	name := util.ToObjectMeta(item).Name
+	threshold := defaultGuardThreshold
+	if value, found := annotations[options.GuardThresholdAnnotation]; found {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Warnf("Ignoring invalid %s annotation '%s' on '%s': %v", options.GuardThresholdAnnotation, value, name, err)
+		} else {
+			threshold = parsed
+		}
+	}
+
+	value, err := guard.Query(options.PrometheusURL, query)
+	if err != nil {
+		log.Errorf("Guard query of '%s' failed with error %v", name, err)
+		return false
+	}
+	if value >= threshold {
+		log.Infof("Guard query of '%s' returned %v, which is not below threshold %v", name, value, threshold)
+		return false
+	}
+	return true
+}
+
+// countTeamReload counts the reload of the workload by the value of its team label, if a team label is configured
+func countTeamReload(item interface{}, collectors metrics.Collectors) {
+	if options.TeamLabel == "" {
+		return
+	}
+	team, found := util.ToObjectMeta(item).Labels[options.TeamLabel]
+	if !found || team == "" {
+		team = metrics.UnknownTeam
+	}
+	collectors.ReloadedByTeam.With(prometheus.Labels{"team": team}).Inc()
+}
+
+// countWorkloadReload counts the reload of the workload along with the resourceVersion of the changed
+// configmap or secret, if detailed metrics are enabled
+func countWorkloadReload(item interface{}, resourceType string, config util.Config, collectors metrics.Collectors) {
+	if !options.DetailedMetrics {
+		return
+	}
+	collectors.ReloadedByWorkload.With(prometheus.Labels{
+		"namespace":               config.Namespace,
+		"kind":                    resourceType,
+		"name":                    util.ToObjectMeta(item).Name,
+		"source_type":             config.Type,
+		"source_name":             config.ResourceName,
+		"source_resource_version": config.ResourceVersion,
+	}).Inc()
+}
+
+// pinToRevision rolls the deployment back to the pod template of the pinned revision instead of
+// applying the change
+func pinToRevision(log *logrus.Entry, clients kube.Clients, config util.Config, item interface{}, revision string, collectors metrics.Collectors) error {
+	name := util.ToObjectMeta(item).Name
+	fail := func(err error) error {
+		log.Errorf("Pinning '%s' of type 'Deployment' in namespace '%s' to revision %s failed with error %v", name, config.Namespace, revision, err)
+		collectors.CountReload(config.Namespace, "Deployment", config.Type, false)
+		recordReloadEvent(clients, config, "Deployment", item, err)
+		notifyReload(config, "Deployment", item, err)
+		return err
+	}
+
+	log.Infof("Changes detected in '%s' of type '%s' in namespace '%s'", config.ResourceName, config.Type, config.Namespace)
+	span := config.Span.Start("patch Deployment", "namespace", config.Namespace, "workload", name, "revision", revision)
+	var deployment *appsv1.Deployment
+	pinned := false
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		attempt++
+		if attempt > 1 {
+			collectors.CountConflictRetry("Deployment")
+		}
+		// the listed item already carries the new env var, start from the stored deployment instead
+		var err error
+		deployment, err = clients.KubernetesClient.AppsV1().Deployments(config.Namespace).Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		template, err := callbacks.GetDeploymentRevisionTemplate(clients, config.Namespace, deployment, revision)
+		if err != nil {
+			return err
+		}
+		if pinned = equality.Semantic.DeepEqual(deployment.Spec.Template, *template); pinned {
+			return nil
+		}
+		deployment.Spec.Template = *template
+		return callbacks.UpdateDeployment(clients, config.Namespace, deployment)
+	})
+	span.End(err)
+	if err != nil {
+		return fail(err)
+	}
+	if pinned {
+		log.Infof("Deployment '%s' in namespace '%s' already runs pinned revision %s, ignoring changes", name, config.Namespace, revision)
+		return nil
+	}
+	log.Infof("Rolled back '%s' of type 'Deployment' in namespace '%s' to pinned revision %s", name, config.Namespace, revision)
+	collectors.CountReload(config.Namespace, "Deployment", config.Type, true)
+	recordReloadEvent(clients, config, "Deployment", deployment, nil)
+	notifyReload(config, "Deployment", deployment, nil)
+	countTeamReload(deployment, collectors)
+	countWorkloadReload(deployment, "Deployment", config, collectors)
+	return nil
+}
+
+// setChangeCause records the change of the configmap or secret as change-cause of the workload's
+// rolling upgrade if enabled, or the change-cause of the change-cause annotation of the workload
+func setChangeCause(log *logrus.Entry, item interface{}, config util.Config) {
+	accessor, err := meta.Accessor(item)
+	if err != nil {
+		log.Warnf("Unable to record change-cause: %v", err)
+		return
+	}
+
+	annotations := accessor.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	changeCause, found := annotations[options.ChangeCauseAnnotation]
+	if !found {
+		if !options.RecordChangeCause {
+			return
+		}
+		changeCause = fmt.Sprintf("Reloader: %s %s changed", strings.ToLower(config.Type), config.ResourceName)
+	}
+	annotations[constants.KubernetesChangeCauseAnnotation] = changeCause
+	accessor.SetAnnotations(annotations)
+}
+
+// markForcedPull records the hash of the change in the force-pull marker annotation of the pod
+// template of the workload. The marker only lasts for the rollout of this reload and leaves the image
+// pull policy of the containers untouched, the next reload replaces it
+func markForcedPull(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config) {
+	if upgradeFuncs.PodTemplateFunc == nil {
+		return
+	}
+	template := upgradeFuncs.PodTemplateFunc(item)
+	if template == nil {
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[options.ForcePullMarkerAnnotation] = config.SHAValue
+}
+
+// ownedByInstance checks whether the workload is addressed to this Reloader instance. Workloads
+// without the instance annotation belong to the default instance
+func ownedByInstance(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}) bool {
+	instance, found := upgradeFuncs.AnnotationsFunc(item)[options.InstanceAnnotation]
+	if !found {
+		instance = upgradeFuncs.PodAnnotationsFunc(item)[options.InstanceAnnotation]
+	}
+	if instance == "" {
+		return options.InstanceName == "" || options.DefaultInstance
+	}
+	return instance == options.InstanceName
+}
+
+// getVolumeMountNames returns the names of all volumes referencing the configmap or secret, directly,
+// through a projected source or, for secrets, as the secret of a volume driver, or the secret provider
+// class of the Secrets Store CSI driver
+func getVolumeMountNames(volumes []v1.Volume, mountType string, volumeName string) []string {
+	var names []string
 	for i := range volumes {
 		if mountType == constants.ConfigmapEnvVarPostfix {
 			if volumes[i].ConfigMap != nil && volumes[i].ConfigMap.Name == volumeName {
-				return volumes[i].Name
+				names = append(names, volumes[i].Name)
+				continue
 			}
 
 			if volumes[i].Projected != nil {
 				for j := range volumes[i].Projected.Sources {
 					if volumes[i].Projected.Sources[j].ConfigMap != nil && volumes[i].Projected.Sources[j].ConfigMap.Name == volumeName {
-						return volumes[i].Name
+						names = append(names, volumes[i].Name)
+						break
 					}
 				}
 			}
+		} else if mountType == constants.SecretProviderClassEnvVarPostfix {
+			csi := volumes[i].CSI
+			if csi != nil && csi.Driver == constants.SecretsStoreCSIDriver && csi.VolumeAttributes[constants.SecretProviderClassVolumeAttribute] == volumeName {
+				names = append(names, volumes[i].Name)
+			}
 		} else if mountType == constants.SecretEnvVarPostfix {
 			if volumes[i].Secret != nil && volumes[i].Secret.SecretName == volumeName {
-				return volumes[i].Name
+				names = append(names, volumes[i].Name)
+				continue
+			}
+			if volumeDriverSecretName(volumes[i]) == volumeName {
+				names = append(names, volumes[i].Name)
+				continue
 			}
 
 			if volumes[i].Projected != nil {
 				for j := range volumes[i].Projected.Sources {
 					if volumes[i].Projected.Sources[j].Secret != nil && volumes[i].Projected.Sources[j].Secret.Name == volumeName {
-						return volumes[i].Name
+						names = append(names, volumes[i].Name)
+						break
 					}
 				}
 			}
 		}
 	}
 
-	return ""
+	return names
 }
 
-func getContainerWithVolumeMount(containers []v1.Container, volumeMountName string) *v1.Container {
+// volumeDriverSecretName returns the name of the secret the driver of the volume authenticates with,
+// if any
+func volumeDriverSecretName(volume v1.Volume) string {
+	var ref *v1.LocalObjectReference
+	switch {
+	case volume.CSI != nil:
+		ref = volume.CSI.NodePublishSecretRef
+	case volume.FlexVolume != nil:
+		ref = volume.FlexVolume.SecretRef
+	case volume.CephFS != nil:
+		ref = volume.CephFS.SecretRef
+	case volume.RBD != nil:
+		ref = volume.RBD.SecretRef
+	case volume.ISCSI != nil:
+		ref = volume.ISCSI.SecretRef
+	case volume.ScaleIO != nil:
+		ref = volume.ScaleIO.SecretRef
+	case volume.StorageOS != nil:
+		ref = volume.StorageOS.SecretRef
+	case volume.AzureFile != nil:
+		return volume.AzureFile.SecretName
+	}
+	if ref == nil {
+		return ""
+	}
+	return ref.Name
+}
+
+func getContainerWithVolumeMount(containers []v1.Container, volumeMountNames []string) *v1.Container {
 	for i := range containers {
 		volumeMounts := containers[i].VolumeMounts
 		for j := range volumeMounts {
-			if volumeMounts[j].Name == volumeMountName {
-				return &containers[i]
+			for _, volumeMountName := range volumeMountNames {
+				if volumeMounts[j].Name == volumeMountName {
+					return &containers[i]
+				}
 			}
 		}
 	}
@@ -219,20 +1048,52 @@ func getContainerWithEnvReference(containers []v1.Container, resourceName string
 	return nil
 }
 
+// referencesImagePullSecret checks whether the pod template of the workload pulls its images with the
+// secret
+func referencesImagePullSecret(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, secretName string) bool {
+	if upgradeFuncs.PodTemplateFunc == nil {
+		return false
+	}
+	template := upgradeFuncs.PodTemplateFunc(item)
+	if template == nil {
+		return false
+	}
+	for _, pullSecret := range template.Spec.ImagePullSecrets {
+		if pullSecret.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
+
+// listsSource checks whether the references name the changed configmap or secret
+func listsSource(references []callbacks.SourceReference, config util.Config) bool {
+	for _, reference := range references {
+		if reference.Name == config.ResourceName && (reference.Type == "" || reference.Type == config.Type) {
+			return true
+		}
+	}
+	return false
+}
+
 func getContainerToUpdate(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config, autoReload bool) *v1.Container {
 	volumes := upgradeFuncs.VolumesFunc(item)
 	containers := upgradeFuncs.ContainersFunc(item)
-	initContainers := upgradeFuncs.InitContainersFunc(item)
+	// init and ephemeral containers can only reference the configmap/secret, the env var is saved in a pod container
+	initContainers := append([]v1.Container{}, upgradeFuncs.InitContainersFunc(item)...)
+	if upgradeFuncs.EphemeralContainersFunc != nil {
+		initContainers = append(initContainers, upgradeFuncs.EphemeralContainersFunc(item)...)
+	}
 	var container *v1.Container
-	// Get the volumeMountName to find volumeMount in container
-	volumeMountName := getVolumeMountName(volumes, config.Type, config.ResourceName)
+	// Get the volumeMountNames to find volumeMounts in containers
+	volumeMountNames := getVolumeMountNames(volumes, config.Type, config.ResourceName)
 	// Get the container with mounted configmap/secret
-	if volumeMountName != "" {
-		container = getContainerWithVolumeMount(containers, volumeMountName)
+	if len(volumeMountNames) > 0 {
+		container = getContainerWithVolumeMount(containers, volumeMountNames)
 		if container == nil && len(initContainers) > 0 {
-			container = getContainerWithVolumeMount(initContainers, volumeMountName)
+			container = getContainerWithVolumeMount(initContainers, volumeMountNames)
 			if container != nil {
-				// if configmap/secret is being used in init container then return the first Pod container to save reloader env
+				// if configmap/secret is being used in init or ephemeral container then return the first Pod container to save reloader env
 				return &containers[0]
 			}
 		} else if container != nil {
@@ -250,6 +1111,16 @@ func getContainerToUpdate(upgradeFuncs callbacks.RollingUpgradeFuncs, item inter
 		}
 	}
 
+	// the image pull secrets of the pod are not referenced by a container, the env var is saved in the first Pod container
+	if container == nil && config.Type == constants.SecretEnvVarPostfix && referencesImagePullSecret(upgradeFuncs, item, config.ResourceName) {
+		return &containers[0]
+	}
+
+	// custom workloads may reference the configmap or secret outside their pod template, the env var is saved in the first Pod container
+	if container == nil && upgradeFuncs.ReferencesFunc != nil && listsSource(upgradeFuncs.ReferencesFunc(item), config) {
+		return &containers[0]
+	}
+
 	// Get the first container if the annotation is related to specified configmap or secret i.e. configmap.reloader.stakater.com/reload
 	if container == nil && !autoReload {
 		return &containers[0]
@@ -258,20 +1129,31 @@ func getContainerToUpdate(upgradeFuncs callbacks.RollingUpgradeFuncs, item inter
 	return container
 }
 
-func updateContainers(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config, autoReload bool) constants.Result {
+// updateContainers sets the SHA env var of the configmap or secret in the container to update. An
+// existing env var is updated in place and a new one is appended, so the order of the other env vars
+// is never changed
+func updateContainers(log *logrus.Entry, upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config, autoReload bool, strategyName string) constants.Result {
 	var result constants.Result
-	envar := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	envar := constants.EnvVarPrefix + util.ConvertToEnvVarName(sourceReference(config)) + "_" + config.Type
 	container := getContainerToUpdate(upgradeFuncs, item, config, autoReload)
 
 	if container == nil {
 		return constants.NoContainerFound
 	}
+	if strategyName == callbacks.AnnotationsStrategy {
+		return updatePodAnnotation(log, upgradeFuncs, item, config)
+	}
+
+	// workloads reloaded by earlier releases hold the legacy SHA of the unchanged configmap or secret
+	if holdsSHA(upgradeFuncs.ContainersFunc(item), envar, config.LegacySHAValue) {
+		return constants.NotUpdated
+	}
 
 	//update if env var exists
 	result = updateEnvVar(upgradeFuncs.ContainersFunc(item), envar, config.SHAValue)
 
 	// if no existing env var exists lets create one
-	if result == constants.NoEnvVarFound {
+	if result == constants.NoEnvVarFound && !config.DriftedOnly {
 		e := v1.EnvVar{
 			Name:  envar,
 			Value: config.SHAValue,
@@ -282,6 +1164,71 @@ func updateContainers(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface
 	return result
 }
 
+// updatePodAnnotation records the hash of the change in the last-reloaded-from annotation of the pod
+// template, which maps "<type>/<name>" of each configmap and secret to the hash last reloaded from
+func updatePodAnnotation(log *logrus.Entry, upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config) constants.Result {
+	template := upgradeFuncs.PodTemplateFunc(item)
+	hashes := map[string]string{}
+	if value, found := template.Annotations[options.LastReloadedFromAnnotation]; found {
+		if err := json.Unmarshal([]byte(value), &hashes); err != nil {
+			log.Warnf("Replacing invalid %s annotation of '%s': %v", options.LastReloadedFromAnnotation, util.ToObjectMeta(item).Name, err)
+			hashes = map[string]string{}
+		}
+	}
+
+	source := strings.ToLower(config.Type) + "/" + sourceReference(config)
+	if hashes[source] == config.SHAValue || (config.LegacySHAValue != "" && hashes[source] == config.LegacySHAValue) {
+		return constants.NotUpdated
+	}
+	if _, recorded := hashes[source]; !recorded && config.DriftedOnly {
+		return constants.NotUpdated
+	}
+	hashes[source] = config.SHAValue
+	value, err := json.Marshal(hashes)
+	if err != nil {
+		log.Errorf("Unable to record %s annotation of '%s': %v", options.LastReloadedFromAnnotation, util.ToObjectMeta(item).Name, err)
+		return constants.NotUpdated
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[options.LastReloadedFromAnnotation] = string(value)
+	return constants.Updated
+}
+
+// holdsChange checks whether the pod template of the workload already holds the hash of the change, in
+// the env var or, for the annotations strategy, in the last-reloaded-from annotation
+func holdsChange(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}, config util.Config, strategyName string) bool {
+	if strategyName != callbacks.AnnotationsStrategy {
+		envar := constants.EnvVarPrefix + util.ConvertToEnvVarName(sourceReference(config)) + "_" + config.Type
+		return holdsSHA(upgradeFuncs.ContainersFunc(item), envar, config.SHAValue)
+	}
+	template := upgradeFuncs.PodTemplateFunc(item)
+	if template == nil {
+		return false
+	}
+	hashes := map[string]string{}
+	if err := json.Unmarshal([]byte(template.Annotations[options.LastReloadedFromAnnotation]), &hashes); err != nil {
+		return false
+	}
+	return config.SHAValue != "" && hashes[strings.ToLower(config.Type)+"/"+sourceReference(config)] == config.SHAValue
+}
+
+// holdsSHA checks whether the env var of the containers holds the SHA, which is never held if empty
+func holdsSHA(containers []v1.Container, envar string, sha string) bool {
+	if sha == "" {
+		return false
+	}
+	for i := range containers {
+		for _, env := range containers[i].Env {
+			if env.Name == envar {
+				return env.Value == sha
+			}
+		}
+	}
+	return false
+}
+
 func updateEnvVar(containers []v1.Container, envar string, shaData string) constants.Result {
 	for i := range containers {
 		envs := containers[i].Env
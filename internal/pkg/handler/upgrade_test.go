@@ -1,23 +1,51 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
 	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/history"
 	"github.com/stakater/Reloader/internal/pkg/metrics"
+	"github.com/stakater/Reloader/internal/pkg/notifier"
 	"github.com/stakater/Reloader/internal/pkg/options"
 	"github.com/stakater/Reloader/internal/pkg/testutil"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
 	"github.com/stakater/Reloader/internal/pkg/util"
 	"github.com/stakater/Reloader/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
 	core_v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/clock"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
 	testclient "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	openshiftv1 "github.com/openshift/api/apps/v1"
+	fakeopenshift "github.com/openshift/client-go/apps/clientset/versioned/fake"
 )
 
 var (
@@ -633,6 +661,10 @@ func TestRollingUpgradeForDeploymentWithConfigmap(t *testing.T) {
 	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
 		t.Errorf("Counter was not increased")
 	}
+	byResource := prometheus.Labels{"success": "true", "namespace": namespace, "kind": "Deployment", "source_type": constants.ConfigmapEnvVarPostfix}
+	if promtestutil.ToFloat64(collectors.ReloadedByResource.With(byResource)) != 1 {
+		t.Errorf("Counter by resource was not increased")
+	}
 }
 
 func TestRollingUpgradeForDeploymentWithConfigmapInProjectedVolume(t *testing.T) {
@@ -1281,6 +1313,9 @@ func TestFailedRollingUpgrade(t *testing.T) {
 	deploymentFuncs.UpdateFunc = func(_ kube.Clients, _ string, _ interface{}) error {
 		return fmt.Errorf("error")
 	}
+	deploymentFuncs.PatchFunc = func(_ kube.Clients, _ string, _ string, _ []byte) error {
+		return fmt.Errorf("error")
+	}
 	collectors := getCollectors()
 
 	_ = PerformRollingUpgrade(clients, config, deploymentFuncs, collectors)
@@ -1289,3 +1324,3011 @@ func TestFailedRollingUpgrade(t *testing.T) {
 		t.Errorf("Counter was not increased")
 	}
 }
+
+func createDeploymentReferencingConfigmap(clients kube.Clients, namespace, name, configmapName string, annotations map[string]string) error {
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(namespace, configmapName)
+	deploymentObj.Name = name
+	for k, v := range annotations {
+		deploymentObj.Annotations[k] = v
+	}
+	_, err := clients.KubernetesClient.AppsV1().Deployments(namespace).Create(deploymentObj)
+	return err
+}
+
+func isDeploymentUpdated(t *testing.T, clients kube.Clients, config util.Config, name string) bool {
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(config.Namespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment %s: %v", name, err)
+	}
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	return testutil.GetResourceSHA(deployment.Spec.Template.Spec.Containers, envName) == config.SHAValue
+}
+
+func TestRollingUpgradeForDeploymentOwnedByInstance(t *testing.T) {
+	instanceNamespace := "test-handler-instance-" + testutil.RandSeq(5)
+	name := "testconfigmap-instance-" + testutil.RandSeq(5)
+	deployments := map[string]map[string]string{
+		name + "-payments":   {options.InstanceAnnotation: "payments-reloader"},
+		name + "-orders":     {options.InstanceAnnotation: "orders-reloader"},
+		name + "-unassigned": {},
+	}
+	for deploymentName, annotations := range deployments {
+		if err := createDeploymentReferencingConfigmap(clients, instanceNamespace, deploymentName, name, annotations); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	tests := []struct {
+		instanceName    string
+		defaultInstance bool
+		data            string
+		updated         []string
+	}{
+		{instanceName: "payments-reloader", data: "www.payments.com", updated: []string{name + "-payments"}},
+		{instanceName: "payments-reloader", defaultInstance: true, data: "www.default.com", updated: []string{name + "-payments", name + "-unassigned"}},
+		{instanceName: "", data: "www.unnamed.com", updated: []string{name + "-unassigned"}},
+	}
+
+	defer func() {
+		options.InstanceName = ""
+		options.DefaultInstance = false
+	}()
+
+	for _, tt := range tests {
+		options.InstanceName = tt.instanceName
+		options.DefaultInstance = tt.defaultInstance
+
+		shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, instanceNamespace, name, tt.data)
+		config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+		config.Namespace = instanceNamespace
+
+		err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors())
+		if err != nil {
+			t.Errorf("Rolling upgrade failed for instance %q: %v", tt.instanceName, err)
+		}
+
+		for deploymentName := range deployments {
+			expected := false
+			for _, updated := range tt.updated {
+				if updated == deploymentName {
+					expected = true
+				}
+			}
+			if isDeploymentUpdated(t, clients, config, deploymentName) != expected {
+				t.Errorf("Instance %q (default: %t): expected deployment %s updated = %t", tt.instanceName, tt.defaultInstance, deploymentName, expected)
+			}
+		}
+	}
+}
+
+func TestRollingUpgradeForDeploymentPinnedToRevision(t *testing.T) {
+	pinNamespace := "test-handler-pin-" + testutil.RandSeq(5)
+	name := "testconfigmap-pin-" + testutil.RandSeq(5)
+	err := createDeploymentReferencingConfigmap(clients, pinNamespace, name, name, map[string]string{options.PinToRevisionAnnotation: "1"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(pinNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+
+	isController := true
+	pinnedTemplate := deployment.Spec.Template.DeepCopy()
+	pinnedTemplate.Spec.Containers[0].Image = "tutum/hello-world:pinned"
+	pinnedTemplate.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "5d8f9b6c7"
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        name + "-5d8f9b6c7",
+			Namespace:   pinNamespace,
+			Annotations: map[string]string{callbacks.RevisionAnnotation: "3", callbacks.RevisionHistoryAnnotation: "1"},
+			OwnerReferences: []v1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: deployment.Name, UID: deployment.UID, Controller: &isController},
+			},
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: *pinnedTemplate},
+	}
+	if _, err = clients.KubernetesClient.AppsV1().ReplicaSets(pinNamespace).Create(replicaSet); err != nil {
+		t.Fatalf("Failed to create replicaSet: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, pinNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = pinNamespace
+	collectors := getCollectors()
+
+	err = PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors)
+	if err != nil {
+		t.Errorf("Rolling upgrade failed for pinned Deployment: %v", err)
+	}
+
+	deployment, err = clients.KubernetesClient.AppsV1().Deployments(pinNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if deployment.Spec.Template.Spec.Containers[0].Image != "tutum/hello-world:pinned" {
+		t.Errorf("Deployment was not rolled back to the pinned revision")
+	}
+	if _, found := deployment.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; found {
+		t.Errorf("Pod template hash label of the pinned revision was not removed")
+	}
+	if isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Changes were applied to a pinned Deployment")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Counter was not increased")
+	}
+}
+
+func TestRollingUpgradeInObserveOnlyMode(t *testing.T) {
+	options.ObserveOnly = true
+	defer func() {
+		options.ObserveOnly = false
+	}()
+
+	observeNamespace := "test-handler-observe-" + testutil.RandSeq(5)
+	name := "testconfigmap-observe-" + testutil.RandSeq(5)
+	client := testclient.NewSimpleClientset(
+		testutil.GetDeployment(observeNamespace, name),
+		testutil.GetDaemonSet(observeNamespace, name),
+		testutil.GetStatefulSet(observeNamespace, name),
+	)
+	recorder := record.NewFakeRecorder(10)
+	observeClients := kube.Clients{KubernetesClient: client, EventRecorder: recorder}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, observeNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = observeNamespace
+	collectors := getCollectors()
+
+	for _, upgradeFuncs := range []callbacks.RollingUpgradeFuncs{GetDeploymentRollingUpgradeFuncs(), GetDaemonSetRollingUpgradeFuncs(), GetStatefulSetRollingUpgradeFuncs()} {
+		err := PerformRollingUpgrade(observeClients, config, upgradeFuncs, collectors)
+		if err != nil {
+			t.Errorf("Rolling upgrade failed for %s in observe-only mode: %v", upgradeFuncs.ResourceType, err)
+		}
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "list" && action.GetVerb() != "get" && action.GetVerb() != "watch" {
+			t.Errorf("Unexpected mutating call in observe-only mode: %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	if promtestutil.ToFloat64(collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonObserveOnly})) != 3 {
+		t.Errorf("Skipped counter was not increased for every observed reload")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 0 {
+		t.Errorf("Reload counter was increased in observe-only mode")
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected no events in observe-only mode, got %d", len(recorder.Events))
+	}
+}
+
+func TestRollingUpgradeInDryRunMode(t *testing.T) {
+	options.ObserveOnly = true
+	options.DryRun = true
+	defer func() {
+		options.ObserveOnly = false
+		options.DryRun = false
+	}()
+
+	dryRunNamespace := "test-handler-dry-run-" + testutil.RandSeq(5)
+	name := "testconfigmap-dry-run-" + testutil.RandSeq(5)
+	client := testclient.NewSimpleClientset(testutil.GetDeployment(dryRunNamespace, name), testutil.GetDaemonSet(dryRunNamespace, name))
+	recorder := record.NewFakeRecorder(10)
+	dryRunClients := kube.Clients{KubernetesClient: client, EventRecorder: recorder}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, dryRunNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = dryRunNamespace
+
+	for _, upgradeFuncs := range []callbacks.RollingUpgradeFuncs{GetDeploymentRollingUpgradeFuncs(), GetDaemonSetRollingUpgradeFuncs()} {
+		if err := PerformRollingUpgrade(dryRunClients, config, upgradeFuncs, getCollectors()); err != nil {
+			t.Errorf("Rolling upgrade failed for %s in dry-run mode: %v", upgradeFuncs.ResourceType, err)
+		}
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "list" && action.GetVerb() != "get" && action.GetVerb() != "watch" {
+			t.Errorf("Unexpected mutating call in dry-run mode: %s %s", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	if len(recorder.Events) != 2 {
+		t.Errorf("Expected an event for every reload skipped in dry-run mode, got %d", len(recorder.Events))
+	}
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; !strings.HasPrefix(event, "Normal "+EventReasonWouldReload+" ") {
+			t.Errorf("Unexpected event in dry-run mode: %s", event)
+		}
+	}
+}
+
+func getPodTemplateSpecWithAllReferences() core_v1.PodTemplateSpec {
+	return core_v1.PodTemplateSpec{
+		Spec: core_v1.PodSpec{
+			InitContainers: []core_v1.Container{
+				{
+					Name: "init",
+					EnvFrom: []core_v1.EnvFromSource{
+						{ConfigMapRef: &core_v1.ConfigMapEnvSource{LocalObjectReference: core_v1.LocalObjectReference{Name: "init-envfrom-configmap"}}},
+					},
+					VolumeMounts: []core_v1.VolumeMount{{Name: "init-secret", MountPath: "/etc/init"}},
+				},
+			},
+			Containers: []core_v1.Container{
+				{
+					Name: "sidecar",
+					Env: []core_v1.EnvVar{
+						{
+							Name: "TOKEN",
+							ValueFrom: &core_v1.EnvVarSource{
+								SecretKeyRef: &core_v1.SecretKeySelector{LocalObjectReference: core_v1.LocalObjectReference{Name: "env-secret"}, Key: "token"},
+							},
+						},
+					},
+				},
+				{
+					Name: "app",
+					EnvFrom: []core_v1.EnvFromSource{
+						{SecretRef: &core_v1.SecretEnvSource{LocalObjectReference: core_v1.LocalObjectReference{Name: "envfrom-secret"}}},
+					},
+					Env: []core_v1.EnvVar{
+						{
+							Name: "URL",
+							ValueFrom: &core_v1.EnvVarSource{
+								ConfigMapKeyRef: &core_v1.ConfigMapKeySelector{LocalObjectReference: core_v1.LocalObjectReference{Name: "env-configmap"}, Key: "url"},
+							},
+						},
+					},
+					VolumeMounts: []core_v1.VolumeMount{
+						{Name: "config", MountPath: "/etc/config/app.yaml", SubPath: "app.yaml"},
+						{Name: "projected", MountPath: "/etc/projected"},
+					},
+				},
+			},
+			Volumes: []core_v1.Volume{
+				{Name: "config", VolumeSource: core_v1.VolumeSource{ConfigMap: &core_v1.ConfigMapVolumeSource{LocalObjectReference: core_v1.LocalObjectReference{Name: "subpath-configmap"}}}},
+				{Name: "init-secret", VolumeSource: core_v1.VolumeSource{Secret: &core_v1.SecretVolumeSource{SecretName: "init-volume-secret"}}},
+				{
+					Name: "projected",
+					VolumeSource: core_v1.VolumeSource{
+						Projected: &core_v1.ProjectedVolumeSource{
+							Sources: []core_v1.VolumeProjection{
+								{ConfigMap: &core_v1.ConfigMapProjection{LocalObjectReference: core_v1.LocalObjectReference{Name: "projected-configmap"}}},
+								{Secret: &core_v1.SecretProjection{LocalObjectReference: core_v1.LocalObjectReference{Name: "projected-secret"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestStatefulSetReferenceDetectionParityWithDeployment(t *testing.T) {
+	deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: getPodTemplateSpecWithAllReferences()}}
+	daemonSet := &appsv1.DaemonSet{Spec: appsv1.DaemonSetSpec{Template: getPodTemplateSpecWithAllReferences()}}
+	statefulSet := &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Template: getPodTemplateSpecWithAllReferences()}}
+
+	references := []struct {
+		name      string
+		envPrefix string
+		container string
+	}{
+		{name: "subpath-configmap", envPrefix: constants.ConfigmapEnvVarPostfix, container: "app"},
+		{name: "projected-configmap", envPrefix: constants.ConfigmapEnvVarPostfix, container: "app"},
+		{name: "projected-secret", envPrefix: constants.SecretEnvVarPostfix, container: "app"},
+		{name: "env-configmap", envPrefix: constants.ConfigmapEnvVarPostfix, container: "app"},
+		{name: "env-secret", envPrefix: constants.SecretEnvVarPostfix, container: "sidecar"},
+		{name: "envfrom-secret", envPrefix: constants.SecretEnvVarPostfix, container: "app"},
+		{name: "init-envfrom-configmap", envPrefix: constants.ConfigmapEnvVarPostfix, container: "sidecar"},
+		{name: "init-volume-secret", envPrefix: constants.SecretEnvVarPostfix, container: "sidecar"},
+		{name: "unused-configmap", envPrefix: constants.ConfigmapEnvVarPostfix, container: ""},
+	}
+
+	for _, reference := range references {
+		config := util.Config{ResourceName: reference.name, Type: reference.envPrefix}
+		for _, workload := range []struct {
+			item         interface{}
+			upgradeFuncs callbacks.RollingUpgradeFuncs
+		}{
+			{item: deployment, upgradeFuncs: GetDeploymentRollingUpgradeFuncs()},
+			{item: daemonSet, upgradeFuncs: GetDaemonSetRollingUpgradeFuncs()},
+			{item: statefulSet, upgradeFuncs: GetStatefulSetRollingUpgradeFuncs()},
+		} {
+			upgradeFuncs := workload.upgradeFuncs
+			container := getContainerToUpdate(upgradeFuncs, workload.item, config, true)
+			detected := ""
+			if container != nil {
+				detected = container.Name
+			}
+			if detected != reference.container {
+				t.Errorf("%s: reference to %s detected in container %q, expected %q", upgradeFuncs.ResourceType, reference.name, detected, reference.container)
+			}
+		}
+	}
+}
+
+func TestRollingUpgradeRecordsChangeCause(t *testing.T) {
+	recordChangeCause := options.RecordChangeCause
+	defer func() { options.RecordChangeCause = recordChangeCause }()
+	options.RecordChangeCause = true
+
+	changeCauseNamespace := "test-handler-change-cause-" + testutil.RandSeq(5)
+	name := "testconfigmap-change-cause-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, changeCauseNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	customized := name + "-customized"
+	err := createDeploymentReferencingConfigmap(clients, changeCauseNamespace, customized, name, map[string]string{options.ChangeCauseAnnotation: "payments config rollout"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, changeCauseNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = changeCauseNamespace
+
+	err = PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors())
+	if err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	expected := map[string]string{
+		name:       "Reloader: configmap " + name + " changed",
+		customized: "payments config rollout",
+	}
+	for deploymentName, changeCause := range expected {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(changeCauseNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		if deployment.Annotations[constants.KubernetesChangeCauseAnnotation] != changeCause {
+			t.Errorf("Deployment %s has change-cause %q, expected %q", deploymentName, deployment.Annotations[constants.KubernetesChangeCauseAnnotation], changeCause)
+		}
+	}
+}
+
+func TestRollingUpgradeRecordsChangeCauseOnlyOfAnnotatedWorkloadsByDefault(t *testing.T) {
+	changeCauseNamespace := "test-handler-change-cause-default-" + testutil.RandSeq(5)
+	name := "testconfigmap-change-cause-default-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, changeCauseNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	customized := name + "-customized"
+	err := createDeploymentReferencingConfigmap(clients, changeCauseNamespace, customized, name, map[string]string{options.ChangeCauseAnnotation: "payments config rollout"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, changeCauseNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = changeCauseNamespace
+
+	err = PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors())
+	if err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	expected := map[string]string{
+		name:       "",
+		customized: "payments config rollout",
+	}
+	for deploymentName, changeCause := range expected {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(changeCauseNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		if deployment.Annotations[constants.KubernetesChangeCauseAnnotation] != changeCause {
+			t.Errorf("Deployment %s has change-cause %q, expected %q", deploymentName, deployment.Annotations[constants.KubernetesChangeCauseAnnotation], changeCause)
+		}
+	}
+}
+
+// recordingStrategy records the workloads it is applied to instead of reloading them
+type recordingStrategy struct {
+	applied map[string]string
+}
+
+func (s *recordingStrategy) Apply(clients kube.Clients, upgradeFuncs callbacks.RollingUpgradeFuncs, namespace string, workload interface{}, hash string) error {
+	s.applied[util.ToObjectMeta(workload).Name] = hash
+	return nil
+}
+
+func TestRollingUpgradeWithRegisteredStrategy(t *testing.T) {
+	strategyNamespace := "test-handler-strategy-" + testutil.RandSeq(5)
+	name := "testconfigmap-strategy-" + testutil.RandSeq(5)
+	deployments := map[string]map[string]string{
+		name:              {options.ReloadStrategyAnnotation: "recording"},
+		name + "-rolling": nil,
+		name + "-unknown": {options.ReloadStrategyAnnotation: "unknown"},
+	}
+	for deploymentName, annotations := range deployments {
+		if err := createDeploymentReferencingConfigmap(clients, strategyNamespace, deploymentName, name, annotations); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	strategy := &recordingStrategy{applied: map[string]string{}}
+	callbacks.RegisterStrategy("recording", strategy)
+
+	collectors := getCollectors()
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, strategyNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = strategyNamespace
+	_ = PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors)
+
+	if len(strategy.applied) != 1 || strategy.applied[name] != shaData {
+		t.Errorf("Registered strategy was not applied to the annotated deployment only, applied to %v", strategy.applied)
+	}
+	if isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment reloaded by the registered strategy was also updated")
+	}
+	if !isDeploymentUpdated(t, clients, config, name+"-rolling") {
+		t.Errorf("Deployment without a reload strategy was not updated by the rolling strategy")
+	}
+	if isDeploymentUpdated(t, clients, config, name+"-unknown") {
+		t.Errorf("Deployment with an unknown reload strategy was updated")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(prometheus.Labels{"success": "false"})) != 1 {
+		t.Errorf("Expected the reload with an unknown strategy to be counted as failed")
+	}
+}
+
+func TestRollingUpgradeRecordsDecisionsInHistory(t *testing.T) {
+	historyNamespace := "test-handler-history-" + testutil.RandSeq(5)
+	name := "testconfigmap-history-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, historyNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "reloader-history")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	History = history.NewStore(filepath.Join(dir, "history.jsonl"), 1<<20)
+	defer func() { History = nil }()
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, historyNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = historyNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	records, err := History.Query(history.Filter{Namespace: historyNamespace, Kind: "Deployment", Name: name})
+	if err != nil {
+		t.Fatalf("Failed to query history: %v", err)
+	}
+	if len(records) != 1 || records[0].Decision != history.Reloaded || records[0].SourceName != name {
+		t.Errorf("Expected the reload to be recorded in the history, got %v", records)
+	}
+}
+
+func TestRollingUpgradeForAlwaysReloadSource(t *testing.T) {
+	alwaysNamespace := "test-handler-always-" + testutil.RandSeq(5)
+	name := "testsecret-always-" + testutil.RandSeq(5)
+	// neither deployment opts in to reloads
+	for deploymentName, sourceName := range map[string]string{name: name, name + "-unrelated": "unrelated-secret"} {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(alwaysNamespace, sourceName)
+		deploymentObj.Name = deploymentName
+		deploymentObj.Annotations = nil
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(alwaysNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.SecretResourceType, alwaysNamespace, name, "dGVzdFNlY3JldEVuY29kaW5nRm9yUmVsb2FkZXI=")
+	config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, name, shaData, options.SecretUpdateOnChangeAnnotation)
+	config.Namespace = alwaysNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment without reload annotations was updated for a source that is not always reloaded")
+	}
+
+	alwaysReloadSources := options.AlwaysReloadSources
+	options.AlwaysReloadSources = []string{"kube-system/cluster-ca", alwaysNamespace + "/" + name}
+	defer func() { options.AlwaysReloadSources = alwaysReloadSources }()
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment referencing an always reloaded source was not updated")
+	}
+	if isDeploymentUpdated(t, clients, config, name+"-unrelated") {
+		t.Errorf("Deployment not referencing the always reloaded source was updated")
+	}
+}
+
+func TestRollingUpgradeWithReloadWhenKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      map[string]string
+		new      map[string]string
+		reloaded bool
+	}{
+		{name: "reaching the value", old: map[string]string{"enabled": "false"}, new: map[string]string{"enabled": "true"}, reloaded: true},
+		{name: "key added with the value", old: map[string]string{}, new: map[string]string{"enabled": "true"}, reloaded: true},
+		{name: "leaving the value", old: map[string]string{"enabled": "true"}, new: map[string]string{"enabled": "false"}, reloaded: false},
+		{name: "other key changing at the value", old: map[string]string{"enabled": "true", "url": "a"}, new: map[string]string{"enabled": "true", "url": "b"}, reloaded: false},
+		{name: "other key changing", old: map[string]string{"enabled": "false", "url": "a"}, new: map[string]string{"enabled": "false", "url": "b"}, reloaded: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			whenKeyNamespace := "test-handler-when-key-" + testutil.RandSeq(5)
+			name := "testconfigmap-when-key-" + testutil.RandSeq(5)
+			err := createDeploymentReferencingConfigmap(clients, whenKeyNamespace, name, name, map[string]string{options.ReloadWhenKeyAnnotation: "enabled=true"})
+			if err != nil {
+				t.Fatalf("Failed to create deployment: %v", err)
+			}
+
+			oldConfigmap := testutil.GetConfigmap(whenKeyNamespace, name, "")
+			oldConfigmap.Data = tt.old
+			newConfigmap := testutil.GetConfigmap(whenKeyNamespace, name, "")
+			newConfigmap.Data = tt.new
+			config, _ := ResourceUpdatedHandler{Resource: newConfigmap, OldResource: oldConfigmap}.GetConfig()
+			if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+				t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+			}
+			if updated := isDeploymentUpdated(t, clients, config, name); updated != tt.reloaded {
+				t.Errorf("Expected deployment updated %v, got %v", tt.reloaded, updated)
+			}
+		})
+	}
+}
+
+func TestRollingUpgradeWithForcePull(t *testing.T) {
+	forcePullNamespace := "test-handler-force-pull-" + testutil.RandSeq(5)
+	name := "testconfigmap-force-pull-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, forcePullNamespace, name, name, map[string]string{options.ForcePullAnnotation: "true"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if err := createDeploymentReferencingConfigmap(clients, forcePullNamespace, name+"-cached", name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, forcePullNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = forcePullNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	for deploymentName, forcePull := range map[string]bool{name: true, name + "-cached": false} {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(forcePullNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		marker, found := deployment.Spec.Template.Annotations[options.ForcePullMarkerAnnotation]
+		if found != forcePull || (forcePull && marker != shaData) {
+			t.Errorf("Deployment %s has force-pull marker %q, expected the hash of the change: %v", deploymentName, marker, forcePull)
+		}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.ImagePullPolicy == core_v1.PullAlways {
+				t.Errorf("Deployment %s got image pull policy %q, expected it to be left alone", deploymentName, container.ImagePullPolicy)
+			}
+		}
+	}
+}
+
+func TestRollingUpgradeCountsReloadsByTeam(t *testing.T) {
+	teamNamespace := "test-handler-team-" + testutil.RandSeq(5)
+	name := "testconfigmap-team-" + testutil.RandSeq(5)
+	teams := map[string]string{
+		name + "-payments": "payments",
+		name + "-orders":   "orders",
+		name + "-nolabel":  "",
+	}
+	for deploymentName, team := range teams {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(teamNamespace, name)
+		deploymentObj.Name = deploymentName
+		if team != "" {
+			deploymentObj.Labels["team"] = team
+		}
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(teamNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	teamLabel := options.TeamLabel
+	options.TeamLabel = "team"
+	defer func() { options.TeamLabel = teamLabel }()
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, teamNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = teamNamespace
+	collectors := getCollectors()
+
+	err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors)
+	if err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	for _, team := range []string{"payments", "orders", metrics.UnknownTeam} {
+		if promtestutil.ToFloat64(collectors.ReloadedByTeam.With(prometheus.Labels{"team": team})) != 1 {
+			t.Errorf("Counter for team %s was not increased", team)
+		}
+	}
+}
+
+func TestRollingUpgradeWithMinAvailableBeforeReload(t *testing.T) {
+	availabilityNamespace := "test-handler-min-available-" + testutil.RandSeq(5)
+	name := "testconfigmap-min-available-" + testutil.RandSeq(5)
+	availableReplicas := map[string]int32{
+		name + "-healthy":   3,
+		name + "-unhealthy": 1,
+	}
+	for deploymentName, available := range availableReplicas {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(availabilityNamespace, name)
+		deploymentObj.Name = deploymentName
+		deploymentObj.Annotations[options.MinAvailableBeforeReloadAnnotation] = "2"
+		deploymentObj.Status.AvailableReplicas = available
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(availabilityNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, availabilityNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = availabilityNamespace
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+
+	err := PerformRollingUpgrade(clients, config, deploymentFuncs, getCollectors())
+	if err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	if !isDeploymentUpdated(t, clients, config, name+"-healthy") {
+		t.Errorf("Healthy deployment was not updated")
+	}
+	if isDeploymentUpdated(t, clients, config, name+"-unhealthy") {
+		t.Errorf("Unhealthy deployment was updated")
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name+"-unhealthy")
+	if _, found := deferred.get(key); !found {
+		t.Fatalf("Reload of unhealthy deployment was not deferred")
+	}
+
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(availabilityNamespace).Get(name+"-unhealthy", v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	deployment.Status.AvailableReplicas = 2
+	if _, err = clients.KubernetesClient.AppsV1().Deployments(availabilityNamespace).UpdateStatus(deployment); err != nil {
+		t.Fatalf("Failed to update deployment status: %v", err)
+	}
+
+	deferred.process(key)
+
+	if !isDeploymentUpdated(t, clients, config, name+"-unhealthy") {
+		t.Errorf("Deferred reload was not applied once the deployment became healthy")
+	}
+	if _, found := deferred.get(key); found {
+		t.Errorf("Applied reload is still deferred")
+	}
+}
+
+func TestDeferredReloadWaitsForUpgradeOfSameWorkload(t *testing.T) {
+	lockNamespace := "test-handler-deferred-lock-" + testutil.RandSeq(5)
+	name := "testconfigmap-deferred-lock-" + testutil.RandSeq(5)
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(lockNamespace, name)
+	deploymentObj.Annotations[options.MinAvailableBeforeReloadAnnotation] = "2"
+	deploymentObj.Status.AvailableReplicas = 1
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(lockNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, lockNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = lockNamespace
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	if err := PerformRollingUpgrade(clients, config, deploymentFuncs, getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name)
+	if _, found := deferred.get(key); !found {
+		t.Fatalf("Reload of unavailable deployment was not deferred")
+	}
+	deploymentObj.Status.AvailableReplicas = 2
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(lockNamespace).UpdateStatus(deploymentObj); err != nil {
+		t.Fatalf("Failed to update deployment status: %v", err)
+	}
+
+	// a live change of the deployment is being applied
+	unlock := lockWorkload(workloadKey(deploymentFuncs, lockNamespace, name))
+	processed := make(chan struct{})
+	go func() {
+		deferred.process(key)
+		close(processed)
+	}()
+	select {
+	case <-processed:
+		t.Errorf("Deferred reload was applied while the deployment was being upgraded for a live change")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+
+	select {
+	case <-processed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Deferred reload was not applied once the upgrade of the deployment completed")
+	}
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deferred reload was not applied once the deployment became available")
+	}
+}
+
+func TestRollingUpgradeCountsReloadsByWorkloadWithDetailedMetrics(t *testing.T) {
+	detailedNamespace := "test-handler-detailed-" + testutil.RandSeq(5)
+	name := "testconfigmap-detailed-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, detailedNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, detailedNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = detailedNamespace
+	config.ResourceVersion = "4711"
+
+	collectors := getCollectors()
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if promtestutil.CollectAndCount(collectors.ReloadedByWorkload) != 0 {
+		t.Errorf("Reload was counted by workload without detailed metrics")
+	}
+
+	detailedMetrics := options.DetailedMetrics
+	options.DetailedMetrics = true
+	defer func() { options.DetailedMetrics = detailedMetrics }()
+
+	config.SHAValue = testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, detailedNamespace, name, "www.stakater.com/detailed")
+	config.ResourceVersion = "4712"
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	labels := prometheus.Labels{
+		"namespace":               detailedNamespace,
+		"kind":                    "Deployment",
+		"name":                    name,
+		"source_type":             constants.ConfigmapEnvVarPostfix,
+		"source_name":             name,
+		"source_resource_version": "4712",
+	}
+	if promtestutil.ToFloat64(collectors.ReloadedByWorkload.With(labels)) != 1 {
+		t.Errorf("Reload was not counted by workload and source resourceVersion")
+	}
+}
+
+func TestRollingUpgradeWithGuardQuery(t *testing.T) {
+	guardNamespace := "test-handler-guard-" + testutil.RandSeq(5)
+	name := "testconfigmap-guard-" + testutil.RandSeq(5)
+	queries := map[string]string{
+		name + "-passing": "passing_error_rate",
+		name + "-failing": "failing_error_rate",
+	}
+	for deploymentName, query := range queries {
+		annotations := map[string]string{options.GuardQueryAnnotation: query, options.GuardThresholdAnnotation: "0.05"}
+		if err := createDeploymentReferencingConfigmap(clients, guardNamespace, deploymentName, name, annotations); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	errorRates := map[string]string{"passing_error_rate": "0.01", "failing_error_rate": "0.2"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1600000000,"%s"]}]}}`, errorRates[r.URL.Query().Get("query")])
+	}))
+	defer server.Close()
+	prometheusURL := options.PrometheusURL
+	options.PrometheusURL = server.URL
+	defer func() { options.PrometheusURL = prometheusURL }()
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, guardNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = guardNamespace
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+
+	err := PerformRollingUpgrade(clients, config, deploymentFuncs, getCollectors())
+	if err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	if !isDeploymentUpdated(t, clients, config, name+"-passing") {
+		t.Errorf("Deployment with passing guard query was not updated")
+	}
+	if isDeploymentUpdated(t, clients, config, name+"-failing") {
+		t.Errorf("Deployment with failing guard query was updated")
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name+"-failing")
+	if _, found := deferred.get(key); !found {
+		t.Fatalf("Reload of deployment with failing guard query was not deferred")
+	}
+
+	errorRates["failing_error_rate"] = "0.02"
+	deferred.process(key)
+
+	if !isDeploymentUpdated(t, clients, config, name+"-failing") {
+		t.Errorf("Deferred reload was not applied once the guard query passed")
+	}
+}
+
+func TestRollingUpgradePreservesEnvVarOrder(t *testing.T) {
+	envOrderNamespace := "test-handler-env-order-" + testutil.RandSeq(5)
+	name := "testconfigmap-env-order-" + testutil.RandSeq(5)
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(envOrderNamespace, name)
+	deploymentObj.Name = name
+	deploymentObj.Spec.Template.Spec.Containers[0].Env = []core_v1.EnvVar{
+		{Name: "ZETA", Value: "1"},
+		{Name: "ALPHA", Value: "2"},
+		{Name: "MIDDLE", Value: "3"},
+	}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(envOrderNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(name) + "_" + constants.ConfigmapEnvVarPostfix
+	expected := []string{"ZETA", "ALPHA", "MIDDLE", envName}
+	for _, data := range []string{"www.stakater.com", "www.google.com", "www.stakater.com/reloader"} {
+		shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, envOrderNamespace, name, data)
+		config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+		config.Namespace = envOrderNamespace
+		if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+			t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+		}
+		if !isDeploymentUpdated(t, clients, config, name) {
+			t.Errorf("Deployment was not updated for %s", data)
+		}
+
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(envOrderNamespace).Get(name, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment: %v", err)
+		}
+		env := deployment.Spec.Template.Spec.Containers[0].Env
+		if len(env) != len(expected) {
+			t.Fatalf("Expected env vars %v, got %v", expected, env)
+		}
+		for i := range expected {
+			if env[i].Name != expected[i] {
+				t.Errorf("Expected env var %s at position %d, got %s", expected[i], i, env[i].Name)
+			}
+		}
+	}
+}
+
+func TestRollingUpgradeDeferredDuringRollout(t *testing.T) {
+	rolloutNamespace := "test-handler-rollout-" + testutil.RandSeq(5)
+	name := "testconfigmap-rollout-" + testutil.RandSeq(5)
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(rolloutNamespace, name)
+	deploymentObj.Name = name
+	deploymentObj.Generation = 2
+	deploymentObj.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(rolloutNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	deferReloadDuringRollout := options.DeferReloadDuringRollout
+	options.DeferReloadDuringRollout = true
+	defer func() { options.DeferReloadDuringRollout = deferReloadDuringRollout }()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	var config util.Config
+	for _, data := range []string{"www.stakater.com", "www.stakater.com/latest"} {
+		shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, rolloutNamespace, name, data)
+		config = getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+		config.Namespace = rolloutNamespace
+		if err := PerformRollingUpgrade(clients, config, deploymentFuncs, collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+		}
+	}
+
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 0 {
+		t.Errorf("Deployment was updated during its rollout")
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name)
+	reload, found := deferred.get(key)
+	if !found {
+		t.Fatalf("Reload during rollout was not deferred")
+	}
+	if reload.config.SHAValue != config.SHAValue {
+		t.Errorf("Deferred reload does not carry the latest change")
+	}
+
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(rolloutNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	deployment.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	if _, err = clients.KubernetesClient.AppsV1().Deployments(rolloutNamespace).UpdateStatus(deployment); err != nil {
+		t.Fatalf("Failed to update deployment status: %v", err)
+	}
+
+	deferred.process(key)
+	deferred.process(key)
+
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not updated with the latest change after its rollout completed")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Expected a single reload after the rollout completed")
+	}
+}
+
+func TestRollingUpgradeDeferredUntilAllowedReloadWindow(t *testing.T) {
+	windowNamespace := "test-handler-window-" + testutil.RandSeq(5)
+	name := "testconfigmap-window-" + testutil.RandSeq(5)
+	for _, deploymentName := range []string{name, name + "-expired"} {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(windowNamespace, name)
+		deploymentObj.Name = deploymentName
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(windowNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	allowedReloadWindow, maxQueuedAge := options.AllowedReloadWindow, options.MaxQueuedAge
+	options.AllowedReloadWindow = "Mon-Fri 09:00-17:00"
+	options.MaxQueuedAge = 24 * time.Hour
+	// Saturday noon, the window opens on Monday morning
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.March, 7, 12, 0, 0, 0, time.UTC))
+	deferredClock := deferred.clock
+	deferred.clock = fakeClock
+	defer func() {
+		options.AllowedReloadWindow, options.MaxQueuedAge = allowedReloadWindow, maxQueuedAge
+		deferred.clock = deferredClock
+	}()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, windowNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = windowNamespace
+	if err := PerformRollingUpgrade(clients, config, deploymentFuncs, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 0 {
+		t.Errorf("Deployment was updated outside of the allowed reload window")
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name)
+	expiredKey := deferredReloadKey(config, deploymentFuncs, name+"-expired")
+	for _, k := range []string{key, expiredKey} {
+		if _, found := deferred.get(k); !found {
+			t.Fatalf("Reload outside of the allowed reload window was not queued for %s", k)
+		}
+	}
+
+	// retried while still outside of the window, the reload is queued again and keeps its age
+	fakeClock.Step(6 * time.Hour)
+	deferred.process(key)
+	reload, found := deferred.get(key)
+	if !found {
+		t.Fatalf("Reload retried outside of the allowed reload window was not queued again")
+	}
+	if !reload.queuedAt.Equal(time.Date(2020, time.March, 7, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Reload queued again lost its queued time, got %s", reload.queuedAt)
+	}
+
+	// the window is not open by the max queued age, the reload is dropped
+	fakeClock.Step(18 * time.Hour)
+	deferred.process(expiredKey)
+	if _, found := deferred.get(expiredKey); found {
+		t.Errorf("Expired reload was not dropped")
+	}
+	if promtestutil.ToFloat64(collectors.Skipped.With(prometheus.Labels{"reason": metrics.SkipReasonExpired})) != 1 {
+		t.Errorf("Expected the expired reload to be counted as skipped")
+	}
+
+	// within the window the queued reload is flushed
+	options.MaxQueuedAge = 0
+	fakeClock.SetTime(time.Date(2020, time.March, 9, 9, 30, 0, 0, time.UTC))
+	deferred.process(key)
+	if _, found := deferred.get(key); found {
+		t.Errorf("Reload within the allowed reload window is still queued")
+	}
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not updated within the allowed reload window")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Expected a single reload within the allowed reload window")
+	}
+}
+
+func TestRollingUpgradeWithReloadWindowAnnotation(t *testing.T) {
+	windowNamespace := "test-handler-window-annotation-" + testutil.RandSeq(5)
+	name := "testconfigmap-window-annotation-" + testutil.RandSeq(5)
+	// the workload is only reloaded at weekends, while other workloads are reloaded on weekdays
+	if err := createDeploymentReferencingConfigmap(clients, windowNamespace, name, name, map[string]string{options.ReloadWindowAnnotation: "Sat,Sun 00:00-23:59"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if err := createDeploymentReferencingConfigmap(clients, windowNamespace, name+"-weekdays", name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	allowedReloadWindow := options.AllowedReloadWindow
+	options.AllowedReloadWindow = "Mon-Fri 09:00-17:00"
+	// Saturday noon
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.March, 7, 12, 0, 0, 0, time.UTC))
+	deferredClock := deferred.clock
+	deferred.clock = fakeClock
+	defer func() {
+		options.AllowedReloadWindow = allowedReloadWindow
+		deferred.clock = deferredClock
+	}()
+
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, windowNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = windowNamespace
+	if err := PerformRollingUpgrade(clients, config, deploymentFuncs, getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	defer deferred.forget(deferredReloadKey(config, deploymentFuncs, name+"-weekdays"))
+
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not updated within the reload window of its annotation")
+	}
+	if isDeploymentUpdated(t, clients, config, name+"-weekdays") {
+		t.Errorf("Deployment without annotation was updated outside of the allowed reload window")
+	}
+	if _, found := deferred.get(deferredReloadKey(config, deploymentFuncs, name+"-weekdays")); !found {
+		t.Errorf("Reload outside of the allowed reload window was not queued")
+	}
+}
+
+func TestRollingUpgradeDeferredDuringPostReloadCooldown(t *testing.T) {
+	testRollingUpgradeDeferredDuringCooldown(t, options.PostReloadCooldownAnnotation)
+}
+
+func TestRollingUpgradeDeferredDuringCooldownOfAlias(t *testing.T) {
+	testRollingUpgradeDeferredDuringCooldown(t, options.CooldownAnnotation)
+}
+
+func testRollingUpgradeDeferredDuringCooldown(t *testing.T, annotation string) {
+	cooldownNamespace := "test-handler-cooldown-" + testutil.RandSeq(5)
+	name := "testconfigmap-cooldown-" + testutil.RandSeq(5)
+	err := createDeploymentReferencingConfigmap(clients, cooldownNamespace, name, name, map[string]string{annotation: "10m"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.March, 9, 12, 0, 0, 0, time.UTC))
+	deferredClock := deferred.clock
+	deferred.clock = fakeClock
+	defer func() { deferred.clock = deferredClock }()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	var configs []util.Config
+	for _, data := range []string{"www.stakater.com", "www.stakater.com/latest"} {
+		shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, cooldownNamespace, name, data)
+		config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+		config.Namespace = cooldownNamespace
+		if err := PerformRollingUpgrade(clients, config, deploymentFuncs, collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+		}
+		configs = append(configs, config)
+		fakeClock.Step(time.Minute)
+	}
+
+	if !isDeploymentUpdated(t, clients, configs[0], name) {
+		t.Errorf("Deployment was not updated with the first change")
+	}
+	if isDeploymentUpdated(t, clients, configs[1], name) {
+		t.Errorf("Deployment was updated during its post-reload cooldown")
+	}
+	key := deferredReloadKey(configs[1], deploymentFuncs, name)
+	if _, found := deferred.get(key); !found {
+		t.Fatalf("Change during the post-reload cooldown was not deferred")
+	}
+
+	fakeClock.Step(10 * time.Minute)
+	deferred.process(key)
+	if !isDeploymentUpdated(t, clients, configs[1], name) {
+		t.Errorf("Deployment was not updated with the latest change after its post-reload cooldown")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 2 {
+		t.Errorf("Expected two reloads, one before and one after the post-reload cooldown")
+	}
+}
+
+func TestRollingUpgradeSampledForHighChurnSecret(t *testing.T) {
+	sampleNamespace := "test-handler-sample-" + testutil.RandSeq(5)
+	name := "testsecret-sample-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, sampleNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.March, 9, 12, 0, 0, 0, time.UTC))
+	deferredClock := deferred.clock
+	deferred.clock = fakeClock
+	defer func() { deferred.clock = deferredClock }()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	secretConfig := func(token string) util.Config {
+		shaData := testutil.ConvertResourceToSHA(testutil.SecretResourceType, sampleNamespace, name, token)
+		config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, name, shaData, options.SecretUpdateOnChangeAnnotation)
+		config.Namespace = sampleNamespace
+		config.ResourceAnnotations = map[string]string{options.ReloadSampleIntervalAnnotation: "10s"}
+		return config
+	}
+
+	// the token rotates every second for 25 seconds, deferred reloads are retried as they become due
+	var config util.Config
+	for second := 0; second < 25; second++ {
+		config = secretConfig(fmt.Sprintf("token-%d", second))
+		if err := PerformRollingUpgrade(clients, config, deploymentFuncs, collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+		}
+		deferred.process(deferredReloadKey(config, deploymentFuncs, name))
+		fakeClock.Step(time.Second)
+	}
+
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 3 {
+		t.Errorf("Expected a reload every 10s during sustained churn, at 0s, 10s and 20s, got %v reloads", reloads)
+	}
+	if !isDeploymentUpdated(t, clients, secretConfig("token-20"), name) {
+		t.Errorf("Deployment was not reloaded with the latest token at 20s")
+	}
+
+	fakeClock.Step(10 * time.Second)
+	deferred.process(deferredReloadKey(config, deploymentFuncs, name))
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not reloaded with the latest token once the churn stopped")
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 4 {
+		t.Errorf("Expected a last reload once the churn stopped, got %v reloads", reloads)
+	}
+}
+
+func TestRollingUpgradeForDeploymentWithEphemeralContainerReference(t *testing.T) {
+	ephemeralNamespace := "test-handler-ephemeral-" + testutil.RandSeq(5)
+	name := "testconfigmap-ephemeral-" + testutil.RandSeq(5)
+	ephemeralContainers := map[string]core_v1.EphemeralContainerCommon{
+		name + "-env": {
+			Name:  "debugger",
+			Image: "busybox",
+			EnvFrom: []core_v1.EnvFromSource{
+				{ConfigMapRef: &core_v1.ConfigMapEnvSource{LocalObjectReference: core_v1.LocalObjectReference{Name: name}}},
+			},
+		},
+		name + "-volume": {
+			Name:         "debugger",
+			Image:        "busybox",
+			VolumeMounts: []core_v1.VolumeMount{{Name: "debug-config", MountPath: "/etc/debug"}},
+		},
+	}
+	for deploymentName, ephemeralContainer := range ephemeralContainers {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(ephemeralNamespace, "unrelated-configmap")
+		deploymentObj.Name = deploymentName
+		podSpec := &deploymentObj.Spec.Template.Spec
+		podSpec.EphemeralContainers = []core_v1.EphemeralContainer{{EphemeralContainerCommon: ephemeralContainer}}
+		podSpec.Volumes = append(podSpec.Volumes, core_v1.Volume{
+			Name: "debug-config",
+			VolumeSource: core_v1.VolumeSource{
+				ConfigMap: &core_v1.ConfigMapVolumeSource{LocalObjectReference: core_v1.LocalObjectReference{Name: name}},
+			},
+		})
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(ephemeralNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, ephemeralNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = ephemeralNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	for deploymentName := range ephemeralContainers {
+		if !isDeploymentUpdated(t, clients, config, deploymentName) {
+			t.Errorf("Deployment %s referencing the configmap from an ephemeral container was not updated", deploymentName)
+		}
+	}
+}
+
+func TestRollingUpgradeReportsConflictingAnnotations(t *testing.T) {
+	name := "testconfigmap-conflicts-" + testutil.RandSeq(5)
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		podAnnotations map[string]string
+		conflict       string
+	}{
+		{
+			name:        "auto-and-explicit",
+			annotations: map[string]string{options.ReloaderAutoAnnotation: "true", options.ConfigmapUpdateOnChangeAnnotation: name},
+			conflict:    metrics.ConflictAutoAndExplicit,
+		},
+		{
+			name:        "auto-and-search",
+			annotations: map[string]string{options.ReloaderAutoAnnotation: "true", options.AutoSearchAnnotation: "true"},
+			conflict:    metrics.ConflictAutoAndSearch,
+		},
+		{
+			name:           "metadata-and-pod-template",
+			annotations:    map[string]string{options.ConfigmapUpdateOnChangeAnnotation: name},
+			podAnnotations: map[string]string{options.ReloaderAutoAnnotation: "false"},
+			conflict:       metrics.ConflictMetadataAndPodTemplate,
+		},
+		{
+			name:        "no-conflict",
+			annotations: map[string]string{options.ReloaderAutoAnnotation: "true"},
+		},
+	}
+
+	for _, test := range tests {
+		conflictNamespace := "test-handler-conflicts-" + testutil.RandSeq(5)
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(conflictNamespace, name)
+		deploymentObj.Name = name + "-" + test.name
+		deploymentObj.Annotations = test.annotations
+		deploymentObj.Spec.Template.Annotations = test.podAnnotations
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(conflictNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+
+		shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, conflictNamespace, name, "www.stakater.com/"+test.name)
+		config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+		config.Namespace = conflictNamespace
+		collectors := getCollectors()
+		if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+			t.Errorf("%s: rolling upgrade failed for Deployment: %v", test.name, err)
+		}
+
+		if !isDeploymentUpdated(t, clients, config, deploymentObj.Name) {
+			t.Errorf("%s: deployment was not updated", test.name)
+		}
+		if test.conflict == "" {
+			if promtestutil.CollectAndCount(collectors.AnnotationConflicts) != 0 {
+				t.Errorf("%s: unexpected annotation conflict", test.name)
+			}
+		} else if promtestutil.ToFloat64(collectors.AnnotationConflicts.With(prometheus.Labels{"conflict": test.conflict})) != 1 {
+			t.Errorf("%s: annotation conflict was not counted", test.name)
+		}
+	}
+}
+
+func TestRollingUpgradeRecordsReloadEvents(t *testing.T) {
+	emitSourceEvents := options.EmitSourceEvents
+	options.EmitSourceEvents = true
+	defer func() { options.EmitSourceEvents = emitSourceEvents }()
+
+	eventsNamespace := "test-handler-events-" + testutil.RandSeq(5)
+	name := "testconfigmap-events-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, eventsNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	eventClients := clients
+	eventClients.EventRecorder = recorder
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, eventsNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = eventsNamespace
+	if err := PerformRollingUpgrade(eventClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	expected := []string{
+		fmt.Sprintf("Normal Reloaded Reloaded after change of ConfigMap '%s'", name),
+		fmt.Sprintf("Normal Reloaded Reloaded Deployment '%s' after the change", name),
+	}
+	for _, want := range expected {
+		select {
+		case got := <-recorder.Events:
+			if got != want {
+				t.Errorf("Expected event %q, got %q", want, got)
+			}
+		default:
+			t.Errorf("Event %q was not recorded", want)
+		}
+	}
+}
+
+func TestRollingUpgradeRecordsReloadEventsOnOwnerOfSource(t *testing.T) {
+	emitSourceEvents := options.EmitSourceEvents
+	options.EmitSourceEvents = true
+	defer func() { options.EmitSourceEvents = emitSourceEvents }()
+
+	ownerNamespace := "test-handler-owner-events-" + testutil.RandSeq(5)
+	name := "testconfigmap-owner-events-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, ownerNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	eventClients := clients
+	eventClients.EventRecorder = recorder
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, ownerNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = ownerNamespace
+	config.Owner = &v1.OwnerReference{APIVersion: "bitnami.com/v1alpha1", Kind: "SealedSecret", Name: name}
+	if err := PerformRollingUpgrade(eventClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	expected := []string{
+		fmt.Sprintf("Normal Reloaded Reloaded after change of ConfigMap '%s' (of SealedSecret '%s')", name, name),
+		fmt.Sprintf("Normal Reloaded Reloaded Deployment '%s' after the change", name),
+		fmt.Sprintf("Normal Reloaded Reloaded Deployment '%s' after the change of its ConfigMap '%s'", name, name),
+	}
+	for _, want := range expected {
+		select {
+		case got := <-recorder.Events:
+			if got != want {
+				t.Errorf("Expected event %q, got %q", want, got)
+			}
+		default:
+			t.Errorf("Event %q was not recorded", want)
+		}
+	}
+}
+
+func TestRollingUpgradeWithAnnotationsStrategy(t *testing.T) {
+	annotationsNamespace := "test-handler-annotations-strategy-" + testutil.RandSeq(5)
+	name := "testconfigmap-annotations-strategy-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, annotationsNamespace, name, name, map[string]string{options.ReloadStrategyAnnotation: callbacks.AnnotationsStrategy}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if err := createDeploymentReferencingConfigmap(clients, annotationsNamespace, name+"-rolling", name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, annotationsNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = annotationsNamespace
+	collectors := getCollectors()
+	// the same change is processed twice, e.g. by the startup reconciliation, and reloads only once
+	for i := 0; i < 2; i++ {
+		if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+		}
+	}
+	if reloads := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloads != 2 {
+		t.Errorf("Expected each deployment to be reloaded once, got %v reloads", reloads)
+	}
+
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(annotationsNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment %s: %v", name, err)
+	}
+	expected := fmt.Sprintf(`{"configmap/%s":"%s"}`, name, shaData)
+	if got := deployment.Spec.Template.Annotations[options.LastReloadedFromAnnotation]; got != expected {
+		t.Errorf("Expected pod template annotation %s, got %s", expected, got)
+	}
+	if isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment with the annotations strategy got an env var")
+	}
+	if !isDeploymentUpdated(t, clients, config, name+"-rolling") {
+		t.Errorf("Deployment with the rolling strategy was not updated")
+	}
+}
+
+func TestRollingUpgradeWithNamespaceDefaultStrategy(t *testing.T) {
+	strategyNamespace := "test-handler-namespace-strategy-" + testutil.RandSeq(5)
+	name := "testconfigmap-namespace-strategy-" + testutil.RandSeq(5)
+	if _, err := clients.KubernetesClient.CoreV1().Namespaces().Create(&core_v1.Namespace{ObjectMeta: v1.ObjectMeta{
+		Name:        strategyNamespace,
+		Annotations: map[string]string{options.NamespaceDefaultStrategyAnnotation: callbacks.AnnotationsStrategy},
+	}}); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	if err := createDeploymentReferencingConfigmap(clients, strategyNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	// the reload-strategy annotation of the workload wins over the default strategy of its namespace
+	if err := createDeploymentReferencingConfigmap(clients, strategyNamespace, name+"-rolling", name, map[string]string{options.ReloadStrategyAnnotation: callbacks.RollingStrategy}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, strategyNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = strategyNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(strategyNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment %s: %v", name, err)
+	}
+	if _, found := deployment.Spec.Template.Annotations[options.LastReloadedFromAnnotation]; !found || isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment without a reload-strategy annotation was not reloaded with the default strategy of its namespace")
+	}
+	if !isDeploymentUpdated(t, clients, config, name+"-rolling") {
+		t.Errorf("Deployment with the rolling strategy was not updated")
+	}
+}
+
+func TestReloadStrategyNamePrecedence(t *testing.T) {
+	strategyClients := kube.Clients{KubernetesClient: testclient.NewSimpleClientset(
+		&core_v1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "payments", Annotations: map[string]string{options.NamespaceDefaultStrategyAnnotation: callbacks.PodDeleteStrategy}}},
+		&core_v1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "default"}},
+	)}
+	workloadStrategy := map[string]string{options.ReloadStrategyAnnotation: callbacks.AnnotationsStrategy}
+	for _, test := range []struct {
+		namespace   string
+		annotations map[string]string
+		expected    string
+	}{
+		{"payments", workloadStrategy, callbacks.AnnotationsStrategy},
+		{"payments", nil, callbacks.PodDeleteStrategy},
+		{"default", nil, options.ReloadStrategy},
+		{"missing", nil, options.ReloadStrategy},
+	} {
+		if name := reloadStrategyName(strategyClients, GetDeploymentRollingUpgradeFuncs(), test.namespace, test.annotations); name != test.expected {
+			t.Errorf("Expected strategy '%s' in namespace '%s' with annotations %v, got '%s'", test.expected, test.namespace, test.annotations, name)
+		}
+	}
+}
+
+// recordingNotifier passes the notifications to its channel
+type recordingNotifier chan notifier.Notification
+
+func (n recordingNotifier) Notify(notification notifier.Notification) {
+	n <- notification
+}
+
+func TestRollingUpgradeNotifiesReloads(t *testing.T) {
+	notifications := make(recordingNotifier, 1)
+	Notifier = notifications
+	defer func() { Notifier = nil }()
+
+	notifyNamespace := "test-handler-notify-" + testutil.RandSeq(5)
+	name := "testconfigmap-notify-" + testutil.RandSeq(5)
+	if err := createDeploymentReferencingConfigmap(clients, notifyNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, notifyNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = notifyNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+
+	select {
+	case notification := <-notifications:
+		if notification.Namespace != notifyNamespace || notification.Kind != "Deployment" || notification.Name != name ||
+			notification.SourceType != constants.ConfigmapEnvVarPostfix || notification.SourceName != name || notification.Result != notifier.Reloaded {
+			t.Errorf("Unexpected notification %+v", notification)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("The reload was not notified")
+	}
+}
+
+func TestRollingUpgradeWithInstantiateStrategy(t *testing.T) {
+	openshiftClient := fakeopenshift.NewSimpleClientset()
+	// the object tracker does not know the instantiate subresource
+	openshiftClient.PrependReactor("create", "deploymentconfigs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "instantiate" {
+			return false, nil, nil
+		}
+		return true, &openshiftv1.DeploymentConfig{}, nil
+	})
+	openshiftClients := kube.Clients{KubernetesClient: clients.KubernetesClient, OpenshiftAppsClient: openshiftClient}
+	instantiateNamespace := "test-handler-instantiate-" + testutil.RandSeq(5)
+	name := "testconfigmap-instantiate-" + testutil.RandSeq(5)
+	deploymentConfig := testutil.GetDeploymentConfig(instantiateNamespace, name)
+	deploymentConfig.Annotations[options.ReloadStrategyAnnotation] = callbacks.InstantiateStrategy
+	if _, err := openshiftClient.AppsV1().DeploymentConfigs(instantiateNamespace).Create(deploymentConfig); err != nil {
+		t.Fatalf("Failed to create deploymentConfig: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, instantiateNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = instantiateNamespace
+	if err := PerformRollingUpgrade(openshiftClients, config, GetDeploymentConfigRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for DeploymentConfig: %v", err)
+	}
+
+	instantiated := false
+	for _, action := range openshiftClient.Actions() {
+		if action.GetSubresource() == "instantiate" {
+			request := action.(k8stesting.CreateAction).GetObject().(*openshiftv1.DeploymentRequest)
+			instantiated = request.Name == name && request.Latest && request.Force
+		}
+		if action.GetVerb() == "update" {
+			t.Errorf("DeploymentConfig with the instantiate strategy was updated")
+		}
+	}
+	if !instantiated {
+		t.Errorf("Latest deployment of the DeploymentConfig was not requested, got actions %v", openshiftClient.Actions())
+	}
+}
+
+func TestRollingUpgradeForCronJob(t *testing.T) {
+	cronJobNamespace := "test-handler-cronjob-" + testutil.RandSeq(5)
+	name := "testconfigmap-cronjob-" + testutil.RandSeq(5)
+	cronJob := testutil.GetCronJobWithEnvVars(cronJobNamespace, name)
+	if _, err := clients.KubernetesClient.BatchV1beta1().CronJobs(cronJobNamespace).Create(cronJob); err != nil {
+		t.Fatalf("Failed to create cronJob: %v", err)
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, cronJobNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = cronJobNamespace
+	collectors := getCollectors()
+	if err := PerformRollingUpgrade(clients, config, GetCronJobRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for CronJob: %v", err)
+	}
+
+	updated, err := clients.KubernetesClient.BatchV1beta1().CronJobs(cronJobNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get cronJob: %v", err)
+	}
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	if testutil.GetResourceSHA(updated.Spec.JobTemplate.Spec.Template.Spec.Containers, envName) != config.SHAValue {
+		t.Errorf("Job template of the CronJob was not updated")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Counter was not increased")
+	}
+}
+
+func TestRollingUpgradeWithPodDeleteStrategy(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	podDeleteClients := kube.Clients{KubernetesClient: kubernetesClient}
+	podDeleteNamespace := "test-handler-pod-delete-" + testutil.RandSeq(5)
+	name := "testconfigmap-pod-delete-" + testutil.RandSeq(5)
+	annotations := map[string]string{options.ReloadStrategyAnnotation: callbacks.PodDeleteStrategy}
+	if err := createDeploymentReferencingConfigmap(podDeleteClients, podDeleteNamespace, name, name, annotations); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	deployment, err := kubernetesClient.AppsV1().Deployments(podDeleteNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	deployment.UID = "deployment-uid"
+	if _, err := kubernetesClient.AppsV1().Deployments(podDeleteNamespace).Update(deployment); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	controller := true
+	replicaSets := []*appsv1.ReplicaSet{
+		{ObjectMeta: v1.ObjectMeta{Name: name + "-abc", UID: "uid", OwnerReferences: []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: name, UID: deployment.UID, Controller: &controller}}}},
+		{ObjectMeta: v1.ObjectMeta{Name: name + "-foreign", UID: "foreign-uid"}},
+	}
+	for _, replicaSet := range replicaSets {
+		if _, err := kubernetesClient.AppsV1().ReplicaSets(podDeleteNamespace).Create(replicaSet); err != nil {
+			t.Fatalf("Failed to create replicaSet: %v", err)
+		}
+	}
+	owner := []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name + "-abc", UID: "uid", Controller: &controller}}
+	foreignOwner := []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name + "-foreign", UID: "foreign-uid", Controller: &controller}}
+	pods := map[string]*core_v1.Pod{
+		"controlled": {ObjectMeta: v1.ObjectMeta{Name: name + "-controlled", Labels: deployment.Spec.Template.Labels, OwnerReferences: owner}},
+		"bare":       {ObjectMeta: v1.ObjectMeta{Name: name + "-bare", Labels: deployment.Spec.Template.Labels}},
+		"foreign":    {ObjectMeta: v1.ObjectMeta{Name: name + "-foreign", Labels: deployment.Spec.Template.Labels, OwnerReferences: foreignOwner}},
+		"other":      {ObjectMeta: v1.ObjectMeta{Name: name + "-other", Labels: map[string]string{"app": "other"}, OwnerReferences: owner}},
+	}
+	for _, pod := range pods {
+		if _, err := kubernetesClient.CoreV1().Pods(podDeleteNamespace).Create(pod); err != nil {
+			t.Fatalf("Failed to create pod: %v", err)
+		}
+	}
+	var evicted []string
+	blocked := false
+	kubernetesClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		if blocked {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		}
+		evicted = append(evicted, action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+		return true, nil, nil
+	})
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, podDeleteNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = podDeleteNamespace
+	if err := PerformRollingUpgrade(podDeleteClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != pods["controlled"].Name {
+		t.Errorf("Expected only the pod controlled by the deployment to be evicted, got %v", evicted)
+	}
+	if isDeploymentUpdated(t, podDeleteClients, config, name) {
+		t.Errorf("Deployment with the pod-delete strategy was updated")
+	}
+
+	blocked = true
+	config.SHAValue = testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, podDeleteNamespace, name, "www.stakater.com/blocked")
+	collectors := getCollectors()
+	err = PerformRollingUpgrade(podDeleteClients, config, GetDeploymentRollingUpgradeFuncs(), collectors)
+	if err == nil {
+		t.Errorf("Expected the reload to fail while the disruption budget blocks evictions")
+	}
+	if !retryable(err) {
+		t.Errorf("Expected the reload blocked by the disruption budget to be requeued, got %v", err)
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelFailed)) != 1 {
+		t.Errorf("Failure counter was not increased")
+	}
+}
+
+func TestRollingUpgradeDeferredByDisruptionBudget(t *testing.T) {
+	budgetNamespace := "test-handler-budget-" + testutil.RandSeq(5)
+	name := "testconfigmap-budget-" + testutil.RandSeq(5)
+	recorder := record.NewFakeRecorder(10)
+	budgetClients := kube.Clients{KubernetesClient: clients.KubernetesClient, EventRecorder: recorder}
+	if err := createDeploymentReferencingConfigmap(budgetClients, budgetNamespace, name, name, nil); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(budgetNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	budget := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: budgetNamespace},
+		Spec:       policyv1beta1.PodDisruptionBudgetSpec{Selector: &v1.LabelSelector{MatchLabels: deployment.Spec.Template.Labels}},
+		Status:     policyv1beta1.PodDisruptionBudgetStatus{PodDisruptionsAllowed: 0},
+	}
+	if budget, err = clients.KubernetesClient.PolicyV1beta1().PodDisruptionBudgets(budgetNamespace).Create(budget); err != nil {
+		t.Fatalf("Failed to create pod disruption budget: %v", err)
+	}
+
+	deferReloadOnDisruptionBudget := options.DeferReloadOnDisruptionBudget
+	options.DeferReloadOnDisruptionBudget = true
+	defer func() { options.DeferReloadOnDisruptionBudget = deferReloadOnDisruptionBudget }()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, budgetNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = budgetNamespace
+	if err := PerformRollingUpgrade(budgetClients, config, deploymentFuncs, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name)
+	if _, found := deferred.get(key); !found || isDeploymentUpdated(t, clients, config, name) {
+		t.Fatalf("Reload was not deferred by the disruption budget")
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, EventReasonReloadDeferred) || !strings.Contains(event, "pod disruption budget '"+name+"'") {
+			t.Errorf("Unexpected event %q", event)
+		}
+	default:
+		t.Errorf("Deferral was not recorded as event")
+	}
+
+	// the retry interval doubles with each retry
+	if delay := deferred.backoff(config, deploymentFuncs, deployment); delay != 2*options.DeferredReloadRetryInterval {
+		t.Errorf("Expected the first retry to back off to %s, got %s", 2*options.DeferredReloadRetryInterval, delay)
+	}
+	deferred.process(key)
+	if delay := deferred.backoff(config, deploymentFuncs, deployment); delay != 4*options.DeferredReloadRetryInterval {
+		t.Errorf("Expected the second retry to back off to %s, got %s", 4*options.DeferredReloadRetryInterval, delay)
+	}
+
+	budget.Status.PodDisruptionsAllowed = 1
+	if _, err := clients.KubernetesClient.PolicyV1beta1().PodDisruptionBudgets(budgetNamespace).UpdateStatus(budget); err != nil {
+		t.Fatalf("Failed to update pod disruption budget status: %v", err)
+	}
+	deferred.process(key)
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not updated once its disruption budget allowed it")
+	}
+	if _, found := deferred.get(key); found {
+		t.Errorf("Reload is still deferred after it was applied")
+	}
+}
+
+func TestRollingUpgradeMonitorsRollouts(t *testing.T) {
+	rolloutNamespace := "test-handler-monitor-" + testutil.RandSeq(5)
+	name := "testconfigmap-monitor-" + testutil.RandSeq(5)
+	recorder := record.NewFakeRecorder(10)
+	monitorClients := kube.Clients{KubernetesClient: clients.KubernetesClient, EventRecorder: recorder}
+	for _, deploymentName := range []string{name + "-completed", name + "-deadline", name + "-timeout"} {
+		if err := createDeploymentReferencingConfigmap(monitorClients, rolloutNamespace, deploymentName, name, nil); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.March, 7, 12, 0, 0, 0, time.UTC))
+	deferredClock := deferred.clock
+	deferred.clock = fakeClock
+	monitorRollouts, rolloutTimeouts := options.MonitorRollouts, options.RolloutTimeouts
+	options.MonitorRollouts = true
+	options.RolloutTimeouts = []string{"Deployment=2m"}
+	defer func() {
+		deferred.clock = deferredClock
+		options.MonitorRollouts, options.RolloutTimeouts = monitorRollouts, rolloutTimeouts
+	}()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, rolloutNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = rolloutNamespace
+	if err := PerformRollingUpgrade(monitorClients, config, deploymentFuncs, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+
+	// the rollouts are still in progress
+	for _, suffix := range []string{"-completed", "-deadline", "-timeout"} {
+		rollouts.check(workloadKey(deploymentFuncs, rolloutNamespace, name+suffix))
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Rollouts in progress were reported: %v", <-recorder.Events)
+	}
+
+	statuses := map[string]appsv1.DeploymentStatus{
+		name + "-completed": {Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+		name + "-deadline": {Conditions: []appsv1.DeploymentCondition{{
+			Type: appsv1.DeploymentProgressing, Reason: callbacks.ProgressDeadlineExceededReason, Message: "ReplicaSet has timed out progressing.",
+		}}},
+	}
+	for deploymentName, status := range statuses {
+		deployment, err := clients.KubernetesClient.AppsV1().Deployments(rolloutNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment: %v", err)
+		}
+		deployment.Status = status
+		if _, err = clients.KubernetesClient.AppsV1().Deployments(rolloutNamespace).UpdateStatus(deployment); err != nil {
+			t.Fatalf("Failed to update deployment status: %v", err)
+		}
+	}
+	fakeClock.Step(3 * time.Minute)
+	expected := map[string]string{
+		"-completed": EventReasonRolloutSucceeded,
+		"-deadline":  "ReplicaSet has timed out progressing.",
+		"-timeout":   "not completed within 2m0s",
+	}
+	for _, suffix := range []string{"-completed", "-deadline", "-timeout"} {
+		key := workloadKey(deploymentFuncs, rolloutNamespace, name+suffix)
+		rollouts.check(key)
+		select {
+		case event := <-recorder.Events:
+			if !strings.Contains(event, expected[suffix]) {
+				t.Errorf("Expected the event of the rollout of %s to contain %q, got %q", name+suffix, expected[suffix], event)
+			}
+		default:
+			t.Errorf("Rollout of %s was not reported", name+suffix)
+		}
+		if _, found := rollouts.pending[key]; found {
+			t.Errorf("Rollout of %s is still monitored after it was reported", name+suffix)
+		}
+	}
+
+	succeeded := prometheus.Labels{"namespace": rolloutNamespace, "kind": "Deployment", "result": metrics.RolloutSucceeded}
+	failed := prometheus.Labels{"namespace": rolloutNamespace, "kind": "Deployment", "result": metrics.RolloutFailed}
+	if promtestutil.ToFloat64(collectors.Rollouts.With(succeeded)) != 1 || promtestutil.ToFloat64(collectors.Rollouts.With(failed)) != 2 {
+		t.Errorf("Expected 1 succeeded and 2 failed rollouts to be counted")
+	}
+}
+
+func TestRollingUpgradeRolledBackAfterFailedRollout(t *testing.T) {
+	rollbackNamespace := "test-handler-rollback-" + testutil.RandSeq(5)
+	name := "testconfigmap-rollback-" + testutil.RandSeq(5)
+	recorder := record.NewFakeRecorder(10)
+	rollbackClients := kube.Clients{KubernetesClient: clients.KubernetesClient, EventRecorder: recorder}
+	annotations := map[string]string{options.AutoRollbackAnnotation: "true"}
+	if err := createDeploymentReferencingConfigmap(rollbackClients, rollbackNamespace, name, name, annotations); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, rollbackNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = rollbackNamespace
+	if err := PerformRollingUpgrade(rollbackClients, config, deploymentFuncs, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Fatalf("Deployment was not updated")
+	}
+
+	// the rollout fails, while the template was changed by others too
+	deployment, err := clients.KubernetesClient.AppsV1().Deployments(rollbackNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	deployment.Spec.Template.Labels["version"] = "2"
+	deployment.Status.Conditions = []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Reason: callbacks.ProgressDeadlineExceededReason}}
+	if _, err = clients.KubernetesClient.AppsV1().Deployments(rollbackNamespace).Update(deployment); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	rollouts.check(workloadKey(deploymentFuncs, rollbackNamespace, name))
+
+	if isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Reload was not rolled back after its rollout failed")
+	}
+	deployment, err = clients.KubernetesClient.AppsV1().Deployments(rollbackNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if deployment.Spec.Template.Labels["version"] != "2" {
+		t.Errorf("Rollback reverted changes of the template not made by Reloader")
+	}
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	if len(events) != 2 || !strings.Contains(events[0], EventReasonRolloutFailed) || !strings.Contains(events[1], EventReasonRolledBack) {
+		t.Errorf("Expected the failed rollout and the rollback to be recorded, got %v", events)
+	}
+}
+
+func TestRollingUpgradeForCrossNamespaceReferences(t *testing.T) {
+	crossClients := kube.Clients{KubernetesClient: testclient.NewSimpleClientset()}
+	sourceNamespace := "test-handler-shared-" + testutil.RandSeq(5)
+	workloadNamespace := "test-handler-cross-" + testutil.RandSeq(5)
+	name := "testconfigmap-cross-" + testutil.RandSeq(5)
+	deployments := map[string]map[string]string{
+		name + "-referencing": {options.ConfigmapUpdateOnChangeAnnotation: sourceNamespace + "/" + name},
+		name + "-by-name":     {options.ConfigmapUpdateOnChangeAnnotation: name},
+		name + "-auto":        {},
+	}
+	for deploymentName, annotations := range deployments {
+		if err := createDeploymentReferencingConfigmap(crossClients, workloadNamespace, deploymentName, name, annotations); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, sourceNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = sourceNamespace
+	if err := crossNamespaceUpgrade(crossClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployments in other namespaces: %v", err)
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(sourceNamespace+"/"+name) + "_" + config.Type
+	for deploymentName := range deployments {
+		deployment, err := crossClients.KubernetesClient.AppsV1().Deployments(workloadNamespace).Get(deploymentName, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get deployment %s: %v", deploymentName, err)
+		}
+		updated := testutil.GetResourceSHA(deployment.Spec.Template.Spec.Containers, envName) == config.SHAValue
+		if expected := deploymentName == name+"-referencing"; updated != expected {
+			t.Errorf("Expected deployment %s to be updated %t, got %t", deploymentName, expected, updated)
+		}
+	}
+}
+
+func TestReferencedSourceNamespaces(t *testing.T) {
+	watchedNamespaces := WatchedNamespaces
+	defer func() { WatchedNamespaces = watchedNamespaces }()
+	crossClients := kube.Clients{KubernetesClient: testclient.NewSimpleClientset()}
+	workloadNamespace := "test-handler-referenced-" + testutil.RandSeq(5)
+	WatchedNamespaces = []string{workloadNamespace}
+	deployments := map[string]map[string]string{
+		"shared-configmap": {options.ConfigmapUpdateOnChangeAnnotation: "shared/app-config," + workloadNamespace + "/local-config"},
+		"vault-secret":     {options.SecretUpdateOnChangeAnnotation: "vault/credentials"},
+		"pattern":          {options.ConfigmapUpdateOnChangeAnnotation: "team-*/app-config"},
+		"same-namespace":   {options.ConfigmapUpdateOnChangeAnnotation: "local-config"},
+	}
+	for deploymentName, annotations := range deployments {
+		if err := createDeploymentReferencingConfigmap(crossClients, workloadNamespace, deploymentName, "local-config", annotations); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	namespaces := ReferencedSourceNamespaces(crossClients)
+	if expected := []string{"shared", "vault"}; !reflect.DeepEqual(namespaces, expected) {
+		t.Errorf("Expected referenced source namespaces %v, got %v", expected, namespaces)
+	}
+}
+
+func TestRollingUpgradeOnlyForChangesOfListedKeys(t *testing.T) {
+	keysNamespace := "test-handler-keys-" + testutil.RandSeq(5)
+	name := "testconfigmap-keys-" + testutil.RandSeq(5)
+	annotations := map[string]string{options.ConfigmapKeysAnnotation: "app.yaml, feature-flags.json"}
+	if err := createDeploymentReferencingConfigmap(clients, keysNamespace, name, name, annotations); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	configmap := testutil.GetConfigmap(keysNamespace, name, "www.google.com")
+	configmap.Data = map[string]string{"app.yaml": "replicas: 2", "leader": "pod-a"}
+	collectors := getCollectors()
+	reload := func(data map[string]string) {
+		configmap.Data = data
+		config := util.GetConfigmapConfig(configmap)
+		if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+			t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+		}
+	}
+
+	reload(map[string]string{"app.yaml": "replicas: 2", "leader": "pod-a"})
+	reload(map[string]string{"app.yaml": "replicas: 2", "leader": "pod-b"})
+	if reloaded := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloaded != 1 {
+		t.Errorf("Expected a change of a key that is not listed to not reload the deployment, got %v reloads", reloaded)
+	}
+	reload(map[string]string{"app.yaml": "replicas: 3", "leader": "pod-b"})
+	if reloaded := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloaded != 2 {
+		t.Errorf("Expected a change of a listed key to reload the deployment, got %v reloads", reloaded)
+	}
+
+	config := util.GetConfigmapConfig(configmap)
+	config.SHAValue = util.GetSHAofKeys(config, []string{"app.yaml", "feature-flags.json"})
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not updated with the SHA of the listed keys")
+	}
+}
+
+func TestRollingUpgradeDetectsSecretReferencesOutsideOfContainers(t *testing.T) {
+	referencesNamespace := "test-handler-references-" + testutil.RandSeq(5)
+	name := "testsecret-references-" + testutil.RandSeq(5)
+	templates := map[string]func(spec *core_v1.PodSpec){
+		"references-image-pull-secret": func(spec *core_v1.PodSpec) {
+			spec.ImagePullSecrets = []core_v1.LocalObjectReference{{Name: name}}
+		},
+		"references-csi-volume": func(spec *core_v1.PodSpec) {
+			spec.Volumes = []core_v1.Volume{{Name: "store", VolumeSource: core_v1.VolumeSource{CSI: &core_v1.CSIVolumeSource{
+				Driver:               "secrets-store.csi.k8s.io",
+				NodePublishSecretRef: &core_v1.LocalObjectReference{Name: name},
+			}}}}
+			spec.Containers[0].VolumeMounts = []core_v1.VolumeMount{{Name: "store", MountPath: "/mnt/store"}}
+		},
+		"references-second-volume": func(spec *core_v1.PodSpec) {
+			spec.Volumes = []core_v1.Volume{
+				{Name: "unmounted", VolumeSource: core_v1.VolumeSource{Secret: &core_v1.SecretVolumeSource{SecretName: name}}},
+				{Name: "mounted", VolumeSource: core_v1.VolumeSource{Secret: &core_v1.SecretVolumeSource{SecretName: name}}},
+			}
+			spec.Containers[0].VolumeMounts = []core_v1.VolumeMount{{Name: "mounted", MountPath: "/etc/mounted"}}
+		},
+		"references-nothing": func(spec *core_v1.PodSpec) {},
+	}
+	for deploymentName, setReference := range templates {
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(referencesNamespace, deploymentName)
+		deploymentObj.Spec.Template.Spec.Containers[0].EnvFrom = nil
+		setReference(&deploymentObj.Spec.Template.Spec)
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(referencesNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.SecretResourceType, referencesNamespace, name, "dGVzdFVwZGF0ZWRTZWNyZXRFbmNvZGluZ0ZvclJlbG9hZGVy")
+	config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, name, shaData, options.SecretUpdateOnChangeAnnotation)
+	config.Namespace = referencesNamespace
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	for deploymentName := range templates {
+		if updated, expected := isDeploymentUpdated(t, clients, config, deploymentName), deploymentName != "references-nothing"; updated != expected {
+			t.Errorf("Expected deployment %s to be updated %t, got %t", deploymentName, expected, updated)
+		}
+	}
+}
+
+func TestRollingUpgradeOnChangeOfSecretProviderClass(t *testing.T) {
+	classNamespace := "test-handler-provider-class-" + testutil.RandSeq(5)
+	name := "testclass-" + testutil.RandSeq(5)
+	annotations := map[string]map[string]string{
+		"mounts-class-auto":      {options.ReloaderAutoAnnotation: "true"},
+		"mounts-class-annotated": {options.SecretProviderClassUpdateOnChangeAnnotation: name},
+		"mounts-other-class":     {options.ReloaderAutoAnnotation: "true"},
+	}
+	for deploymentName, deploymentAnnotations := range annotations {
+		class := name
+		if deploymentName == "mounts-other-class" {
+			class = name + "-other"
+		}
+		deploymentObj := testutil.GetDeploymentWithEnvVarSources(classNamespace, deploymentName)
+		deploymentObj.Annotations = deploymentAnnotations
+		spec := &deploymentObj.Spec.Template.Spec
+		spec.Containers[0].EnvFrom = nil
+		spec.Volumes = []core_v1.Volume{{Name: "store", VolumeSource: core_v1.VolumeSource{CSI: &core_v1.CSIVolumeSource{
+			Driver:           constants.SecretsStoreCSIDriver,
+			VolumeAttributes: map[string]string{constants.SecretProviderClassVolumeAttribute: class},
+		}}}}
+		spec.Containers[0].VolumeMounts = []core_v1.VolumeMount{{Name: "store", MountPath: "/mnt/store"}}
+		if _, err := clients.KubernetesClient.AppsV1().Deployments(classNamespace).Create(deploymentObj); err != nil {
+			t.Fatalf("Failed to create deployment %s: %v", deploymentName, err)
+		}
+	}
+
+	class := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "secrets-store.csi.x-k8s.io/v1",
+		"kind":       "SecretProviderClass",
+		"metadata":   map[string]interface{}{"name": name, "namespace": classNamespace},
+		"spec":       map[string]interface{}{"provider": "vault", "parameters": map[string]interface{}{"roleName": "app"}},
+	}}
+	config := util.GetSecretProviderClassConfig(class)
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	for deploymentName := range annotations {
+		if updated, expected := isDeploymentUpdated(t, clients, config, deploymentName), deploymentName != "mounts-other-class"; updated != expected {
+			t.Errorf("Expected deployment %s to be updated %t, got %t", deploymentName, expected, updated)
+		}
+	}
+}
+
+func TestRollingUpgradeKeepsLegacySHAOfUnchangedConfigmap(t *testing.T) {
+	legacyNamespace := "test-handler-legacy-" + testutil.RandSeq(5)
+	name := "testconfigmap-legacy-" + testutil.RandSeq(5)
+	configmap := testutil.GetConfigmap(legacyNamespace, name, "www.google.com")
+	config := util.GetConfigmapConfig(configmap)
+	if config.LegacySHAValue == "" || config.LegacySHAValue == config.SHAValue {
+		t.Fatalf("Expected a legacy SHA different from the SHA of the configmap")
+	}
+
+	deploymentObj := testutil.GetDeploymentWithEnvVarSources(legacyNamespace, name)
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(name) + "_" + constants.ConfigmapEnvVarPostfix
+	deploymentObj.Spec.Template.Spec.Containers[0].Env = []core_v1.EnvVar{{Name: envName, Value: config.LegacySHAValue}}
+	if _, err := clients.KubernetesClient.AppsV1().Deployments(legacyNamespace).Create(deploymentObj); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	collectors := getCollectors()
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if reloaded := promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)); reloaded != 0 {
+		t.Errorf("Deployment holding the legacy SHA of the unchanged configmap was reloaded")
+	}
+
+	config = util.GetConfigmapConfig(testutil.GetConfigmap(legacyNamespace, name, "www.stakater.com"))
+	if err := PerformRollingUpgrade(clients, config, GetDeploymentRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment holding a legacy SHA was not reloaded after a change of the configmap")
+	}
+}
+
+func TestRollingUpgradeDeferredUntilCertificateValid(t *testing.T) {
+	certificateNamespace := "test-handler-certificate-" + testutil.RandSeq(5)
+	name := "testsecret-certificate-" + testutil.RandSeq(5)
+	annotations := map[string]string{options.SecretUpdateOnChangeAnnotation: name}
+	if err := createDeploymentReferencingConfigmap(clients, certificateNamespace, name, name, annotations); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	deferReloadUntilCertificateValid := options.DeferReloadUntilCertificateValid
+	options.DeferReloadUntilCertificateValid = true
+	fakeClock := clock.NewFakeClock(time.Date(2020, time.March, 7, 12, 0, 0, 0, time.UTC))
+	deferredClock := deferred.clock
+	deferred.clock = fakeClock
+	defer func() {
+		options.DeferReloadUntilCertificateValid = deferReloadUntilCertificateValid
+		deferred.clock = deferredClock
+	}()
+
+	collectors := getCollectors()
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	shaData := testutil.ConvertResourceToSHA(testutil.SecretResourceType, certificateNamespace, name, "dGVzdFVwZGF0ZWRTZWNyZXRFbmNvZGluZ0ZvclJlbG9hZGVy")
+	config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, name, shaData, options.SecretUpdateOnChangeAnnotation)
+	config.Namespace = certificateNamespace
+	config.NotBefore = fakeClock.Now().Add(time.Hour)
+	if err := PerformRollingUpgrade(clients, config, deploymentFuncs, collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for Deployment: %v", err)
+	}
+	key := deferredReloadKey(config, deploymentFuncs, name)
+	if _, found := deferred.get(key); !found || isDeploymentUpdated(t, clients, config, name) {
+		t.Fatalf("Reload was not deferred until the certificate becomes valid")
+	}
+
+	fakeClock.Step(time.Hour)
+	deferred.process(key)
+	if !isDeploymentUpdated(t, clients, config, name) {
+		t.Errorf("Deployment was not updated once the certificate became valid")
+	}
+}
+
+func TestRollingUpgradeForCustomWorkload(t *testing.T) {
+	customNamespace := "test-handler-custom-" + testutil.RandSeq(5)
+	name := "testconfigmap-custom-" + testutil.RandSeq(5)
+	kind, err := callbacks.ParseCustomWorkloadKind("flinkdeployments.v1beta1.flink.apache.org={.spec.podTemplate}")
+	if err != nil {
+		t.Fatalf("Failed to parse custom workload: %v", err)
+	}
+	kind.Kind = "FlinkDeployment"
+	flinkDeployment := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "flink.apache.org/v1beta1",
+		"kind":       "FlinkDeployment",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   customNamespace,
+			"annotations": map[string]interface{}{options.ConfigmapUpdateOnChangeAnnotation: name},
+		},
+		"spec": map[string]interface{}{
+			"flinkVersion": "v1_17",
+			"podTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "flink-main-container"},
+					},
+				},
+			},
+		},
+	}}
+	customClients := kube.Clients{KubernetesClient: clients.KubernetesClient, DynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), flinkDeployment)}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, customNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = customNamespace
+	collectors := getCollectors()
+	if err := PerformRollingUpgrade(customClients, config, GetCustomWorkloadRollingUpgradeFuncs(kind), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for custom workload: %v", err)
+	}
+
+	updated, err := customClients.DynamicClient.Resource(kind.Resource).Namespace(customNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get custom workload: %v", err)
+	}
+	containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "podTemplate", "spec", "containers")
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	var env []interface{}
+	if len(containers) == 1 {
+		env, _, _ = unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+	}
+	if len(env) != 1 || env[0].(map[string]interface{})["name"] != envName || env[0].(map[string]interface{})["value"] != config.SHAValue {
+		t.Errorf("Pod template of the custom workload was not updated, got containers %v", containers)
+	}
+	if version, _, _ := unstructured.NestedString(updated.Object, "spec", "flinkVersion"); version != "v1_17" {
+		t.Errorf("Fields of the custom workload outside the pod template were not kept")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Counter was not increased")
+	}
+}
+
+func TestParseCustomWorkloadKind(t *testing.T) {
+	kind, err := callbacks.ParseCustomWorkloadKind("kafkas.v1beta2.kafka.strimzi.io=.spec.kafka.template.pod")
+	if err != nil {
+		t.Fatalf("Failed to parse custom workload: %v", err)
+	}
+	if kind.Resource.Resource != "kafkas" || kind.Resource.Version != "v1beta2" || kind.Resource.Group != "kafka.strimzi.io" || strings.Join(kind.TemplatePath, ".") != "spec.kafka.template.pod" {
+		t.Errorf("Custom workload was parsed as %v", kind)
+	}
+	kind, err = callbacks.ParseCustomWorkloadKind("kafkas.v1beta2.kafka.strimzi.io=.spec.kafka.template.pod;{.spec.kafka.configRefs}")
+	if err != nil {
+		t.Fatalf("Failed to parse custom workload with references: %v", err)
+	}
+	if strings.Join(kind.TemplatePath, ".") != "spec.kafka.template.pod" || strings.Join(kind.ReferencesPath, ".") != "spec.kafka.configRefs" {
+		t.Errorf("Custom workload with references was parsed as %v", kind)
+	}
+	for _, invalid := range []string{"kafkas.kafka.strimzi.io", "kafkas=.spec.template", "kafkas.v1beta2.kafka.strimzi.io=.spec..pod", "kafkas.v1beta2.kafka.strimzi.io=.spec.containers[0]", "kafkas.v1beta2.kafka.strimzi.io=.spec.template;.spec.refs[*]"} {
+		if _, err := callbacks.ParseCustomWorkloadKind(invalid); err == nil {
+			t.Errorf("Parsing invalid custom workload '%s' did not fail", invalid)
+		}
+	}
+}
+
+func TestAutoReloadDiscoversReferencesOfCustomWorkload(t *testing.T) {
+	customNamespace := "test-handler-custom-refs-" + testutil.RandSeq(5)
+	name := "testconfigmap-custom-refs-" + testutil.RandSeq(5)
+	kind, err := callbacks.ParseCustomWorkloadKind("flinkdeployments.v1beta1.flink.apache.org=.spec.podTemplate;.spec.configRefs")
+	if err != nil {
+		t.Fatalf("Failed to parse custom workload: %v", err)
+	}
+	newFlinkDeployment := func(name string, references []interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "flink.apache.org/v1beta1",
+			"kind":       "FlinkDeployment",
+			"metadata": map[string]interface{}{
+				"name":        name,
+				"namespace":   customNamespace,
+				"annotations": map[string]interface{}{options.ReloaderAutoAnnotation: "true"},
+			},
+			"spec": map[string]interface{}{
+				"configRefs": references,
+				"podTemplate": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "flink-main-container"},
+						},
+					},
+				},
+			},
+		}}
+	}
+	// the pod templates do not reference the configmap, only the references of the first one name it
+	referencing := newFlinkDeployment("referencing", []interface{}{"flink-logging", map[string]interface{}{"kind": "ConfigMap", "name": name}})
+	otherKind := newFlinkDeployment("other-kind", []interface{}{map[string]interface{}{"kind": "Secret", "name": name}})
+	customClients := kube.Clients{KubernetesClient: clients.KubernetesClient, DynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), referencing, otherKind)}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, customNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ReloaderAutoAnnotation)
+	config.Namespace = customNamespace
+	if err := PerformRollingUpgrade(customClients, config, GetCustomWorkloadRollingUpgradeFuncs(kind), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for custom workload: %v", err)
+	}
+
+	for workload, expected := range map[string]bool{"referencing": true, "other-kind": false} {
+		updated, err := customClients.DynamicClient.Resource(kind.Resource).Namespace(customNamespace).Get(workload, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get custom workload: %v", err)
+		}
+		containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "podTemplate", "spec", "containers")
+		if _, found, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env"); found != expected {
+			t.Errorf("Expected custom workload '%s' to be reloaded: %t, got containers %v", workload, expected, containers)
+		}
+	}
+}
+
+func TestWorkloadRegistryMakesCustomWorkloadsReloadable(t *testing.T) {
+	registryNamespace := "test-handler-registry-" + testutil.RandSeq(5)
+	name := "testconfigmap-registry-" + testutil.RandSeq(5)
+	defer ApplyWorkloadRegistry(nil, nil)
+	sparkApplication := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "sparkoperator.k8s.io/v1beta2",
+		"kind":       "SparkApplication",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   registryNamespace,
+			"annotations": map[string]interface{}{options.ConfigmapUpdateOnChangeAnnotation: name},
+		},
+		"spec": map[string]interface{}{
+			"driver": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "spark-kubernetes-driver"},
+						},
+					},
+				},
+			},
+		},
+	}}
+	registryClients := kube.Clients{KubernetesClient: clients.KubernetesClient, DynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), sparkApplication)}
+	resource := schema.GroupVersionResource{Group: "sparkoperator.k8s.io", Version: "v1beta2", Resource: "sparkapplications"}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, registryNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = registryNamespace
+	reloaded := func() bool {
+		updated, err := registryClients.DynamicClient.Resource(resource).Namespace(registryNamespace).Get(name, v1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get custom workload: %v", err)
+		}
+		containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "driver", "template", "spec", "containers")
+		_, found, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+		return found
+	}
+
+	if err := upgradeWorkloads(registryClients, config, getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed: %v", err)
+	}
+	if reloaded() {
+		t.Fatalf("Custom workload of an unregistered kind was reloaded")
+	}
+
+	ApplyWorkloadRegistry(nil, &core_v1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "reloader-workloads"},
+		Data: map[string]string{
+			"spark": "sparkapplications.v1beta2.sparkoperator.k8s.io=.spec.driver.template\ninvalid",
+		},
+	})
+	if kinds := RegisteredWorkloadKinds(); len(kinds) != 1 || kinds[0].Resource != resource {
+		t.Fatalf("Expected the registry to register the valid kind only, got %v", kinds)
+	}
+	if err := upgradeWorkloads(registryClients, config, getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed: %v", err)
+	}
+	if !reloaded() {
+		t.Errorf("Custom workload of the kind added to the registry was not reloaded")
+	}
+}
+
+func TestRollingUpgradeForKnativeService(t *testing.T) {
+	knativeNamespace := "test-handler-knative-" + testutil.RandSeq(5)
+	name := "testconfigmap-knative-" + testutil.RandSeq(5)
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   knativeNamespace,
+			"annotations": map[string]interface{}{options.ConfigmapUpdateOnChangeAnnotation: name},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{"autoscaling.knative.dev/target": "10"},
+				},
+				"spec": map[string]interface{}{
+					"containerConcurrency": int64(5),
+					"containers": []interface{}{
+						map[string]interface{}{"image": "app"},
+					},
+				},
+			},
+		},
+	}}
+	knativeClients := kube.Clients{KubernetesClient: clients.KubernetesClient, DynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), service)}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, knativeNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = knativeNamespace
+	if err := PerformRollingUpgrade(knativeClients, config, GetKnativeServiceRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Knative Service: %v", err)
+	}
+
+	updated, err := knativeClients.DynamicClient.Resource(kube.KnativeServiceResource).Namespace(knativeNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get Knative Service: %v", err)
+	}
+	annotations, _, _ := unstructured.NestedStringMap(updated.Object, "spec", "template", "metadata", "annotations")
+	if !strings.Contains(annotations[options.LastReloadedFromAnnotation], config.SHAValue) {
+		t.Errorf("Revision template of the Knative Service was not annotated with the hash, got annotations %v", annotations)
+	}
+	if annotations["autoscaling.knative.dev/target"] != "10" {
+		t.Errorf("Annotations of the revision template were not kept, got %v", annotations)
+	}
+	if concurrency, _, _ := unstructured.NestedInt64(updated.Object, "spec", "template", "spec", "containerConcurrency"); concurrency != 5 {
+		t.Errorf("Fields of the revision spec were not kept, got containerConcurrency %d", concurrency)
+	}
+	containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "template", "spec", "containers")
+	if _, found, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env"); found {
+		t.Errorf("Env vars were added to the containers of the Knative Service")
+	}
+}
+
+func TestRollingUpgradeForArgoRolloutOnlyChangesMainTemplate(t *testing.T) {
+	rolloutNamespace := "test-handler-rollout-" + testutil.RandSeq(5)
+	name := "testconfigmap-rollout-" + testutil.RandSeq(5)
+	templatePath := options.ArgoRolloutTemplatePath
+	options.ArgoRolloutTemplatePath = "{.spec.template}"
+	defer func() { options.ArgoRolloutTemplatePath = templatePath }()
+	strategy := map[string]interface{}{
+		"canary": map[string]interface{}{
+			"analysis": map[string]interface{}{
+				"templates": []interface{}{
+					map[string]interface{}{"templateName": "success-rate"},
+				},
+			},
+			"steps": []interface{}{
+				map[string]interface{}{
+					"experiment": map[string]interface{}{
+						"templates": []interface{}{
+							map[string]interface{}{
+								"name":    "canary",
+								"specRef": "canary",
+								"template": map[string]interface{}{
+									"spec": map[string]interface{}{
+										"containers": []interface{}{
+											map[string]interface{}{"name": "app", "image": "app"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	rollout := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   rolloutNamespace,
+			"annotations": map[string]interface{}{options.ConfigmapUpdateOnChangeAnnotation: name},
+		},
+		"spec": map[string]interface{}{
+			"strategy": runtime.DeepCopyJSONValue(strategy),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "app", "image": "app"},
+					},
+				},
+			},
+		},
+	}}
+	rolloutClients := kube.Clients{KubernetesClient: clients.KubernetesClient, DynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), rollout)}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, rolloutNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = rolloutNamespace
+	if err := PerformRollingUpgrade(rolloutClients, config, GetArgoRolloutRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for Argo Rollout: %v", err)
+	}
+
+	updated, err := rolloutClients.DynamicClient.Resource(kube.ArgoRolloutResource).Namespace(rolloutNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get Argo Rollout: %v", err)
+	}
+	containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "template", "spec", "containers")
+	if _, found, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env"); !found {
+		t.Errorf("Main pod template of the Argo Rollout was not updated, got containers %v", containers)
+	}
+	if updatedStrategy, _, _ := unstructured.NestedMap(updated.Object, "spec", "strategy"); !reflect.DeepEqual(updatedStrategy, strategy) {
+		t.Errorf("Analysis or experiment templates of the Argo Rollout were changed, got strategy %v", updatedStrategy)
+	}
+}
+
+func TestRollingUpgradeForScaledJob(t *testing.T) {
+	scaledJobNamespace := "test-handler-scaledjob-" + testutil.RandSeq(5)
+	name := "testsecret-scaledjob-" + testutil.RandSeq(5)
+	scaledJob := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "keda.sh/v1alpha1",
+		"kind":       "ScaledJob",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"namespace":   scaledJobNamespace,
+			"annotations": map[string]interface{}{options.SecretUpdateOnChangeAnnotation: name},
+		},
+		"spec": map[string]interface{}{
+			"jobTargetRef": map[string]interface{}{
+				"backoffLimit": int64(4),
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"restartPolicy": "Never",
+						"containers": []interface{}{
+							map[string]interface{}{"name": "worker", "image": "worker"},
+						},
+					},
+				},
+			},
+			"triggers": []interface{}{
+				map[string]interface{}{"type": "rabbitmq"},
+			},
+		},
+	}}
+	scaledJobClients := kube.Clients{KubernetesClient: clients.KubernetesClient, DynamicClient: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), scaledJob)}
+
+	shaData := testutil.ConvertResourceToSHA(testutil.SecretResourceType, scaledJobNamespace, name, "dGVzdFVwZGF0ZWRTZWNyZXRFbmNvZGluZ0ZvclJlbG9hZGVy")
+	config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, name, shaData, options.SecretUpdateOnChangeAnnotation)
+	config.Namespace = scaledJobNamespace
+	collectors := getCollectors()
+	if err := PerformRollingUpgrade(scaledJobClients, config, GetScaledJobRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for ScaledJob: %v", err)
+	}
+
+	updated, err := scaledJobClients.DynamicClient.Resource(kube.ScaledJobResource).Namespace(scaledJobNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get ScaledJob: %v", err)
+	}
+	containers, _, _ := unstructured.NestedSlice(updated.Object, "spec", "jobTargetRef", "template", "spec", "containers")
+	env, _, _ := unstructured.NestedSlice(containers[0].(map[string]interface{}), "env")
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	if len(env) != 1 || env[0].(map[string]interface{})["name"] != envName {
+		t.Errorf("Job target of the ScaledJob was not updated, got containers %v", containers)
+	}
+	if backoffLimit, _, _ := unstructured.NestedInt64(updated.Object, "spec", "jobTargetRef", "backoffLimit"); backoffLimit != 4 {
+		t.Errorf("Fields of the job target outside its pod template were not kept")
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Counter was not increased")
+	}
+}
+
+func TestRollingUpgradeForStandaloneReplicaSet(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	replicaSetClients := kube.Clients{KubernetesClient: kubernetesClient}
+	replicaSetNamespace := "test-handler-replicaset-" + testutil.RandSeq(5)
+	name := "testconfigmap-replicaset-" + testutil.RandSeq(5)
+	deployment := testutil.GetDeploymentWithEnvVars(replicaSetNamespace, name)
+	controller := true
+	standalone := &appsv1.ReplicaSet{ObjectMeta: deployment.ObjectMeta, Spec: appsv1.ReplicaSetSpec{Selector: deployment.Spec.Selector, Template: deployment.Spec.Template}}
+	standalone.UID = "standalone-uid"
+	managed := standalone.DeepCopy()
+	managed.Name = name + "-managed"
+	managed.UID = "managed-uid"
+	managed.OwnerReferences = []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: name, UID: "deployment-uid", Controller: &controller}}
+	for _, replicaSet := range []*appsv1.ReplicaSet{standalone, managed} {
+		if _, err := kubernetesClient.AppsV1().ReplicaSets(replicaSetNamespace).Create(replicaSet); err != nil {
+			t.Fatalf("Failed to create replicaSet: %v", err)
+		}
+	}
+	pods := []*core_v1.Pod{
+		{ObjectMeta: v1.ObjectMeta{Name: name + "-standalone", Labels: standalone.Spec.Template.Labels, OwnerReferences: []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name, UID: standalone.UID, Controller: &controller}}}},
+		{ObjectMeta: v1.ObjectMeta{Name: name + "-managed", Labels: managed.Spec.Template.Labels, OwnerReferences: []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: managed.Name, UID: managed.UID, Controller: &controller}}}},
+	}
+	for _, pod := range pods {
+		if _, err := kubernetesClient.CoreV1().Pods(replicaSetNamespace).Create(pod); err != nil {
+			t.Fatalf("Failed to create pod: %v", err)
+		}
+	}
+	var evicted []string
+	kubernetesClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		evicted = append(evicted, action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+		return true, nil, nil
+	})
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, replicaSetNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = replicaSetNamespace
+	collectors := getCollectors()
+	if err := PerformRollingUpgrade(replicaSetClients, config, GetReplicaSetRollingUpgradeFuncs(), collectors); err != nil {
+		t.Errorf("Rolling upgrade failed for ReplicaSet: %v", err)
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	updated, err := kubernetesClient.AppsV1().ReplicaSets(replicaSetNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get replicaSet: %v", err)
+	}
+	if testutil.GetResourceSHA(updated.Spec.Template.Spec.Containers, envName) != config.SHAValue {
+		t.Errorf("Standalone replicaSet was not updated")
+	}
+	notUpdated, err := kubernetesClient.AppsV1().ReplicaSets(replicaSetNamespace).Get(managed.Name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get replicaSet: %v", err)
+	}
+	if testutil.GetResourceSHA(notUpdated.Spec.Template.Spec.Containers, envName) != "" {
+		t.Errorf("ReplicaSet controlled by a deployment was updated")
+	}
+	if len(evicted) != 1 || evicted[0] != pods[0].Name {
+		t.Errorf("Expected only the pod of the standalone replicaSet to be evicted, got %v", evicted)
+	}
+	if promtestutil.ToFloat64(collectors.Reloaded.With(labelSucceeded)) != 1 {
+		t.Errorf("Counter was not increased")
+	}
+}
+
+func TestRollingUpgradeForStandaloneReplicaSetEvictsBlockedPodsOnRetry(t *testing.T) {
+	callbacks.SetEvictionRate(10)
+	defer callbacks.SetEvictionRate(0)
+
+	kubernetesClient := testclient.NewSimpleClientset()
+	replicaSetClients := kube.Clients{KubernetesClient: kubernetesClient}
+	replicaSetNamespace := "test-handler-replicaset-retry-" + testutil.RandSeq(5)
+	name := "testconfigmap-replicaset-retry-" + testutil.RandSeq(5)
+	deployment := testutil.GetDeploymentWithEnvVars(replicaSetNamespace, name)
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: deployment.ObjectMeta, Spec: appsv1.ReplicaSetSpec{Selector: deployment.Spec.Selector, Template: deployment.Spec.Template}}
+	replicaSet.UID = "replicaset-uid"
+	if _, err := kubernetesClient.AppsV1().ReplicaSets(replicaSetNamespace).Create(replicaSet); err != nil {
+		t.Fatalf("Failed to create replicaSet: %v", err)
+	}
+	controller := true
+	owner := []v1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: name, UID: replicaSet.UID, Controller: &controller}}
+	for _, suffix := range []string{"-a", "-b", "-c"} {
+		pod := &core_v1.Pod{ObjectMeta: v1.ObjectMeta{Name: name + suffix, Labels: replicaSet.Spec.Template.Labels, OwnerReferences: owner}, Spec: replicaSet.Spec.Template.Spec}
+		if _, err := kubernetesClient.CoreV1().Pods(replicaSetNamespace).Create(pod); err != nil {
+			t.Fatalf("Failed to create pod: %v", err)
+		}
+	}
+	var evicted []string
+	blocked := true
+	kubernetesClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		if blocked {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 10)
+		}
+		evicted = append(evicted, action.(k8stesting.CreateAction).GetObject().(*policyv1beta1.Eviction).Name)
+		return true, nil, nil
+	})
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, replicaSetNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = replicaSetNamespace
+	err := PerformRollingUpgrade(replicaSetClients, config, GetReplicaSetRollingUpgradeFuncs(), getCollectors())
+	if !retryable(err) {
+		t.Errorf("Expected the reload blocked by the disruption budget to be requeued, got %v", err)
+	}
+
+	// the pod recreated from the updated template before the retry is up to date
+	updated, err := kubernetesClient.AppsV1().ReplicaSets(replicaSetNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get replicaSet: %v", err)
+	}
+	if err := kubernetesClient.CoreV1().Pods(replicaSetNamespace).Delete(name+"-c", &v1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete pod: %v", err)
+	}
+	recreated := &core_v1.Pod{ObjectMeta: v1.ObjectMeta{Name: name + "-d", Labels: updated.Spec.Template.Labels, OwnerReferences: owner}, Spec: updated.Spec.Template.Spec}
+	if _, err := kubernetesClient.CoreV1().Pods(replicaSetNamespace).Create(recreated); err != nil {
+		t.Fatalf("Failed to create pod: %v", err)
+	}
+
+	blocked = false
+	start := time.Now()
+	if err := PerformRollingUpgrade(replicaSetClients, config, GetReplicaSetRollingUpgradeFuncs(), getCollectors()); err != nil {
+		t.Errorf("Retried rolling upgrade failed for ReplicaSet: %v", err)
+	}
+	sort.Strings(evicted)
+	if len(evicted) != 2 || evicted[0] != name+"-a" || evicted[1] != name+"-b" {
+		t.Errorf("Expected the pods left on the outdated template to be evicted on retry, got %v", evicted)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the evictions to be paced by the eviction rate, took %v", elapsed)
+	}
+}
+
+func TestRollingUpgradeWithWorkloadCache(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	cachedClients := kube.Clients{KubernetesClient: kubernetesClient}
+	cacheNamespace := "test-handler-cache-" + testutil.RandSeq(5)
+	name := "testconfigmap-cache-" + testutil.RandSeq(5)
+	other := "testconfigmap-cache-other-" + testutil.RandSeq(5)
+	for _, deployment := range []*appsv1.Deployment{testutil.GetDeploymentWithEnvVars(cacheNamespace, name), testutil.GetDeploymentWithEnvVars(cacheNamespace, other)} {
+		if _, err := kubernetesClient.AppsV1().Deployments(cacheNamespace).Create(deployment); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	stopCh := make(chan struct{})
+	defer func() {
+		close(stopCh)
+		workloads = nil
+	}()
+	StartWorkloadCache(kubernetesClient, []string{cacheNamespace}, stopCh)
+	if informers := workloads.informers["Deployment"]; !cache.WaitForCacheSync(stopCh, informers[0].HasSynced) {
+		t.Fatalf("Workload cache did not sync")
+	}
+	kubernetesClient.ClearActions()
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, cacheNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = cacheNamespace
+	upgradeFuncs := GetDeploymentRollingUpgradeFuncs()
+	upgradeFuncs.ItemsFunc = cachedItems(upgradeFuncs)
+	if items := referencingItems(cachedClients, config, upgradeFuncs); len(items) != 1 || util.ToObjectMeta(items[0]).Name != name {
+		t.Errorf("Expected only the deployment referencing the configmap to be looked up, got %d", len(items))
+	}
+	if err := PerformRollingUpgrade(cachedClients, config, upgradeFuncs, getCollectors()); err != nil {
+		t.Errorf("Rolling upgrade failed for cached deployment: %v", err)
+	}
+	for _, action := range kubernetesClient.Actions() {
+		if action.GetVerb() == "list" {
+			t.Errorf("Deployments were listed although they are cached")
+		}
+	}
+
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	updated, err := kubernetesClient.AppsV1().Deployments(cacheNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if testutil.GetResourceSHA(updated.Spec.Template.Spec.Containers, envName) != config.SHAValue {
+		t.Errorf("Cached deployment was not updated")
+	}
+	notUpdated, err := kubernetesClient.AppsV1().Deployments(cacheNamespace).Get(other, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if testutil.GetResourceSHA(notUpdated.Spec.Template.Spec.Containers, envName) != "" {
+		t.Errorf("Deployment referencing another configmap was updated")
+	}
+}
+
+func TestCrossNamespaceLookupWithWorkloadCache(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	workloadNamespace := "test-handler-cache-" + testutil.RandSeq(5)
+	sourceNamespace := "test-handler-cache-source-" + testutil.RandSeq(5)
+	name := "testconfigmap-cache-" + testutil.RandSeq(5)
+	values := map[string]string{
+		"qualified": sourceNamespace + "/" + name,
+		"pattern":   sourceNamespace + "/testconfigmap-cache-*",
+		"unrelated": sourceNamespace + "/other",
+		"local":     name,
+	}
+	for deploymentName, value := range values {
+		deployment := testutil.GetDeployment(workloadNamespace, deploymentName)
+		deployment.Annotations = map[string]string{options.ConfigmapUpdateOnChangeAnnotation: value}
+		if _, err := kubernetesClient.AppsV1().Deployments(workloadNamespace).Create(deployment); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+	}
+
+	stopCh := make(chan struct{})
+	defer func() {
+		close(stopCh)
+		workloads = nil
+	}()
+	StartWorkloadCache(kubernetesClient, []string{workloadNamespace}, stopCh)
+	if informers := workloads.informers["Deployment"]; !cache.WaitForCacheSync(stopCh, informers[0].HasSynced) {
+		t.Fatalf("Workload cache did not sync")
+	}
+
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, "", options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = sourceNamespace
+	items, found := crossNamespaceItems(config, GetDeploymentRollingUpgradeFuncs())
+	if !found {
+		t.Fatalf("Cached deployments were not looked up")
+	}
+	names := map[string]bool{}
+	for _, item := range items {
+		names[util.ToObjectMeta(item).Name] = true
+	}
+	if len(names) != 2 || !names["qualified"] || !names["pattern"] {
+		t.Errorf("Expected the deployments referencing the configmap of the other namespace to be looked up, got %v", names)
+	}
+}
+
+func TestRollingUpgradeConflictIsRetryable(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	conflictClients := kube.Clients{KubernetesClient: kubernetesClient}
+	conflictNamespace := "test-handler-conflict-" + testutil.RandSeq(5)
+	name := "testconfigmap-conflict-" + testutil.RandSeq(5)
+	if _, err := kubernetesClient.AppsV1().Deployments(conflictNamespace).Create(testutil.GetDeploymentWithEnvVars(conflictNamespace, name)); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	kubernetesClient.PrependReactor("patch", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(appsv1.Resource("deployments"), name, fmt.Errorf("the object has been modified"))
+	})
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, conflictNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = conflictNamespace
+	err := PerformRollingUpgrade(conflictClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors())
+	if !retryable(err) {
+		t.Errorf("Expected the conflicting update to be retryable, got %v", err)
+	}
+	if retryable(apierrors.NewForbidden(appsv1.Resource("deployments"), name, fmt.Errorf("forbidden"))) {
+		t.Errorf("Forbidden update was retryable")
+	}
+}
+
+func TestRollingUpgradePatchKeepsConcurrentChanges(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	patchClients := kube.Clients{KubernetesClient: kubernetesClient}
+	patchNamespace := "test-handler-patch-" + testutil.RandSeq(5)
+	name := "testconfigmap-patch-" + testutil.RandSeq(5)
+	listed, err := kubernetesClient.AppsV1().Deployments(patchNamespace).Create(testutil.GetDeploymentWithEnvVars(patchNamespace, name))
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	// e.g. an autoscaler scales the deployment after it was listed
+	scaled := listed.DeepCopy()
+	replicas := int32(5)
+	scaled.Spec.Replicas = &replicas
+	if _, err := kubernetesClient.AppsV1().Deployments(patchNamespace).Update(scaled); err != nil {
+		t.Fatalf("Failed to scale deployment: %v", err)
+	}
+	kubernetesClient.ClearActions()
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, patchNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = patchNamespace
+	if err := upgradeItem(patchClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors(), listed); err != nil {
+		t.Errorf("Rolling upgrade failed for deployment: %v", err)
+	}
+	for _, action := range kubernetesClient.Actions() {
+		if action.GetVerb() == "update" && action.GetResource().Resource == "deployments" {
+			t.Errorf("Deployment was replaced instead of patched")
+		}
+	}
+
+	updated, err := kubernetesClient.AppsV1().Deployments(patchNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	if testutil.GetResourceSHA(updated.Spec.Template.Spec.Containers, envName) != config.SHAValue {
+		t.Errorf("Deployment was not patched with the hash of the change")
+	}
+	if updated.Spec.Replicas == nil || *updated.Spec.Replicas != replicas {
+		t.Errorf("Concurrent change of the replicas was lost")
+	}
+}
+
+func TestRollingUpgradeRetriesConflictingUpdate(t *testing.T) {
+	patchWorkloads := options.PatchWorkloads
+	options.PatchWorkloads = false
+	defer func() { options.PatchWorkloads = patchWorkloads }()
+
+	kubernetesClient := testclient.NewSimpleClientset()
+	conflictClients := kube.Clients{KubernetesClient: kubernetesClient}
+	conflictNamespace := "test-handler-conflict-" + testutil.RandSeq(5)
+	name := "testconfigmap-conflict-" + testutil.RandSeq(5)
+	listed, err := kubernetesClient.AppsV1().Deployments(conflictNamespace).Create(testutil.GetDeploymentWithEnvVars(conflictNamespace, name))
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	replicas := int32(5)
+	conflicted := false
+	kubernetesClient.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		// e.g. an autoscaler scales the deployment after it was listed
+		scaled := listed.DeepCopy()
+		scaled.Spec.Replicas = &replicas
+		if err := kubernetesClient.Tracker().Update(appsv1.SchemeGroupVersion.WithResource("deployments"), scaled, conflictNamespace); err != nil {
+			t.Fatalf("Failed to scale deployment: %v", err)
+		}
+		return true, nil, apierrors.NewConflict(appsv1.Resource("deployments"), name, fmt.Errorf("the object has been modified"))
+	})
+
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, conflictNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = conflictNamespace
+	collectors := getCollectors()
+	if err := upgradeItem(conflictClients, config, GetDeploymentRollingUpgradeFuncs(), collectors, listed); err != nil {
+		t.Errorf("Rolling upgrade failed for deployment: %v", err)
+	}
+
+	updated, err := kubernetesClient.AppsV1().Deployments(conflictNamespace).Get(name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+	if testutil.GetResourceSHA(updated.Spec.Template.Spec.Containers, envName) != config.SHAValue {
+		t.Errorf("Deployment was not updated after the conflict")
+	}
+	if updated.Spec.Replicas == nil || *updated.Spec.Replicas != replicas {
+		t.Errorf("Concurrent change of the replicas was lost")
+	}
+	if retries := promtestutil.ToFloat64(collectors.ConflictRetries.With(prometheus.Labels{"kind": "Deployment"})); retries != 1 {
+		t.Errorf("Expected 1 conflict retry to be counted, got %v", retries)
+	}
+}
+
+func TestUpgradeItemsLimitsConcurrentReloads(t *testing.T) {
+	maxConcurrentReloads := options.MaxConcurrentReloads
+	options.MaxConcurrentReloads = 3
+	defer func() { options.MaxConcurrentReloads = maxConcurrentReloads }()
+
+	concurrentNamespace := "test-handler-concurrent-" + testutil.RandSeq(5)
+	var items []interface{}
+	for i := 0; i < 6; i++ {
+		items = append(items, testutil.GetDeployment(concurrentNamespace, fmt.Sprintf("testconfigmap-concurrent-%d", i)))
+	}
+	// the same workload listed twice is never upgraded concurrently
+	items = append(items, testutil.GetDeployment(concurrentNamespace, "testconfigmap-concurrent-0"))
+
+	var running, maxRunning int32
+	upgrading := map[string]bool{}
+	var lock sync.Mutex
+	err := upgradeItems(GetDeploymentRollingUpgradeFuncs(), items, func(item interface{}) error {
+		name := util.ToObjectMeta(item).Name
+		lock.Lock()
+		if upgrading[name] {
+			t.Errorf("Deployment '%s' was upgraded concurrently", name)
+		}
+		upgrading[name] = true
+		lock.Unlock()
+
+		current := atomic.AddInt32(&running, 1)
+		for {
+			observed := atomic.LoadInt32(&maxRunning)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxRunning, observed, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		lock.Lock()
+		upgrading[name] = false
+		lock.Unlock()
+		if name == "testconfigmap-concurrent-3" {
+			return fmt.Errorf("upgrade failed")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Errorf("Error of the failed upgrade was not returned")
+	}
+	if maxRunning < 2 || maxRunning > 3 {
+		t.Errorf("Expected 2 to 3 concurrent upgrades, got %d", maxRunning)
+	}
+}
+
+func TestSyncReloadsOnlyDriftedWorkloads(t *testing.T) {
+	kubernetesClient := testclient.NewSimpleClientset()
+	syncClients := kube.Clients{KubernetesClient: kubernetesClient}
+	syncNamespace := "test-handler-sync-" + testutil.RandSeq(5)
+	name := "testconfigmap-sync-" + testutil.RandSeq(5)
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, syncNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = syncNamespace
+	config.DriftedOnly = true
+	envName := constants.EnvVarPrefix + util.ConvertToEnvVarName(config.ResourceName) + "_" + config.Type
+
+	neverReloaded, err := kubernetesClient.AppsV1().Deployments(syncNamespace).Create(testutil.GetDeploymentWithEnvVars(syncNamespace, name))
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	drifted := testutil.GetDeploymentWithEnvVars(syncNamespace, name)
+	drifted.Name = name + "-drifted"
+	drifted.Spec.Template.Spec.Containers[0].Env = append(drifted.Spec.Template.Spec.Containers[0].Env, core_v1.EnvVar{Name: envName, Value: "outdated"})
+	if drifted, err = kubernetesClient.AppsV1().Deployments(syncNamespace).Create(drifted); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	deploymentFuncs := GetDeploymentRollingUpgradeFuncs()
+	for _, item := range []interface{}{neverReloaded, drifted} {
+		if err := upgradeItem(syncClients, config, deploymentFuncs, getCollectors(), item); err != nil {
+			t.Errorf("Sync failed for deployment: %v", err)
+		}
+	}
+
+	updated, err := kubernetesClient.AppsV1().Deployments(syncNamespace).Get(drifted.Name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if testutil.GetResourceSHA(updated.Spec.Template.Spec.Containers, envName) != shaData {
+		t.Errorf("Deployment holding an outdated hash was not reloaded")
+	}
+	untouched, err := kubernetesClient.AppsV1().Deployments(syncNamespace).Get(neverReloaded.Name, v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if testutil.GetResourceSHA(untouched.Spec.Template.Spec.Containers, envName) != "" {
+		t.Errorf("Deployment that never recorded a hash was reloaded")
+	}
+}
+
+func TestWorkloadLogFields(t *testing.T) {
+	config := getConfigWithAnnotations(constants.SecretEnvVarPostfix, "db-credentials", "", options.SecretUpdateOnChangeAnnotation)
+	config.Namespace = "payments"
+	config.SourceNamespace = "shared"
+	fields := workloadLog(config, "Deployment", "api").Data
+	expected := logrus.Fields{"namespace": "payments", "kind": "Deployment", "workload": "api", "trigger": "secret/shared/db-credentials"}
+	for field, value := range expected {
+		if fields[field] != value {
+			t.Errorf("Expected log field %s to be %v, got %v", field, value, fields[field])
+		}
+	}
+}
+
+func TestRollingUpgradeIsTraced(t *testing.T) {
+	var lock sync.Mutex
+	parents := map[string]string{}
+	names := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						SpanID       string `json:"spanId"`
+						ParentSpanID string `json:"parentSpanId"`
+						Name         string `json:"name"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Export request could not be decoded: %v", err)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					names[span.SpanID] = span.Name
+					parents[span.Name] = span.ParentSpanID
+				}
+			}
+		}
+	}))
+	defer server.Close()
+	if err := tracing.Configure(server.URL, "reloader"); err != nil {
+		t.Fatalf("Configuring the exporter failed: %v", err)
+	}
+
+	kubernetesClient := testclient.NewSimpleClientset()
+	tracedClients := kube.Clients{KubernetesClient: kubernetesClient}
+	tracedNamespace := "test-handler-traced-" + testutil.RandSeq(5)
+	name := "testconfigmap-traced-" + testutil.RandSeq(5)
+	shaData := testutil.ConvertResourceToSHA(testutil.ConfigmapResourceType, tracedNamespace, name, "www.stakater.com")
+	config := getConfigWithAnnotations(constants.ConfigmapEnvVarPostfix, name, shaData, options.ConfigmapUpdateOnChangeAnnotation)
+	config.Namespace = tracedNamespace
+	if _, err := kubernetesClient.AppsV1().Deployments(tracedNamespace).Create(testutil.GetDeploymentWithEnvVars(tracedNamespace, name)); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	root := tracing.Start("configMaps update")
+	config.Span = root
+	if sourceLog(config).Data["trace_id"] != root.TraceID() {
+		t.Errorf("Expected the logs of the change to carry the ID of its trace")
+	}
+	err := PerformRollingUpgrade(tracedClients, config, GetDeploymentRollingUpgradeFuncs(), getCollectors())
+	root.End(err)
+	tracing.Shutdown(5 * time.Second)
+	if err != nil {
+		t.Fatalf("Rolling upgrade failed for deployment: %v", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if names[parents["patch Deployment"]] != "match Deployment" || names[parents["match Deployment"]] != "configMaps update" {
+		t.Errorf("Expected the patch of the deployment to be traced as child of the matching of deployments in the trace of the change, got parents %v", parents)
+	}
+}
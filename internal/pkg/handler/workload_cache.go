@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/callbacks"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/diagnostics"
+	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/util"
+	"github.com/stakater/Reloader/pkg/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// referencesIndex is the reverse index of the cached workloads by "<namespace>/<type>/<name>" of the
+// configmaps and secrets they reference or name in their reload annotations, and by "<namespace>/*"
+// and "*" if they may be reloaded by others of their namespace or of all namespaces, e.g. through a
+// pattern
+const referencesIndex = "references"
+
+// workloadCache serves the workloads of the built-in kinds from shared informers instead of listing
+// them from the API server for every change
+type workloadCache struct {
+	// namespaces are the namespaces of the informers, all namespaces if it holds the empty namespace
+	namespaces util.List
+	informers  map[string][]cache.SharedIndexInformer
+}
+
+// workloads is the cache of the workloads, the workloads are listed from the API server if nil
+var workloads *workloadCache
+
+// StartWorkloadCache starts the shared informers caching the deployments, daemonSets, statefulSets,
+// cronJobs, replicaSets and replicationControllers of the namespaces until the stop channel is closed
+func StartWorkloadCache(client kubernetes.Interface, namespaces []string, stopCh <-chan struct{}) {
+	c := &workloadCache{namespaces: namespaces, informers: map[string][]cache.SharedIndexInformer{}}
+	for _, namespace := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, 0, informers.WithNamespace(namespace))
+		c.add(GetDeploymentRollingUpgradeFuncs(), factory.Apps().V1().Deployments().Informer())
+		c.add(GetDaemonSetRollingUpgradeFuncs(), factory.Apps().V1().DaemonSets().Informer())
+		c.add(GetStatefulSetRollingUpgradeFuncs(), factory.Apps().V1().StatefulSets().Informer())
+		c.add(GetCronJobRollingUpgradeFuncs(), factory.Batch().V1beta1().CronJobs().Informer())
+		c.add(GetReplicaSetRollingUpgradeFuncs(), factory.Apps().V1().ReplicaSets().Informer())
+		c.add(GetReplicationControllerRollingUpgradeFuncs(), factory.Core().V1().ReplicationControllers().Informer())
+		factory.Start(stopCh)
+	}
+	for resourceType, cached := range c.informers {
+		cached := cached
+		diagnostics.Publish("workload cache "+resourceType, func() interface{} {
+			size := 0
+			for _, informer := range cached {
+				size += len(informer.GetStore().ListKeys())
+			}
+			return size
+		})
+	}
+	workloads = c
+}
+
+func (c *workloadCache) add(upgradeFuncs callbacks.RollingUpgradeFuncs, informer cache.SharedIndexInformer) {
+	err := informer.AddIndexers(cache.Indexers{referencesIndex: func(obj interface{}) ([]string, error) {
+		if !reloadable(obj) {
+			return nil, nil
+		}
+		return workloadReferences(upgradeFuncs, obj), nil
+	}})
+	if err != nil {
+		logrus.Errorf("Failed to index %s: %v", upgradeFuncs.ResourceType, err)
+	}
+	c.informers[upgradeFuncs.ResourceType] = append(c.informers[upgradeFuncs.ResourceType], informer)
+}
+
+// synced returns the synced informers of the kind of workloads if they cache the namespace, none if
+// any of them did not sync yet
+func (c *workloadCache) synced(resourceType string, namespace string) []cache.SharedIndexInformer {
+	if len(c.namespaces) == 0 || (c.namespaces[0] != v1.NamespaceAll && namespace == v1.NamespaceAll) {
+		return nil
+	}
+	if c.namespaces[0] != v1.NamespaceAll && !c.namespaces.Contains(namespace) {
+		return nil
+	}
+	return c.syncedInformers(resourceType)
+}
+
+// syncedInformers returns the informers of the kind of workloads of all cached namespaces, none if any
+// of them did not sync yet
+func (c *workloadCache) syncedInformers(resourceType string) []cache.SharedIndexInformer {
+	informers := c.informers[resourceType]
+	for _, informer := range informers {
+		if !informer.HasSynced() {
+			return nil
+		}
+	}
+	return informers
+}
+
+// cachedItems returns the items func of the kind of workloads listing them from the cache, if cached
+func cachedItems(upgradeFuncs callbacks.RollingUpgradeFuncs) callbacks.ItemsFunc {
+	list := upgradeFuncs.ItemsFunc
+	return func(clients kube.Clients, namespace string) []interface{} {
+		c := workloads
+		if c == nil {
+			return list(clients, namespace)
+		}
+		informers := c.synced(upgradeFuncs.ResourceType, namespace)
+		if informers == nil {
+			return list(clients, namespace)
+		}
+		var items []interface{}
+		for _, informer := range informers {
+			var objects []interface{}
+			if namespace == v1.NamespaceAll {
+				objects = informer.GetIndexer().List()
+			} else {
+				objects, _ = informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+			}
+			items = append(items, copyItems(objects)...)
+		}
+		return items
+	}
+}
+
+// referencingItems returns the workloads of the kind in the namespace of the config that may reference
+// the changed configmap or secret, looked up in the references index of the cache, or all workloads of
+// the kind if they are not cached
+func referencingItems(clients kube.Clients, config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs) []interface{} {
+	c := workloads
+	if c == nil || !indexedSource(config) || config.SourceNamespace != "" || config.Namespace == v1.NamespaceAll {
+		return upgradeFuncs.ItemsFunc(clients, config.Namespace)
+	}
+	informers := c.synced(upgradeFuncs.ResourceType, config.Namespace)
+	if informers == nil {
+		return upgradeFuncs.ItemsFunc(clients, config.Namespace)
+	}
+	items, err := lookUpItems(informers, []string{sourceKey(config), config.Namespace + "/*"}, func(namespace string) bool {
+		return namespace == config.Namespace
+	})
+	if err != nil {
+		logrus.Errorf("Failed to look up %s referencing '%s': %v", upgradeFuncs.ResourceType, config.ResourceName, err)
+		return upgradeFuncs.ItemsFunc(clients, config.Namespace)
+	}
+	return items
+}
+
+// crossNamespaceItems returns the workloads of the kind in the other namespaces that may reference the
+// changed configmap or secret as "<namespace>/<name>", looked up in the references index of the cache.
+// It returns false if they are not cached
+func crossNamespaceItems(config util.Config, upgradeFuncs callbacks.RollingUpgradeFuncs) ([]interface{}, bool) {
+	c := workloads
+	if c == nil || !indexedSource(config) {
+		return nil, false
+	}
+	informers := c.syncedInformers(upgradeFuncs.ResourceType)
+	if informers == nil {
+		return nil, false
+	}
+	items, err := lookUpItems(informers, []string{sourceKey(config), "*"}, func(namespace string) bool {
+		return namespace != config.Namespace
+	})
+	if err != nil {
+		logrus.Errorf("Failed to look up %s referencing '%s/%s': %v", upgradeFuncs.ResourceType, config.Namespace, config.ResourceName, err)
+		return nil, false
+	}
+	return items, true
+}
+
+// indexedSource checks whether the workloads referencing the changed source are in the references index
+func indexedSource(config util.Config) bool {
+	return config.Type == constants.ConfigmapEnvVarPostfix || config.Type == constants.SecretEnvVarPostfix
+}
+
+// sourceKey returns the key of the changed configmap or secret in the references index
+func sourceKey(config util.Config) string {
+	return referenceKey(config.Namespace, config.Type, config.ResourceName)
+}
+
+func referenceKey(namespace string, sourceType string, name string) string {
+	return namespace + "/" + sourceType + "/" + name
+}
+
+// lookUpItems returns copies of the workloads of the informers indexed by any of the keys, once each,
+// that are in a namespace kept by keep
+func lookUpItems(informers []cache.SharedIndexInformer, keys []string, keep func(namespace string) bool) ([]interface{}, error) {
+	var items []interface{}
+	for _, informer := range informers {
+		seen := map[interface{}]bool{}
+		for _, key := range keys {
+			objects, err := informer.GetIndexer().ByIndex(referencesIndex, key)
+			if err != nil {
+				return nil, err
+			}
+			for _, object := range objects {
+				if seen[object] || !keep(util.ToObjectMeta(object).Namespace) {
+					continue
+				}
+				seen[object] = true
+				items = append(items, copyItems([]interface{}{object})...)
+			}
+		}
+	}
+	return items, nil
+}
+
+// copyItems copies the cached objects that are reloaded, which the callbacks modify
+func copyItems(objects []interface{}) []interface{} {
+	items := make([]interface{}, 0, len(objects))
+	for _, object := range objects {
+		if cached, isObject := object.(runtime.Object); isObject && reloadable(object) {
+			items = append(items, cached.DeepCopyObject())
+		}
+	}
+	return items
+}
+
+// reloadable checks whether the cached object is reloaded, the items funcs leave out the replicaSets
+// and replicationControllers that have a controller like the deployments creating them
+func reloadable(object interface{}) bool {
+	switch workload := object.(type) {
+	case *appsv1.ReplicaSet:
+		return meta_v1.GetControllerOf(workload) == nil
+	case *v1.ReplicationController:
+		return meta_v1.GetControllerOf(workload) == nil && workload.Spec.Template != nil
+	}
+	return true
+}
+
+// workloadReferences returns the keys of the workload in the references index: the configmaps and
+// secrets its pod template references or its reload annotations name, in its namespace or as
+// "<namespace>/<name>" in another, "<namespace>/*" if its reload annotations hold patterns or it
+// resolves its source, and "*" if its patterns may match references of other namespaces
+func workloadReferences(upgradeFuncs callbacks.RollingUpgradeFuncs, item interface{}) []string {
+	namespace := util.ToObjectMeta(item).Namespace
+	var keys []string
+	patterns := false
+	resolves := false
+	for _, annotations := range []map[string]string{upgradeFuncs.AnnotationsFunc(item), upgradeFuncs.PodAnnotationsFunc(item)} {
+		if _, found := annotations[options.ResolveSourceFromAnnotation]; found {
+			resolves = true
+		}
+		for sourceType, annotation := range map[string]string{constants.ConfigmapEnvVarPostfix: options.ConfigmapUpdateOnChangeAnnotation, constants.SecretEnvVarPostfix: options.SecretUpdateOnChangeAnnotation} {
+			value, found := annotations[annotation]
+			if !found {
+				continue
+			}
+			for _, name := range strings.Split(value, ",") {
+				if isPattern(name) {
+					patterns = true
+				} else if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+					keys = append(keys, referenceKey(parts[0], sourceType, parts[1]))
+				} else {
+					keys = append(keys, referenceKey(namespace, sourceType, name))
+				}
+			}
+		}
+	}
+	if patterns || resolves {
+		keys = append(keys, namespace+"/*")
+	}
+	if patterns {
+		keys = append(keys, "*")
+	}
+
+	if upgradeFuncs.ReferencesFunc != nil {
+		for _, reference := range upgradeFuncs.ReferencesFunc(item) {
+			for _, sourceType := range []string{constants.ConfigmapEnvVarPostfix, constants.SecretEnvVarPostfix} {
+				if reference.Type == "" || reference.Type == sourceType {
+					keys = append(keys, referenceKey(namespace, sourceType, reference.Name))
+				}
+			}
+		}
+	}
+
+	if upgradeFuncs.PodTemplateFunc == nil {
+		return keys
+	}
+	template := upgradeFuncs.PodTemplateFunc(item)
+	if template == nil {
+		return keys
+	}
+	spec := template.Spec
+	for _, sourceType := range []string{constants.ConfigmapEnvVarPostfix, constants.SecretEnvVarPostfix} {
+		for _, name := range referencedNames(spec, sourceType) {
+			keys = append(keys, referenceKey(namespace, sourceType, name))
+		}
+	}
+	for _, container := range spec.EphemeralContainers {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				keys = append(keys, referenceKey(namespace, constants.ConfigmapEnvVarPostfix, env.ValueFrom.ConfigMapKeyRef.Name))
+			} else if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				keys = append(keys, referenceKey(namespace, constants.SecretEnvVarPostfix, env.ValueFrom.SecretKeyRef.Name))
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				keys = append(keys, referenceKey(namespace, constants.ConfigmapEnvVarPostfix, envFrom.ConfigMapRef.Name))
+			} else if envFrom.SecretRef != nil {
+				keys = append(keys, referenceKey(namespace, constants.SecretEnvVarPostfix, envFrom.SecretRef.Name))
+			}
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if name := volumeDriverSecretName(volume); name != "" {
+			keys = append(keys, referenceKey(namespace, constants.SecretEnvVarPostfix, name))
+		}
+	}
+	for _, pullSecret := range spec.ImagePullSecrets {
+		keys = append(keys, referenceKey(namespace, constants.SecretEnvVarPostfix, pullSecret.Name))
+	}
+	return keys
+}
@@ -0,0 +1,186 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+const (
+	// HealthPath is the path at which Reloader reports whether it is healthy
+	HealthPath = "/healthz"
+	// ReadinessPath is the path at which Reloader reports whether it is ready
+	ReadinessPath = "/readyz"
+)
+
+// Checker tracks the state Reloader reports to the probes of its pod. It is ready once the caches of
+// all informers are synced and, if required, it leads, until it shuts down. It is unhealthy once an
+// informer stopped, or once the API server was unreachable for longer than the unreachable timeout
+type Checker struct {
+	lock               sync.Mutex
+	clock              clock.Clock
+	informers          map[string]func() bool
+	stopped            map[string]bool
+	leadershipRequired bool
+	leading            bool
+	shuttingDown       bool
+	unreachableTimeout time.Duration
+	lastContact        time.Time
+}
+
+// NewChecker returns a checker never considering the API server unreachable
+func NewChecker() *Checker {
+	return newChecker(clock.RealClock{})
+}
+
+func newChecker(clock clock.Clock) *Checker {
+	return &Checker{
+		clock:       clock,
+		informers:   map[string]func() bool{},
+		stopped:     map[string]bool{},
+		lastContact: clock.Now(),
+	}
+}
+
+// Default is the checker of the Reloader process
+var Default = NewChecker()
+
+// SetUnreachableTimeout considers the API server unreachable when it could not be contacted for longer
+// than the timeout, never if 0
+func (c *Checker) SetUnreachableTimeout(timeout time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.unreachableTimeout = timeout
+}
+
+// AddInformer adds the informer of the name, Reloader is not ready until hasSynced returns true
+func (c *Checker) AddInformer(name string, hasSynced func() bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.informers[name] = hasSynced
+	delete(c.stopped, name)
+}
+
+// InformerStopped marks the informer of the name as stopped, which is unhealthy unless Reloader shuts
+// down
+func (c *Checker) InformerStopped(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stopped[name] = true
+}
+
+// RequireLeadership makes Reloader ready only while it leads
+func (c *Checker) RequireLeadership() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.leadershipRequired = true
+}
+
+// SetLeading sets whether Reloader leads
+func (c *Checker) SetLeading(leading bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.leading = leading
+}
+
+// ShutDown makes Reloader not ready anymore
+func (c *Checker) ShutDown() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.shuttingDown = true
+}
+
+// Contacted records the API server was contacted successfully if err is nil
+func (c *Checker) Contacted(err error) {
+	if err != nil {
+		logrus.Warnf("Unable to contact the API server: %v", err)
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastContact = c.clock.Now()
+}
+
+// WatchAPIServer contacts the API server with ping at every interval until the stop channel is closed
+func (c *Checker) WatchAPIServer(ping func() error, interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Contacted(ping())
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Ready returns why Reloader is not ready, nil if it is
+func (c *Checker) Ready() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.shuttingDown {
+		return fmt.Errorf("shutting down")
+	}
+	if c.leadershipRequired && !c.leading {
+		return fmt.Errorf("not leading")
+	}
+	if len(c.informers) == 0 {
+		return fmt.Errorf("no informers started")
+	}
+	var unsynced []string
+	for name, hasSynced := range c.informers {
+		if !hasSynced() {
+			unsynced = append(unsynced, name)
+		}
+	}
+	if len(unsynced) > 0 {
+		sort.Strings(unsynced)
+		return fmt.Errorf("caches of %s not synced", strings.Join(unsynced, ", "))
+	}
+	return nil
+}
+
+// Healthy returns why Reloader is unhealthy, nil if it is healthy
+func (c *Checker) Healthy() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.shuttingDown {
+		return nil
+	}
+	var stopped []string
+	for name := range c.stopped {
+		stopped = append(stopped, name)
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("informers of %s stopped", strings.Join(stopped, ", "))
+	}
+	if unreachable := c.clock.Since(c.lastContact); c.unreachableTimeout > 0 && unreachable > c.unreachableTimeout {
+		return fmt.Errorf("API server unreachable for %s", unreachable.Round(time.Second))
+	}
+	return nil
+}
+
+// Register serves the health and readiness of the checker on the mux
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc(HealthPath, serve(c.Healthy))
+	mux.HandleFunc(ReadinessPath, serve(c.Ready))
+}
+
+// serve responds with 200 if check returns nil, and with 503 and the returned reason otherwise
+func serve(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
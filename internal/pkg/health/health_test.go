@@ -0,0 +1,97 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestReadyOnceInformersSyncedAndLeading(t *testing.T) {
+	c := newChecker(clock.NewFakeClock(time.Now()))
+	if err := c.Ready(); err == nil {
+		t.Errorf("Expected not to be ready before informers are started")
+	}
+
+	synced := false
+	c.AddInformer("configMaps", func() bool { return synced })
+	if err := c.Ready(); err == nil {
+		t.Errorf("Expected not to be ready before the caches are synced")
+	}
+	synced = true
+	if err := c.Ready(); err != nil {
+		t.Errorf("Expected to be ready once the caches are synced, got %v", err)
+	}
+
+	c.RequireLeadership()
+	if err := c.Ready(); err == nil {
+		t.Errorf("Expected not to be ready before leading")
+	}
+	c.SetLeading(true)
+	if err := c.Ready(); err != nil {
+		t.Errorf("Expected to be ready while leading, got %v", err)
+	}
+
+	c.ShutDown()
+	if err := c.Ready(); err == nil {
+		t.Errorf("Expected not to be ready while shutting down")
+	}
+}
+
+func TestUnhealthyOnceInformerStopped(t *testing.T) {
+	c := newChecker(clock.NewFakeClock(time.Now()))
+	c.AddInformer("secrets", func() bool { return true })
+	if err := c.Healthy(); err != nil {
+		t.Errorf("Expected to be healthy, got %v", err)
+	}
+	c.InformerStopped("secrets")
+	if err := c.Healthy(); err == nil {
+		t.Errorf("Expected to be unhealthy once an informer stopped")
+	}
+	c.ShutDown()
+	if err := c.Healthy(); err != nil {
+		t.Errorf("Expected stopped informers to be healthy while shutting down, got %v", err)
+	}
+}
+
+func TestUnhealthyOnceAPIServerUnreachable(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	c := newChecker(fakeClock)
+	c.SetUnreachableTimeout(time.Minute)
+
+	fakeClock.Step(50 * time.Second)
+	c.Contacted(nil)
+	fakeClock.Step(50 * time.Second)
+	c.Contacted(fmt.Errorf("connection refused"))
+	if err := c.Healthy(); err != nil {
+		t.Errorf("Expected to be healthy within the unreachable timeout, got %v", err)
+	}
+	fakeClock.Step(20 * time.Second)
+	if err := c.Healthy(); err == nil {
+		t.Errorf("Expected to be unhealthy once the API server was unreachable for longer than the timeout")
+	}
+	c.Contacted(nil)
+	if err := c.Healthy(); err != nil {
+		t.Errorf("Expected to be healthy once the API server was contacted again, got %v", err)
+	}
+}
+
+func TestRegisterServesProbes(t *testing.T) {
+	c := newChecker(clock.NewFakeClock(time.Now()))
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d before informers are started, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status %d when healthy, got %d", http.StatusOK, recorder.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Reloaded is the decision of a workload that was reloaded
+	Reloaded = "reloaded"
+	// Failed is the decision of a workload whose reload failed
+	Failed = "failed"
+	// Deferred is the decision of a workload whose reload was put off
+	Deferred = "deferred"
+	// Skipped is the decision of a workload whose reload was not performed
+	Skipped = "skipped"
+)
+
+// Record is a reload decision taken for a workload on a change of a configmap or secret
+type Record struct {
+	Time       time.Time `json:"time"`
+	Namespace  string    `json:"namespace"`
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	SourceType string    `json:"sourceType"`
+	SourceName string    `json:"sourceName"`
+	Decision   string    `json:"decision"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// Filter selects the records of a query, empty fields match any record
+type Filter struct {
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+func (f Filter) matches(record Record) bool {
+	return (f.Namespace == "" || f.Namespace == record.Namespace) &&
+		(f.Kind == "" || f.Kind == record.Kind) &&
+		(f.Name == "" || f.Name == record.Name)
+}
+
+// Store is an append-only file of records, one JSON object per line. Once the file reaches the
+// max size it is rotated to "<path>.1", replacing the previously rotated file, so at most twice
+// the max size is kept
+type Store struct {
+	path    string
+	maxSize int64
+	lock    sync.Mutex
+}
+
+// NewStore returns the store of the file at path, rotated once it reaches maxSize bytes
+func NewStore(path string, maxSize int64) *Store {
+	return &Store{path: path, maxSize: maxSize}
+}
+
+// Append adds the record to the store
+func (s *Store) Append(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if info, err := os.Stat(s.path); err == nil && info.Size()+int64(len(line)) > s.maxSize {
+		if err := os.Rename(s.path, s.path+".1"); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(line)
+	return err
+}
+
+// Query returns the records matching the filter, oldest first
+func (s *Store) Query(filter Filter) ([]Record, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var records []Record
+	for _, path := range []string{s.path + ".1", s.path} {
+		matching, err := query(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, matching...)
+	}
+	return records, nil
+}
+
+func query(path string, filter Filter) ([]Record, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		// a line cut short by a crash while appending is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
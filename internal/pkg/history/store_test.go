@@ -0,0 +1,101 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tempStorePath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "reloader-history")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	return filepath.Join(dir, "history.jsonl")
+}
+
+func TestStoreQuery(t *testing.T) {
+	path := tempStorePath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	store := NewStore(path, 1<<20)
+
+	now := time.Date(2020, time.March, 9, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: now, Namespace: "default", Kind: "Deployment", Name: "payments", SourceType: "configmap", SourceName: "payments-config", Decision: Reloaded},
+		{Time: now, Namespace: "default", Kind: "StatefulSet", Name: "payments", SourceType: "configmap", SourceName: "payments-config", Decision: Deferred, Reason: "rollout-in-progress"},
+		{Time: now, Namespace: "billing", Kind: "Deployment", Name: "payments", SourceType: "secret", SourceName: "payments-secret", Decision: Failed},
+	}
+	for _, record := range records {
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Failed to append record: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []Record
+	}{
+		{name: "all", filter: Filter{}, expected: records},
+		{name: "deployment", filter: Filter{Kind: "Deployment", Name: "payments"}, expected: []Record{records[0], records[2]}},
+		{name: "deployment in namespace", filter: Filter{Namespace: "default", Kind: "Deployment", Name: "payments"}, expected: records[:1]},
+		{name: "unknown", filter: Filter{Name: "orders"}, expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to query records: %v", err)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %d records, got %v", len(tt.expected), got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("Expected record %v, got %v", tt.expected[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStoreRotation(t *testing.T) {
+	path := tempStorePath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	// room for about three records per file
+	store := NewStore(path, 600)
+
+	for i := 0; i < 10; i++ {
+		record := Record{Time: time.Unix(int64(i), 0).UTC(), Namespace: "default", Kind: "Deployment", Name: "payments", Decision: Reloaded}
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Failed to append record: %v", err)
+		}
+	}
+
+	for _, file := range []string{path, path + ".1"} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", file, err)
+		}
+		if info.Size() > 600 {
+			t.Errorf("File %s exceeds the max size with %d bytes", file, info.Size())
+		}
+	}
+	got, err := store.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Failed to query records: %v", err)
+	}
+	if len(got) == 0 || len(got) >= 10 {
+		t.Fatalf("Expected the oldest records to be rotated out, got %d records", len(got))
+	}
+	if last := got[len(got)-1]; last.Time != time.Unix(9, 0).UTC() {
+		t.Errorf("Expected the latest record last, got %v", last)
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].Time.After(got[i-1].Time) {
+			t.Errorf("Records are not ordered oldest first: %v", got)
+		}
+	}
+}
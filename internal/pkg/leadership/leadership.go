@@ -0,0 +1,79 @@
+package leadership
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Config configures the election of the Reloader instance that performs rolling upgrades
+type Config struct {
+	// LeaseName and LeaseNamespace name the Lease the instances compete for
+	LeaseName      string
+	LeaseNamespace string
+	// Identity identifies this instance as lease holder, e.g. the name of its pod
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// ScopedLeaseName returns the name of the lease of instances scoped to the resource types, so instances
+// splitting the resource types between them are elected each for their own, e.g. reloader-configmaps.
+// It is the name itself if the instances are not scoped
+func ScopedLeaseName(name string, resources []string) string {
+	if len(resources) == 0 {
+		return name
+	}
+	return name + "-" + strings.ToLower(strings.Join(resources, "-"))
+}
+
+// Run waits until this instance is elected and then runs lead, with a stop channel that is closed
+// once the leadership is lost or the stop channel is closed. Run returns when lead returned after
+// the leadership was lost, or when the stop channel is closed. The lease is released on stop, so
+// another instance can take over without waiting for the lease to expire
+func Run(client kubernetes.Interface, config Config, lead func(stopCh <-chan struct{}), stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: config.LeaseName, Namespace: config.LeaseNamespace},
+		Client:     client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: config.Identity},
+	}
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   config.LeaseDuration,
+		RenewDeadline:   config.RenewDeadline,
+		RetryPeriod:     config.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            config.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				logrus.Infof("Elected as leader of lease '%s' in namespace '%s'", config.LeaseName, config.LeaseNamespace)
+				lead(ctx.Done())
+			},
+			OnStoppedLeading: func() {
+				logrus.Infof("Stopped leading lease '%s' in namespace '%s'", config.LeaseName, config.LeaseNamespace)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != config.Identity {
+					logrus.Infof("Instance '%s' leads lease '%s' in namespace '%s'", identity, config.LeaseName, config.LeaseNamespace)
+				}
+			},
+		},
+	})
+}
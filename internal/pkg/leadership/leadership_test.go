@@ -0,0 +1,120 @@
+package leadership
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testConfig(identity string) Config {
+	return Config{
+		LeaseName:      "reloader",
+		LeaseNamespace: "default",
+		Identity:       identity,
+		LeaseDuration:  time.Second,
+		RenewDeadline:  500 * time.Millisecond,
+		RetryPeriod:    100 * time.Millisecond,
+	}
+}
+
+func TestRunElectsSingleLeaderAndHandsOver(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	leading := make(chan string, 2)
+	lead := func(identity string) func(stopCh <-chan struct{}) {
+		return func(stopCh <-chan struct{}) {
+			leading <- identity
+			<-stopCh
+		}
+	}
+
+	firstStop := make(chan struct{})
+	firstDone := make(chan struct{})
+	go func() {
+		Run(client, testConfig("first"), lead("first"), firstStop)
+		close(firstDone)
+	}()
+	select {
+	case identity := <-leading:
+		if identity != "first" {
+			t.Fatalf("Expected first instance to lead, got %s", identity)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No instance was elected")
+	}
+
+	secondStop := make(chan struct{})
+	defer close(secondStop)
+	go Run(client, testConfig("second"), lead("second"), secondStop)
+	select {
+	case identity := <-leading:
+		t.Fatalf("Instance %s leads while the first instance holds the lease", identity)
+	case <-time.After(2 * time.Second):
+	}
+
+	close(firstStop)
+	select {
+	case <-firstDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("First instance did not stop")
+	}
+	select {
+	case identity := <-leading:
+		if identity != "second" {
+			t.Fatalf("Expected second instance to take over, got %s", identity)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Second instance did not take over the released lease")
+	}
+}
+
+func TestRunElectsInstancesScopedToResourcesForTheirOwnLease(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	leading := make(chan string, 2)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	for _, resource := range []string{"configMaps", "secrets"} {
+		config := testConfig(resource)
+		config.LeaseName = ScopedLeaseName(config.LeaseName, []string{resource})
+		go Run(client, config, func(identity string) func(stopCh <-chan struct{}) {
+			return func(stopCh <-chan struct{}) {
+				leading <- identity
+				<-stopCh
+			}
+		}(resource), stopCh)
+	}
+
+	// both instances lead at once, each holding only its own lease
+	elected := map[string]bool{}
+	for len(elected) < 2 {
+		select {
+		case identity := <-leading:
+			elected[identity] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected both scoped instances to lead, got %v", elected)
+		}
+	}
+	leases, err := client.CoordinationV1().Leases("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list the leases: %v", err)
+	}
+	holders := map[string]string{}
+	for _, lease := range leases.Items {
+		holders[lease.Name] = *lease.Spec.HolderIdentity
+	}
+	want := map[string]string{"reloader-configmaps": "configMaps", "reloader-secrets": "secrets"}
+	if !reflect.DeepEqual(holders, want) {
+		t.Errorf("Expected the leases %v, got %v", want, holders)
+	}
+}
+
+func TestScopedLeaseName(t *testing.T) {
+	if name := ScopedLeaseName("reloader", nil); name != "reloader" {
+		t.Errorf("Expected an unscoped lease to keep its name, got %s", name)
+	}
+	if name := ScopedLeaseName("reloader", []string{"configMaps"}); name != "reloader-configmaps" {
+		t.Errorf("Expected the lease scoped to configmaps to be named reloader-configmaps, got %s", name)
+	}
+}
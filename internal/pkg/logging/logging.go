@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LevelPath is the path at which the log level is read with GET and changed with PUT, e.g. to "debug"
+const LevelPath = "/log-level"
+
+// configured is the level set by Configure, which toggling the debug level returns to
+var configured = struct {
+	lock  sync.Mutex
+	level logrus.Level
+}{level: logrus.InfoLevel}
+
+// Configure sets the level and the format, "json" or "text" and the empty string for text, of the logs
+func Configure(format string, level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	configured.lock.Lock()
+	configured.level = parsed
+	configured.lock.Unlock()
+	logrus.SetLevel(parsed)
+
+	switch format {
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case "text", "":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	default:
+		return fmt.Errorf("unsupported logging formatter: %q", format)
+	}
+	return nil
+}
+
+// SetLevel changes the level of the logs until it is changed again
+func SetLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(parsed)
+	logrus.Infof("Changed the log level to %s", parsed)
+	return nil
+}
+
+// ToggleDebug changes the level of the logs to debug, or back to the configured level if it is debug
+func ToggleDebug() {
+	configured.lock.Lock()
+	level := configured.level
+	configured.lock.Unlock()
+	if logrus.GetLevel() != logrus.DebugLevel {
+		level = logrus.DebugLevel
+	}
+	logrus.SetLevel(level)
+	logrus.Infof("Changed the log level to %s", level)
+}
+
+// Register serves the log level at the level path of the mux
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc(LevelPath, serveLevel)
+}
+
+// serveLevel responds with the log level to GET requests, and changes it to the level in the body of
+// PUT requests
+func serveLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 64))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := SetLevel(strings.TrimSpace(string(body))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fmt.Fprintln(w, logrus.GetLevel())
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestConfigure(t *testing.T) {
+	defer Configure("", "info")
+
+	if err := Configure("json", "warning"); err != nil {
+		t.Fatalf("Configuring JSON logs failed: %v", err)
+	}
+	if _, ok := logrus.StandardLogger().Formatter.(*logrus.JSONFormatter); !ok {
+		t.Errorf("Expected JSON logs, got %T", logrus.StandardLogger().Formatter)
+	}
+	if logrus.GetLevel() != logrus.WarnLevel {
+		t.Errorf("Expected the warning level, got %s", logrus.GetLevel())
+	}
+	if err := Configure("text", "verbose"); err == nil {
+		t.Errorf("Configuring an unknown level did not fail")
+	}
+	if err := Configure("xml", "info"); err == nil {
+		t.Errorf("Configuring an unknown format did not fail")
+	}
+}
+
+func TestToggleDebugReturnsToConfiguredLevel(t *testing.T) {
+	defer Configure("", "info")
+	Configure("", "error")
+
+	ToggleDebug()
+	if logrus.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected the debug level, got %s", logrus.GetLevel())
+	}
+	ToggleDebug()
+	if logrus.GetLevel() != logrus.ErrorLevel {
+		t.Errorf("Expected the configured level, got %s", logrus.GetLevel())
+	}
+}
+
+func TestServeLevel(t *testing.T) {
+	defer Configure("", "info")
+	mux := http.NewServeMux()
+	Register(mux)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, LevelPath, strings.NewReader("debug\n")))
+	if recorder.Code != http.StatusOK || logrus.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected the level to be changed to debug, got status %d and level %s", recorder.Code, logrus.GetLevel())
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, LevelPath, nil))
+	if strings.TrimSpace(recorder.Body.String()) != "debug" {
+		t.Errorf("Expected the debug level to be served, got %q", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, LevelPath, strings.NewReader("verbose")))
+	if recorder.Code != http.StatusBadRequest || logrus.GetLevel() != logrus.DebugLevel {
+		t.Errorf("Expected an unknown level to be rejected, got status %d and level %s", recorder.Code, logrus.GetLevel())
+	}
+}
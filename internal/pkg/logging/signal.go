@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ToggleDebugOnSignal toggles the debug level on every SIGUSR1 until the stop channel is closed
+func ToggleDebugOnSignal(stopCh <-chan struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-signals:
+			ToggleDebug()
+		case <-stopCh:
+			return
+		}
+	}
+}
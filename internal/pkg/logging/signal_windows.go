@@ -0,0 +1,5 @@
+package logging
+
+// ToggleDebugOnSignal does nothing, there is no SIGUSR1 on Windows
+func ToggleDebugOnSignal(stopCh <-chan struct{}) {
+}
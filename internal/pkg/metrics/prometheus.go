@@ -4,14 +4,81 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/diagnostics"
+	"github.com/stakater/Reloader/internal/pkg/health"
+	"github.com/stakater/Reloader/internal/pkg/logging"
 	"net/http"
+	"strconv"
+)
+
+const (
+	// SkipReasonObserveOnly is used when a reload was skipped because Reloader runs in observe-only mode
+	SkipReasonObserveOnly = "observe-only"
+	// SkipReasonIgnoredManager is used when a change was skipped because it was made by an ignored field manager
+	SkipReasonIgnoredManager = "ignored-manager"
+	// SkipReasonIgnoredSource is used when a change was skipped because the configmap or secret has the ignore annotation
+	SkipReasonIgnoredSource = "ignored-source"
+	// SkipReasonExpired is used when a deferred reload was dropped because it was queued longer than the max queued age
+	SkipReasonExpired = "expired"
+	// SkipReasonDebounced is used when a change was merged with the next change of the same resource within the debounce window
+	SkipReasonDebounced = "debounced"
+	// ConflictMetadataAndPodTemplate is used when both the workload and its pod template carry reload annotations
+	ConflictMetadataAndPodTemplate = "metadata-and-pod-template"
+	// ConflictAutoAndExplicit is used when a workload has the auto annotation and a list of configmaps or secrets
+	ConflictAutoAndExplicit = "auto-and-explicit"
+	// ConflictAutoAndSearch is used when a workload has both the auto and the search annotation
+	ConflictAutoAndSearch = "auto-and-search"
+	// UnknownTeam is the team of reloaded workloads without the team label
+	UnknownTeam = "unknown"
+	// RolloutSucceeded is the result of monitored rollouts of reloaded workloads that completed
+	RolloutSucceeded = "succeeded"
+	// RolloutFailed is the result of monitored rollouts of reloaded workloads that failed or timed out
+	RolloutFailed = "failed"
+	// RetryRetried is the result of failed changes that are queued again with backoff
+	RetryRetried = "retried"
+	// RetryDropped is the result of failed changes that are dropped after the max retries
+	RetryDropped = "dropped"
+	// RetrySuperseded is the result of failed changes that are dropped as the resource changed again
+	RetrySuperseded = "superseded"
 )
 
 type Collectors struct {
-	Reloaded *prometheus.CounterVec
+	Changes             prometheus.Counter
+	Reloaded            *prometheus.CounterVec
+	Skipped             *prometheus.CounterVec
+	ReloadedByTeam      *prometheus.CounterVec
+	AnnotationConflicts *prometheus.CounterVec
+	// ReloadedByResource counts reloads and failed reloads by the namespace and kind of the workload
+	// and the type of the changed source
+	ReloadedByResource *prometheus.CounterVec
+	// Events counts the events of the configmap and secret informers
+	Events *prometheus.CounterVec
+	// ReloadedByWorkload is only filled with detailed metrics enabled, as it has a series per
+	// workload and source change
+	ReloadedByWorkload *prometheus.CounterVec
+	// Rollouts counts the monitored rollouts of reloaded workloads by their result
+	Rollouts *prometheus.CounterVec
+	// QueueDepth is the number of changes waiting in the queues of the informers, without the retries
+	// waiting for their backoff
+	QueueDepth *prometheus.GaugeVec
+	// Retries counts the failed changes by whether they are retried or dropped
+	Retries *prometheus.CounterVec
+	// ConflictRetries counts the updates of workloads retried after conflicting with concurrent changes
+	ConflictRetries *prometheus.CounterVec
+	// NotificationsDropped counts the notifications dropped by sink and reason, e.g. while the circuit
+	// breaker of the sink is open
+	NotificationsDropped *prometheus.CounterVec
 }
 
 func NewCollectors() Collectors {
+	changes := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "changes_processed_total",
+			Help:      "Counter of configmap and secret changes processed by Reloader.",
+		},
+	)
+
 	reloaded := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "reloader",
@@ -25,19 +92,203 @@ func NewCollectors() Collectors {
 	reloaded.With(prometheus.Labels{"success": "true"}).Add(0)
 	reloaded.With(prometheus.Labels{"success": "false"}).Add(0)
 
+	skipped := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "reload_skipped_total",
+			Help:      "Counter of reloads detected but skipped by Reloader.",
+		},
+		[]string{"reason"},
+	)
+
+	reloadedByTeam := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "reloads_by_team_total",
+			Help:      "Counter of reloads executed by Reloader, by the team label of the workload.",
+		},
+		[]string{"team"},
+	)
+
+	reloadedByResource := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "reload_executed_by_resource_total",
+			Help:      "Counter of reloads executed by Reloader, by namespace and kind of the workload and the type of the changed source.",
+		},
+		[]string{"success", "namespace", "kind", "source_type"},
+	)
+
+	events := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "informer_events_total",
+			Help:      "Counter of configmap and secret events received by the informers of Reloader.",
+		},
+		[]string{"resource", "event"},
+	)
+
+	reloadedByWorkload := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "reload_executed_by_workload_total",
+			Help:      "Counter of reloads executed by Reloader, by workload and the resourceVersion of the changed source.",
+		},
+		[]string{"namespace", "kind", "name", "source_type", "source_name", "source_resource_version"},
+	)
+
+	annotationConflicts := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "annotation_conflicts_total",
+			Help:      "Counter of reloads of workloads with conflicting reload annotations.",
+		},
+		[]string{"conflict"},
+	)
+
+	rollouts := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "rollouts_total",
+			Help:      "Counter of monitored rollouts of workloads reloaded by Reloader, by namespace, kind and result.",
+		},
+		[]string{"namespace", "kind", "result"},
+	)
+
+	queueDepth := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "reloader",
+			Name:      "queue_depth",
+			Help:      "Number of changes waiting in the queues of Reloader, by resource and namespace of the informer.",
+		},
+		[]string{"resource", "namespace"},
+	)
+
+	retries := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "retries_total",
+			Help:      "Counter of changes whose workload updates failed with a transient error, by resource and whether they are retried or dropped.",
+		},
+		[]string{"resource", "result"},
+	)
+
+	conflictRetries := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "conflict_retries_total",
+			Help:      "Counter of workload updates retried by Reloader after conflicting with concurrent changes, by kind of the workload.",
+		},
+		[]string{"kind"},
+	)
+
+	notificationsDropped := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "reloader",
+			Name:      "notifications_dropped_total",
+			Help:      "Counter of notifications of reloads dropped by Reloader, by sink and reason.",
+		},
+		[]string{"sink", "reason"},
+	)
+
 	return Collectors{
-		Reloaded: reloaded,
+		Changes:              changes,
+		Reloaded:             reloaded,
+		Skipped:              skipped,
+		ReloadedByTeam:       reloadedByTeam,
+		ReloadedByResource:   reloadedByResource,
+		Events:               events,
+		ReloadedByWorkload:   reloadedByWorkload,
+		AnnotationConflicts:  annotationConflicts,
+		Rollouts:             rollouts,
+		QueueDepth:           queueDepth,
+		Retries:              retries,
+		ConflictRetries:      conflictRetries,
+		NotificationsDropped: notificationsDropped,
 	}
 }
 
-func SetupPrometheusEndpoint() Collectors {
+// CountReload counts an executed or failed reload of a workload of the kind in the namespace, caused by
+// a change of a source of the type
+func (c Collectors) CountReload(namespace string, kind string, sourceType string, success bool) {
+	value := strconv.FormatBool(success)
+	c.Reloaded.With(prometheus.Labels{"success": value}).Inc()
+	c.ReloadedByResource.With(prometheus.Labels{
+		"success":     value,
+		"namespace":   namespace,
+		"kind":        kind,
+		"source_type": sourceType,
+	}).Inc()
+}
+
+// CountEvent counts an event of the informer of the resource, e.g. "configMaps" and "update"
+func (c Collectors) CountEvent(resource string, event string) {
+	c.Events.With(prometheus.Labels{"resource": resource, "event": event}).Inc()
+}
+
+// CountRollout counts a monitored rollout of a reloaded workload of the kind in the namespace with the
+// result, RolloutSucceeded or RolloutFailed
+func (c Collectors) CountRollout(namespace string, kind string, result string) {
+	c.Rollouts.With(prometheus.Labels{"namespace": namespace, "kind": kind, "result": result}).Inc()
+}
+
+// SetQueueDepth sets the number of changes waiting in the queue of the informer of the resource in the
+// namespace, all namespaces if empty
+func (c Collectors) SetQueueDepth(resource string, namespace string, depth int) {
+	c.QueueDepth.With(prometheus.Labels{"resource": resource, "namespace": namespace}).Set(float64(depth))
+}
+
+// CountRetry counts a failed change of the resource with the result, RetryRetried, RetryDropped or
+// RetrySuperseded
+func (c Collectors) CountRetry(resource string, result string) {
+	c.Retries.With(prometheus.Labels{"resource": resource, "result": result}).Inc()
+}
+
+// CountConflictRetry counts a retried update of a workload of the kind that conflicted with a concurrent
+// change
+func (c Collectors) CountConflictRetry(kind string) {
+	c.ConflictRetries.With(prometheus.Labels{"kind": kind}).Inc()
+}
+
+func SetupPrometheusEndpoint(addr string, path string) Collectors {
 	collectors := NewCollectors()
+	prometheus.MustRegister(collectors.Changes)
 	prometheus.MustRegister(collectors.Reloaded)
+	prometheus.MustRegister(collectors.Skipped)
+	prometheus.MustRegister(collectors.ReloadedByTeam)
+	prometheus.MustRegister(collectors.ReloadedByResource)
+	prometheus.MustRegister(collectors.Events)
+	prometheus.MustRegister(collectors.ReloadedByWorkload)
+	prometheus.MustRegister(collectors.AnnotationConflicts)
+	prometheus.MustRegister(collectors.Rollouts)
+	prometheus.MustRegister(collectors.QueueDepth)
+	prometheus.MustRegister(collectors.Retries)
+	prometheus.MustRegister(collectors.ConflictRetries)
+	prometheus.MustRegister(collectors.NotificationsDropped)
 
+	server := newMetricsServer(addr, path)
+	if server == nil {
+		logrus.Info("Metrics endpoint is disabled")
+		return collectors
+	}
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		logrus.Fatal(http.ListenAndServe(":9090", nil))
+		logrus.Fatal(server.ListenAndServe())
 	}()
 
 	return collectors
 }
+
+// newMetricsServer returns the server exposing the registered metrics at the path on the address,
+// along with the health and readiness of Reloader, its log level and, if enabled, its diagnostics, or
+// nil if the address is empty
+func newMetricsServer(addr string, path string) *http.Server {
+	if addr == "" {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, promhttp.Handler())
+	health.Default.Register(mux)
+	logging.Register(mux)
+	diagnostics.Register(mux)
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/health"
+)
+
+func TestMetricsServerDisabled(t *testing.T) {
+	if server := newMetricsServer("", "/metrics"); server != nil {
+		t.Errorf("Expected no metrics server for an empty address, got one listening on %s", server.Addr)
+	}
+}
+
+func TestMetricsServerServesConfiguredAddressAndPath(t *testing.T) {
+	// reserve a free port on the loopback interface
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server := newMetricsServer(addr, "/internal/metrics")
+	go server.ListenAndServe()
+	defer server.Close()
+
+	var response *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if response, err = http.Get("http://" + addr + "/internal/metrics"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Metrics server is not listening on %s: %v", addr, err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || !strings.Contains(string(body), "go_goroutines") {
+		t.Errorf("Expected metrics at the configured path, got status %d", response.StatusCode)
+	}
+
+	response, err = http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to get the default path: %v", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected no metrics at the default path, got status %d", response.StatusCode)
+	}
+}
+
+func TestMetricsServerServesHealthAndReadiness(t *testing.T) {
+	server := newMetricsServer(":0", "/metrics")
+	for _, path := range []string{health.HealthPath, health.ReadinessPath} {
+		recorder := httptest.NewRecorder()
+		server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code == http.StatusNotFound {
+			t.Errorf("Expected %s to be served along with the metrics", path)
+		}
+	}
+}
+
+func TestCountReloadCountsByResource(t *testing.T) {
+	collectors := NewCollectors()
+	collectors.CountReload("payments", "Deployment", "CONFIGMAP", true)
+	collectors.CountReload("payments", "Deployment", "CONFIGMAP", false)
+	collectors.CountReload("orders", "StatefulSet", "SECRET", true)
+
+	reloaded := counterValues(collectors.Reloaded, "success")
+	if reloaded["true"] != 2 || reloaded["false"] != 1 {
+		t.Errorf("Unexpected reloads %v", reloaded)
+	}
+	byNamespace := counterValues(collectors.ReloadedByResource, "namespace")
+	if byNamespace["payments"] != 2 || byNamespace["orders"] != 1 {
+		t.Errorf("Unexpected reloads by namespace %v", byNamespace)
+	}
+	byKind := counterValues(collectors.ReloadedByResource, "kind")
+	if byKind["Deployment"] != 2 || byKind["StatefulSet"] != 1 {
+		t.Errorf("Unexpected reloads by kind %v", byKind)
+	}
+	bySource := counterValues(collectors.ReloadedByResource, "source_type")
+	if bySource["CONFIGMAP"] != 2 || bySource["SECRET"] != 1 {
+		t.Errorf("Unexpected reloads by source type %v", bySource)
+	}
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Summary sums up the changes Reloader processed, e.g. to report them at shutdown
+type Summary struct {
+	Changes  float64
+	Reloads  float64
+	Failures float64
+	Skipped  map[string]float64
+}
+
+// Summarize reads the summary from the collectors
+func (c Collectors) Summarize() Summary {
+	reloaded := counterValues(c.Reloaded, "success")
+	return Summary{
+		Changes:  counterValue(c.Changes),
+		Reloads:  reloaded["true"],
+		Failures: reloaded["false"],
+		Skipped:  counterValues(c.Skipped, "reason"),
+	}
+}
+
+func (s Summary) String() string {
+	reasons := make([]string, 0, len(s.Skipped))
+	for reason := range s.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	skipped := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		skipped = append(skipped, fmt.Sprintf("%s=%v", reason, s.Skipped[reason]))
+	}
+	if len(skipped) == 0 {
+		skipped = append(skipped, "none")
+	}
+	return fmt.Sprintf("%v changes processed, %v reloads applied, %v reloads failed, skipped: %s", s.Changes, s.Reloads, s.Failures, strings.Join(skipped, ", "))
+}
+
+func counterValue(counter prometheus.Counter) float64 {
+	var metric dto.Metric
+	if err := counter.Write(&metric); err != nil {
+		return 0
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// counterValues returns the values of the counters of the vector by the value of the label
+func counterValues(vector *prometheus.CounterVec, label string) map[string]float64 {
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		vector.Collect(metrics)
+		close(metrics)
+	}()
+
+	values := make(map[string]float64)
+	for m := range metrics {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			continue
+		}
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == label {
+				values[pair.GetValue()] += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSummarize(t *testing.T) {
+	collectors := NewCollectors()
+	if summary := collectors.Summarize().String(); summary != "0 changes processed, 0 reloads applied, 0 reloads failed, skipped: none" {
+		t.Errorf("Unexpected summary without events: %s", summary)
+	}
+
+	// three changes, fanning out to four reloads of which one fails, and two skipped reloads
+	for i := 0; i < 3; i++ {
+		collectors.Changes.Inc()
+	}
+	for _, success := range []string{"true", "true", "false", "true"} {
+		collectors.Reloaded.With(prometheus.Labels{"success": success}).Inc()
+	}
+	collectors.Skipped.With(prometheus.Labels{"reason": SkipReasonObserveOnly}).Inc()
+	collectors.Skipped.With(prometheus.Labels{"reason": SkipReasonIgnoredManager}).Inc()
+
+	summary := collectors.Summarize()
+	if summary.Changes != 3 || summary.Reloads != 3 || summary.Failures != 1 {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if summary.Skipped[SkipReasonObserveOnly] != 1 || summary.Skipped[SkipReasonIgnoredManager] != 1 {
+		t.Errorf("Unexpected skipped reloads %v", summary.Skipped)
+	}
+	expected := "3 changes processed, 3 reloads applied, 1 reloads failed, skipped: ignored-manager=1, observe-only=1"
+	if summary.String() != expected {
+		t.Errorf("Expected summary %q, got %q", expected, summary.String())
+	}
+}
@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"sync"
+	"time"
+)
+
+// Digest lists the workloads reloaded for a change of a source, summarized by a single notification
+type Digest struct {
+	Workloads []Workload `json:"workloads"`
+}
+
+// Workload is a workload of a digest and the error of its reload, if it failed
+type Workload struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// digestKey identifies the notifications of the reloads for a change of a source in a namespace
+type digestKey struct {
+	namespace  string
+	sourceType string
+	sourceName string
+	result     string
+}
+
+// DigestNotifier aggregates the notifications of the reloads for a change of a source in a namespace,
+// with the same result, into a single notification of its Notifier. The digest is sent Window after
+// the first of its notifications, a single notification is sent as it is
+type DigestNotifier struct {
+	Notifier Notifier
+	Window   time.Duration
+
+	lock    sync.Mutex
+	pending map[digestKey]*Notification
+}
+
+// NewDigestNotifier returns a notifier sending the notifications of the reloads for a change of a
+// source to the notifier as digests, once per window
+func NewDigestNotifier(notifier Notifier, window time.Duration) *DigestNotifier {
+	return &DigestNotifier{Notifier: notifier, Window: window, pending: make(map[digestKey]*Notification)}
+}
+
+// Notify adds the notification to the pending digest of its source change, or starts one
+func (n *DigestNotifier) Notify(notification Notification) {
+	key := digestKey{namespace: notification.Namespace, sourceType: notification.SourceType, sourceName: notification.SourceName, result: notification.Result}
+	workload := Workload{Kind: notification.Kind, Name: notification.Name, Error: notification.Error}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if digest, found := n.pending[key]; found {
+		digest.Digest.Workloads = append(digest.Digest.Workloads, workload)
+		return
+	}
+	notification.Digest = &Digest{Workloads: []Workload{workload}}
+	n.pending[key] = &notification
+	time.AfterFunc(n.Window, func() {
+		n.send(key)
+	})
+}
+
+// send notifies the Notifier of the pending digest of the key
+func (n *DigestNotifier) send(key digestKey) {
+	n.lock.Lock()
+	notification := n.pending[key]
+	delete(n.pending, key)
+	n.lock.Unlock()
+
+	if len(notification.Digest.Workloads) == 1 {
+		notification.Digest = nil
+	} else {
+		// the workloads and their errors are listed in the digest
+		notification.Kind = ""
+		notification.Name = ""
+		notification.Error = ""
+	}
+	n.Notifier.Notify(*notification)
+}
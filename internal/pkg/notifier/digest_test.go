@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// channelNotifier sends the notifications to its channel
+type channelNotifier chan Notification
+
+func (n channelNotifier) Notify(notification Notification) {
+	n <- notification
+}
+
+func TestDigestNotifierSummarizesFanOutOfSourceChange(t *testing.T) {
+	notifications := make(channelNotifier, 10)
+	notifier := NewDigestNotifier(notifications, 50*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		notifier.Notify(Notification{Namespace: "payments", Kind: "Deployment", Name: fmt.Sprintf("api-%d", i), SourceType: "SECRET", SourceName: "db", Result: Reloaded})
+	}
+	notifier.Notify(Notification{Namespace: "payments", Kind: "Deployment", Name: "worker", SourceType: "CONFIGMAP", SourceName: "queues", Result: Reloaded})
+
+	received := map[string]Notification{}
+	for i := 0; i < 2; i++ {
+		select {
+		case notification := <-notifications:
+			received[notification.SourceName] = notification
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 2 notifications, got %d", len(received))
+		}
+	}
+	select {
+	case notification := <-notifications:
+		t.Errorf("Expected no more notifications, got %+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	digest := received["db"]
+	if digest.Digest == nil || len(digest.Digest.Workloads) != 5 || digest.Name != "" {
+		t.Errorf("Expected a digest of the 5 reloaded workloads, got %+v", digest)
+	}
+	if single := received["queues"]; single.Digest != nil || single.Name != "worker" {
+		t.Errorf("Expected the single reload to be notified as it is, got %+v", single)
+	}
+
+	var message bytes.Buffer
+	if err := template.Must(template.New("slack").Parse(DefaultSlackTemplate)).Execute(&message, digest); err != nil {
+		t.Fatalf("Failed to render the digest: %v", err)
+	}
+	if expected := "5 workloads in namespace payments restarted due to Secret db change"; message.String() != expected {
+		t.Errorf("Expected the message %q, got %q", expected, message.String())
+	}
+}
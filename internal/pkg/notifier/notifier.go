@@ -0,0 +1,148 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Reloaded is the result of a reload that was performed
+	Reloaded = "reloaded"
+	// Failed is the result of a reload that failed
+	Failed = "failed"
+	// RolloutSucceeded is the result of a monitored rollout of a reloaded workload that completed
+	RolloutSucceeded = "rollout-succeeded"
+	// RolloutFailed is the result of a monitored rollout of a reloaded workload that failed or timed out
+	RolloutFailed = "rollout-failed"
+)
+
+// Notification describes a reload of a workload and the change of the configmap or secret causing it
+type Notification struct {
+	Time       time.Time `json:"time"`
+	Namespace  string    `json:"namespace"`
+	Kind       string    `json:"kind"`
+	Name       string    `json:"name"`
+	SourceType string    `json:"sourceType"`
+	SourceName string    `json:"sourceName"`
+	// SourceOwnerKind and SourceOwnerName name the controller of the source, e.g. the SealedSecret a
+	// secret was unsealed from, if it has one
+	SourceOwnerKind string `json:"sourceOwnerKind,omitempty"`
+	SourceOwnerName string `json:"sourceOwnerName,omitempty"`
+	Result          string `json:"result"`
+	Error           string `json:"error,omitempty"`
+	// Digest lists the workloads of a digest of the reloads for a change of the source, instead of
+	// Kind, Name and Error
+	Digest *Digest `json:"digest,omitempty"`
+}
+
+// Notifier sends notifications of reloads to an external sink
+type Notifier interface {
+	// Notify sends the notification, failures are logged
+	Notify(notification Notification)
+}
+
+// Notifiers notifies each of its notifiers
+type Notifiers []Notifier
+
+// Notify notifies each notifier in turn
+func (n Notifiers) Notify(notification Notification) {
+	for _, notifier := range n {
+		notifier.Notify(notification)
+	}
+}
+
+// WebhookNotifier posts notifications as JSON to each of its URLs, retrying failed posts
+type WebhookNotifier struct {
+	URLs []string
+	// Retries is the number of times a failed post is retried, waiting RetryInterval in between
+	Retries       int
+	RetryInterval time.Duration
+	Client        *http.Client
+}
+
+// NewWebhookNotifier returns a notifier posting to the URLs. The server certificates are verified
+// with the CA certificates in the PEM file at caFile, or the system ones if empty, unless
+// insecureSkipVerify is set
+func NewWebhookNotifier(urls []string, retries int, retryInterval time.Duration, caFile string, insecureSkipVerify bool) (*WebhookNotifier, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	return &WebhookNotifier{
+		URLs:          urls,
+		Retries:       retries,
+		RetryInterval: retryInterval,
+		Client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+		},
+	}, nil
+}
+
+// Notify posts the notification to each URL
+func (n *WebhookNotifier) Notify(notification Notification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		logrus.Errorf("Unable to encode notification: %v", err)
+		return
+	}
+	for _, url := range n.URLs {
+		for attempt := 0; ; attempt++ {
+			err = n.post(url, body)
+			if err == nil || attempt >= n.Retries {
+				break
+			}
+			time.Sleep(n.RetryInterval)
+		}
+		if err != nil {
+			logrus.Errorf("Failed to notify %s of the reload of '%s' of type '%s' in namespace '%s': %v", url, notification.Name, notification.Kind, notification.Namespace, err)
+		}
+	}
+}
+
+// Send posts the notification to each URL once, returning the error of the last failed post
+func (n *WebhookNotifier) Send(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		logrus.Errorf("Unable to encode notification: %v", err)
+		return nil
+	}
+	var failed error
+	for _, url := range n.URLs {
+		if err := n.post(url, body); err != nil {
+			failed = fmt.Errorf("%s: %v", url, err)
+		}
+	}
+	return failed
+}
+
+func (n *WebhookNotifier) post(url string, body []byte) error {
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifierRetriesFailedPosts(t *testing.T) {
+	var lock sync.Mutex
+	posts := 0
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		posts++
+		if posts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Invalid notification: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	notification := Notification{
+		Time:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Namespace:  "default",
+		Kind:       "Deployment",
+		Name:       "payments",
+		SourceType: "CONFIGMAP",
+		SourceName: "payments-config",
+		Result:     Reloaded,
+	}
+	notifier := &WebhookNotifier{URLs: []string{server.URL}, Retries: 2, RetryInterval: time.Millisecond}
+	notifier.Notify(notification)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if posts != 2 {
+		t.Errorf("Expected the failed post to be retried once, got %d posts", posts)
+	}
+	if received != notification {
+		t.Errorf("Expected notification %+v, got %+v", notification, received)
+	}
+}
+
+func TestNewWebhookNotifierRejectsCAFileWithoutCertificates(t *testing.T) {
+	if _, err := NewWebhookNotifier([]string{"https://example.com"}, 0, 0, "notifier_test.go", false); err == nil {
+		t.Errorf("CA file without certificates was accepted")
+	}
+}
@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DropBufferFull is the reason of notifications dropped as the buffer of their sink is full
+	DropBufferFull = "buffer-full"
+	// DropCircuitOpen is the reason of notifications dropped while the circuit breaker of their sink
+	// is open
+	DropCircuitOpen = "circuit-open"
+	// DropRetriesExhausted is the reason of notifications dropped as all retries to send them failed
+	DropRetriesExhausted = "retries-exhausted"
+)
+
+// Sender sends a notification to a sink once, returning why it failed
+type Sender interface {
+	Send(notification Notification) error
+}
+
+// SinkOptions configure the retries, the buffer and the circuit breaker of a SinkNotifier
+type SinkOptions struct {
+	// Retries is the number of times a failed send is retried, waiting RetryBaseDelay doubled for
+	// each retry up to RetryMaxDelay in between
+	Retries        int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// BufferSize is the number of notifications waiting to be sent, further ones are dropped
+	BufferSize int
+	// BreakerThreshold is the number of notifications in a row whose retries failed that opens the
+	// circuit breaker, which is never opened if not positive
+	BreakerThreshold int
+	// BreakerCooldown is the time the circuit breaker stays open. The next notification after it is
+	// sent once, closing the breaker if it succeeds and opening it again otherwise
+	BreakerCooldown time.Duration
+}
+
+// SinkNotifier sends the notifications to its sink in the background, from a bounded buffer, retrying
+// failed sends with exponential backoff. A sink failing persistently opens its circuit breaker, which
+// drops the notifications without sending them until its cooldown ends
+type SinkNotifier struct {
+	// Name names the sink in logs and metrics
+	Name    string
+	Sender  Sender
+	Options SinkOptions
+	// Dropped counts the dropped notifications by sink and reason, if set
+	Dropped *prometheus.CounterVec
+
+	buffer    chan Notification
+	lock      sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// NewSinkNotifier returns a notifier sending to the sender with the options, and starts sending
+func NewSinkNotifier(name string, sender Sender, options SinkOptions, dropped *prometheus.CounterVec) *SinkNotifier {
+	if options.BufferSize < 1 {
+		options.BufferSize = 1
+	}
+	n := &SinkNotifier{
+		Name:    name,
+		Sender:  sender,
+		Options: options,
+		Dropped: dropped,
+		buffer:  make(chan Notification, options.BufferSize),
+	}
+	go n.run()
+	return n
+}
+
+// Notify buffers the notification to be sent, or drops it if the buffer is full or the circuit
+// breaker is open
+func (n *SinkNotifier) Notify(notification Notification) {
+	if _, open := n.breakerOpen(); open {
+		n.drop(notification, DropCircuitOpen)
+		return
+	}
+	select {
+	case n.buffer <- notification:
+	default:
+		n.drop(notification, DropBufferFull)
+	}
+}
+
+func (n *SinkNotifier) run() {
+	for notification := range n.buffer {
+		n.send(notification)
+	}
+}
+
+// send sends the notification with retries, or only once to probe the sink once the cooldown of the
+// open circuit breaker ended
+func (n *SinkNotifier) send(notification Notification) {
+	probe, open := n.breakerOpen()
+	if open {
+		n.drop(notification, DropCircuitOpen)
+		return
+	}
+	retries := n.Options.Retries
+	if probe {
+		retries = 0
+	}
+	delay := n.Options.RetryBaseDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = n.Sender.Send(notification); err == nil || attempt >= retries {
+			break
+		}
+		time.Sleep(delay)
+		if delay *= 2; n.Options.RetryMaxDelay > 0 && delay > n.Options.RetryMaxDelay {
+			delay = n.Options.RetryMaxDelay
+		}
+	}
+	n.record(err)
+	if err != nil {
+		logrus.Errorf("Failed to notify %s of the reload of '%s' of type '%s' in namespace '%s': %v", n.Name, notification.Name, notification.Kind, notification.Namespace, err)
+		n.drop(notification, DropRetriesExhausted)
+	}
+}
+
+// breakerOpen checks whether the circuit breaker is open, and whether its cooldown ended so the next
+// notification probes the sink
+func (n *SinkNotifier) breakerOpen() (probe bool, open bool) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.openUntil.IsZero() {
+		return false, false
+	}
+	if time.Now().Before(n.openUntil) {
+		return false, true
+	}
+	return true, false
+}
+
+// record closes the circuit breaker once a notification was sent, and opens it once BreakerThreshold
+// notifications in a row failed or a probe failed
+func (n *SinkNotifier) record(err error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if err == nil {
+		if !n.openUntil.IsZero() {
+			logrus.Infof("Notifier %s recovered, closing its circuit breaker", n.Name)
+		}
+		n.failures = 0
+		n.openUntil = time.Time{}
+		return
+	}
+	n.failures++
+	if n.Options.BreakerThreshold > 0 && (n.failures >= n.Options.BreakerThreshold || !n.openUntil.IsZero()) {
+		logrus.Warnf("Notifier %s failed %d times in a row, dropping its notifications for %s", n.Name, n.failures, n.Options.BreakerCooldown)
+		n.openUntil = time.Now().Add(n.Options.BreakerCooldown)
+	}
+}
+
+func (n *SinkNotifier) drop(notification Notification, reason string) {
+	logrus.Debugf("Dropping the notification of the reload of '%s' of type '%s' in namespace '%s' for %s: %s", notification.Name, notification.Kind, notification.Namespace, n.Name, reason)
+	if n.Dropped != nil {
+		n.Dropped.With(prometheus.Labels{"sink": n.Name, "reason": reason}).Inc()
+	}
+}
@@ -0,0 +1,134 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakySender fails to send while it is unhealthy and passes the sent notifications to its channel
+type flakySender struct {
+	lock     sync.Mutex
+	healthy  bool
+	attempts int
+	sent     chan Notification
+}
+
+func (s *flakySender) Send(notification Notification) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attempts++
+	if !s.healthy {
+		return fmt.Errorf("unavailable")
+	}
+	s.sent <- notification
+	return nil
+}
+
+func (s *flakySender) setHealthy(healthy bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.healthy = healthy
+}
+
+func (s *flakySender) attempted() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.attempts
+}
+
+func newDroppedCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "dropped"}, []string{"sink", "reason"})
+}
+
+func waitFor(t *testing.T, condition func() bool, message string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSinkNotifierTripsBreakerAndRecovers(t *testing.T) {
+	sender := &flakySender{sent: make(chan Notification, 10)}
+	dropped := newDroppedCounter()
+	notifier := NewSinkNotifier("webhook-1", sender, SinkOptions{
+		Retries:          2,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    2 * time.Millisecond,
+		BufferSize:       10,
+		BreakerThreshold: 2,
+		BreakerCooldown:  100 * time.Millisecond,
+	}, dropped)
+	droppedFor := func(reason string) float64 {
+		return promtestutil.ToFloat64(dropped.With(prometheus.Labels{"sink": "webhook-1", "reason": reason}))
+	}
+
+	// two notifications in a row fail all their retries and open the breaker
+	for i := 1; i <= 2; i++ {
+		notifier.Notify(Notification{Name: fmt.Sprintf("api-%d", i)})
+		waitFor(t, func() bool { return droppedFor(DropRetriesExhausted) == float64(i) }, "Failed notification was not dropped after its retries")
+	}
+	if attempts := sender.attempted(); attempts != 6 {
+		t.Errorf("Expected each notification to be sent once and retried twice, got %d attempts", attempts)
+	}
+	notifier.Notify(Notification{Name: "api-3"})
+	if droppedFor(DropCircuitOpen) != 1 {
+		t.Errorf("Notification was not dropped while the breaker is open")
+	}
+	if attempts := sender.attempted(); attempts != 6 {
+		t.Errorf("Sink was called while the breaker is open, got %d attempts", attempts)
+	}
+
+	// once the cooldown ended, a notification to the healed sink closes the breaker
+	sender.setHealthy(true)
+	time.Sleep(150 * time.Millisecond)
+	for _, name := range []string{"api-4", "api-5"} {
+		notifier.Notify(Notification{Name: name})
+		select {
+		case notification := <-sender.sent:
+			if notification.Name != name {
+				t.Errorf("Expected notification %s to be sent, got %s", name, notification.Name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Notification %s was not sent after the sink recovered", name)
+		}
+	}
+	if droppedFor(DropCircuitOpen) != 1 || droppedFor(DropRetriesExhausted) != 2 {
+		t.Errorf("Notifications were dropped after the sink recovered")
+	}
+}
+
+// blockingSender blocks sending until it is released
+type blockingSender struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingSender) Send(notification Notification) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func TestSinkNotifierDropsNotificationsBeyondItsBuffer(t *testing.T) {
+	sender := &blockingSender{started: make(chan struct{}, 10), release: make(chan struct{})}
+	defer close(sender.release)
+	dropped := newDroppedCounter()
+	notifier := NewSinkNotifier("slack", sender, SinkOptions{BufferSize: 1}, dropped)
+
+	notifier.Notify(Notification{Name: "api-1"})
+	<-sender.started
+	// the second notification waits in the buffer, the third one does not fit
+	notifier.Notify(Notification{Name: "api-2"})
+	notifier.Notify(Notification{Name: "api-3"})
+	if count := promtestutil.ToFloat64(dropped.With(prometheus.Labels{"sink": "slack", "reason": DropBufferFull})); count != 1 {
+		t.Errorf("Expected one notification to be dropped as the buffer is full, got %v", count)
+	}
+}
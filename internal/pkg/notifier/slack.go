@@ -0,0 +1,189 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+)
+
+const (
+	// DefaultSlackTemplate is the template of the Slack messages, unless another one is given
+	DefaultSlackTemplate = `{{if .Digest}}{{len .Digest.Workloads}} workloads{{else}}{{.Kind}} {{.Name}}{{end}} in namespace {{.Namespace}} {{if eq .Result "failed"}}failed to restart{{else if eq .Result "rollout-failed"}}failed to roll out{{else if eq .Result "rollout-succeeded"}}rolled out{{else}}restarted{{end}} due to {{.SourceKind}} {{.SourceName}}{{if .SourceOwnerName}} ({{.SourceOwnerKind}} {{.SourceOwnerName}}){{end}} change`
+	// slackPostMessageURL is the Slack API method posting messages with a bot token
+	slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+)
+
+// SourceKind returns the kind of the changed source, e.g. "ConfigMap"
+func (n Notification) SourceKind() string {
+	switch n.SourceType {
+	case constants.ConfigmapEnvVarPostfix:
+		return "ConfigMap"
+	case constants.SecretEnvVarPostfix:
+		return "Secret"
+	case constants.SecretProviderClassEnvVarPostfix:
+		return "SecretProviderClass"
+	case constants.ExternalSecretEnvVarPostfix:
+		return "ExternalSecret"
+	case constants.CertificateEnvVarPostfix:
+		return "Certificate"
+	case constants.VaultEnvVarPostfix:
+		return "Vault path"
+	case constants.AWSSecretEnvVarPostfix:
+		return "AWS secret"
+	case constants.GCPSecretEnvVarPostfix:
+		return "GCP secret"
+	case constants.AzureSecretEnvVarPostfix:
+		return "Azure secret"
+	case constants.ConsulKeyEnvVarPostfix:
+		return "Consul key"
+	}
+	return strings.ToLower(n.SourceType)
+}
+
+// SlackNotifier posts notifications as messages to Slack, with an incoming webhook or a bot token.
+// It posts at most one message per MinInterval, the notifications in between are dropped and
+// counted in the next message
+type SlackNotifier struct {
+	// WebhookURL is the incoming webhook messages are posted to, the bot token is used if empty
+	WebhookURL string
+	// Token is the bot token posting messages to Channel
+	Token   string
+	Channel string
+	// APIURL is the Slack API method posting messages with the token, the public one if empty
+	APIURL      string
+	Template    *template.Template
+	MinInterval time.Duration
+	Client      *http.Client
+
+	lock       sync.Mutex
+	lastPosted time.Time
+	dropped    int
+}
+
+// NewSlackNotifier returns a notifier posting messages from the template text, which is executed
+// with the Notification, to the webhook URL or, if empty, with the token to the channel
+func NewSlackNotifier(webhookURL string, token string, channel string, templateText string, minInterval time.Duration) (*SlackNotifier, error) {
+	if webhookURL == "" && (token == "" || channel == "") {
+		return nil, fmt.Errorf("either a webhook URL or a token and a channel are required")
+	}
+	if templateText == "" {
+		templateText = DefaultSlackTemplate
+	}
+	messageTemplate, err := template.New("slack").Parse(templateText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %v", err)
+	}
+	return &SlackNotifier{
+		WebhookURL:  webhookURL,
+		Token:       token,
+		Channel:     channel,
+		Template:    messageTemplate,
+		MinInterval: minInterval,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Notify posts the message of the notification, unless a message was posted within MinInterval
+func (n *SlackNotifier) Notify(notification Notification) {
+	if err := n.Send(notification); err != nil {
+		logrus.Errorf("Failed to post the Slack message of the reload of '%s' of type '%s' in namespace '%s': %v", notification.Name, notification.Kind, notification.Namespace, err)
+	}
+}
+
+// Send posts the message of the notification once, unless a message was posted within MinInterval.
+// The notifications dropped before a failed post are counted in the next message
+func (n *SlackNotifier) Send(notification Notification) error {
+	var message bytes.Buffer
+	if err := n.Template.Execute(&message, notification); err != nil {
+		logrus.Errorf("Unable to render the Slack message of the reload of '%s' of type '%s' in namespace '%s': %v", notification.Name, notification.Kind, notification.Namespace, err)
+		return nil
+	}
+
+	n.lock.Lock()
+	now := time.Now()
+	if !n.lastPosted.IsZero() && now.Sub(n.lastPosted) < n.MinInterval {
+		n.dropped++
+		n.lock.Unlock()
+		return nil
+	}
+	lastPosted, dropped := n.lastPosted, n.dropped
+	n.lastPosted = now
+	n.dropped = 0
+	n.lock.Unlock()
+
+	if dropped > 0 {
+		fmt.Fprintf(&message, " (and %d more reloads)", dropped)
+	}
+	err := n.post(message.String())
+	if err != nil {
+		// the failed post is retried without waiting for MinInterval
+		n.lock.Lock()
+		if n.lastPosted.Equal(now) {
+			n.lastPosted = lastPosted
+		}
+		n.dropped += dropped
+		n.lock.Unlock()
+	}
+	return err
+}
+
+func (n *SlackNotifier) post(text string) error {
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	url := n.WebhookURL
+	payload := map[string]string{"text": text}
+	if url == "" {
+		url = n.APIURL
+		if url == "" {
+			url = slackPostMessageURL
+		}
+		payload["channel"] = n.Channel
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if n.WebhookURL == "" {
+		request.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	if n.WebhookURL != "" {
+		return nil
+	}
+	// the API reports errors in the body of successful responses
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack API error %s", result.Error)
+	}
+	return nil
+}
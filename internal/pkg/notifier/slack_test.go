@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slackServer records the payloads and authorization headers of the posted messages
+func slackServer(t *testing.T, payloads *[]map[string]string, authorizations *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Invalid message: %v", err)
+		}
+		*payloads = append(*payloads, payload)
+		*authorizations = append(*authorizations, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"ok":true}`))
+	}))
+}
+
+func TestSlackNotifierRateLimitsMessages(t *testing.T) {
+	var payloads []map[string]string
+	var authorizations []string
+	server := slackServer(t, &payloads, &authorizations)
+	defer server.Close()
+
+	notifier, err := NewSlackNotifier(server.URL, "", "", "", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+	notification := Notification{Namespace: "default", Kind: "Deployment", Name: "foo", SourceType: "SECRET", SourceName: "bar", Result: Reloaded}
+	for i := 0; i < 3; i++ {
+		notifier.Notify(notification)
+	}
+	// the interval passed, the next message counts the dropped ones
+	notifier.lastPosted = time.Now().Add(-2 * time.Hour)
+	notification.Result = Failed
+	notifier.Notify(notification)
+
+	expected := []string{
+		"Deployment foo in namespace default restarted due to Secret bar change",
+		"Deployment foo in namespace default failed to restart due to Secret bar change (and 2 more reloads)",
+	}
+	if len(payloads) != len(expected) {
+		t.Fatalf("Expected %d messages, got %v", len(expected), payloads)
+	}
+	for i, text := range expected {
+		if payloads[i]["text"] != text || authorizations[i] != "" {
+			t.Errorf("Expected message %q without authorization, got %v", text, payloads[i])
+		}
+	}
+}
+
+func TestSlackNotifierPostsWithTokenAndTemplate(t *testing.T) {
+	var payloads []map[string]string
+	var authorizations []string
+	server := slackServer(t, &payloads, &authorizations)
+	defer server.Close()
+
+	notifier, err := NewSlackNotifier("", "xoxb-token", "#platform", "{{.SourceKind}} {{.SourceName}} reloaded {{.Name}}", 0)
+	if err != nil {
+		t.Fatalf("Failed to create notifier: %v", err)
+	}
+	notifier.APIURL = server.URL
+	notifier.Notify(Notification{Kind: "StatefulSet", Name: "db", SourceType: "CONFIGMAP", SourceName: "db-config", Result: Reloaded})
+
+	if len(payloads) != 1 || payloads[0]["text"] != "ConfigMap db-config reloaded db" || payloads[0]["channel"] != "#platform" {
+		t.Errorf("Unexpected messages %v", payloads)
+	}
+	if len(authorizations) != 1 || authorizations[0] != "Bearer xoxb-token" {
+		t.Errorf("Expected the token to authorize the message, got %v", authorizations)
+	}
+}
+
+func TestNewSlackNotifierValidatesOptions(t *testing.T) {
+	if _, err := NewSlackNotifier("", "xoxb-token", "", "", 0); err == nil {
+		t.Errorf("Token without a channel was accepted")
+	}
+	if _, err := NewSlackNotifier("https://hooks.slack.com/services/x", "", "", "{{.Name", 0); err == nil {
+		t.Errorf("Invalid template was accepted")
+	}
+}
@@ -1,5 +1,7 @@
 package options
 
+import "time"
+
 var (
 	// ConfigmapUpdateOnChangeAnnotation is an annotation to detect changes in
 	// configmaps specified by name
@@ -7,6 +9,15 @@ var (
 	// SecretUpdateOnChangeAnnotation is an annotation to detect changes in
 	// secrets specified by name
 	SecretUpdateOnChangeAnnotation = "secret.reloader.stakater.com/reload"
+	// SecretProviderClassUpdateOnChangeAnnotation is an annotation to detect changes in
+	// secret provider classes of the Secrets Store CSI driver specified by name
+	SecretProviderClassUpdateOnChangeAnnotation = "secretproviderclass.reloader.stakater.com/reload"
+	// ExternalSecretUpdateOnChangeAnnotation is an annotation to detect changes in the secrets synced
+	// by external secrets of the External Secrets Operator
+	ExternalSecretUpdateOnChangeAnnotation = "externalsecret.reloader.stakater.com/reload"
+	// CertificateUpdateOnChangeAnnotation is an annotation to detect changes in the secrets issued for
+	// certificates of cert-manager
+	CertificateUpdateOnChangeAnnotation = "certificate.reloader.stakater.com/reload"
 	// ReloaderAutoAnnotation is an annotation to detect changes in secrets
 	ReloaderAutoAnnotation = "reloader.stakater.com/auto"
 	// AutoSearchAnnotation is an annotation to detect changes in
@@ -15,6 +26,385 @@ var (
 	// SearchMatchAnnotation is an annotation to tag secrets to be found with
 	// AutoSearchAnnotation
 	SearchMatchAnnotation = "reloader.stakater.com/match"
-	// LogFormat is the log format to use (json, or empty string for default)
+	// ResolveSourceFromAnnotation is an annotation to reference a configmap through an indirection,
+	// e.g. "secret/app-refs/configmap" for the configmap named by key configmap of secret app-refs
+	ResolveSourceFromAnnotation = "reloader.stakater.com/resolve-source-from"
+	// VaultPathAnnotation is an annotation to reload a workload on new versions of the secrets at
+	// the Vault KV paths it lists, e.g. secrets injected by Vault Agent
+	VaultPathAnnotation = "reloader.stakater.com/vault-path"
+	// AWSSecretAnnotation is an annotation to reload a workload on changes of the secrets of AWS
+	// Secrets Manager it lists by ARN or name, e.g. secrets fetched by the application at startup
+	AWSSecretAnnotation = "reloader.stakater.com/aws-secret"
+	// GCPSecretAnnotation is an annotation to reload a workload on new versions of the secrets of GCP
+	// Secret Manager it lists by resource name, e.g. "projects/my-project/secrets/db"
+	GCPSecretAnnotation = "reloader.stakater.com/gcp-secret"
+	// AzureSecretAnnotation is an annotation to reload a workload on new versions of the secrets of
+	// Azure Key Vault it lists by URI, e.g. "https://my-vault.vault.azure.net/secrets/db"
+	AzureSecretAnnotation = "reloader.stakater.com/azure-secret"
+	// ConsulKeyAnnotation is an annotation to reload a workload on changes of the keys of the Consul
+	// KV store it lists, e.g. "config/app/db" of configuration loaded by the application at startup
+	ConsulKeyAnnotation = "reloader.stakater.com/consul-key"
+	// ContentTypeAnnotation is an annotation to declare the values of a secret as "json" or "yaml",
+	// so they are canonicalized before hashing
+	ContentTypeAnnotation = "reloader.stakater.com/content-type"
+	// LogFormat is the log format to use (json, or text and the empty string for text)
 	LogFormat = ""
+	// LogLevel is the level of the logs, which can be changed at runtime at the log level endpoint of
+	// the metrics address or toggled to debug with SIGUSR1
+	LogLevel = "info"
+	// InstanceAnnotation is an annotation to address a workload to a specific
+	// Reloader instance
+	InstanceAnnotation = "reloader.stakater.com/instance"
+	// InstanceName is the name of this Reloader instance, matched against
+	// InstanceAnnotation
+	InstanceName = ""
+	// DefaultInstance marks this instance as the owner of workloads that are
+	// not addressed to any instance
+	DefaultInstance = false
+	// PinToRevisionAnnotation is an annotation to roll a deployment back to
+	// the pod template of the given revision instead of applying changes
+	PinToRevisionAnnotation = "reloader.stakater.com/pin-to-revision"
+	// HashSaltAnnotation is an annotation on configmaps or secrets whose value
+	// is folded into their SHA, changing it reloads all referencing workloads
+	HashSaltAnnotation = "reloader.stakater.com/hash-salt"
+	// ExternalChecksumKeyAnnotation is an annotation on configmaps or secrets
+	// naming the only key their SHA is computed from, for resources pointing
+	// at externally stored config
+	ExternalChecksumKeyAnnotation = "reloader.stakater.com/external-checksum-key"
+	// IgnoreKeysAnnotation is an annotation on configmaps or secrets listing
+	// keys left out of their SHA, e.g. timestamps written by other tools
+	IgnoreKeysAnnotation = "reloader.stakater.com/ignore-keys"
+	// IgnoreAnnotation is an annotation on configmaps or secrets whose changes
+	// never reload any workload, not even those with the auto annotation
+	IgnoreAnnotation = "reloader.stakater.com/ignore"
+	// HashAlgorithm is the algorithm the SHAs of configmaps and secrets are
+	// generated with, one of "sha256", "sha512" or the legacy "sha1"
+	HashAlgorithm = "sha256"
+	// FIPSMode restricts the HashAlgorithm to algorithms approved by FIPS
+	FIPSMode = false
+	// ObserveOnly detects and reports all rolling upgrades without ever
+	// updating a workload
+	ObserveOnly = false
+	// DryRun observes like ObserveOnly, but records the rolling upgrades that
+	// would be performed as events on the workloads
+	DryRun = false
+	// ChangeCauseAnnotation is an annotation on workloads to customize the
+	// change-cause recorded for rolling upgrades
+	ChangeCauseAnnotation = "reloader.stakater.com/change-cause"
+	// RecordChangeCause records the change-cause of rolling upgrades on all
+	// workloads so it shows up in the rollout history, not only on the
+	// workloads with the ChangeCauseAnnotation
+	RecordChangeCause = false
+	// ReloadOnSourceCreate reloads the workloads referencing a configmap or secret when it is created,
+	// e.g. workloads deployed before it that could not start properly
+	ReloadOnSourceCreate = true
+	// ReloadOnSourceDelete reloads the workloads with the ReloadOnDeleteAnnotation referencing a
+	// configmap or secret when it is deleted
+	ReloadOnSourceDelete = false
+	// WatchSecretProviderClasses watches the secret provider classes of the Secrets Store CSI driver
+	// and reloads the workloads mounting volumes of a changed one
+	WatchSecretProviderClasses = false
+	// WatchExternalSecrets watches the external secrets of the External Secrets Operator and reloads
+	// the workloads listing a refreshed one whose synced secret changed
+	WatchExternalSecrets = false
+	// WatchCertificates watches the certificates of cert-manager and reloads the workloads listing a
+	// re-issued one whose certificate changed
+	WatchCertificates = false
+	// ReloadOnDeleteAnnotation is an annotation to reload a workload when a configmap or secret it
+	// references is deleted, if ReloadOnSourceDelete is enabled
+	ReloadOnDeleteAnnotation = "reloader.stakater.com/reload-on-delete"
+	// ReconcileWorkers is the number of workers processing the configmaps and
+	// secrets found when syncing
+	ReconcileWorkers = 5
+	// SyncOnStart reloads, at startup, the workloads holding an other hash of the configmaps and
+	// secrets than their current one, having missed their changes while Reloader was down
+	SyncOnStart = true
+	// ResyncPeriod is the interval of the syncs of the workloads with all configmaps and secrets, they
+	// are not synced periodically if 0
+	ResyncPeriod time.Duration
+	// MaxConcurrentReloads is the number of workers processing the changes of each informer and the
+	// number of workloads reloaded at the same time for all changes
+	MaxConcurrentReloads = 1
+	// MinAvailableBeforeReloadAnnotation is an annotation to defer the reload of a workload
+	// until it has at least the given number of available replicas
+	MinAvailableBeforeReloadAnnotation = "reloader.stakater.com/min-available-before-reload"
+	// GuardQueryAnnotation is an annotation to defer the reload of a workload until the given
+	// PromQL query returns a value below its GuardThresholdAnnotation
+	GuardQueryAnnotation = "reloader.stakater.com/guard-query"
+	// GuardThresholdAnnotation is an annotation to set the value the guard query of a workload
+	// must return less than, 1 by default
+	GuardThresholdAnnotation = "reloader.stakater.com/guard-threshold"
+	// PrometheusURL is the URL of the Prometheus evaluating guard queries, guard queries are ignored if empty
+	PrometheusURL = ""
+	// ReloadWhenKeyAnnotation is an annotation to only reload a workload when a change of a configmap
+	// sets a key to a value, e.g. "enabled=true"
+	ReloadWhenKeyAnnotation = "reloader.stakater.com/reload-when-key"
+	// ConfigmapKeysAnnotation is an annotation to only reload a workload when the values of the
+	// listed keys of a configmap change, e.g. "app.yaml,feature-flags.json"
+	ConfigmapKeysAnnotation = "reloader.stakater.com/configmap-keys"
+	// SecretKeysAnnotation is an annotation to only reload a workload when the values of the listed
+	// keys of a secret change
+	SecretKeysAnnotation = "reloader.stakater.com/secret-keys"
+	// ConfigmapRolloverAnnotation is an annotation naming the generations of an immutable configmap of
+	// a workload by a prefix or pattern, e.g. "myconfig-", its references to older generations are
+	// replaced once a newer generation is created
+	ConfigmapRolloverAnnotation = "reloader.stakater.com/configmap-rollover"
+	// SecretRolloverAnnotation is an annotation naming the generations of an immutable secret of a
+	// workload by a prefix or pattern
+	SecretRolloverAnnotation = "reloader.stakater.com/secret-rollover"
+	// ForcePullAnnotation is an annotation to mark the rollouts of a workload reloaded by Reloader as
+	// pulling its images again, with the ForcePullMarkerAnnotation in its pod template
+	ForcePullAnnotation = "reloader.stakater.com/force-pull"
+	// ForcePullMarkerAnnotation is the pod template annotation recording the hash of the change a
+	// workload with the ForcePullAnnotation was reloaded for
+	ForcePullMarkerAnnotation = "reloader.stakater.com/force-pull-hash"
+	// PostReloadCooldownAnnotation is an annotation to defer further reloads of a workload until the
+	// given duration passed since its last reload, e.g. "10m"
+	PostReloadCooldownAnnotation = "reloader.stakater.com/post-reload-cooldown"
+	// CooldownAnnotation is a shorter alias of the PostReloadCooldownAnnotation, which wins if a workload
+	// has both
+	CooldownAnnotation = "reloader.stakater.com/cooldown"
+	// AutoRollbackAnnotation is an annotation to revert the hash Reloader injected into the pod template
+	// of a workload once the rollout of the reload fails
+	AutoRollbackAnnotation = "reloader.stakater.com/auto-rollback"
+	// ReloadSampleIntervalAnnotation is an annotation on configmaps or secrets to reload the workloads
+	// referencing them at most once per the given interval, e.g. "1m", with their latest content
+	ReloadSampleIntervalAnnotation = "reloader.stakater.com/reload-sample-interval"
+	// ReloadStrategyAnnotation is an annotation to name the strategy reloading a workload
+	ReloadStrategyAnnotation = "reloader.stakater.com/reload-strategy"
+	// NamespaceDefaultStrategyAnnotation is an annotation of namespaces to name the strategy reloading
+	// their workloads without a ReloadStrategyAnnotation, instead of the ReloadStrategy
+	NamespaceDefaultStrategyAnnotation = "reloader.stakater.com/default-strategy"
+	// LastReloadedFromAnnotation is the pod template annotation the annotations strategy records
+	// the hashes of the changes of configmaps and secrets in
+	LastReloadedFromAnnotation = "reloader.stakater.com/last-reloaded-from"
+	// ReloadStrategy is the strategy reloading workloads without a ReloadStrategyAnnotation
+	ReloadStrategy = "rolling"
+	// PodDeleteRate is the maximum number of pods per second the pod-delete strategy, and the reloads
+	// of standalone replicaSets and replicationControllers, evict, unlimited if not positive
+	PodDeleteRate = 1.0
+	// DeferReloadDuringRollout defers the reload of a workload that still rolls out a previous
+	// change until the rollout completes
+	DeferReloadDuringRollout = false
+	// DeferReloadOnDisruptionBudget defers the reload of a workload while a PodDisruptionBudget of its
+	// pods allows no disruptions
+	DeferReloadOnDisruptionBudget = false
+	// DeferReloadUntilCertificateValid defers the reloads for a changed TLS secret, e.g. a certificate
+	// re-issued by cert-manager, until its certificate becomes valid
+	DeferReloadUntilCertificateValid = false
+	// AllowedReloadWindow is the weekly window reloads are allowed in, e.g. "Mon-Fri 09:00-17:00" in
+	// UTC. Reloads outside of it are deferred until it opens, reloads are allowed at any time if empty
+	AllowedReloadWindow = ""
+	// ReloadWindowAnnotation is an annotation to restrict the reloads of a workload to a weekly
+	// window, replacing the AllowedReloadWindow for it
+	ReloadWindowAnnotation = "reloader.stakater.com/reload-window"
+	// MaxQueuedAge is the time after which deferred reloads are dropped, they are never dropped if 0
+	MaxQueuedAge time.Duration
+	// ReloadDebounce is the window starting with an update of a configmap or secret, during which
+	// further updates of it are merged into one reload, updates are handled immediately if 0
+	ReloadDebounce time.Duration
+	// ShutdownTimeout is the time Reloader drains the changes already detected before it exits on
+	// termination, the changes still queued then are dropped
+	ShutdownTimeout = 25 * time.Second
+	// APIServerUnreachableTimeout is the time after which Reloader reports to be unhealthy when it
+	// cannot contact the API server, never if 0
+	APIServerUnreachableTimeout = 2 * time.Minute
+	// MaxRetries is the number of times a change is handled again after workload updates failed with a
+	// transient error, e.g. a conflict, before it is dropped
+	MaxRetries = 5
+	// RetryBaseDelay is the delay of the first retry of a change, doubled for every further retry
+	RetryBaseDelay = time.Second
+	// RetryMaxDelay is the maximum delay of the retries of a change
+	RetryMaxDelay = 5 * time.Minute
+	// DeferredReloadRetryInterval is the interval at which deferred reloads are retried
+	DeferredReloadRetryInterval = 30 * time.Second
+	// DeferredReloadMaxRetryInterval caps the retry interval of reloads deferred during rollouts or
+	// by disruption budgets, which doubles each time they are deferred again
+	DeferredReloadMaxRetryInterval = 5 * time.Minute
+	// MonitorRollouts tracks the rollouts of reloaded workloads and reports whether they complete
+	MonitorRollouts = false
+	// RolloutCheckInterval is the interval at which monitored rollouts are checked
+	RolloutCheckInterval = 10 * time.Second
+	// RolloutTimeout is the time after which monitored rollouts that did not complete are failed
+	RolloutTimeout = 10 * time.Minute
+	// RolloutTimeouts is a list of "<kind>=<duration>" overriding the RolloutTimeout for a kind of
+	// workload, e.g. "StatefulSet=30m"
+	RolloutTimeouts = []string{}
+	// ReloadKnativeServices reloads the Knative Services referencing a changed configmap or secret in
+	// their revision template by rolling a new revision
+	ReloadKnativeServices = false
+	// ReloadScaledJobs reloads the KEDA ScaledJobs referencing a changed configmap or secret in the pod
+	// template of their job target, for the jobs they spawn next
+	ReloadScaledJobs = false
+	// ReloadArgoRollouts reloads the Argo Rollouts referencing a changed configmap or secret in their
+	// pod template at ArgoRolloutTemplatePath
+	ReloadArgoRollouts = false
+	// ArgoRolloutTemplatePath is the JSONPath of the pod template of Argo Rollouts that is reloaded, the
+	// templates of their analyses and experiments are not
+	ArgoRolloutTemplatePath = ".spec.template"
+	// CustomWorkloads is a list of "<resource>.<version>.<group>=<path>[;<references path>]" of custom
+	// resources embedding a pod template at the path, e.g.
+	// "flinkdeployments.v1beta1.flink.apache.org=.spec.podTemplate", that are reloaded like the built-in
+	// workloads, auto reload also discovering the configmaps and secrets at the references path
+	CustomWorkloads = []string{}
+	// WorkloadRegistryConfigMap is the "<namespace>/<name>", or the name in the namespace of Reloader, of
+	// a configmap listing more kinds of custom workloads like CustomWorkloads, applied on each change
+	WorkloadRegistryConfigMap = ""
+	// CacheWorkloads serves the deployments, daemonSets, statefulSets, cronJobs, replicaSets and
+	// replicationControllers from shared informers indexed by the configmaps and secrets they reference,
+	// instead of listing them from the API server for every change
+	CacheWorkloads = true
+	// PatchWorkloads writes the changes of Reloader to workloads as strategic merge patches instead of
+	// replacing the workloads, so concurrent changes of other fields are kept
+	PatchWorkloads = true
+	// MetricsAddr is the address the metrics endpoint listens on, metrics are not served if empty
+	MetricsAddr = ":9090"
+	// MetricsPath is the path metrics are served at
+	MetricsPath = "/metrics"
+	// EnablePprof serves the pprof handlers at /debug/pprof/ and the runtime variables, e.g. the lengths
+	// of the queues and the sizes of the caches, at /debug/vars along with the metrics
+	EnablePprof = false
+	// OTLPEndpoint is the OTLP/HTTP endpoint the spans of the reload pipeline are exported to, from the
+	// informer event to the patch of the workloads, tracing is disabled if empty
+	OTLPEndpoint = ""
+	// TeamLabel is the workload label whose value reloads are counted by, counting is disabled if empty
+	TeamLabel = ""
+	// DetailedMetrics enables the per-workload reload counter, labelled with the resourceVersion
+	// of the changed source
+	DetailedMetrics = false
+	// RequireDiscovery makes Reloader exit if the environment cannot be detected at startup,
+	// instead of assuming Kubernetes and retrying the detection in the background
+	RequireDiscovery = false
+	// TriggerURL is the endpoint polled for trigger messages naming workloads to reload, none are
+	// polled if empty
+	TriggerURL = ""
+	// TriggerPollInterval is the interval at which the TriggerURL is polled
+	TriggerPollInterval = 30 * time.Second
+	// VaultAddress is the address of the Vault server polled for new versions of the secrets at the
+	// VaultPaths, none are polled if empty
+	VaultAddress = ""
+	// VaultPaths are the KV version 2 paths polled, e.g. "secret/app/db" of the "secret" mount
+	VaultPaths []string
+	// VaultPollInterval is the interval at which the VaultPaths are polled
+	VaultPollInterval = time.Minute
+	// VaultRole is the role of the Kubernetes auth method of Vault to log in with
+	VaultRole = ""
+	// VaultAuthPath is the mount path of the Kubernetes auth method of Vault
+	VaultAuthPath = "kubernetes"
+	// VaultTokenFile holds the service account token to log in to Vault with
+	VaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// AWSQueueURL is the SQS queue the events of AWS Secrets Manager are received from, e.g. by an
+	// EventBridge rule, none are received if empty
+	AWSQueueURL = ""
+	// AWSRegion is the region of the AWSQueueURL
+	AWSRegion = ""
+	// AWSRoleARN is the role assumed with the token of the AWSWebIdentityTokenFile to receive the
+	// events, if no access keys are set in the environment
+	AWSRoleARN = ""
+	// AWSWebIdentityTokenFile holds the token the AWSRoleARN is assumed with, e.g. the projected
+	// service account token of IAM roles for service accounts
+	AWSWebIdentityTokenFile = ""
+	// GCPSubscription is the Pub/Sub subscription the notifications of GCP Secret Manager are pulled
+	// from, e.g. "projects/my-project/subscriptions/secret-events", none are pulled if empty
+	GCPSubscription = ""
+	// GCPCredentialsFile is the key file of the service account pulling the notifications, the
+	// service account of the metadata server, e.g. of GKE Workload Identity, is used if empty
+	GCPCredentialsFile = ""
+	// AzureEventGridAddr is the address the endpoint of an Event Grid subscription of the events of
+	// Azure Key Vault is served at, none is served if empty
+	AzureEventGridAddr = ""
+	// AzureEventGridPath is the path of the endpoint of the Event Grid subscription
+	AzureEventGridPath = "/azure/eventgrid"
+	// AzureEventGridKey is the value of the "key" query parameter events must carry, any event is
+	// accepted if empty
+	AzureEventGridKey = ""
+	// ConsulAddress is the address of the Consul agent the keys under the ConsulPrefixes are watched
+	// at, none are watched if empty
+	ConsulAddress = ""
+	// ConsulPrefixes are the KV prefixes watched, e.g. "config/app/"
+	ConsulPrefixes []string
+	// ConsulToken is the ACL token of the queries of the Consul agent
+	ConsulToken = ""
+	// HistoryFile is the file reload decisions are recorded in, none are recorded if empty
+	HistoryFile = ""
+	// HistoryMaxSize is the size in bytes at which the history file is rotated
+	HistoryMaxSize int64 = 10 << 20
+	// AlwaysReloadSources is a list of "<namespace>/<name>" configmaps or secrets whose changes
+	// reload all workloads referencing them, regardless of their annotations
+	AlwaysReloadSources = []string{}
+	// EnableCrossNamespaceReferences reloads workloads referencing configmaps or secrets of other
+	// namespaces as "<namespace>/<name>" in their reload annotations
+	EnableCrossNamespaceReferences = false
+	// CrossNamespaceSourceNamespaces is a list of namespaces whose configmaps and secrets are watched
+	// only for the workloads of the watched namespaces referencing them
+	CrossNamespaceSourceNamespaces = []string{}
+	// CrossNamespaceSourceDiscoveryInterval is the interval at which the namespaces of configmaps and
+	// secrets referenced across namespaces are looked up and watched, if not watched yet. They are
+	// only logged once if 0
+	CrossNamespaceSourceDiscoveryInterval = time.Minute
+	// IgnoredSourceManagers is a list of field managers whose changes to
+	// configmaps or secrets never trigger a rolling upgrade
+	IgnoredSourceManagers = []string{}
+	// ResourceLabelSelector is the label selector of the configmaps and secrets that are watched,
+	// all are watched if empty
+	ResourceLabelSelector = ""
+	// EmitEvents records every reload as Kubernetes Event on the reloaded workload
+	EmitEvents = true
+	// EmitSourceEvents also records every reload as Kubernetes Event on the changed configmap or secret
+	EmitSourceEvents = false
+	// WebhookURLs are the URLs performed and failed reloads are posted to as JSON
+	WebhookURLs = []string{}
+	// WebhookRetries is the number of times a failed post to a webhook is retried
+	WebhookRetries = 3
+	// WebhookRetryInterval is the time waited before a failed post to a webhook is retried, doubled for
+	// each further retry up to NotificationRetryMaxDelay
+	WebhookRetryInterval = 5 * time.Second
+	// WebhookCAFile is a PEM file of the CA certificates verifying the webhooks, the system ones
+	// are used if empty
+	WebhookCAFile = ""
+	// WebhookInsecureSkipVerify disables the verification of the certificates of the webhooks
+	WebhookInsecureSkipVerify = false
+	// SlackWebhookURL is the Slack incoming webhook reloads are posted to as messages
+	SlackWebhookURL = ""
+	// SlackToken is the Slack bot token posting reloads as messages to the SlackChannel, if no
+	// SlackWebhookURL is given
+	SlackToken   = ""
+	SlackChannel = ""
+	// SlackMessageTemplate is the Go template of the Slack messages, executed with the notification
+	SlackMessageTemplate = ""
+	// SlackMinInterval is the minimum time between two Slack messages, the reloads in between are
+	// counted in the next message
+	SlackMinInterval = 10 * time.Second
+	// SlackRetries is the number of times a failed post to Slack is retried
+	SlackRetries = 3
+	// SlackRetryInterval is the time waited before a failed post to Slack is retried, doubled for each
+	// further retry up to NotificationRetryMaxDelay
+	SlackRetryInterval = 5 * time.Second
+	// NotificationRetryMaxDelay is the maximum time waited before a failed notification is retried
+	NotificationRetryMaxDelay = time.Minute
+	// NotificationBufferSize is the number of notifications waiting to be sent to each webhook or Slack,
+	// further ones are dropped
+	NotificationBufferSize = 100
+	// NotificationBreakerThreshold is the number of notifications in a row a webhook or Slack failed to
+	// receive after their retries, that drops their further notifications for the
+	// NotificationBreakerCooldown. The notifications are never dropped for failures if not positive
+	NotificationBreakerThreshold = 5
+	// NotificationBreakerCooldown is the time notifications to a failing webhook or Slack are dropped for
+	NotificationBreakerCooldown = time.Minute
+	// NotificationDigestWindow is the time the notifications of the reloads for a change of a source
+	// are collected in, to notify them as a single digest. Each reload is notified if 0
+	NotificationDigestWindow time.Duration
+	// EnableHA runs Reloader as one of several replicas, only the replica holding the lease
+	// performs rolling upgrades
+	EnableHA = false
+	// LeaderElectionLeaseName is the name of the Lease the replicas compete for
+	LeaderElectionLeaseName = "reloader"
+	// LeaderElectionNamespace is the namespace of the Lease, the namespace of the pod if empty
+	LeaderElectionNamespace = ""
+	// LeaderElectionLeaseDuration is the time replicas wait before taking over an unrenewed lease
+	LeaderElectionLeaseDuration = 15 * time.Second
+	// LeaderElectionRenewDeadline is the time the leader retries renewing the lease before giving up
+	LeaderElectionRenewDeadline = 10 * time.Second
+	// LeaderElectionRetryPeriod is the interval at which replicas try to acquire or renew the lease
+	LeaderElectionRetryPeriod = 2 * time.Second
 )
@@ -0,0 +1,123 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a recurring weekly time window, e.g. "Mon-Fri 09:00-17:00". Times are in UTC, a window
+// ending before it starts spans midnight and belongs to the day it starts on
+type Window struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+// Parse parses a window of the form "<days> <HH:MM>-<HH:MM>". Days are a comma separated list of
+// weekdays or weekday ranges like "Mon-Fri,Sun", or "*" for every day
+func Parse(value string) (*Window, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid window '%s', expected '<days> <HH:MM>-<HH:MM>'", value)
+	}
+
+	window := &Window{}
+	if err := window.parseDays(fields[0]); err != nil {
+		return nil, fmt.Errorf("invalid window '%s': %v", value, err)
+	}
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid window '%s', expected a time range '<HH:MM>-<HH:MM>'", value)
+	}
+	var err error
+	if window.start, err = parseTimeOfDay(times[0]); err != nil {
+		return nil, fmt.Errorf("invalid window '%s': %v", value, err)
+	}
+	if window.end, err = parseTimeOfDay(times[1]); err != nil {
+		return nil, fmt.Errorf("invalid window '%s': %v", value, err)
+	}
+	if window.start == window.end {
+		return nil, fmt.Errorf("invalid window '%s', it has no duration", value)
+	}
+	return window, nil
+}
+
+func (w *Window) parseDays(value string) error {
+	if value == "*" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+		return nil
+	}
+	for _, days := range strings.Split(value, ",") {
+		bounds := strings.Split(days, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid days '%s'", days)
+		}
+		first, found := weekdays[strings.ToLower(bounds[0])]
+		if !found {
+			return fmt.Errorf("invalid weekday '%s'", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, found = weekdays[strings.ToLower(bounds[1])]; !found {
+				return fmt.Errorf("invalid weekday '%s'", bounds[1])
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			w.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// Contains checks whether the time lies within the window
+func (w *Window) Contains(t time.Time) bool {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	timeOfDay := t.Sub(midnight)
+	if w.start < w.end {
+		return w.days[t.Weekday()] && timeOfDay >= w.start && timeOfDay < w.end
+	}
+	// the window spans midnight
+	previousDay := (t.Weekday() + 6) % 7
+	return (w.days[t.Weekday()] && timeOfDay >= w.start) || (w.days[previousDay] && timeOfDay < w.end)
+}
+
+// Next returns the time the window next opens at, or the time itself if it lies within the window
+func (w *Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	for day := 0; day <= 7; day++ {
+		opening := midnight.AddDate(0, 0, day).Add(w.start)
+		if opening.After(t) && w.days[opening.Weekday()] {
+			return opening
+		}
+	}
+	return t
+}
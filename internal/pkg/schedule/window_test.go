@@ -0,0 +1,72 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// 2020-03-02 is a Monday
+func at(day int, hour int, minute int) time.Time {
+	return time.Date(2020, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, value := range []string{"", "Mon-Fri", "Mon-Fri 09:00", "Mon-Fri 9-17", "Funday 09:00-17:00", "Mon-Fri 09:00-09:00", "Mon-Tue-Wed 09:00-17:00"} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("Expected window '%s' to be invalid", value)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		window   string
+		time     time.Time
+		contains bool
+	}{
+		{"Mon-Fri 09:00-17:00", at(2, 9, 0), true},
+		{"Mon-Fri 09:00-17:00", at(6, 16, 59), true},
+		{"Mon-Fri 09:00-17:00", at(2, 17, 0), false},
+		{"Mon-Fri 09:00-17:00", at(2, 8, 59), false},
+		{"Mon-Fri 09:00-17:00", at(7, 12, 0), false},
+		{"Sat,Sun 10:00-12:00", at(8, 11, 0), true},
+		{"Fri-Mon 10:00-12:00", at(8, 11, 0), true},
+		{"Fri-Mon 10:00-12:00", at(3, 11, 0), false},
+		{"* 00:00-01:00", at(4, 0, 30), true},
+		{"Fri 22:00-02:00", at(6, 23, 0), true},
+		{"Fri 22:00-02:00", at(7, 1, 59), true},
+		{"Fri 22:00-02:00", at(7, 2, 0), false},
+		{"Fri 22:00-02:00", at(6, 1, 0), false},
+	}
+	for _, test := range tests {
+		window, err := Parse(test.window)
+		if err != nil {
+			t.Fatalf("Unable to parse window '%s': %v", test.window, err)
+		}
+		if window.Contains(test.time) != test.contains {
+			t.Errorf("Expected window '%s' to contain %s: %t", test.window, test.time, test.contains)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	window, err := Parse("Mon-Fri 09:00-17:00")
+	if err != nil {
+		t.Fatalf("Unable to parse window: %v", err)
+	}
+	tests := []struct {
+		time time.Time
+		next time.Time
+	}{
+		{at(2, 12, 0), at(2, 12, 0)},
+		{at(2, 8, 0), at(2, 9, 0)},
+		{at(2, 18, 0), at(3, 9, 0)},
+		{at(6, 17, 0), at(9, 9, 0)},
+		{at(7, 12, 0), at(9, 9, 0)},
+	}
+	for _, test := range tests {
+		if next := window.Next(test.time); !next.Equal(test.next) {
+			t.Errorf("Expected window to open at %s after %s, got %s", test.next, test.time, next)
+		}
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/stakater/Reloader/internal/pkg/util"
 	"github.com/stakater/Reloader/pkg/kube"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -412,6 +414,23 @@ func GetDeploymentConfigWithEnvVars(namespace string, deploymentConfigName strin
 	}
 }
 
+// GetCronJobWithEnvVars provides a cronJob whose job template references the configmap and secret of the name
+func GetCronJobWithEnvVars(namespace string, cronJobName string) *batchv1beta1.CronJob {
+	podTemplateSpecWithEnvVars := getPodTemplateSpecWithEnvVars(cronJobName)
+	podTemplateSpecWithEnvVars.Spec.RestartPolicy = v1.RestartPolicyOnFailure
+	return &batchv1beta1.CronJob{
+		ObjectMeta: getObjectMeta(namespace, cronJobName, false),
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule: "*/5 * * * *",
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: podTemplateSpecWithEnvVars,
+				},
+			},
+		},
+	}
+}
+
 func GetDeploymentWithEnvVarSources(namespace string, deploymentName string) *appsv1.Deployment {
 	replicaset := int32(1)
 	return &appsv1.Deployment{
@@ -0,0 +1,248 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// TracesPath is the path of the OTLP/HTTP endpoint spans are exported to if the endpoint has none
+	TracesPath = "/v1/traces"
+	// batchSize is the number of ended spans exported at most in one request
+	batchSize = 512
+	// queueSize is the number of ended spans waiting to be exported, spans ending while it is full
+	// are dropped
+	queueSize = 2048
+	// exportInterval is the interval at which the ended spans are exported if fewer than a batch
+	// ended since the last export
+	exportInterval = 5 * time.Second
+
+	// spanKindInternal and statusCodeError are the OTLP values of the kind of the spans and of the
+	// status of failed spans
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+// exporter exports the ended spans in batches to an OTLP/HTTP endpoint, encoded as JSON
+type exporter struct {
+	url     string
+	service string
+	client  *http.Client
+	spans   chan *Span
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Configure exports the spans of the reload pipeline to the OTLP/HTTP endpoint, e.g.
+// "http://otel-collector:4318", with the service name as the "service.name" of their resource.
+// The spans are sent to the traces path of the endpoint if its URL has no path
+func Configure(endpoint string, service string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid OTLP endpoint '%s': %v", endpoint, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid OTLP endpoint '%s': the scheme must be http or https", endpoint)
+	}
+	if strings.Trim(parsed.Path, "/") == "" {
+		parsed.Path = TracesPath
+	}
+	e := &exporter{
+		url:     parsed.String(),
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		spans:   make(chan *Span, queueSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go e.run()
+	current.Store(e)
+	return nil
+}
+
+// Shutdown disables tracing and exports the spans ended so far, waiting at most for the timeout.
+// Spans started before and ending later are not exported
+func Shutdown(timeout time.Duration) {
+	e := currentExporter()
+	if e == nil {
+		return
+	}
+	current.Store((*exporter)(nil))
+	close(e.stop)
+	select {
+	case <-e.done:
+	case <-time.After(timeout):
+		logrus.Warnf("Exporting the remaining spans to %s did not finish within %s", e.url, timeout)
+	}
+}
+
+// export queues the ended span to be exported, dropping it if the queue is full
+func (e *exporter) export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		logrus.Debugf("Dropping span '%s', %d spans are waiting to be exported", span.name, queueSize)
+	}
+}
+
+// run exports the queued spans once a batch ended or at the export interval until stopped, then
+// exports the spans still queued
+func (e *exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					e.send(batch)
+					return
+				}
+			}
+		}
+		e.send(batch)
+		batch = nil
+	}
+}
+
+// send posts the spans to the endpoint in batches, logging the failed requests
+func (e *exporter) send(spans []*Span) {
+	for len(spans) > 0 {
+		count := len(spans)
+		if count > batchSize {
+			count = batchSize
+		}
+		if err := e.post(spans[:count]); err != nil {
+			logrus.Warnf("Unable to export %d spans to %s: %v", count, e.url, err)
+		}
+		spans = spans[count:]
+	}
+}
+
+func (e *exporter) post(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	response, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+// The OTLP/HTTP JSON encoding of the exported spans
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []attribute `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []spanData `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	spanData struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []attribute `json:"attributes,omitempty"`
+		Status            *status     `json:"status,omitempty"`
+	}
+	attribute struct {
+		Key   string `json:"key"`
+		Value value  `json:"value"`
+	}
+	value struct {
+		StringValue string `json:"stringValue"`
+	}
+	status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// request returns the export request of the spans
+func (e *exporter) request(spans []*Span) exportRequest {
+	data := make([]spanData, 0, len(spans))
+	for _, span := range spans {
+		data = append(data, encode(span))
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: attributes(map[string]string{"service.name": e.service})},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "github.com/stakater/Reloader"}, Spans: data}},
+	}}}
+}
+
+func encode(span *Span) spanData {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	data := spanData{
+		TraceID:           hex.EncodeToString(span.traceID[:]),
+		SpanID:            hex.EncodeToString(span.spanID[:]),
+		Name:              span.name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        attributes(span.attributes),
+	}
+	if span.parentID != [8]byte{} {
+		data.ParentSpanID = hex.EncodeToString(span.parentID[:])
+	}
+	if span.err != nil {
+		data.Status = &status{Code: statusCodeError, Message: span.err.Error()}
+	}
+	return data
+}
+
+// attributes returns the attributes of the values, sorted by their keys
+func attributes(values map[string]string) []attribute {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := make([]attribute, 0, len(keys))
+	for _, key := range keys {
+		encoded = append(encoded, attribute{Key: key, Value: value{StringValue: values[key]}})
+	}
+	return encoded
+}
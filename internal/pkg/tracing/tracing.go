@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span is an operation of the reload pipeline, e.g. the handling of an informer event, the computation
+// of the hash of the changed configmap or secret, the matching of the workloads referencing it or the
+// patch of a workload. Spans are nil if tracing is disabled, all methods of a nil span do nothing
+type Span struct {
+	exporter   *exporter
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	lock       sync.Mutex
+	attributes map[string]string
+	err        error
+	ended      bool
+}
+
+// current holds the exporter spans are exported to, tracing is disabled if it holds nil
+var current atomic.Value
+
+// currentExporter returns the exporter spans are exported to, nil if tracing is disabled
+func currentExporter() *exporter {
+	exporter, _ := current.Load().(*exporter)
+	return exporter
+}
+
+// Start starts the root span of a new trace named by the name, with the attributes given as key and
+// value pairs. It returns nil if tracing is disabled
+func Start(name string, attributes ...string) *Span {
+	exporter := currentExporter()
+	if exporter == nil {
+		return nil
+	}
+	span := newSpan(exporter, name, attributes)
+	randomID(span.traceID[:])
+	return span
+}
+
+// Start starts a child span of the span named by the name, with the attributes given as key and value
+// pairs. It returns nil if the span is nil
+func (s *Span) Start(name string, attributes ...string) *Span {
+	if s == nil {
+		return nil
+	}
+	span := newSpan(s.exporter, name, attributes)
+	span.traceID = s.traceID
+	span.parentID = s.spanID
+	return span
+}
+
+func newSpan(exporter *exporter, name string, attributes []string) *Span {
+	span := &Span{
+		exporter:   exporter,
+		name:       name,
+		start:      time.Now(),
+		attributes: map[string]string{},
+	}
+	randomID(span.spanID[:])
+	for i := 0; i+1 < len(attributes); i += 2 {
+		span.attributes[attributes[i]] = attributes[i+1]
+	}
+	return span
+}
+
+// SetAttribute sets the attribute of the key to the value
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.attributes[key] = value
+}
+
+// End ends the span, failed with the error if not nil, and exports it. Spans are exported once, later
+// calls do nothing
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.err = err
+	s.lock.Unlock()
+	s.exporter.export(s)
+}
+
+// TraceID returns the hex encoded ID of the trace of the span, empty if the span is nil
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// randomID fills the ID with random bytes
+func randomID(id []byte) {
+	// the IDs only have to be unique, a failed read leaves the bytes read so far
+	_, _ = rand.Read(id)
+}
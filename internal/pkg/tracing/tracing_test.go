@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpansAreExportedToOTLPEndpoint(t *testing.T) {
+	var lock sync.Mutex
+	var requests []exportRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != TracesPath {
+			t.Errorf("Spans were exported to %s instead of %s", r.URL.Path, TracesPath)
+		}
+		var request exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Export request could not be decoded: %v", err)
+		}
+		lock.Lock()
+		requests = append(requests, request)
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	if err := Configure(server.URL, "reloader"); err != nil {
+		t.Fatalf("Configuring the exporter failed: %v", err)
+	}
+	root := Start("configMaps update", "namespace", "default")
+	child := root.Start("compute hash")
+	child.End(nil)
+	child.End(errors.New("ended twice"))
+	root.End(errors.New("update failed"))
+	Shutdown(5 * time.Second)
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("Expected the spans to be exported in 1 request but got %d", len(requests))
+	}
+	resourceSpans := requests[0].ResourceSpans[0]
+	if resourceSpans.Resource.Attributes[0].Key != "service.name" || resourceSpans.Resource.Attributes[0].Value.StringValue != "reloader" {
+		t.Errorf("Expected the service name of the spans to be exported, got %v", resourceSpans.Resource.Attributes)
+	}
+	spans := resourceSpans.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans to be exported but got %d", len(spans))
+	}
+	exportedChild, exportedRoot := spans[0], spans[1]
+	if exportedRoot.TraceID != root.TraceID() || exportedChild.TraceID != root.TraceID() {
+		t.Errorf("Expected the spans to be exported in trace %s", root.TraceID())
+	}
+	if exportedRoot.ParentSpanID != "" || exportedChild.ParentSpanID != exportedRoot.SpanID {
+		t.Errorf("Expected the child span to be exported as the child of the root span")
+	}
+	if exportedChild.Status != nil {
+		t.Errorf("Expected the child span to be exported once, without error")
+	}
+	if exportedRoot.Status == nil || exportedRoot.Status.Code != statusCodeError || exportedRoot.Status.Message != "update failed" {
+		t.Errorf("Expected the root span to be exported as failed, got %v", exportedRoot.Status)
+	}
+	if len(exportedRoot.Attributes) != 1 || exportedRoot.Attributes[0].Key != "namespace" || exportedRoot.Attributes[0].Value.StringValue != "default" {
+		t.Errorf("Expected the attributes of the root span to be exported, got %v", exportedRoot.Attributes)
+	}
+}
+
+func TestSpansAreNilIfTracingIsDisabled(t *testing.T) {
+	span := Start("configMaps update")
+	if span != nil {
+		t.Fatalf("Expected no span if tracing is disabled")
+	}
+	child := span.Start("compute hash", "key", "value")
+	child.SetAttribute("key", "value")
+	child.End(nil)
+	if child != nil || child.TraceID() != "" {
+		t.Errorf("Expected children of nil spans to be nil")
+	}
+}
+
+func TestConfigureRejectsInvalidEndpoints(t *testing.T) {
+	for _, endpoint := range []string{"otel-collector:4318", "grpc://otel-collector:4317", "http://%zz"} {
+		if err := Configure(endpoint, "reloader"); err == nil {
+			Shutdown(time.Second)
+			t.Errorf("Configuring the invalid endpoint '%s' did not fail", endpoint)
+		}
+	}
+}
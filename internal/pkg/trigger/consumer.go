@@ -0,0 +1,83 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message asks for the reload of the workloads it names, by name or by label selector
+type Message struct {
+	// ID identifies the message, a message redelivered with the same ID does not reload a workload again
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	// Kind is the kind of the workloads, e.g. "Deployment", workloads of all kinds match if empty
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Selector string `json:"selector"`
+}
+
+// Consumer delivers the messages of an external event source
+type Consumer interface {
+	// Consume delivers the messages to the channel until the stop channel is closed
+	Consume(messages chan<- Message, stopCh <-chan struct{})
+}
+
+// HTTPConsumer polls an endpoint returning a JSON array of messages, e.g. a bridge draining a queue
+type HTTPConsumer struct {
+	URL      string
+	Interval time.Duration
+	Client   *http.Client
+}
+
+// Consume polls the endpoint every interval until the stop channel is closed
+func (c HTTPConsumer) Consume(messages chan<- Message, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		polled, err := c.poll()
+		if err != nil {
+			logrus.Errorf("Failed to poll trigger messages from %s: %v", c.URL, err)
+		}
+		for _, message := range polled {
+			select {
+			case messages <- message:
+			case <-stopCh:
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (c HTTPConsumer) poll() ([]Message, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Get(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	var messages []Message
+	if err := json.NewDecoder(response.Body).Decode(&messages); err != nil {
+		return nil, fmt.Errorf("invalid messages: %v", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,56 @@
+package trigger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPConsumerDeliversPolledMessages(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		switch polls {
+		case 1:
+			w.Write([]byte(`[{"id":"1","namespace":"default","kind":"Deployment","name":"payments"}]`))
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+		case 3:
+			w.Write([]byte(`[{"id":"2","namespace":"default","selector":"team=payments"}]`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	messages := make(chan Message)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		HTTPConsumer{URL: server.URL, Interval: time.Millisecond}.Consume(messages, stopCh)
+		close(done)
+	}()
+
+	expected := []Message{
+		{ID: "1", Namespace: "default", Kind: "Deployment", Name: "payments"},
+		{ID: "2", Namespace: "default", Selector: "team=payments"},
+	}
+	for _, want := range expected {
+		select {
+		case got := <-messages:
+			if got != want {
+				t.Errorf("Expected message %v, got %v", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Message %v was not delivered", want)
+		}
+	}
+
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Consumer did not stop")
+	}
+}
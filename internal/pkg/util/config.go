@@ -1,9 +1,24 @@
 package util
 
 import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/crypto"
 	"github.com/stakater/Reloader/internal/pkg/options"
+	"github.com/stakater/Reloader/internal/pkg/tracing"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
 //Config contains rolling upgrade configuration parameters
@@ -14,28 +29,334 @@ type Config struct {
 	Annotation          string
 	SHAValue            string
 	Type                string
+	ResourceVersion     string
+	ResourceUID         types.UID
+	// SourceNamespace is the namespace of the changed configmap or secret if it is not the Namespace of
+	// the workload reloaded for it, which references it as "<namespace>/<name>"
+	SourceNamespace string
+	// ResourceData is the data of a changed configmap, and PreviousResourceData its data before the
+	// change, which is nil if unknown
+	ResourceData         map[string]string
+	PreviousResourceData map[string]string
+	// KeyHashes are the SHAs of the values of each key of the changed configmap or secret
+	KeyHashes map[string]string
+	// LegacySHAValue is the SHA of the changed configmap or secret generated with the legacy algorithm,
+	// which workloads reloaded by earlier releases hold, if it is not the configured algorithm
+	LegacySHAValue string
+	// Deleted is set if the configmap or secret was deleted, its SHA then marks the deletion
+	Deleted bool
+	// DriftedOnly is set when syncing the workloads with the configmap or secret, only the workloads
+	// holding an other hash of it are reloaded, not those that never recorded one
+	DriftedOnly bool
+	// NotBefore is the time the certificate of a changed TLS secret becomes valid, zero if the secret
+	// holds no certificate that can be parsed
+	NotBefore time.Time
+	// Owner is the controller of the changed configmap or secret, e.g. the SealedSecret it was unsealed
+	// from, nil if it has none
+	Owner *metav1.OwnerReference
+	// Span is the span of the handling of the change the workloads are reloaded for, nil if it is not
+	// traced
+	Span *tracing.Span
 }
 
 // GetConfigmapConfig provides utility config for configmap
 func GetConfigmapConfig(configmap *v1.ConfigMap) Config {
+	data := getConfigmapChecksumData(configmap)
+	sha := func(algorithm string) string {
+		return addHashSalt(algorithm, getSHAfromConfigmap(algorithm, data), configmap.Annotations)
+	}
 	return Config{
 		Namespace:           configmap.Namespace,
 		ResourceName:        configmap.Name,
 		ResourceAnnotations: configmap.Annotations,
 		Annotation:          options.ConfigmapUpdateOnChangeAnnotation,
-		SHAValue:            GetSHAfromConfigmap(configmap.Data),
+		SHAValue:            sha(crypto.Algorithm()),
 		Type:                constants.ConfigmapEnvVarPostfix,
+		ResourceVersion:     configmap.ResourceVersion,
+		ResourceUID:         configmap.UID,
+		ResourceData:        configmap.Data,
+		KeyHashes:           getConfigmapKeyHashes(data),
+		LegacySHAValue:      legacySHA(sha),
+		Owner:               metav1.GetControllerOf(configmap),
 	}
 }
 
 // GetSecretConfig provides utility config for secret
 func GetSecretConfig(secret *v1.Secret) Config {
+	data := normalizeSecretData(secret, getSecretChecksumData(secret))
+	sha := func(algorithm string) string {
+		return addHashSalt(algorithm, getSHAfromSecret(algorithm, data), secret.Annotations)
+	}
 	return Config{
 		Namespace:           secret.Namespace,
 		ResourceName:        secret.Name,
 		ResourceAnnotations: secret.Annotations,
 		Annotation:          options.SecretUpdateOnChangeAnnotation,
-		SHAValue:            GetSHAfromSecret(secret.Data),
+		SHAValue:            sha(crypto.Algorithm()),
 		Type:                constants.SecretEnvVarPostfix,
+		ResourceVersion:     secret.ResourceVersion,
+		ResourceUID:         secret.UID,
+		KeyHashes:           getSecretKeyHashes(data),
+		LegacySHAValue:      legacySHA(sha),
+		NotBefore:           certificateNotBefore(secret.Data[v1.TLSCertKey]),
+		Owner:               metav1.GetControllerOf(secret),
+	}
+}
+
+// GetSecretProviderClassConfig provides utility config for secret provider classes of the Secrets
+// Store CSI driver, its SHA is generated from its spec
+func GetSecretProviderClassConfig(class *unstructured.Unstructured) Config {
+	spec, err := json.Marshal(class.Object["spec"])
+	if err != nil {
+		logrus.Errorf("Unable to marshal the spec of secret provider class '%s': %v", class.GetName(), err)
+	}
+	sha := func(algorithm string) string {
+		return addHashSalt(algorithm, crypto.GenerateSHAWith(algorithm, string(spec)), class.GetAnnotations())
+	}
+	return Config{
+		Namespace:           class.GetNamespace(),
+		ResourceName:        class.GetName(),
+		ResourceAnnotations: class.GetAnnotations(),
+		Annotation:          options.SecretProviderClassUpdateOnChangeAnnotation,
+		SHAValue:            sha(crypto.Algorithm()),
+		Type:                constants.SecretProviderClassEnvVarPostfix,
+		ResourceVersion:     class.GetResourceVersion(),
+		ResourceUID:         class.GetUID(),
+		LegacySHAValue:      legacySHA(sha),
+		Owner:               metav1.GetControllerOf(class),
+	}
+}
+
+// GetExternalSecretConfig provides utility config for external secrets of the External Secrets
+// Operator, its SHA is generated from the data of the secret it syncs
+func GetExternalSecretConfig(externalSecret *unstructured.Unstructured, secret *v1.Secret) Config {
+	secretConfig := GetSecretConfig(secret)
+	return Config{
+		Namespace:           externalSecret.GetNamespace(),
+		ResourceName:        externalSecret.GetName(),
+		ResourceAnnotations: externalSecret.GetAnnotations(),
+		Annotation:          options.ExternalSecretUpdateOnChangeAnnotation,
+		SHAValue:            secretConfig.SHAValue,
+		Type:                constants.ExternalSecretEnvVarPostfix,
+		ResourceVersion:     externalSecret.GetResourceVersion(),
+		ResourceUID:         externalSecret.GetUID(),
+		LegacySHAValue:      secretConfig.LegacySHAValue,
+		Owner:               metav1.GetControllerOf(externalSecret),
+	}
+}
+
+// ExternalSecretTargetName returns the name of the secret the external secret syncs, its own name
+// unless its target names another one
+func ExternalSecretTargetName(externalSecret *unstructured.Unstructured) string {
+	if name, _, _ := unstructured.NestedString(externalSecret.Object, "spec", "target", "name"); name != "" {
+		return name
+	}
+	return externalSecret.GetName()
+}
+
+// GetCertificateConfig provides utility config for certificates of cert-manager, its SHA is generated
+// from the data of the secret issued for it. It becomes valid at the status.notBefore of the
+// certificate, or else at the notBefore of the certificate in the secret
+func GetCertificateConfig(certificate *unstructured.Unstructured, secret *v1.Secret) Config {
+	secretConfig := GetSecretConfig(secret)
+	notBefore := secretConfig.NotBefore
+	if value, _, _ := unstructured.NestedString(certificate.Object, "status", "notBefore"); value != "" {
+		if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+			notBefore = parsed
+		}
+	}
+	return Config{
+		Namespace:           certificate.GetNamespace(),
+		ResourceName:        certificate.GetName(),
+		ResourceAnnotations: certificate.GetAnnotations(),
+		Annotation:          options.CertificateUpdateOnChangeAnnotation,
+		SHAValue:            secretConfig.SHAValue,
+		Type:                constants.CertificateEnvVarPostfix,
+		ResourceVersion:     certificate.GetResourceVersion(),
+		ResourceUID:         certificate.GetUID(),
+		LegacySHAValue:      secretConfig.LegacySHAValue,
+		NotBefore:           notBefore,
+		Owner:               metav1.GetControllerOf(certificate),
+	}
+}
+
+// CertificateSecretName returns the name of the secret issued for the certificate, its spec.secretName
+func CertificateSecretName(certificate *unstructured.Unstructured) string {
+	name, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	return name
+}
+
+// certificateNotBefore returns the start of the validity of the first PEM encoded certificate, the
+// leaf certificate of a chain, or the zero time if there is none
+func certificateNotBefore(data []byte) time.Time {
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}
+		}
+		return certificate.NotBefore
+	}
+	return time.Time{}
+}
+
+// GetSHAofKeys returns the SHA of only the listed keys of the changed configmap or secret, which
+// changes with their values and its hash salt but not with the values of other keys
+func GetSHAofKeys(config Config, keys []string) string {
+	values := []string{}
+	for _, key := range keys {
+		if hash, found := config.KeyHashes[strings.TrimSpace(key)]; found {
+			values = append(values, strings.TrimSpace(key)+"="+hash)
+		}
+	}
+	sort.Strings(values)
+	return addHashSalt(crypto.Algorithm(), crypto.GenerateSHA(strings.Join(values, ";")), config.ResourceAnnotations)
+}
+
+func getConfigmapKeyHashes(data map[string]string) map[string]string {
+	hashes := make(map[string]string, len(data))
+	for key, value := range data {
+		hashes[key] = crypto.GenerateSHA(value)
+	}
+	return hashes
+}
+
+func getSecretKeyHashes(data map[string][]byte) map[string]string {
+	hashes := make(map[string]string, len(data))
+	for key, value := range data {
+		hashes[key] = crypto.GenerateSHA(string(value))
+	}
+	return hashes
+}
+
+// getConfigmapChecksumData returns the data the SHA of a configmap is computed from, its data and
+// binaryData without its ignored keys, or only the external checksum key if the configmap names one.
+// Metadata, which the API server defaults and updates on every write, never contributes to the SHA
+func getConfigmapChecksumData(configmap *v1.ConfigMap) map[string]string {
+	data := configmap.Data
+	if len(configmap.BinaryData) > 0 {
+		// keys are unique across data and binaryData
+		data = make(map[string]string, len(configmap.Data)+len(configmap.BinaryData))
+		for key, value := range configmap.Data {
+			data[key] = value
+		}
+		for key, value := range configmap.BinaryData {
+			data[key] = string(value)
+		}
+	}
+
+	key, found := configmap.Annotations[options.ExternalChecksumKeyAnnotation]
+	if !found {
+		ignoredKeys := getIgnoredKeys(configmap.Annotations)
+		if len(ignoredKeys) == 0 {
+			return data
+		}
+		checksumData := make(map[string]string, len(data))
+		for key, value := range data {
+			if !ignoredKeys.Contains(key) {
+				checksumData[key] = value
+			}
+		}
+		return checksumData
+	}
+	checksumData := map[string]string{}
+	if value, ok := data[key]; ok {
+		checksumData[key] = value
+	}
+	return checksumData
+}
+
+// getSecretChecksumData returns the data the SHA of a secret is computed from, which is its data
+// without its ignored keys, or only the external checksum key if the secret names one
+func getSecretChecksumData(secret *v1.Secret) map[string][]byte {
+	key, found := secret.Annotations[options.ExternalChecksumKeyAnnotation]
+	if !found {
+		ignoredKeys := getIgnoredKeys(secret.Annotations)
+		if len(ignoredKeys) == 0 {
+			return secret.Data
+		}
+		data := make(map[string][]byte, len(secret.Data))
+		for key, value := range secret.Data {
+			if !ignoredKeys.Contains(key) {
+				data[key] = value
+			}
+		}
+		return data
+	}
+	data := map[string][]byte{}
+	if value, ok := secret.Data[key]; ok {
+		data[key] = value
+	}
+	return data
+}
+
+// getIgnoredKeys returns the keys listed in the ignore-keys annotation of a configmap or secret
+func getIgnoredKeys(annotations map[string]string) List {
+	var keys List
+	for _, key := range strings.Split(annotations[options.IgnoreKeysAnnotation], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// normalizeSecretData canonicalizes the values of a secret of a json or yaml content type, so a
+// change of formatting or key order does not change its SHA. Values not of the content type are
+// left untouched, and their content is never logged
+func normalizeSecretData(secret *v1.Secret, data map[string][]byte) map[string][]byte {
+	contentType := secret.Annotations[options.ContentTypeAnnotation]
+	if contentType != "json" && contentType != "yaml" {
+		return data
+	}
+	normalized := make(map[string][]byte, len(data))
+	for key, value := range data {
+		normalized[key] = canonicalize(value, contentType)
+	}
+	return normalized
+}
+
+func canonicalize(value []byte, contentType string) []byte {
+	document := value
+	if contentType == "yaml" {
+		converted, err := yaml.ToJSON(value)
+		if err != nil {
+			return value
+		}
+		document = converted
+	}
+
+	// keep numbers as written, so large numbers do not lose precision
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil || decoder.More() {
+		return value
+	}
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		return value
+	}
+	return canonical
+}
+
+// addHashSalt folds the hash salt annotation of a configmap or secret into its SHA, the SHA is left
+// untouched when no salt is set
+func addHashSalt(algorithm string, sha string, annotations map[string]string) string {
+	salt := annotations[options.HashSaltAnnotation]
+	if salt == "" {
+		return sha
+	}
+	return crypto.GenerateSHAWith(algorithm, sha+";"+salt)
+}
+
+// legacySHA returns the SHA generated by sha with the legacy algorithm, or nothing if it is the
+// configured algorithm
+func legacySHA(sha func(algorithm string) string) string {
+	if crypto.Algorithm() == crypto.LegacyAlgorithm {
+		return ""
 	}
+	return sha(crypto.LegacyAlgorithm)
 }
@@ -24,12 +24,28 @@ func InterfaceSlice(slice interface{}) []interface{} {
 	return ret
 }
 
+// InterfacePointerSlice converts a slice to an interface array holding pointers to its elements
+func InterfacePointerSlice(slice interface{}) []interface{} {
+	s := reflect.ValueOf(slice)
+	if s.Kind() != reflect.Slice {
+		logrus.Errorf("InterfacePointerSlice() given a non-slice type")
+	}
+
+	ret := make([]interface{}, s.Len())
+
+	for i := 0; i < s.Len(); i++ {
+		ret[i] = s.Index(i).Addr().Interface()
+	}
+
+	return ret
+}
+
 type ObjectMeta struct {
 	metav1.ObjectMeta
 }
 
 func ToObjectMeta(kubernetesObject interface{}) ObjectMeta {
-	objectValue := reflect.ValueOf(kubernetesObject)
+	objectValue := reflect.Indirect(reflect.ValueOf(kubernetesObject))
 	fieldName := reflect.TypeOf((*metav1.ObjectMeta)(nil)).Elem().Name()
 	field := objectValue.FieldByName(fieldName).Interface().(metav1.ObjectMeta)
 
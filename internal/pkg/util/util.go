@@ -30,21 +30,29 @@ func ConvertToEnvVarName(text string) string {
 }
 
 func GetSHAfromConfigmap(data map[string]string) string {
+	return getSHAfromConfigmap(crypto.Algorithm(), data)
+}
+
+func getSHAfromConfigmap(algorithm string, data map[string]string) string {
 	values := []string{}
 	for k, v := range data {
 		values = append(values, k+"="+v)
 	}
 	sort.Strings(values)
-	return crypto.GenerateSHA(strings.Join(values, ";"))
+	return crypto.GenerateSHAWith(algorithm, strings.Join(values, ";"))
 }
 
 func GetSHAfromSecret(data map[string][]byte) string {
+	return getSHAfromSecret(crypto.Algorithm(), data)
+}
+
+func getSHAfromSecret(algorithm string, data map[string][]byte) string {
 	values := []string{}
 	for k, v := range data {
 		values = append(values, k+"="+string(v[:]))
 	}
 	sort.Strings(values)
-	return crypto.GenerateSHA(strings.Join(values, ";"))
+	return crypto.GenerateSHAWith(algorithm, strings.Join(values, ";"))
 }
 
 type List []string
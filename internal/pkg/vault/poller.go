@@ -0,0 +1,164 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+// Poller polls the metadata of the secrets at KV version 2 paths of Vault, e.g. "secret/app/db" of
+// the "secret" mount, authenticated with the Kubernetes auth method
+type Poller struct {
+	Address  string
+	Paths    []string
+	Interval time.Duration
+	// Role is the role of the Kubernetes auth method to log in with
+	Role string
+	// AuthPath is the mount path of the Kubernetes auth method
+	AuthPath string
+	// TokenFile holds the service account token to log in with
+	TokenFile string
+	Client    *http.Client
+
+	token string
+}
+
+// errForbidden is returned for requests with a missing or expired Vault token
+var errForbidden = errors.New("permission denied")
+
+// Watch polls the paths every interval until the stop channel is closed and delivers the new
+// versions to the channel. The versions found by the first successful poll of a path are not changes
+func (p *Poller) Watch(changes chan<- external.Change, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	versions := map[string]int{}
+	for {
+		for _, path := range p.Paths {
+			version, err := p.currentVersion(path)
+			if err != nil {
+				logrus.Errorf("Failed to poll Vault path '%s': %v", path, err)
+				continue
+			}
+			previous, found := versions[path]
+			versions[path] = version
+			if !found || previous == version {
+				continue
+			}
+			select {
+			case changes <- external.Change{Type: constants.VaultEnvVarPostfix, Name: path, Version: strconv.Itoa(version)}:
+			case <-stopCh:
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// currentVersion returns the current version of the secret at the path, logging in again if the
+// token expired
+func (p *Poller) currentVersion(path string) (int, error) {
+	mount, secret := splitPath(path)
+	if secret == "" {
+		return 0, fmt.Errorf("path names no secret of the mount '%s'", mount)
+	}
+	var metadata struct {
+		Data struct {
+			CurrentVersion int `json:"current_version"`
+		} `json:"data"`
+	}
+	err := p.request(http.MethodGet, "/v1/"+mount+"/metadata/"+secret, nil, &metadata)
+	if err == errForbidden && p.token != "" {
+		p.token = ""
+		err = p.request(http.MethodGet, "/v1/"+mount+"/metadata/"+secret, nil, &metadata)
+	}
+	return metadata.Data.CurrentVersion, err
+}
+
+// login logs in with the service account token and keeps the client token of Vault
+func (p *Poller) login() error {
+	jwt, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return fmt.Errorf("unable to read the service account token: %v", err)
+	}
+	body, err := json.Marshal(map[string]string{"role": p.Role, "jwt": strings.TrimSpace(string(jwt))})
+	if err != nil {
+		return err
+	}
+	var login struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := p.do(http.MethodPost, "/v1/auth/"+p.AuthPath+"/login", body, &login); err != nil {
+		return fmt.Errorf("login with role '%s' failed: %v", p.Role, err)
+	}
+	if login.Auth.ClientToken == "" {
+		return fmt.Errorf("login with role '%s' returned no token", p.Role)
+	}
+	p.token = login.Auth.ClientToken
+	return nil
+}
+
+// request sends an authenticated request, logging in first if there is no token yet
+func (p *Poller) request(method string, path string, body []byte, result interface{}) error {
+	if p.token == "" {
+		if err := p.login(); err != nil {
+			return err
+		}
+	}
+	return p.do(method, path, body, result)
+}
+
+func (p *Poller) do(method string, path string, body []byte, result interface{}) error {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequest(method, strings.TrimSuffix(p.Address, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if p.token != "" {
+		request.Header.Set("X-Vault-Token", p.token)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusForbidden {
+		return errForbidden
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// splitPath splits the path into the mount of the KV secrets engine, its first segment, and the
+// path of the secret in the mount
+func splitPath(path string) (string, string) {
+	parts := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
@@ -0,0 +1,85 @@
+package vault
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stakater/Reloader/internal/pkg/constants"
+	"github.com/stakater/Reloader/internal/pkg/external"
+)
+
+func TestPollerDeliversNewVersions(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatalf("Failed to create token file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.WriteString("service-account-token\n")
+	tokenFile.Close()
+
+	logins := 0
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			var login map[string]string
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["role"] != "reloader" || login["jwt"] != "service-account-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			logins++
+			w.Write([]byte(`{"auth":{"client_token":"token"}}`))
+		case "/v1/secret/metadata/app/db":
+			if r.Header.Get("X-Vault-Token") != "token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			polls++
+			switch polls {
+			case 1, 2:
+				w.Write([]byte(`{"data":{"current_version":1}}`))
+			case 3:
+				// the token expired
+				w.WriteHeader(http.StatusForbidden)
+			default:
+				w.Write([]byte(`{"data":{"current_version":2}}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	changes := make(chan external.Change)
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	poller := &Poller{Address: server.URL, Paths: []string{"secret/app/db"}, Interval: time.Millisecond, Role: "reloader", AuthPath: "kubernetes", TokenFile: tokenFile.Name()}
+	go func() {
+		poller.Watch(changes, stopCh)
+		close(done)
+	}()
+
+	select {
+	case got := <-changes:
+		if want := (external.Change{Type: constants.VaultEnvVarPostfix, Name: "secret/app/db", Version: "2"}); got != want {
+			t.Errorf("Expected change %v, got %v", want, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Change was not delivered")
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Poller did not stop")
+	}
+	if logins != 2 {
+		t.Errorf("Expected a login at the start and after the token expired, got %d logins", logins)
+	}
+}
@@ -2,26 +2,91 @@ package kube
 
 import (
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/clientcmd"
 
 	appsclient "github.com/openshift/client-go/apps/clientset/versioned"
 	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 )
 
 // Clients struct exposes interfaces for kubernetes as well as openshift if available
 type Clients struct {
 	KubernetesClient    kubernetes.Interface
 	OpenshiftAppsClient appsclient.Interface
+	// DynamicClient reloads custom workloads, none are reloaded if nil
+	DynamicClient dynamic.Interface
+	// EventRecorder records the reloads as Kubernetes Events, none are recorded if nil
+	EventRecorder record.EventRecorder
 }
 
+const discoveryRetryInterval = 30 * time.Second
+
 var (
-	// IsOpenshift is true if environment is Openshift, it is false if environment is Kubernetes
-	IsOpenshift = isOpenshift()
+	openshift  int32
+	detectOnce sync.Once
+
+	eventRecorder record.EventRecorder
+	recorderOnce  sync.Once
 )
 
+// IsOpenshift returns true if environment is Openshift, it is false if environment is Kubernetes
+// or could not be detected yet
+func IsOpenshift() bool {
+	DetectEnvironment(false)
+	return atomic.LoadInt32(&openshift) == 1
+}
+
+// DetectEnvironment detects whether the environment is Openshift, only the first call detects it.
+// If discovery fails, it exits if requireDiscovery is set, else it assumes a Kubernetes environment
+// and keeps retrying the detection in the background
+func DetectEnvironment(requireDiscovery bool) {
+	detectOnce.Do(func() {
+		detectEnvironment(isOpenshift, requireDiscovery, discoveryRetryInterval)
+	})
+}
+
+func detectEnvironment(discover func() (bool, error), requireDiscovery bool, retryInterval time.Duration) {
+	err := setEnvironment(discover)
+	if err == nil {
+		return
+	}
+	if requireDiscovery {
+		logrus.Fatalf("Unable to detect environment error = %v", err)
+	}
+
+	logrus.Warnf("Unable to detect environment, assuming Kubernetes and retrying every %s, error = %v", retryInterval, err)
+	go wait.PollInfinite(retryInterval, func() (bool, error) {
+		return setEnvironment(discover) == nil, nil
+	})
+}
+
+func setEnvironment(discover func() (bool, error)) error {
+	isOpenshift, err := discover()
+	if err != nil {
+		return err
+	}
+	if isOpenshift {
+		logrus.Info("Environment: Openshift")
+		atomic.StoreInt32(&openshift, 1)
+	} else {
+		logrus.Info("Environment: Kubernetes")
+		atomic.StoreInt32(&openshift, 0)
+	}
+	return nil
+}
+
 // GetClients returns a `Clients` object containing both openshift and kubernetes clients with an openshift identifier
 func GetClients() Clients {
 	client, err := GetKubernetesClient()
@@ -31,31 +96,51 @@ func GetClients() Clients {
 
 	var appsClient *appsclient.Clientset
 
-	if IsOpenshift {
+	if IsOpenshift() {
 		appsClient, err = GetOpenshiftAppsClient()
 		if err != nil {
 			logrus.Warnf("Unable to create Openshift Apps client error = %v", err)
 		}
 	}
 
+	dynamicClient, err := GetDynamicClient()
+	if err != nil {
+		logrus.Warnf("Unable to create dynamic client error = %v", err)
+	}
+
 	return Clients{
 		KubernetesClient:    client,
 		OpenshiftAppsClient: appsClient,
+		DynamicClient:       dynamicClient,
+		EventRecorder:       getEventRecorder(client),
 	}
 }
 
-func isOpenshift() bool {
+// getEventRecorder returns the recorder writing events with the client, only the first call creates it
+func getEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	recorderOnce.Do(func() {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+		eventRecorder = broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "reloader"})
+	})
+	return eventRecorder
+}
+
+// isOpenshift checks for the Openshift project API, any response of the API server but success
+// means Kubernetes, an error means discovery failed
+func isOpenshift() (bool, error) {
 	client, err := GetKubernetesClient()
 	if err != nil {
-		logrus.Fatalf("Unable to create Kubernetes client error = %v", err)
+		return false, err
 	}
 	_, err = client.RESTClient().Get().AbsPath("/apis/project.openshift.io").Do().Raw()
 	if err == nil {
-		logrus.Info("Environment: Openshift")
-		return true
+		return true, nil
+	}
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return false, nil
 	}
-	logrus.Info("Environment: Kubernetes")
-	return false
+	return false, err
 }
 
 // GetOpenshiftAppsClient returns an Openshift Client that can query on Apps
@@ -76,6 +161,15 @@ func GetKubernetesClient() (*kubernetes.Clientset, error) {
 	return kubernetes.NewForConfig(config)
 }
 
+// GetDynamicClient gets the client for resources without types in client-go, e.g. custom resources
+func GetDynamicClient() (dynamic.Interface, error) {
+	config, err := getConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
 func getConfig() (*rest.Config, error) {
 	var config *rest.Config
 	var err error
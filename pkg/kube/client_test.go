@@ -0,0 +1,50 @@
+package kube
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDetectEnvironmentRetriesInBackground(t *testing.T) {
+	// skip the detection against a real cluster
+	detectOnce.Do(func() {})
+	defer atomic.StoreInt32(&openshift, 0)
+
+	var attempts int32
+	discover := func() (bool, error) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			return false, errors.New("discovery unavailable")
+		}
+		return true, nil
+	}
+
+	detectEnvironment(discover, false, 10*time.Millisecond)
+	if IsOpenshift() {
+		t.Fatalf("Expected Kubernetes to be assumed while discovery fails")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !IsOpenshift() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Openshift was not detected in the background after %d attempts", atomic.LoadInt32(&attempts))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDetectEnvironmentWithoutDiscoveryFailure(t *testing.T) {
+	detectOnce.Do(func() {})
+	defer atomic.StoreInt32(&openshift, 0)
+
+	detectEnvironment(func() (bool, error) { return true, nil }, true, time.Millisecond)
+	if !IsOpenshift() {
+		t.Errorf("Expected Openshift to be detected")
+	}
+
+	detectEnvironment(func() (bool, error) { return false, nil }, true, time.Millisecond)
+	if IsOpenshift() {
+		t.Errorf("Expected Kubernetes to be detected")
+	}
+}
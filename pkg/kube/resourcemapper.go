@@ -1,8 +1,12 @@
 package kube
 
 import (
+	"fmt"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
 )
 
 // ResourceMap are resources from where changes are going to be detected
@@ -10,3 +14,65 @@ var ResourceMap = map[string]runtime.Object{
 	"configMaps": &v1.ConfigMap{},
 	"secrets":    &v1.Secret{},
 }
+
+// SecretProviderClassResource is the resource of the secret provider classes of the Secrets Store
+// CSI driver, watched with the dynamic client as its types are not part of client-go
+var SecretProviderClassResource = schema.GroupVersionResource{
+	Group:    "secrets-store.csi.x-k8s.io",
+	Version:  "v1",
+	Resource: "secretproviderclasses",
+}
+
+// ExternalSecretResource is the resource of the external secrets of the External Secrets Operator,
+// watched with the dynamic client as its types are not part of client-go
+var ExternalSecretResource = schema.GroupVersionResource{
+	Group:    "external-secrets.io",
+	Version:  "v1beta1",
+	Resource: "externalsecrets",
+}
+
+// CertificateResource is the resource of the certificates of cert-manager, watched with the dynamic
+// client as its types are not part of client-go
+var CertificateResource = schema.GroupVersionResource{
+	Group:    "cert-manager.io",
+	Version:  "v1",
+	Resource: "certificates",
+}
+
+// KnativeServiceResource is the resource of the services of Knative Serving, reloaded with the dynamic
+// client as its types are not part of client-go
+var KnativeServiceResource = schema.GroupVersionResource{
+	Group:    "serving.knative.dev",
+	Version:  "v1",
+	Resource: "services",
+}
+
+// ScaledJobResource is the resource of the scaled jobs of KEDA, reloaded with the dynamic client as
+// its types are not part of client-go
+var ScaledJobResource = schema.GroupVersionResource{
+	Group:    "keda.sh",
+	Version:  "v1alpha1",
+	Resource: "scaledjobs",
+}
+
+// ArgoRolloutResource is the resource of the rollouts of Argo Rollouts, reloaded with the dynamic
+// client as its types are not part of client-go
+var ArgoRolloutResource = schema.GroupVersionResource{
+	Group:    "argoproj.io",
+	Version:  "v1alpha1",
+	Resource: "rollouts",
+}
+
+// ResourceKind discovers the kind of the resource, e.g. of a custom resource definition
+func ResourceKind(client kubernetes.Interface, resource schema.GroupVersionResource) (string, error) {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(resource.GroupVersion().String())
+	if err != nil {
+		return "", err
+	}
+	for _, apiResource := range resources.APIResources {
+		if apiResource.Name == resource.Resource {
+			return apiResource.Kind, nil
+		}
+	}
+	return "", fmt.Errorf("resource '%s' not found in '%s'", resource.Resource, resource.GroupVersion().String())
+}